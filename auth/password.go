@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MinPasswordLength is the shortest password accepted for a Neba account.
+	MinPasswordLength = 8
+)
+
+// HashPassword hashes the given password with bcrypt so that it can be stored
+// in the database. The plain text password is never persisted.
+//
+// Parameters:
+//   - password: The plain text password to hash.
+//
+// Returns:
+//   - string: The bcrypt hash of the password.
+//   - error: An error if the password is too short or hashing fails.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword reports whether the given plain text password matches the
+// stored bcrypt hash.
+//
+// Parameters:
+//   - hash: The stored bcrypt hash.
+//   - password: The plain text password to verify.
+//
+// Returns:
+//   - bool: true if the password matches the hash, otherwise false.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/furkansuleymana/neba/database/models"
+)
+
+// Role is the role assigned to a Neba account. Each role grants a fixed set
+// of permissions, and every role includes the permissions of the roles below
+// it: viewer < operator < admin.
+type Role string
+
+// Permission is a single operation that can be allowed or denied.
+type Permission string
+
+const (
+	// Roles
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+
+	// Permissions
+	PermViewDevices     Permission = "devices:view"
+	PermDiscoverDevices Permission = "devices:discover"
+	PermRestartDevices  Permission = "devices:restart"
+	PermFactoryReset    Permission = "devices:factory-reset"
+	PermManageDevices   Permission = "devices:manage"
+	PermConfigureDevice Permission = "devices:configure"
+	PermManageCreds     Permission = "credentials:manage"
+	PermManageUsers     Permission = "users:manage"
+	PermViewAudit       Permission = "audit:view"
+	PermManageSettings  Permission = "settings:manage"
+	PermBackup          Permission = "database:backup"
+)
+
+// Roles lists every role, from the least to the most privileged.
+var Roles = []Role{RoleViewer, RoleOperator, RoleAdmin}
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {
+		PermViewDevices,
+		PermDiscoverDevices,
+	},
+	RoleOperator: {
+		PermRestartDevices,
+	},
+	RoleAdmin: {
+		PermFactoryReset,
+		PermManageDevices,
+		PermConfigureDevice,
+		PermManageCreds,
+		PermManageUsers,
+		PermViewAudit,
+		PermManageSettings,
+		PermBackup,
+	},
+}
+
+// Permissions is the set of permissions granted to a user. It is handed to
+// templates so that actions the user cannot perform can be hidden.
+type Permissions map[Permission]bool
+
+// Has reports whether the set contains the given permission.
+func (p Permissions) Has(perm Permission) bool {
+	return p[perm]
+}
+
+// PermissionsFor returns the permissions granted by the given role, including
+// those of all less privileged roles. Unknown roles grant nothing.
+//
+// Parameters:
+//   - role: The role to resolve.
+//
+// Returns:
+//   - Permissions: The permissions granted by the role.
+func PermissionsFor(role Role) Permissions {
+	perms := Permissions{}
+	index := slices.Index(Roles, role)
+	if index < 0 {
+		return perms
+	}
+	for _, r := range Roles[:index+1] {
+		for _, perm := range rolePermissions[r] {
+			perms[perm] = true
+		}
+	}
+	return perms
+}
+
+// Can reports whether the given user is allowed to perform the operation.
+//
+// Parameters:
+//   - user: The user performing the operation.
+//   - perm: The permission required by the operation.
+//
+// Returns:
+//   - bool: true if the user's role grants the permission, otherwise false.
+func Can(user models.User, perm Permission) bool {
+	return PermissionsFor(Role(user.Role)).Has(perm)
+}
+
+// Unscoped reports whether the user can access every device: users without
+// scopes, and admins.
+//
+// Parameters:
+//   - user: The user to check.
+//
+// Returns:
+//   - bool: true if the user's access is not limited by scopes.
+func Unscoped(user models.User) bool {
+	return len(user.Scopes) == 0 || Role(user.Role) == RoleAdmin
+}
+
+// InScope reports whether the given device is visible to the user. Users
+// without scopes, and admins, can access every device. Otherwise the device's
+// site or one of its tags must be listed in the user's scopes.
+//
+// Parameters:
+//   - user: The user accessing the device.
+//   - device: The device being accessed.
+//
+// Returns:
+//   - bool: true if the user may access the device, otherwise false.
+func InScope(user models.User, device models.AxisDevice) bool {
+	if Unscoped(user) {
+		return true
+	}
+	if device.Site != "" && slices.Contains(user.Scopes, device.Site) {
+		return true
+	}
+	for _, tag := range device.Tags {
+		if slices.Contains(user.Scopes, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRole returns an error if the given role is not one of Roles.
+func ValidateRole(role Role) error {
+	if !slices.Contains(Roles, role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	return nil
+}
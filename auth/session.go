@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+)
+
+const (
+	// Session constants
+	SessionCookieName = "neba_session"
+	SessionLifetime   = 12 * time.Hour
+
+	// CSRF constants
+	CSRFHeaderName = "X-CSRF-Token"
+	CSRFFormField  = "csrf_token"
+)
+
+// NewSession creates a new session for the given user with a random session
+// token and a random CSRF token. The session is not persisted.
+//
+// Parameters:
+//   - username: The name of the user the session belongs to.
+//
+// Returns:
+//   - models.Session: The newly created session.
+//   - error: An error if random tokens could not be generated.
+func NewSession(username string) (models.Session, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("generate session token: %w", err)
+	}
+
+	csrfToken, err := randomToken(32)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("generate CSRF token: %w", err)
+	}
+
+	return models.Session{
+		Token:     token,
+		Username:  username,
+		CSRFToken: csrfToken,
+		ExpiresAt: time.Now().Add(SessionLifetime),
+	}, nil
+}
+
+// ValidCSRFToken compares the CSRF token sent by the client with the one
+// stored in the session in constant time.
+//
+// Parameters:
+//   - session: The session of the current request.
+//   - token: The CSRF token sent by the client.
+//
+// Returns:
+//   - bool: true if the tokens match, otherwise false.
+func ValidCSRFToken(session models.Session, token string) bool {
+	if token == "" || session.CSRFToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(session.CSRFToken), []byte(token)) == 1
+}
+
+// randomToken returns a URL-safe base64 encoded string of n random bytes.
+func randomToken(n int) (string, error) {
+	key := make([]byte, n)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key), nil
+}
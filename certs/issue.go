@@ -0,0 +1,250 @@
+package certs
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/pki"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// CA file names, relative to the config directory
+	CACertFile = "neba-ca.crt"
+	CAKeyFile  = "neba-ca.key"
+
+	// Issuing constants
+	issuedPrefix    = "neba-" // IDs of certificates issued by the CA on devices
+	renewalUsername = "Neba"  // Who starts automatic renewals
+)
+
+// LoadCA returns the CA stored in the config directory, or nil if there is
+// none yet.
+//
+// Parameters:
+//   - configDir: The directory of the configuration file, which holds the CA.
+//
+// Returns:
+//   - *pki.CA: The CA, or nil if there is none.
+//   - error:   An error if the CA exists but cannot be read.
+func LoadCA(configDir string) (*pki.CA, error) {
+	ca, err := pki.LoadCA(CAPaths(configDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return ca, err
+}
+
+// CAPaths returns the paths of the CA certificate and key in the config
+// directory.
+//
+// Parameters:
+//   - configDir: The directory of the configuration file.
+//
+// Returns:
+//   - string: The path of the CA certificate.
+//   - string: The path of the CA key.
+func CAPaths(configDir string) (string, string) {
+	return filepath.Join(configDir, CACertFile), filepath.Join(configDir, CAKeyFile)
+}
+
+// Renew starts a job that replaces the HTTPS certificates issued by Neba's CA
+// that expire within the configured number of days, as of the last time the
+// certificates were read. Nothing happens if automatic renewal is off, there
+// is no CA, or no certificate is due.
+//
+// Parameters:
+//   - db:        A pointer to the bbolt.DB instance.
+//   - jm:        The job manager to run the renewals with.
+//   - configDir: The directory of the configuration file, which holds the CA.
+//   - config:    The current configuration.
+//
+// Returns:
+//   - *models.Job: The started job, or nil if no certificate is due.
+//   - error:       An error if the CA or the certificates cannot be read, or
+//     the job cannot be started.
+func Renew(db *bbolt.DB, jm *jobs.Manager, configDir string, config configs.AppConfig) (*models.Job, error) {
+	if !config.Certificates.AutoRenew {
+		return nil, nil
+	}
+	ca, err := LoadCA(configDir)
+	if ca == nil || err != nil {
+		return nil, err
+	}
+	scans, err := StoredScans(db)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := database.Devices(db).List()
+	if err != nil {
+		return nil, err
+	}
+
+	renewBefore := time.Now().AddDate(0, 0, config.Certificates.RenewDays)
+	var due []models.AxisDevice
+	var serials []string
+	for _, device := range devices {
+		scan := scans[device.SerialNumber]
+		for _, cert := range scan.Certificates {
+			if cert.ID == scan.HTTPS && IssuedBy(cert, ca) && cert.NotAfter.Before(renewBefore) {
+				due = append(due, device)
+				serials = append(serials, device.SerialNumber)
+			}
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	return jobs.Start(db, jm, "Renew certificates", renewalUsername, serials,
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			IssueAll(ctx, db, due, ca, config, report)
+			return fmt.Sprintf("Renewed certificates expiring before %s.", renewBefore.Format(time.DateOnly)), nil
+		})
+}
+
+// IssueAll issues a certificate to each device in turn, reporting the
+// outcome per device.
+//
+// Parameters:
+//   - ctx:     The context of the job; no further devices are started once
+//     it is cancelled.
+//   - db:      A pointer to the bbolt.DB instance.
+//   - devices: The devices to issue certificates to.
+//   - ca:      The CA to sign the certificates with.
+//   - config:  The current configuration.
+//   - report:  The reporter of the job.
+func IssueAll(ctx context.Context, db *bbolt.DB, devices []models.AxisDevice, ca *pki.CA, config configs.AppConfig, report *jobs.Reporter) {
+	for _, device := range devices {
+		if ctx.Err() != nil {
+			return
+		}
+		message, err := Issue(db, device, ca, config)
+		if err != nil {
+			report.Failed(device.SerialNumber, "%v", err)
+			continue
+		}
+		report.Succeeded(device.SerialNumber, "%s", message)
+	}
+}
+
+// Issue has the device create a new key and signing request, signs it with
+// the CA for the device's IP address and hostname, and makes the device trust
+// the CA and use the new certificate for HTTPS. Certificates the CA issued to
+// the device before are removed afterwards.
+//
+// Parameters:
+//   - db:     A pointer to the bbolt.DB instance.
+//   - device: The device to issue the certificate to.
+//   - ca:     The CA to sign the certificate with.
+//   - config: The current configuration.
+//
+// Returns:
+//   - string: A description of the issued certificate.
+//   - error:  An error if any step fails; the device keeps its previous
+//     HTTPS certificate unless binding the new one succeeded.
+func Issue(db *bbolt.DB, device models.AxisDevice, ca *pki.CA, config configs.AppConfig) (string, error) {
+	client := network.NewClient(device.IPAddress, device.Username, device.Password)
+	host := network.Host(device.IPAddress)
+	names := []string{host}
+	commonName := host
+	if info, err := network.GetNetworkInfo(client); err == nil && info.System.Hostname.Hostname != "" {
+		commonName = info.System.Hostname.Hostname
+		names = append(names, commonName)
+	}
+
+	request := network.CSRRequest{
+		ID:         issuedPrefix + time.Now().Format("20060102150405"),
+		CommonName: commonName,
+	}
+	for _, name := range names {
+		if net.ParseIP(name) != nil {
+			request.IPAddresses = append(request.IPAddresses, name)
+		} else {
+			request.DNSNames = append(request.DNSNames, name)
+		}
+	}
+	csr, err := network.CreateCSR(client, request)
+	if err != nil {
+		return "", err
+	}
+	chain, err := ca.SignCSR([]byte(csr), names, time.Duration(config.Certificates.ValidityDays)*24*time.Hour)
+	if err != nil {
+		return "", err
+	}
+	cert, err := pki.ParseCertificatePEM(chain)
+	if err != nil {
+		return "", err
+	}
+
+	if err := trustCA(client, ca); err != nil {
+		return "", err
+	}
+	if err := network.InstallCertificate(client, request.ID, string(chain)); err != nil {
+		return "", err
+	}
+	if err := network.BindHTTPSCertificate(client, request.ID); err != nil {
+		return "", err
+	}
+
+	// Remove what the CA issued before, now that it is no longer in use.
+	if certificates, err := network.ListCertificates(client); err == nil {
+		for _, old := range certificates {
+			if old.ID != request.ID && strings.HasPrefix(old.ID, issuedPrefix) && IssuedBy(old, ca) {
+				if err := network.DeleteCertificate(client, old.ID); err != nil {
+					log.Printf("Failed to remove old certificate from %s: %v", device.SerialNumber, err)
+				}
+			}
+		}
+	}
+	if err := StoreScan(db, ScanDevice(device)); err != nil {
+		log.Printf("Failed to store certificates of %s: %v", device.SerialNumber, err)
+	}
+
+	return fmt.Sprintf("Issued %s for %s, valid until %s, and now used for HTTPS.",
+		request.ID, strings.Join(names, ", "), cert.NotAfter.Format(time.DateOnly)), nil
+}
+
+// trustCA adds the CA certificate to the certificates the device trusts,
+// unless it is there already.
+func trustCA(client *network.Client, ca *pki.CA) error {
+	trusted, err := network.ListCACertificates(client)
+	if err != nil {
+		return err
+	}
+	for _, cert := range trusted {
+		if cert.Subject == ca.Certificate.Subject.String() && cert.NotAfter.Equal(ca.Certificate.NotAfter) {
+			return nil
+		}
+	}
+	id := issuedPrefix + "ca-" + strings.ReplaceAll(ca.Fingerprint()[:11], ":", "")
+	return network.InstallCACertificate(client, id, string(ca.CertPEM))
+}
+
+// IssuedBy reports whether the device certificate was issued by the CA, by
+// its authority key identifier, which the CA sets on every certificate it
+// signs. Names alone are not enough, since another CA may have the same one.
+//
+// Parameters:
+//   - cert: The certificate on the device.
+//   - ca:   The CA.
+//
+// Returns:
+//   - bool: true if the CA issued the certificate.
+func IssuedBy(cert network.DeviceCertificate, ca *pki.CA) bool {
+	return cert.AuthorityID != "" && cert.AuthorityID == hex.EncodeToString(ca.KeyID()) &&
+		cert.Issuer == ca.Certificate.Subject.String()
+}
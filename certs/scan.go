@@ -0,0 +1,183 @@
+// Package certs keeps track of the certificates of Axis devices, and issues
+// and renews their HTTPS certificates with Neba's certificate authority.
+package certs
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Certificate scan constants
+	scansSetting = "certificates.scans"
+	scanWorkers  = 16
+
+	// Certificate statuses
+	StatusValid    = "Valid"
+	StatusExpiring = "Expiring"
+	StatusExpired  = "Expired"
+)
+
+// Scan holds the certificates of a device as last read by Neba.
+type Scan struct {
+	SerialNumber   string                      `json:"serial_number"`
+	ScannedAt      time.Time                   `json:"scanned_at"`
+	Certificates   []network.DeviceCertificate `json:"certificates"`
+	CACertificates []network.DeviceCertificate `json:"ca_certificates"`
+	HTTPS          string                      `json:"https"` // ID of the certificate used for HTTPS
+	Error          string                      `json:"error,omitempty"`
+}
+
+// ScanAll reads the certificates of every device and stores them for the
+// expiry dashboard.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - []Scan: The certificates per device, ordered by serial number.
+//   - error:  An error if the devices cannot be read or the certificates
+//     cannot be stored.
+func ScanAll(db *bbolt.DB) ([]Scan, error) {
+	devices, err := database.Devices(db).List()
+	if err != nil {
+		return nil, fmt.Errorf("scan certificates: %w", err)
+	}
+
+	scans := make([]Scan, len(devices))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range min(scanWorkers, len(devices)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				scans[i] = ScanDevice(devices[i])
+			}
+		}()
+	}
+	for i := range devices {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	slices.SortFunc(scans, func(a, b Scan) int { return strings.Compare(a.SerialNumber, b.SerialNumber) })
+	stored := make(map[string]Scan, len(scans))
+	for _, scan := range scans {
+		stored[scan.SerialNumber] = scan
+	}
+	if err := database.PutSetting(db, scansSetting, stored); err != nil {
+		return nil, fmt.Errorf("store certificates: %w", err)
+	}
+	return scans, nil
+}
+
+// ScanDevice reads the certificates of the device.
+//
+// Parameters:
+//   - device: The device to read the certificates of.
+//
+// Returns:
+//   - Scan: The certificates, with the error if they cannot be read.
+func ScanDevice(device models.AxisDevice) Scan {
+	scan := Scan{SerialNumber: device.SerialNumber, ScannedAt: time.Now()}
+	client := network.NewClient(device.IPAddress, device.Username, device.Password)
+
+	var err error
+	if scan.Certificates, err = network.ListCertificates(client); err == nil {
+		if scan.CACertificates, err = network.ListCACertificates(client); err == nil {
+			scan.HTTPS, err = network.HTTPSCertificate(client)
+		}
+	}
+	if err != nil {
+		scan.Error = err.Error()
+	}
+	return scan
+}
+
+// StoreScan replaces the stored certificates of one device, so that the
+// dashboard reflects changes made on the device page right away. The stored
+// certificates are read and written in one transaction, so that devices
+// stored at the same time do not drop each other's certificates.
+//
+// Parameters:
+//   - db:   A pointer to the bbolt.DB instance.
+//   - scan: The certificates of the device.
+//
+// Returns:
+//   - error: An error if the certificates cannot be stored.
+func StoreScan(db *bbolt.DB, scan Scan) error {
+	scans := map[string]Scan{}
+	return database.UpdateSetting(db, scansSetting, &scans, func() error {
+		scans[scan.SerialNumber] = scan
+		return nil
+	})
+}
+
+// StoredScans returns the last read certificates by serial number.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - map[string]Scan: The certificates per device.
+//   - error:           An error if the certificates cannot be read.
+func StoredScans(db *bbolt.DB) (map[string]Scan, error) {
+	scans := map[string]Scan{}
+	if err := database.GetSetting(db, scansSetting, &scans); err != nil {
+		return nil, err
+	}
+	return scans, nil
+}
+
+// Expiring returns the certificates of the scans that expire within the given
+// number of days, or have expired.
+//
+// Parameters:
+//   - scans:    The certificates per device.
+//   - warnDays: How many days before expiry a certificate is flagged.
+//
+// Returns:
+//   - map[string][]network.DeviceCertificate: The flagged certificates by serial number.
+func Expiring(scans []Scan, warnDays int) map[string][]network.DeviceCertificate {
+	now := time.Now()
+	expiring := map[string][]network.DeviceCertificate{}
+	for _, scan := range scans {
+		for _, cert := range slices.Concat(scan.Certificates, scan.CACertificates) {
+			if Status(cert, warnDays, now) != StatusValid {
+				expiring[scan.SerialNumber] = append(expiring[scan.SerialNumber], cert)
+			}
+		}
+	}
+	return expiring
+}
+
+// Status returns whether the certificate is valid, expires within the given
+// number of days, or has expired.
+//
+// Parameters:
+//   - cert:     The certificate.
+//   - warnDays: How many days before expiry a certificate is flagged.
+//   - now:      The time to check against.
+//
+// Returns:
+//   - string: StatusValid, StatusExpiring, or StatusExpired.
+func Status(cert network.DeviceCertificate, warnDays int, now time.Time) string {
+	switch {
+	case !now.Before(cert.NotAfter):
+		return StatusExpired
+	case now.AddDate(0, 0, warnDays).After(cert.NotAfter):
+		return StatusExpiring
+	default:
+		return StatusValid
+	}
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/network"
+)
+
+func runRestart(args []string) int {
+	fs := newFlagSet("restart", "neba restart <serial|site|tag...> [flags]")
+	loc := addLocationFlags(fs)
+	selectors, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(selectors) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	devices, err := resolveTargets(db, selectors)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	exitCode := 0
+	for _, device := range devices {
+		err := network.Restart(deviceClient(device))
+		auditCLI(db, "Restart", []string{device.SerialNumber}, nil, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "neba: %s: %v\n", device.SerialNumber, err)
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("Restart of %s requested.\n", device.SerialNumber)
+	}
+	return exitCode
+}
+
+func runReport(args []string) int {
+	fs := newFlagSet("report", "neba report <serial> [-o file] [flags]")
+	loc := addLocationFlags(fs)
+	output := fs.String("o", "", `output file, or "-" for stdout (default "serverreport_<serial>.zip")`)
+	serials, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(serials) != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	device, err := database.View(db, database.DevicesBucket, serials[0])
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	report, err := network.ServerReport(deviceClient(*device))
+	if err != nil {
+		return fail("%s: %v", device.SerialNumber, err)
+	}
+
+	if *output == "-" {
+		os.Stdout.Write(report)
+		return 0
+	}
+	if *output == "" {
+		*output = fmt.Sprintf("serverreport_%s.zip", device.SerialNumber)
+	}
+	if err := os.WriteFile(*output, report, 0644); err != nil {
+		return fail("%v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Saved the server report of %s to %s.\n", device.SerialNumber, *output)
+	return 0
+}
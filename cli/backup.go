@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/furkansuleymana/neba/database"
+)
+
+func runBackup(args []string) int {
+	fs := newFlagSet("backup", "neba backup [-o file] [flags]")
+	loc := addLocationFlags(fs)
+	output := fs.String("o", "", `output file, or "-" for stdout (default: a new file in the backup folder)`)
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	cm, err := loadConfig(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	db, err := openDatabase(cm.Get())
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer database.CloseDB(db)
+
+	switch *output {
+	case "":
+		path, err := database.BackupToDir(db, cm.Get().Backup.Dir)
+		if err != nil {
+			return fail("%v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Saved a backup to %s.\n", path)
+	case "-":
+		if _, err := database.WriteBackup(db, os.Stdout); err != nil {
+			return fail("%v", err)
+		}
+	default:
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if _, err := database.WriteBackup(db, f); err != nil {
+			f.Close()
+			return fail("%v", err)
+		}
+		if err := f.Close(); err != nil {
+			return fail("%v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Saved a backup to %s.\n", *output)
+	}
+	return 0
+}
+
+func runExport(args []string) int {
+	fs := newFlagSet("export", "neba export [--format csv|json] [-o file] [flags]")
+	loc := addLocationFlags(fs)
+	format := fs.String("format", database.FormatCSV, "inventory format: csv or json")
+	output := fs.String("o", "-", `output file, or "-" for stdout`)
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fail("%v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := database.ExportInventory(db, w, *format); err != nil {
+		return fail("%v", err)
+	}
+	return 0
+}
+
+func runImport(args []string) int {
+	fs := newFlagSet("import", "neba import <file> [--mode skip|overwrite|merge] [flags]")
+	loc := addLocationFlags(fs)
+	format := fs.String("format", "", "inventory format: csv or json (default: from the file extension)")
+	mode := fs.String("mode", string(database.ImportSkip), "what to do with existing devices: skip, overwrite, or merge")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+	if !slices.Contains(database.ImportModes, database.ImportMode(*mode)) {
+		return fail("unknown import mode %q", *mode)
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(files[0])), ".")
+	}
+
+	var r io.Reader = os.Stdin
+	if files[0] != "-" {
+		f, err := os.Open(files[0])
+		if err != nil {
+			return fail("%v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	records, err := database.ParseInventory(r, *format)
+	if err != nil {
+		return fail("%s: %v", files[0], err)
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	result, err := database.ImportInventory(db, records, database.ImportMode(*mode))
+	auditCLI(db, "Import inventory", append(result.Created, result.Updated...), map[string]string{
+		"file": files[0],
+		"mode": *mode,
+	}, err)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	fmt.Println(result)
+	return 0
+}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database"
+	"go.etcd.io/bbolt"
+)
+
+// command is a single neba subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists the subcommands in the order in which they are shown in the
+// usage message. It is filled in by init to avoid an initialization cycle
+// with the help command.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "Run the web server (default)", runServe},
+		{"discover", "Discover Axis devices on the network", runDiscover},
+		{"devices", "List, add, and remove managed devices", runDevices},
+		{"restart", "Restart devices by serial number, site, or tag", runRestart},
+		{"report", "Download the server report of a device", runReport},
+		{"param", "Get or set device parameters", runParam},
+		{"backup", "Back up the database", runBackup},
+		{"export", "Export the device inventory as CSV or JSON", runExport},
+		{"import", "Import a device inventory from CSV or JSON", runImport},
+		{"inventory", "Generate an inventory report as CSV, JSON, or HTML", runInventory},
+		{"service", "Install and control Neba as a system service", runService},
+		{"help", "Show this help", runHelp},
+	}
+}
+
+// Run parses the command line and runs the requested subcommand. Without a
+// subcommand, or if the first argument is a flag, the web server is started.
+//
+// Parameters:
+//   - args: The command line arguments, without the program name.
+//
+// Returns:
+//   - int: The exit code of the subcommand.
+func Run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runServe(args)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "neba: unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return 2
+}
+
+func runHelp(args []string) int {
+	usage(os.Stdout)
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: neba [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "neba <command> -h" for the flags of a command.`)
+}
+
+// newFlagSet returns a flag set for the given subcommand that reports errors
+// instead of exiting, and prints the given usage line.
+func newFlagSet(name, usageLine string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\nFlags:\n", usageLine)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// fail prints the error and returns the exit code for a failed command.
+func fail(format string, args ...any) int {
+	fmt.Fprintf(os.Stderr, "neba: "+format+"\n", args...)
+	return 1
+}
+
+// parseArgs parses flags and positional arguments in any order, so that both
+// "neba report -o file SERIAL" and "neba report SERIAL -o file" work.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// locationFlags are the flags that select the configuration file, and with
+// it the database, that a command works with.
+type locationFlags struct {
+	config  string
+	dataDir string
+}
+
+// addLocationFlags defines the --config and --data-dir flags on the flag set.
+func addLocationFlags(fs *flag.FlagSet) *locationFlags {
+	loc := &locationFlags{}
+	fs.StringVar(&loc.config, "config", "", "path of the configuration file (default: $"+configs.ConfigPathEnv+
+		", next to the executable, or the user configuration directory)")
+	fs.StringVar(&loc.dataDir, "data-dir", "", "directory for the configuration and the database; ignored with --config")
+	return loc
+}
+
+// loadConfig creates the configuration manager for the file selected by the
+// flags, in order of precedence: the --config file, the configuration in the
+// --data-dir directory, or the default location. Relative paths in the
+// configuration are resolved against its directory. With a data directory,
+// the process also changes into it, so that the service keeps no references
+// to the directory it was started from.
+func loadConfig(loc *locationFlags) (*configs.CManager, error) {
+	switch {
+	case loc.config != "":
+		return configs.Load(loc.config)
+	case loc.dataDir != "":
+		if err := os.MkdirAll(loc.dataDir, 0750); err != nil {
+			return nil, fmt.Errorf("create data directory: %w", err)
+		}
+		if err := os.Chdir(loc.dataDir); err != nil {
+			return nil, fmt.Errorf("change to data directory: %w", err)
+		}
+		return configs.ConfigManagerIn(".")
+	default:
+		return configs.ConfigManager()
+	}
+}
+
+// openDatabase opens the database named in the configuration, creating its
+// directory if needed.
+func openDatabase(config configs.AppConfig) (*bbolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(config.Database.Path), 0755); err != nil {
+		return nil, fmt.Errorf("create database directory: %w", err)
+	}
+	return database.Open(config.Database.Path, database.Buckets...)
+}
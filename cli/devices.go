@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+	"go.etcd.io/bbolt"
+)
+
+func runDevices(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: neba devices list|add|rm [flags]")
+		return 2
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return runDevicesList(args[1:])
+	case "add":
+		return runDevicesAdd(args[1:])
+	case "rm", "remove":
+		return runDevicesRemove(args[1:])
+	default:
+		return fail("unknown devices action %q", args[0])
+	}
+}
+
+func runDevicesList(args []string) int {
+	fs := newFlagSet("devices list", "neba devices list [serial|site|tag...] [flags]")
+	loc := addLocationFlags(fs)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	selectors, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	var devices []models.AxisDevice
+	if len(selectors) == 0 {
+		devices, err = database.List(db, database.DevicesBucket)
+	} else {
+		devices, err = resolveTargets(db, selectors)
+	}
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	if *asJSON {
+		// Never print stored device passwords.
+		type device struct {
+			SerialNumber string   `json:"serial_number"`
+			Model        string   `json:"model"`
+			IPAddress    string   `json:"ip_address"`
+			OSVersion    string   `json:"os_version"`
+			Username     string   `json:"username"`
+			Site         string   `json:"site,omitempty"`
+			Tags         []string `json:"tags,omitempty"`
+		}
+		out := make([]device, 0, len(devices))
+		for _, d := range devices {
+			out = append(out, device{d.SerialNumber, d.Model, d.IPAddress, d.OSVersion, d.Username, d.Site, d.Tags})
+		}
+		if err := printJSON(out); err != nil {
+			return fail("%v", err)
+		}
+		return 0
+	}
+
+	rows := make([][]string, 0, len(devices))
+	for _, d := range devices {
+		rows = append(rows, []string{d.SerialNumber, d.Model, d.IPAddress, d.OSVersion, d.Site, strings.Join(d.Tags, ",")})
+	}
+	if err := printTable([]string{"SERIAL", "MODEL", "IP ADDRESS", "AXIS OS", "SITE", "TAGS"}, rows); err != nil {
+		return fail("%v", err)
+	}
+	return 0
+}
+
+func runDevicesAdd(args []string) int {
+	fs := newFlagSet("devices add", "neba devices add --address <ip> [flags]")
+	loc := addLocationFlags(fs)
+	address := fs.String("address", "", "IP address or host name of the device (required)")
+	username := fs.String("username", "root", "username on the device")
+	password := fs.String("password", "", "password on the device")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	site := fs.String("site", "", "site the device belongs to")
+	tags := fs.String("tags", "", "comma-separated tags")
+	noVerify := fs.Bool("no-verify", false, "do not connect to the device; requires --serial")
+	serial := fs.String("serial", "", "serial number, used with --no-verify")
+	model := fs.String("model", "", "model, used with --no-verify")
+	force := fs.Bool("force", false, "replace a device with the same serial number")
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	if *address == "" {
+		return fail("--address is required")
+	}
+	if *passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fail("read password from stdin: %v", err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	device := models.AxisDevice{
+		SerialNumber: *serial,
+		Model:        *model,
+		IPAddress:    *address,
+		Username:     *username,
+		Password:     *password,
+		Site:         *site,
+		Tags:         splitList(*tags),
+	}
+
+	if *noVerify {
+		if device.SerialNumber == "" {
+			return fail("--serial is required with --no-verify")
+		}
+	} else {
+		info, err := network.GetBasicDeviceInfo(network.NewClient(device.IPAddress, device.Username, device.Password))
+		if err != nil {
+			return fail("connect to %s: %v", device.IPAddress, err)
+		}
+		device.SerialNumber = info.SerialNumber
+		device.Model = info.ProdNbr
+		device.OSVersion = info.Version
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	_, err = database.View(db, database.DevicesBucket, device.SerialNumber)
+	switch {
+	case err == nil && !*force:
+		return fail("device %s already exists; use --force to replace it", device.SerialNumber)
+	case err != nil && !errors.Is(err, database.ErrNotFound):
+		return fail("%v", err)
+	}
+
+	err = database.Update(db, database.DevicesBucket, device)
+	auditCLI(db, "Add device", []string{device.SerialNumber}, map[string]string{
+		"address": device.IPAddress,
+		"site":    device.Site,
+		"tags":    strings.Join(device.Tags, ","),
+	}, err)
+	if err != nil {
+		return fail("save device: %v", err)
+	}
+
+	fmt.Printf("Added %s (%s) at %s.\n", device.SerialNumber, device.Model, device.IPAddress)
+	return 0
+}
+
+func runDevicesRemove(args []string) int {
+	fs := newFlagSet("devices rm", "neba devices rm <serial...> [flags]")
+	loc := addLocationFlags(fs)
+	serials, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(serials) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	exitCode := 0
+	for _, serial := range serials {
+		if _, err := database.View(db, database.DevicesBucket, serial); err != nil {
+			fmt.Fprintf(os.Stderr, "neba: %v\n", err)
+			exitCode = 1
+			continue
+		}
+		err := database.Delete(db, database.DevicesBucket, serial)
+		auditCLI(db, "Remove device", []string{serial}, nil, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "neba: remove %s: %v\n", serial, err)
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("Removed %s.\n", serial)
+	}
+	return exitCode
+}
+
+// resolveTargets returns the devices selected by the given selectors. Each
+// selector is either a serial number, or a site or tag that selects every
+// device with that site or tag. Each device is returned once.
+func resolveTargets(db *bbolt.DB, selectors []string) ([]models.AxisDevice, error) {
+	devices, err := database.List(db, database.DevicesBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []models.AxisDevice
+	seen := make(map[string]bool)
+	for _, selector := range selectors {
+		matched := false
+		for _, device := range devices {
+			if device.SerialNumber != selector && device.Site != selector && !slices.Contains(device.Tags, selector) {
+				continue
+			}
+			matched = true
+			if !seen[device.SerialNumber] {
+				seen[device.SerialNumber] = true
+				targets = append(targets, device)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no device matches %q", selector)
+		}
+	}
+	return targets, nil
+}
+
+// splitList splits a comma-separated list into its trimmed, non-empty elements.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// deviceClient returns a VAPIX client for the given device.
+func deviceClient(device models.AxisDevice) *network.Client {
+	return network.NewClient(device.IPAddress, device.Username, device.Password)
+}
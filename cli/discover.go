@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"github.com/furkansuleymana/neba/network"
+)
+
+func runDiscover(args []string) int {
+	fs := newFlagSet("discover", "neba discover [flags]")
+	loc := addLocationFlags(fs)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	timeout := fs.Int("timeout", 0, "seconds to wait for responses (default: from the configuration)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	cm, err := loadConfig(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	discovery := cm.Get().Discovery
+	if *timeout > 0 {
+		discovery.TimeoutSeconds = *timeout
+	}
+
+	devices, err := network.DiscoverSSDP(discovery.TimeoutSeconds, discovery.LocalAddress)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	network.MarkUnprovisioned(devices)
+
+	if *asJSON {
+		if err := printJSON(devices); err != nil {
+			return fail("%v", err)
+		}
+		return 0
+	}
+
+	rows := make([][]string, 0, len(devices))
+	for _, device := range devices {
+		state := ""
+		if device["Unprovisioned"] == "true" {
+			state = "factory new"
+		}
+		rows = append(rows, []string{
+			device["SerialNumber"],
+			device["ModelName"],
+			device["FriendlyName"],
+			device["PresentationURL"],
+			state,
+		})
+	}
+	if err := printTable([]string{"SERIAL", "MODEL", "NAME", "URL", "STATE"}, rows); err != nil {
+		return fail("%v", err)
+	}
+	return 0
+}
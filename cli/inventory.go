@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/reports"
+)
+
+func runInventory(args []string) int {
+	fs := newFlagSet("inventory", "neba inventory [--format csv|json|html] [--columns list] [--group by] [-o file] [flags]")
+	loc := addLocationFlags(fs)
+	format := fs.String("format", reports.FormatCSV, "report format: "+strings.Join(reports.Formats, ", "))
+	columns := fs.String("columns", strings.Join(reports.DefaultColumns, ","), "comma-separated columns")
+	group := fs.String("group", "", "group devices by "+strings.Join(reports.GroupBy, ", "))
+	output := fs.String("o", "-", `output file, or "-" for stdout`)
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	devices, err := database.Devices(db).List()
+	if err != nil {
+		return fail("%v", err)
+	}
+	report, err := reports.NewInventoryReport(devices, reports.Options{
+		Columns: reports.ParseColumns(*columns),
+		GroupBy: *group,
+	}, time.Now())
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fail("%v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := report.Write(w, *format); err != nil {
+		return fail("%v", err)
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// cliSource is recorded as the source IP of audit entries made from the
+	// command line.
+	cliSource = "cli"
+)
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable writes the rows to stdout as aligned columns under the headers.
+func printTable(headers []string, rows [][]string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// openStore loads the configuration selected by the flags and opens
+// the database. The returned function closes the database. Since the
+// database can only be opened by one process at a time, this fails while
+// the server is running.
+func openStore(loc *locationFlags) (*bbolt.DB, func(), error) {
+	cm, err := loadConfig(loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := openDatabase(cm.Get())
+	if errors.Is(err, database.ErrLocked) {
+		return nil, nil, fmt.Errorf("the database %s is in use by a running Neba server; stop the server first", cm.Get().Database.Path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, func() { database.CloseDB(db) }, nil
+}
+
+// auditCLI records an action taken from the command line in the audit log,
+// attributed to the operating system user running the command. Secrets are
+// redacted from the parameters, as they are for requests to the web server.
+func auditCLI(db *bbolt.DB, action string, targets []string, params map[string]string, err error) {
+	entry := models.AuditEntry{
+		Time:       time.Now(),
+		Username:   cliSource,
+		SourceIP:   cliSource,
+		Action:     action,
+		Targets:    targets,
+		Parameters: database.RedactParameters(params),
+		Outcome:    models.AuditSuccess,
+	}
+	if u, uerr := user.Current(); uerr == nil {
+		entry.Username = cliSource + ":" + u.Username
+	}
+	if err != nil {
+		entry.Outcome = models.AuditFailure
+		entry.Message = database.RedactMessage(err.Error(), params)
+	}
+
+	if aerr := database.AppendAudit(db, entry); aerr != nil {
+		fmt.Fprintf(os.Stderr, "neba: failed to write audit entry: %v\n", aerr)
+	}
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/furkansuleymana/neba/network"
+)
+
+func runParam(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: neba param get|set [flags]")
+		return 2
+	}
+
+	switch args[0] {
+	case "get":
+		return runParamGet(args[1:])
+	case "set":
+		return runParamSet(args[1:])
+	default:
+		return fail("unknown param action %q", args[0])
+	}
+}
+
+func runParamGet(args []string) int {
+	fs := newFlagSet("param get", "neba param get <serial|site|tag> [group] [flags]")
+	loc := addLocationFlags(fs)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		fs.Usage()
+		return 2
+	}
+	group := ""
+	if len(positional) == 2 {
+		group = positional[1]
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	devices, err := resolveTargets(db, positional[:1])
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	exitCode := 0
+	results := make(map[string]map[string]string, len(devices))
+	var rows [][]string
+	for _, device := range devices {
+		params, err := network.GetParams(deviceClient(device), group)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "neba: %s: %v\n", device.SerialNumber, err)
+			exitCode = 1
+			continue
+		}
+		results[device.SerialNumber] = params
+
+		keys := make([]string, 0, len(params))
+		for key := range params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			rows = append(rows, []string{device.SerialNumber, key, params[key]})
+		}
+	}
+
+	if *asJSON {
+		err = printJSON(results)
+	} else {
+		err = printTable([]string{"SERIAL", "PARAMETER", "VALUE"}, rows)
+	}
+	if err != nil {
+		return fail("%v", err)
+	}
+	return exitCode
+}
+
+func runParamSet(args []string) int {
+	fs := newFlagSet("param set", "neba param set <serial|site|tag> <name=value...> [flags]")
+	loc := addLocationFlags(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) < 2 {
+		fs.Usage()
+		return 2
+	}
+
+	params := make(map[string]string, len(positional)-1)
+	for _, assignment := range positional[1:] {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok || name == "" {
+			return fail("invalid parameter %q, expected name=value", assignment)
+		}
+		params[name] = value
+	}
+
+	db, closeDB, err := openStore(loc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer closeDB()
+
+	devices, err := resolveTargets(db, positional[:1])
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	exitCode := 0
+	for _, device := range devices {
+		err := network.SetParams(deviceClient(device), params)
+		auditCLI(db, "Set parameters", []string{device.SerialNumber}, params, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "neba: %s: %v\n", device.SerialNumber, err)
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("Updated %d parameters on %s.\n", len(params), device.SerialNumber)
+	}
+	return exitCode
+}
@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/furkansuleymana/neba/certs"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/events"
+	"github.com/furkansuleymana/neba/handlers"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/server"
+	"github.com/furkansuleymana/neba/timesync"
+	"github.com/furkansuleymana/neba/ui"
+	"github.com/pkg/browser"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Shutdown constants
+	shutdownTimeout = 30 * time.Second
+
+	// Maintenance constants
+	configWatchInterval = 2 * time.Second
+	eventSyncInterval   = time.Minute
+	eventPruneInterval  = time.Hour
+	jobPruneInterval    = time.Hour
+	jobRetention        = 30 * 24 * time.Hour
+)
+
+// runServe runs the web server until it receives SIGINT or SIGTERM.
+func runServe(args []string) int {
+	fs := newFlagSet("serve", "neba [serve] [flags]")
+	headless := fs.Bool("headless", false, "do not open the web UI in a browser")
+	loc := addLocationFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Create configuration manager
+	cm, err := loadConfig(loc)
+	if err != nil {
+		log.Println("Failed to create config manager:", err)
+		return 1
+	}
+
+	// Get current config
+	config := cm.Get()
+
+	// Open database
+	db, err := openDatabase(config)
+	if err != nil {
+		log.Println("Failed to open database:", err)
+		return 1
+	}
+	defer database.CloseDB(db)
+
+	// Jobs that were running when Neba last stopped will never finish
+	interrupted, err := database.FailInterruptedJobs(db)
+	if err != nil {
+		log.Println("Failed to update interrupted jobs:", err)
+		return 1
+	}
+	for _, job := range interrupted {
+		slog.Warn("job was interrupted by a restart", slog.String("job", job.Kind), slog.String("id", job.ID))
+	}
+
+	// Start background jobs
+	jm := jobs.NewManager()
+	sessionPurge, err := jm.Every("purge expired sessions", config.Polling.SessionPurge.Duration(), func(ctx context.Context) {
+		if _, err := database.PurgeExpiredSessions(db); err != nil {
+			slog.Error("failed to purge expired sessions", slog.Any("error", err))
+		}
+	})
+	if err != nil {
+		log.Println("Failed to start background jobs:", err)
+		return 1
+	}
+	backup, err := jm.Every("back up database", config.Backup.Interval.Duration(), func(ctx context.Context) {
+		backupDatabase(db, cm.Get())
+	})
+	if err != nil {
+		log.Println("Failed to start background jobs:", err)
+		return 1
+	}
+	clockCheck, err := jm.Every("check device clocks", config.Polling.ClockCheck.Duration(), func(ctx context.Context) {
+		checks, err := timesync.CheckClocks(db, cm.Get().Polling.MaxClockDrift.Duration())
+		if err != nil {
+			slog.Error("failed to check device clocks", slog.Any("error", err))
+			return
+		}
+		for _, check := range checks {
+			if check.Exceeded {
+				slog.Warn("device clock drifts", slog.String("serial", check.SerialNumber), slog.String("clock", check.Describe()))
+			}
+		}
+	})
+	if err != nil {
+		log.Println("Failed to start background jobs:", err)
+		return 1
+	}
+	certificateScan, err := jm.Every("read device certificates", config.Certificates.Scan.Duration(), func(ctx context.Context) {
+		scans, err := certs.ScanAll(db)
+		if err != nil {
+			slog.Error("failed to read device certificates", slog.Any("error", err))
+			return
+		}
+		for serial, certificates := range certs.Expiring(scans, cm.Get().Certificates.WarnDays) {
+			for _, certificate := range certificates {
+				slog.Warn("device certificate expires soon", slog.String("serial", serial),
+					slog.String("certificate", certificate.ID), slog.Time("expires", certificate.NotAfter))
+			}
+		}
+		job, err := certs.Renew(db, jm, cm.Dir(), cm.Get())
+		if err != nil {
+			slog.Error("failed to renew device certificates", slog.Any("error", err))
+		} else if job != nil {
+			slog.Info("renewing device certificates", slog.String("job", job.ID), slog.Int("devices", len(job.Targets)))
+		}
+	})
+	if err != nil {
+		log.Println("Failed to start background jobs:", err)
+		return 1
+	}
+	subscriber := events.NewSubscriber(db)
+	if err := subscriber.Sync(config.Events.Enabled, config.Events.TopicFilters); err != nil {
+		slog.Error("failed to subscribe to device events", slog.Any("error", err))
+	}
+	_, err = jm.Every("sync device event subscriptions", eventSyncInterval, func(ctx context.Context) {
+		current := cm.Get()
+		if err := subscriber.Sync(current.Events.Enabled, current.Events.TopicFilters); err != nil {
+			slog.Error("failed to sync device event subscriptions", slog.Any("error", err))
+		}
+	})
+	if err != nil {
+		log.Println("Failed to start background jobs:", err)
+		return 1
+	}
+	_, err = jm.Every("prune device events", eventPruneInterval, func(ctx context.Context) {
+		before := time.Now().AddDate(0, 0, -cm.Get().Events.RetentionDays)
+		if pruned, err := database.PruneEvents(db, before); err != nil {
+			slog.Error("failed to prune device events", slog.Any("error", err))
+		} else if pruned > 0 {
+			slog.Info("pruned device events", slog.Int("events", pruned))
+		}
+	})
+	if err != nil {
+		log.Println("Failed to start background jobs:", err)
+		return 1
+	}
+	_, err = jm.Every("prune finished jobs", jobPruneInterval, func(ctx context.Context) {
+		if pruned, err := database.PruneJobs(db, time.Now().Add(-jobRetention)); err != nil {
+			slog.Error("failed to prune finished jobs", slog.Any("error", err))
+		} else if pruned > 0 {
+			slog.Info("pruned finished jobs", slog.Int("jobs", pruned))
+		}
+	})
+	if err != nil {
+		log.Println("Failed to start background jobs:", err)
+		return 1
+	}
+	_, err = jm.Every("reload config", configWatchInterval, func(ctx context.Context) {
+		if _, err := cm.ReloadIfChanged(); err != nil {
+			slog.Error("failed to reload config, keeping the previous one", slog.Any("error", err))
+		}
+	})
+	if err != nil {
+		log.Println("Failed to start background jobs:", err)
+		return 1
+	}
+
+	// Apply configuration changes where possible
+	cm.Subscribe(func(old, new configs.AppConfig) {
+		if old.Polling.SessionPurge != new.Polling.SessionPurge {
+			sessionPurge.Reset(new.Polling.SessionPurge.Duration())
+		}
+		if old.Backup.Interval != new.Backup.Interval {
+			backup.Reset(new.Backup.Interval.Duration())
+		}
+		if old.Polling.ClockCheck != new.Polling.ClockCheck {
+			clockCheck.Reset(new.Polling.ClockCheck.Duration())
+		}
+		if old.Certificates.Scan != new.Certificates.Scan {
+			certificateScan.Reset(new.Certificates.Scan.Duration())
+		}
+		if !reflect.DeepEqual(old.Events, new.Events) {
+			if err := subscriber.Sync(new.Events.Enabled, new.Events.TopicFilters); err != nil {
+				slog.Error("failed to sync device event subscriptions", slog.Any("error", err))
+			}
+		}
+		if sections := configs.RestartRequired(config, new); len(sections) > 0 {
+			slog.Warn("config changes take effect after a restart", slog.Any("sections", sections))
+		}
+	})
+
+	// Setup server
+	static := http.FileServer(http.FS(ui.FS))
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
+		static.ServeHTTP(w, r)
+	})
+
+	handlers.RegisterAuthRoutes(static, mux, db)
+	handlers.RegisterRootRoute(static, mux)
+	handlers.RegisterHomeRoute(static, mux)
+	handlers.RegisterDiscoverDevicesRoute(static, mux, cm)
+	handlers.RegisterManageDevicesRoute(static, mux, db)
+	handlers.RegisterOnboardRoute(static, mux, db)
+	handlers.RegisterReportsRoute(static, mux, db)
+	handlers.RegisterNetworkRoute(static, mux, db, cm, jm)
+	handlers.RegisterProvisionRoute(static, mux, db, jm)
+	handlers.RegisterCredentialsRoute(static, mux, db)
+	handlers.RegisterAccountsRoute(static, mux, db, jm)
+	handlers.RegisterJobsRoute(static, mux, db)
+	handlers.RegisterTimeRoute(static, mux, db, cm, jm)
+	handlers.RegisterCertificatesRoute(static, mux, db, cm)
+	handlers.RegisterCARoute(static, mux, db, cm, jm)
+	handlers.RegisterComplianceRoute(static, mux, db, jm)
+	handlers.RegisterSnapshotRoute(static, mux, db, cm)
+	handlers.RegisterLiveViewRoute(static, mux, db, cm)
+	handlers.RegisterEventsRoute(static, mux, db, cm, subscriber)
+	handlers.RegisterActionRulesRoute(static, mux, db, jm)
+	handlers.RegisterUsersRoute(static, mux, db)
+	handlers.RegisterAuditRoute(static, mux, db)
+	handlers.RegisterSettingsRoute(static, mux, cm)
+	handlers.RegisterBackupRoute(static, mux, db, cm)
+
+	srv, err := server.New(config, cm.Dir(), handlers.RequireAuth(db, handlers.Audit(db, mux)))
+	if err != nil {
+		log.Println("Failed to set up server:", err)
+		return 1
+	}
+
+	// Go!
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// Open browser
+	url := srv.URL()
+	if !*headless {
+		if err = browser.OpenURL(url); err != nil {
+			log.Println("Failed to open browser:", err)
+		}
+	}
+	log.Println("Neba is running!", url)
+
+	// Wait for a signal or a failing listener, then shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+	case err := <-serveErr:
+		log.Println("Server failed:", err)
+		exitCode = 1
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Failed to shut down server:", err)
+	}
+	subscriber.Stop()
+	if err := jm.Shutdown(shutdownCtx); err != nil {
+		log.Println("Failed to drain running jobs:", err)
+	}
+
+	log.Println("Neba has stopped.")
+	return exitCode
+}
+
+// backupDatabase writes a scheduled backup, if enabled, and deletes the
+// oldest backups beyond the configured number.
+func backupDatabase(db *bbolt.DB, config configs.AppConfig) {
+	if !config.Backup.Enabled {
+		return
+	}
+
+	path, err := database.BackupToDir(db, config.Backup.Dir)
+	if err != nil {
+		slog.Error("failed to back up database", slog.Any("error", err))
+		return
+	}
+	slog.Info("backed up database", slog.String("path", path))
+
+	if _, err := database.PruneBackups(config.Backup.Dir, config.Backup.Keep); err != nil {
+		slog.Error("failed to delete old backups", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/furkansuleymana/neba/service"
+)
+
+func runService(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: neba service install|uninstall|start|stop|print [flags]")
+		return 2
+	}
+	action := args[0]
+
+	fs := newFlagSet("service "+action, "neba service "+action+" [flags]")
+	dataDir := fs.String("data-dir", service.DefaultDataDir(), "directory for the configuration and the database")
+	user := fs.String("user", "", "account to run the service as; required with systemd unless --data-dir is "+service.DefaultDataDir()+" (default: a systemd dynamic user)")
+	format := fs.String("format", service.DefaultFormat(), "definition format for print: "+strings.Join(service.Formats, ", "))
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch action {
+	case "print":
+		if !slices.Contains(service.Formats, *format) {
+			return fail("unknown format %q", *format)
+		}
+		def, err := service.NewDefinition(*dataDir, *user)
+		if err != nil {
+			return fail("%v", err)
+		}
+		definition, err := def.Render(*format)
+		if err != nil {
+			return fail("%v", err)
+		}
+		os.Stdout.Write(definition)
+	case "install":
+		def, err := service.NewDefinition(*dataDir, *user)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if err := def.Install(); err != nil {
+			return fail("%v", err)
+		}
+		fmt.Printf("Installed the %s service with data in %s. Start it with \"neba service start\".\n", service.Name, def.DataDir)
+	case "uninstall":
+		if err := service.Uninstall(); err != nil {
+			return fail("%v", err)
+		}
+		fmt.Printf("Uninstalled the %s service. Its data has been kept.\n", service.Name)
+	case "start":
+		if err := service.Start(); err != nil {
+			return fail("%v", err)
+		}
+	case "stop":
+		if err := service.Stop(); err != nil {
+			return fail("%v", err)
+		}
+	default:
+		return fail("unknown service action %q", action)
+	}
+	return 0
+}
@@ -0,0 +1,164 @@
+// Package compliance checks Axis devices against the recommendations of the
+// Axis hardening guide, as selected by a configurable policy.
+package compliance
+
+import (
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/network"
+)
+
+const (
+	// Factory default credentials of older Axis devices
+	DefaultUsername = "root"
+	DefaultPassword = "pass"
+)
+
+// DeviceSettings are the settings of a device that the rules are checked
+// against.
+type DeviceSettings struct {
+	Params          map[string]string // Every parameter, by full name
+	Unprovisioned   bool              // Whether the device has no administrator password
+	PasswordProbed  bool              // Whether the factory default password was tried
+	DefaultPassword bool              // Whether root accepts the factory default password
+	HTTPS           bool              // Whether Neba connects to the device over HTTPS
+}
+
+// Param returns the value of the parameter with the given name, which is
+// given without the "root." prefix.
+func (s DeviceSettings) Param(name string) (string, bool) {
+	value, ok := s.Params["root."+name]
+	return value, ok
+}
+
+// Finding is the outcome of checking a device against a single rule.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Title    string   `json:"title"`
+	Severity Severity `json:"severity"`
+	Status   Status   `json:"status"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// Report is the outcome of checking a device against every enabled rule of
+// a policy.
+type Report struct {
+	SerialNumber string    `json:"serial_number"`
+	CheckedAt    time.Time `json:"checked_at"`
+	Score        int       `json:"score"` // Percentage of the weight of the known findings that passed
+	Findings     []Finding `json:"findings"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Failed returns the findings that failed.
+func (r Report) Failed() []Finding {
+	var failed []Finding
+	for _, finding := range r.Findings {
+		if finding.Status == StatusFailed {
+			failed = append(failed, finding)
+		}
+	}
+	return failed
+}
+
+// ReadSettings reads the settings of the device that the rules are checked
+// against. Trying the factory default password is a failed login whenever
+// the device is hardened, which feeds the brute-force protection of AXIS OS
+// and fills the device log, so it is only tried if asked for.
+//
+// Parameters:
+//   - c:             The client of the device.
+//   - probePassword: Whether to try to log in with the factory default
+//     password.
+//
+// Returns:
+//   - DeviceSettings: The settings of the device.
+//   - error:          An error if the parameters of the device cannot be read.
+func ReadSettings(c *network.Client, probePassword bool) (DeviceSettings, error) {
+	params, err := network.GetParams(c, "")
+	if err != nil {
+		return DeviceSettings{}, err
+	}
+	settings := DeviceSettings{
+		Params: params,
+		HTTPS:  strings.HasPrefix(strings.ToLower(c.Address), "https://"),
+	}
+
+	// Devices without an administrator cannot have a default password.
+	settings.Unprovisioned, err = network.IsUnprovisioned(c)
+	if err != nil || settings.Unprovisioned || !probePassword {
+		return settings, nil
+	}
+	settings.PasswordProbed = true
+	probe := network.NewClient(c.Address, DefaultUsername, DefaultPassword)
+	_, err = network.GetParams(probe, "Brand")
+	settings.DefaultPassword = err == nil
+
+	return settings, nil
+}
+
+// Evaluate checks the settings of a device against every enabled rule of the
+// policy and scores the outcome. Findings whose status is unknown are not
+// scored; a device without any scored findings scores 100.
+//
+// Parameters:
+//   - settings: The settings of the device.
+//   - policy:   The policy to check the device against.
+//
+// Returns:
+//   - []Finding: The outcome per enabled rule, in the order of Rules.
+//   - int:       The score of the device, from 0 to 100.
+func Evaluate(settings DeviceSettings, policy Policy) ([]Finding, int) {
+	var findings []Finding
+	passed, total := 0, 0
+	for _, rule := range Rules {
+		rulePolicy := policy.Rule(rule.ID)
+		if !rulePolicy.Enabled {
+			continue
+		}
+		status, detail := rule.check(settings, policy)
+		findings = append(findings, Finding{
+			Rule:     rule.ID,
+			Title:    rule.Title,
+			Severity: rulePolicy.Severity,
+			Status:   status,
+			Detail:   detail,
+		})
+
+		weight := rulePolicy.Severity.Weight()
+		switch status {
+		case StatusPassed:
+			passed += weight
+			total += weight
+		case StatusFailed:
+			total += weight
+		}
+	}
+
+	if total == 0 {
+		return findings, 100
+	}
+	return findings, passed * 100 / total
+}
+
+// Check reads the settings of a device and checks them against the policy.
+//
+// Parameters:
+//   - c:            The client of the device.
+//   - serialNumber: The serial number of the device, for the report.
+//   - policy:       The policy to check the device against.
+//
+// Returns:
+//   - Report: The outcome of the check; its Error is set if the settings of
+//     the device cannot be read.
+func Check(c *network.Client, serialNumber string, policy Policy) Report {
+	report := Report{SerialNumber: serialNumber, CheckedAt: time.Now()}
+	settings, err := ReadSettings(c, policy.ProbeDefaultPassword)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Findings, report.Score = Evaluate(settings, policy)
+	return report
+}
@@ -0,0 +1,187 @@
+package compliance
+
+import (
+	"testing"
+	"time"
+)
+
+// onlyPolicy returns a policy that enables the given rules at the given
+// severities, and disables every other rule.
+func onlyPolicy(severities map[string]Severity) Policy {
+	policy := DefaultPolicy()
+	for _, rule := range Rules {
+		severity, ok := severities[rule.ID]
+		if !ok {
+			severity = rule.Severity
+		}
+		policy.Rules[rule.ID] = RulePolicy{Enabled: ok, Severity: severity}
+	}
+	return policy
+}
+
+// params prefixes the names of the parameters with "root.".
+func params(values map[string]string) map[string]string {
+	prefixed := make(map[string]string, len(values))
+	for name, value := range values {
+		prefixed["root."+name] = value
+	}
+	return prefixed
+}
+
+func TestEvaluate(t *testing.T) {
+	hardened := params(map[string]string{
+		"HTTPS.Enabled":                      "yes",
+		"HTTPS.ConnectionPolicy.Admin":       "HTTPS",
+		"HTTPS.ConnectionPolicy.Operator":    "HTTPS",
+		"HTTPS.ConnectionPolicy.Viewer":      "HTTPS",
+		"System.BoaProtViewer":               "password",
+		"System.BoaProtOperator":             "password",
+		"Network.RTSP.ProtViewer":            "password",
+		"Network.FTP.Enabled":                "no",
+		"Network.SSH.Enabled":                "no",
+		"Network.Bonjour.Enabled":            "no",
+		"Network.UPnP.Enabled":               "no",
+		"Properties.Firmware.Version":        "11.11.73",
+		"Properties.Firmware.BuildDate":      time.Now().AddDate(0, -1, 0).Format("Jan 2 2006 15:04"),
+		"Network.Interface.I0.dot1x.Enabled": "no",
+		"SNMP.Enabled":                       "yes",
+		"SNMP.V1":                            "no",
+		"SNMP.V2c":                           "no",
+		"SNMP.V3":                            "yes",
+	})
+
+	tests := []struct {
+		name      string
+		settings  DeviceSettings
+		policy    Policy
+		want      map[string]Status
+		wantScore int
+	}{
+		{
+			name:      "hardened device with the default policy",
+			settings:  DeviceSettings{Params: hardened, PasswordProbed: true},
+			policy:    DefaultPolicy(),
+			want:      map[string]Status{"https-only": StatusPassed, "default-accounts": StatusPassed, "anonymous-viewing": StatusPassed, "ftp": StatusPassed, "ssh": StatusPassed, "bonjour": StatusPassed, "upnp": StatusPassed, "firmware-age": StatusPassed, "snmp-v1-v2": StatusPassed},
+			wantScore: 100,
+		},
+		{
+			name:      "no enabled rules",
+			settings:  DeviceSettings{Params: hardened},
+			policy:    onlyPolicy(nil),
+			want:      map[string]Status{},
+			wantScore: 100,
+		},
+		{
+			name:      "unknown findings are not scored",
+			settings:  DeviceSettings{Params: params(map[string]string{"Network.FTP.Enabled": "yes"})},
+			policy:    onlyPolicy(map[string]Severity{"ftp": SeverityMedium, "ssh": SeverityHigh}),
+			want:      map[string]Status{"ftp": StatusFailed, "ssh": StatusUnknown},
+			wantScore: 0,
+		},
+		{
+			name: "weighted by severity",
+			settings: DeviceSettings{Params: params(map[string]string{
+				"HTTPS.Enabled":           "no",
+				"Network.FTP.Enabled":     "no",
+				"Network.Bonjour.Enabled": "no",
+			})},
+			policy:    onlyPolicy(map[string]Severity{"https-only": SeverityHigh, "ftp": SeverityMedium, "bonjour": SeverityLow}),
+			want:      map[string]Status{"https-only": StatusFailed, "ftp": StatusPassed, "bonjour": StatusPassed},
+			wantScore: 50,
+		},
+		{
+			name: "severity of the policy",
+			settings: DeviceSettings{Params: params(map[string]string{
+				"HTTPS.Enabled":       "no",
+				"Network.FTP.Enabled": "no",
+			})},
+			policy:    onlyPolicy(map[string]Severity{"https-only": SeverityLow, "ftp": SeverityMedium}),
+			want:      map[string]Status{"https-only": StatusFailed, "ftp": StatusPassed},
+			wantScore: 66,
+		},
+		{
+			name: "HTTP allowed for viewers",
+			settings: DeviceSettings{Params: params(map[string]string{
+				"HTTPS.Enabled":                 "yes",
+				"HTTPS.ConnectionPolicy.Viewer": "HTTP",
+			})},
+			policy:    onlyPolicy(map[string]Severity{"https-only": SeverityHigh}),
+			want:      map[string]Status{"https-only": StatusFailed},
+			wantScore: 0,
+		},
+		{
+			name:      "unprovisioned device",
+			settings:  DeviceSettings{Params: hardened, Unprovisioned: true},
+			policy:    onlyPolicy(map[string]Severity{"default-accounts": SeverityHigh}),
+			want:      map[string]Status{"default-accounts": StatusFailed},
+			wantScore: 0,
+		},
+		{
+			name:      "factory default password",
+			settings:  DeviceSettings{Params: hardened, PasswordProbed: true, DefaultPassword: true},
+			policy:    onlyPolicy(map[string]Severity{"default-accounts": SeverityHigh}),
+			want:      map[string]Status{"default-accounts": StatusFailed},
+			wantScore: 0,
+		},
+		{
+			name:      "default password not tried",
+			settings:  DeviceSettings{Params: hardened},
+			policy:    onlyPolicy(map[string]Severity{"default-accounts": SeverityHigh}),
+			want:      map[string]Status{"default-accounts": StatusPassed},
+			wantScore: 100,
+		},
+		{
+			name:     "old firmware",
+			settings: DeviceSettings{Params: params(map[string]string{"Properties.Firmware.BuildDate": "Nov 21 2019 10:48"})},
+			policy: func() Policy {
+				policy := onlyPolicy(map[string]Severity{"firmware-age": SeverityMedium})
+				policy.MaxFirmwareAgeDays = 30
+				return policy
+			}(),
+			want:      map[string]Status{"firmware-age": StatusFailed},
+			wantScore: 0,
+		},
+		{
+			name:      "firmware build date not understood",
+			settings:  DeviceSettings{Params: params(map[string]string{"Properties.Firmware.BuildDate": "yesterday"})},
+			policy:    onlyPolicy(map[string]Severity{"firmware-age": SeverityMedium}),
+			want:      map[string]Status{"firmware-age": StatusUnknown},
+			wantScore: 100,
+		},
+		{
+			name: "SNMP v2c and anonymous viewing",
+			settings: DeviceSettings{Params: params(map[string]string{
+				"SNMP.Enabled":         "yes",
+				"SNMP.V2c":             "yes",
+				"System.BoaProtViewer": "anonymous",
+			})},
+			policy:    onlyPolicy(map[string]Severity{"snmp-v1-v2": SeverityMedium, "anonymous-viewing": SeverityHigh}),
+			want:      map[string]Status{"anonymous-viewing": StatusFailed, "snmp-v1-v2": StatusFailed},
+			wantScore: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, score := Evaluate(tt.settings, tt.policy)
+			if score != tt.wantScore {
+				t.Errorf("Evaluate() score = %d, want %d", score, tt.wantScore)
+			}
+			if len(findings) != len(tt.want) {
+				t.Errorf("Evaluate() returned %d findings, want %d: %+v", len(findings), len(tt.want), findings)
+			}
+			for _, finding := range findings {
+				want, ok := tt.want[finding.Rule]
+				if !ok {
+					t.Errorf("unexpected finding for rule %s", finding.Rule)
+					continue
+				}
+				if finding.Status != want {
+					t.Errorf("rule %s: status = %s (%s), want %s", finding.Rule, finding.Status, finding.Detail, want)
+				}
+				if finding.Severity != tt.policy.Rule(finding.Rule).Severity {
+					t.Errorf("rule %s: severity = %s, want the one of the policy", finding.Rule, finding.Severity)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package compliance
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Severity is how much a rule weighs in the score of a device.
+type Severity string
+
+const (
+	SeverityHigh   Severity = "high"
+	SeverityMedium Severity = "medium"
+	SeverityLow    Severity = "low"
+
+	// DefaultMaxFirmwareAgeDays is how old firmware may be by default
+	DefaultMaxFirmwareAgeDays = 365
+)
+
+// Severities lists the severities from the highest to the lowest.
+var Severities = []Severity{SeverityHigh, SeverityMedium, SeverityLow}
+
+// Weight returns the points a rule of the severity is worth.
+func (s Severity) Weight() int {
+	switch s {
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// Policy selects the rules devices are checked against, and how much each
+// of them weighs. Rules missing from the policy use their defaults, so rules
+// added by later versions of Neba are checked without editing the policy.
+type Policy struct {
+	Rules              map[string]RulePolicy `json:"rules"`
+	MaxFirmwareAgeDays int                   `json:"max_firmware_age_days"`
+
+	// ProbeDefaultPassword is whether checks try to log in with the factory
+	// default password. Off by default, since every try is a failed login on
+	// hardened devices.
+	ProbeDefaultPassword bool `json:"probe_default_password"`
+}
+
+// RulePolicy is the policy of a single rule.
+type RulePolicy struct {
+	Enabled  bool     `json:"enabled"`
+	Severity Severity `json:"severity"`
+}
+
+// DefaultPolicy returns the policy with every rule at its default.
+func DefaultPolicy() Policy {
+	return Policy{Rules: map[string]RulePolicy{}, MaxFirmwareAgeDays: DefaultMaxFirmwareAgeDays}
+}
+
+// Rule returns the policy of the rule with the given ID.
+//
+// Parameters:
+//   - id: The ID of the rule.
+//
+// Returns:
+//   - RulePolicy: The policy of the rule, or its default if the policy does
+//     not mention it.
+func (p Policy) Rule(id string) RulePolicy {
+	if rule, ok := p.Rules[id]; ok {
+		return rule
+	}
+	if rule, ok := RuleByID(id); ok {
+		return RulePolicy{Enabled: rule.Enabled, Severity: rule.Severity}
+	}
+	return RulePolicy{}
+}
+
+// Validate checks that the policy only mentions known rules and severities.
+//
+// Returns:
+//   - error: An error describing the first invalid value.
+func (p Policy) Validate() error {
+	for id, rule := range p.Rules {
+		if _, ok := RuleByID(id); !ok {
+			return fmt.Errorf("unknown rule %q", id)
+		}
+		if !slices.Contains(Severities, rule.Severity) {
+			return fmt.Errorf("rule %s: unknown severity %q", id, rule.Severity)
+		}
+	}
+	if p.MaxFirmwareAgeDays < 1 {
+		return fmt.Errorf("the maximum firmware age must be at least one day")
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package compliance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	"github.com/furkansuleymana/neba/network"
+)
+
+// Change is a parameter change that remedies a finding.
+type Change struct {
+	Param string // Name without the "root." prefix
+	From  string
+	To    string
+}
+
+// Remediation is how a failed finding of a device is remedied.
+type Remediation struct {
+	Rule    string
+	Title   string
+	Changes []Change // Empty if the finding must be remedied by hand
+	Note    string   // Steps to take by hand, or what is left unchanged and why
+}
+
+// Plan returns how to remedy the failed findings of a device. Only rules
+// that are enabled by the policy are considered.
+//
+// Parameters:
+//   - settings: The settings of the device.
+//   - policy:   The policy the device is checked against.
+//   - rules:    The IDs of the rules to remedy; empty for every rule.
+//
+// Returns:
+//   - []Remediation: The remediation per failed finding, in the order of Rules.
+func Plan(settings DeviceSettings, policy Policy, rules []string) []Remediation {
+	var plan []Remediation
+	for _, rule := range Rules {
+		if len(rules) > 0 && !slices.Contains(rules, rule.ID) {
+			continue
+		}
+		if !policy.Rule(rule.ID).Enabled {
+			continue
+		}
+		if status, _ := rule.check(settings, policy); status != StatusFailed {
+			continue
+		}
+
+		remediation := Remediation{Rule: rule.ID, Title: rule.Title, Note: rule.Hint}
+		if rule.remedy != nil {
+			remediation.Changes, remediation.Note = rule.remedy(settings)
+		}
+		plan = append(plan, remediation)
+	}
+	return plan
+}
+
+// Fingerprint identifies the changes of a plan, so that a plan computed
+// again can be compared with the plan that was previewed. Notes are left
+// out, since they change nothing on the device.
+//
+// Parameters:
+//   - plan: The plan returned by Plan.
+//
+// Returns:
+//   - string: The hex-encoded SHA-256 hash of the changes of the plan.
+func Fingerprint(plan []Remediation) string {
+	hash := sha256.New()
+	for _, remediation := range plan {
+		for _, change := range remediation.Changes {
+			hash.Write([]byte(remediation.Rule + "\x00" + change.Param + "\x00" + change.From + "\x00" + change.To + "\n"))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Apply makes the changes of the plan on the device in a single request.
+//
+// Parameters:
+//   - c:    The client of the device.
+//   - plan: The plan returned by Plan.
+//
+// Returns:
+//   - int:   The number of changed parameters.
+//   - error: An error if the device rejects the changes.
+func Apply(c *network.Client, plan []Remediation) (int, error) {
+	params := map[string]string{}
+	for _, remediation := range plan {
+		for _, change := range remediation.Changes {
+			params[change.Param] = change.To
+		}
+	}
+	if err := network.SetParams(c, params); err != nil {
+		return 0, err
+	}
+	return len(params), nil
+}
+
+// changes returns the changes needed to set the given parameters, skipping
+// those the device does not have or that are set already.
+func (s DeviceSettings) changes(params map[string]string) []Change {
+	var changes []Change
+	for param, to := range params {
+		if from, ok := s.Param(param); ok && !strings.EqualFold(from, to) {
+			changes = append(changes, Change{Param: param, From: from, To: to})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(a.Param, b.Param) })
+	return changes
+}
+
+func remedyHTTPS(s DeviceSettings) ([]Change, string) {
+	params := map[string]string{"HTTPS.Enabled": "yes"}
+	note := ""
+	for _, role := range connectionPolicyRoles {
+		// Neba signs in as an administrator, and would lock itself out.
+		if role == "Admin" && !s.HTTPS {
+			if policy, ok := s.Param("HTTPS.ConnectionPolicy.Admin"); ok && !strings.EqualFold(policy, "HTTPS") {
+				note = "Administrators may still use HTTP, since Neba connects to the device over HTTP. " +
+					"Change the address of the device to an https:// URL to require HTTPS for them, too."
+			}
+			continue
+		}
+		params["HTTPS.ConnectionPolicy."+role] = "HTTPS"
+	}
+	return s.changes(params), note
+}
+
+func remedyAnonymousViewing(s DeviceSettings) ([]Change, string) {
+	params := map[string]string{}
+	for _, protection := range loginProtections {
+		if value, ok := s.Param(protection.param); ok && strings.EqualFold(value, "anonymous") {
+			params[protection.param] = "password"
+		}
+	}
+	return s.changes(params), ""
+}
+
+func remedySNMP(s DeviceSettings) ([]Change, string) {
+	params := map[string]string{}
+	for _, version := range snmpVersions {
+		params["SNMP."+version] = "no"
+	}
+	note := ""
+	if v3, _ := s.Param("SNMP.V3"); !isYes(v3) {
+		note = "SNMP v3 is not enabled, so SNMP stops working until it is configured."
+	}
+	return s.changes(params), note
+}
@@ -0,0 +1,152 @@
+package compliance
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    DeviceSettings
+		policy      Policy
+		rules       []string
+		wantRules   []string
+		wantChanges map[string][]Change
+		wantNote    map[string]bool // Whether the remediation of the rule has a note
+	}{
+		{
+			name: "HTTPS over HTTP keeps HTTP for administrators",
+			settings: DeviceSettings{Params: params(map[string]string{
+				"HTTPS.Enabled":                   "no",
+				"HTTPS.ConnectionPolicy.Admin":    "HTTP",
+				"HTTPS.ConnectionPolicy.Operator": "HTTP",
+				"HTTPS.ConnectionPolicy.Viewer":   "HTTPS",
+			})},
+			policy:    onlyPolicy(map[string]Severity{"https-only": SeverityHigh}),
+			wantRules: []string{"https-only"},
+			wantChanges: map[string][]Change{"https-only": {
+				{Param: "HTTPS.ConnectionPolicy.Operator", From: "HTTP", To: "HTTPS"},
+				{Param: "HTTPS.Enabled", From: "no", To: "yes"},
+			}},
+			wantNote: map[string]bool{"https-only": true},
+		},
+		{
+			name: "HTTPS over HTTPS includes administrators",
+			settings: DeviceSettings{HTTPS: true, Params: params(map[string]string{
+				"HTTPS.Enabled":                "yes",
+				"HTTPS.ConnectionPolicy.Admin": "HTTP",
+			})},
+			policy:    onlyPolicy(map[string]Severity{"https-only": SeverityHigh}),
+			wantRules: []string{"https-only"},
+			wantChanges: map[string][]Change{"https-only": {
+				{Param: "HTTPS.ConnectionPolicy.Admin", From: "HTTP", To: "HTTPS"},
+			}},
+		},
+		{
+			name: "only the selected rules",
+			settings: DeviceSettings{Params: params(map[string]string{
+				"Network.FTP.Enabled": "yes",
+				"Network.SSH.Enabled": "yes",
+			})},
+			policy:      onlyPolicy(map[string]Severity{"ftp": SeverityMedium, "ssh": SeverityMedium}),
+			rules:       []string{"ssh"},
+			wantRules:   []string{"ssh"},
+			wantChanges: map[string][]Change{"ssh": {{Param: "Network.SSH.Enabled", From: "yes", To: "no"}}},
+		},
+		{
+			name:      "disabled rules are left out",
+			settings:  DeviceSettings{Params: params(map[string]string{"Network.FTP.Enabled": "yes"})},
+			policy:    onlyPolicy(map[string]Severity{"ssh": SeverityMedium}),
+			wantRules: nil,
+		},
+		{
+			name:      "rules without a remedy have a hint",
+			settings:  DeviceSettings{Unprovisioned: true},
+			policy:    onlyPolicy(map[string]Severity{"default-accounts": SeverityHigh}),
+			wantRules: []string{"default-accounts"},
+			wantNote:  map[string]bool{"default-accounts": true},
+		},
+		{
+			name: "SNMP without v3",
+			settings: DeviceSettings{Params: params(map[string]string{
+				"SNMP.Enabled": "yes",
+				"SNMP.V1":      "yes",
+				"SNMP.V2c":     "no",
+				"SNMP.V3":      "no",
+			})},
+			policy:      onlyPolicy(map[string]Severity{"snmp-v1-v2": SeverityMedium}),
+			wantRules:   []string{"snmp-v1-v2"},
+			wantChanges: map[string][]Change{"snmp-v1-v2": {{Param: "SNMP.V1", From: "yes", To: "no"}}},
+			wantNote:    map[string]bool{"snmp-v1-v2": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := Plan(tt.settings, tt.policy, tt.rules)
+			var rules []string
+			for _, remediation := range plan {
+				rules = append(rules, remediation.Rule)
+				if !slices.Equal(remediation.Changes, tt.wantChanges[remediation.Rule]) {
+					t.Errorf("rule %s: changes = %+v, want %+v", remediation.Rule, remediation.Changes, tt.wantChanges[remediation.Rule])
+				}
+				if (remediation.Note != "") != tt.wantNote[remediation.Rule] {
+					t.Errorf("rule %s: note = %q, want a note %v", remediation.Rule, remediation.Note, tt.wantNote[remediation.Rule])
+				}
+			}
+			if !slices.Equal(rules, tt.wantRules) {
+				t.Errorf("Plan() rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	plan := []Remediation{{
+		Rule:    "ftp",
+		Changes: []Change{{Param: "Network.FTP.Enabled", From: "yes", To: "no"}},
+	}}
+
+	tests := []struct {
+		name      string
+		other     []Remediation
+		wantEqual bool
+	}{
+		{
+			name:      "same changes",
+			other:     []Remediation{{Rule: "ftp", Changes: []Change{{Param: "Network.FTP.Enabled", From: "yes", To: "no"}}}},
+			wantEqual: true,
+		},
+		{
+			name:      "notes are ignored",
+			other:     []Remediation{{Rule: "ftp", Note: "changed", Changes: []Change{{Param: "Network.FTP.Enabled", From: "yes", To: "no"}}}},
+			wantEqual: true,
+		},
+		{
+			name:  "current value changed",
+			other: []Remediation{{Rule: "ftp", Changes: []Change{{Param: "Network.FTP.Enabled", From: "on", To: "no"}}}},
+		},
+		{
+			name: "additional change",
+			other: []Remediation{
+				{Rule: "ftp", Changes: []Change{{Param: "Network.FTP.Enabled", From: "yes", To: "no"}}},
+				{Rule: "ssh", Changes: []Change{{Param: "Network.SSH.Enabled", From: "yes", To: "no"}}},
+			},
+		},
+		{
+			name:  "nothing to change",
+			other: nil,
+		},
+		{
+			name:  "values not merged across fields",
+			other: []Remediation{{Rule: "ftp", Changes: []Change{{Param: "Network.FTP.Enabled", From: "yesno", To: ""}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if equal := Fingerprint(tt.other) == Fingerprint(plan); equal != tt.wantEqual {
+				t.Errorf("Fingerprint() equal = %v, want %v", equal, tt.wantEqual)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package compliance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of checking a device against a rule.
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusUnknown Status = "unknown" // The device does not report the setting; not scored
+)
+
+// Rule is a recommendation of the Axis hardening guide that Neba can verify.
+type Rule struct {
+	ID          string
+	Title       string
+	Description string
+	Severity    Severity // Default severity
+	Enabled     bool     // Whether the rule is checked by default
+	Hint        string   // How to remedy a failed finding by hand, for rules without a remedy
+
+	check  func(s DeviceSettings, p Policy) (Status, string)
+	remedy func(s DeviceSettings) ([]Change, string)
+}
+
+// Remediable reports whether Neba can remedy failed findings of the rule by
+// changing parameters.
+func (r Rule) Remediable() bool {
+	return r.remedy != nil
+}
+
+// RuleByID returns the rule with the given ID.
+func RuleByID(id string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Rules lists every rule Neba checks, in the order they are shown.
+var Rules = []Rule{
+	{
+		ID:          "https-only",
+		Title:       "HTTPS enforced",
+		Description: "HTTPS is enabled and required for administrators, operators, and viewers.",
+		Severity:    SeverityHigh,
+		Enabled:     true,
+		check:       checkHTTPS,
+		remedy:      remedyHTTPS,
+	},
+	{
+		ID:          "default-accounts",
+		Title:       "No default accounts",
+		Description: "The device has an administrator password, and root does not accept the factory default password.",
+		Severity:    SeverityHigh,
+		Enabled:     true,
+		Hint:        "Set an administrator password on the Provision Devices page, or rotate the passwords on the Device Accounts page.",
+		check:       checkDefaultAccounts,
+	},
+	{
+		ID:          "anonymous-viewing",
+		Title:       "No anonymous viewing",
+		Description: "Video and settings require a login over HTTP and RTSP.",
+		Severity:    SeverityHigh,
+		Enabled:     true,
+		check:       checkAnonymousViewing,
+		remedy:      remedyAnonymousViewing,
+	},
+	serviceRule("ftp", "FTP disabled", "Network.FTP.Enabled", SeverityMedium),
+	serviceRule("ssh", "SSH disabled", "Network.SSH.Enabled", SeverityMedium),
+	serviceRule("bonjour", "Bonjour disabled", "Network.Bonjour.Enabled", SeverityLow),
+	serviceRule("upnp", "UPnP disabled", "Network.UPnP.Enabled", SeverityLow),
+	{
+		ID:          "firmware-age",
+		Title:       "Recent firmware",
+		Description: "The firmware is not older than the maximum firmware age of the policy.",
+		Severity:    SeverityMedium,
+		Enabled:     true,
+		Hint:        "Upgrade the firmware of the device.",
+		check:       checkFirmwareAge,
+	},
+	{
+		ID:          "dot1x",
+		Title:       "802.1X enabled",
+		Description: "The device authenticates to the network with IEEE 802.1X. Enable this rule on networks that use port-based access control.",
+		Severity:    SeverityMedium,
+		Enabled:     false,
+		Hint:        "Configure 802.1X with the certificates and credentials of your network.",
+		check:       checkDot1X,
+	},
+	{
+		ID:          "snmp-v1-v2",
+		Title:       "No SNMP v1 or v2c",
+		Description: "SNMP is disabled, or only SNMP v3 is enabled, since earlier versions send community strings in plain text.",
+		Severity:    SeverityMedium,
+		Enabled:     true,
+		check:       checkSNMP,
+		remedy:      remedySNMP,
+	},
+}
+
+// serviceRule returns a rule that requires the service with the given
+// enabled parameter to be off.
+func serviceRule(id, title, param string, severity Severity) Rule {
+	service, _, _ := strings.Cut(title, " ")
+	return Rule{
+		ID:          id,
+		Title:       title,
+		Description: fmt.Sprintf("%s is disabled unless it is in use, to reduce the attack surface.", service),
+		Severity:    severity,
+		Enabled:     true,
+		check: func(s DeviceSettings, p Policy) (Status, string) {
+			enabled, ok := s.Param(param)
+			switch {
+			case !ok:
+				return StatusUnknown, fmt.Sprintf("The device does not report whether %s is enabled.", service)
+			case isYes(enabled):
+				return StatusFailed, fmt.Sprintf("%s is enabled.", service)
+			default:
+				return StatusPassed, ""
+			}
+		},
+		remedy: func(s DeviceSettings) ([]Change, string) {
+			return s.changes(map[string]string{param: "no"}), ""
+		},
+	}
+}
+
+// connectionPolicyRoles are the roles of the HTTPS connection policy
+var connectionPolicyRoles = []string{"Admin", "Operator", "Viewer"}
+
+func checkHTTPS(s DeviceSettings, p Policy) (Status, string) {
+	enabled, ok := s.Param("HTTPS.Enabled")
+	if !ok {
+		return StatusUnknown, "The device does not report its HTTPS settings."
+	}
+	if !isYes(enabled) {
+		return StatusFailed, "HTTPS is disabled."
+	}
+
+	var allowed []string
+	for _, role := range connectionPolicyRoles {
+		if policy, ok := s.Param("HTTPS.ConnectionPolicy." + role); ok && !strings.EqualFold(policy, "HTTPS") {
+			allowed = append(allowed, strings.ToLower(role))
+		}
+	}
+	if len(allowed) > 0 {
+		return StatusFailed, fmt.Sprintf("HTTP is allowed for %s.", strings.Join(allowed, ", "))
+	}
+	return StatusPassed, ""
+}
+
+func checkDefaultAccounts(s DeviceSettings, p Policy) (Status, string) {
+	switch {
+	case s.Unprovisioned:
+		return StatusFailed, "The device has no administrator password."
+	case s.DefaultPassword:
+		return StatusFailed, fmt.Sprintf("%s accepts the factory default password.", DefaultUsername)
+	case !s.PasswordProbed:
+		return StatusPassed, "The device has an administrator password; the factory default password was not tried."
+	default:
+		return StatusPassed, ""
+	}
+}
+
+// loginProtections are the parameters that allow anonymous access if set to
+// "anonymous", and what they allow
+var loginProtections = []struct{ param, name string }{
+	{"System.BoaProtViewer", "viewing over HTTP"},
+	{"System.BoaProtOperator", "operating over HTTP"},
+	{"Network.RTSP.ProtViewer", "viewing over RTSP"},
+}
+
+// snmpVersions are the SNMP versions that send community strings in plain
+// text
+var snmpVersions = []string{"V1", "V2c"}
+
+func checkAnonymousViewing(s DeviceSettings, p Policy) (Status, string) {
+	var anonymous []string
+	known := false
+	for _, protection := range loginProtections {
+		value, ok := s.Param(protection.param)
+		known = known || ok
+		if ok && strings.EqualFold(value, "anonymous") {
+			anonymous = append(anonymous, protection.name)
+		}
+	}
+	switch {
+	case !known:
+		return StatusUnknown, "The device does not report its login requirements."
+	case len(anonymous) > 0:
+		return StatusFailed, fmt.Sprintf("Anonymous %s is allowed.", strings.Join(anonymous, ", "))
+	default:
+		return StatusPassed, ""
+	}
+}
+
+func checkFirmwareAge(s DeviceSettings, p Policy) (Status, string) {
+	version, _ := s.Param("Properties.Firmware.Version")
+	value, ok := s.Param("Properties.Firmware.BuildDate")
+	if !ok {
+		return StatusUnknown, "The device does not report the build date of its firmware."
+	}
+	built, err := parseBuildDate(value)
+	if err != nil {
+		return StatusUnknown, fmt.Sprintf("The build date %q of the firmware is not understood.", value)
+	}
+
+	age := int(time.Since(built).Hours() / 24)
+	if age > p.MaxFirmwareAgeDays {
+		return StatusFailed, fmt.Sprintf("Firmware %s was built %d days ago, on %s.", version, age, built.Format(time.DateOnly))
+	}
+	return StatusPassed, fmt.Sprintf("Firmware %s, built on %s.", version, built.Format(time.DateOnly))
+}
+
+func checkDot1X(s DeviceSettings, p Policy) (Status, string) {
+	enabled, ok := s.Param("Network.Interface.I0.dot1x.Enabled")
+	switch {
+	case !ok:
+		return StatusUnknown, "The device does not report its 802.1X settings."
+	case !isYes(enabled):
+		return StatusFailed, "802.1X is disabled."
+	default:
+		return StatusPassed, ""
+	}
+}
+
+func checkSNMP(s DeviceSettings, p Policy) (Status, string) {
+	enabled, ok := s.Param("SNMP.Enabled")
+	if !ok {
+		return StatusUnknown, "The device does not report its SNMP settings."
+	}
+	if !isYes(enabled) {
+		return StatusPassed, ""
+	}
+
+	var versions []string
+	for _, version := range snmpVersions {
+		if value, ok := s.Param("SNMP." + version); ok && isYes(value) {
+			versions = append(versions, strings.ToLower(version))
+		}
+	}
+	if len(versions) > 0 {
+		return StatusFailed, fmt.Sprintf("SNMP %s is enabled.", strings.Join(versions, " and "))
+	}
+	return StatusPassed, ""
+}
+
+// buildDateLayouts are the formats of firmware build dates, such as
+// "Nov 21 2023 10:48".
+var buildDateLayouts = []string{"Jan 2 2006 15:04", "Jan 2 2006", time.DateOnly}
+
+func parseBuildDate(value string) (time.Time, error) {
+	value = strings.Join(strings.Fields(value), " ")
+	for _, layout := range buildDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown build date format: %s", value)
+}
+
+// isYes reports whether a boolean parameter is set.
+func isYes(value string) bool {
+	switch strings.ToLower(value) {
+	case "yes", "true", "1", "on":
+		return true
+	}
+	return false
+}
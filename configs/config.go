@@ -1,9 +1,12 @@
 package configs
 
 import (
+	"bytes"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -21,48 +24,137 @@ const (
 // AppConfig holds the application's configuration settings as defined in the
 // "configFileName" file. This includes settings such as the server address and
 // port. It does not contain any device-specific configurations like
-// IP addresses or serial numbers.
+// IP addresses or serial numbers. Relative paths are resolved against the
+// directory of the configuration file.
 type AppConfig struct {
-	Server struct {
+	Version int `json:"version"` // See CurrentVersion
+	Server  struct {
 		HTTP struct {
-			Address string `json:"address"`
-			Port    string `json:"port"`
+			Address   string   `json:"address"`             // Empty to listen on all interfaces
+			Addresses []string `json:"addresses,omitempty"` // Additional addresses to listen on
+			Port      string   `json:"port"`
 		} `json:"http"`
+		HTTPS struct {
+			Enabled      bool     `json:"enabled"`
+			Address      string   `json:"address"`
+			Addresses    []string `json:"addresses,omitempty"`
+			Port         string   `json:"port"`
+			CertFile     string   `json:"cert_file"` // Empty to use a self-signed certificate
+			KeyFile      string   `json:"key_file"`
+			RedirectHTTP bool     `json:"redirect_http"`
+		} `json:"https"`
 	} `json:"server"`
 	Database struct {
 		Path string `json:"path"`
 	} `json:"database"`
+	Discovery struct {
+		TimeoutSeconds int    `json:"timeout_seconds"` // How long to wait for SSDP responses
+		LocalAddress   string `json:"local_address"`   // Empty to search on the default interface
+	} `json:"discovery"`
+	Polling struct {
+		SessionPurge  Duration `json:"session_purge"`   // Interval of removing expired sessions
+		ClockCheck    Duration `json:"clock_check"`     // Interval of comparing device clocks to the host's
+		MaxClockDrift Duration `json:"max_clock_drift"` // How far a device clock may be off before it is flagged
+	} `json:"polling"`
+	Backup struct {
+		Enabled  bool     `json:"enabled"`  // Whether to back up the database on a schedule
+		Interval Duration `json:"interval"` // Time between two scheduled backups
+		Dir      string   `json:"dir"`      // Directory of the backup files
+		Keep     int      `json:"keep"`     // Number of scheduled backups to keep; 0 keeps all
+	} `json:"backup"`
+	Certificates struct {
+		Scan     Duration `json:"scan"`      // Interval of reading the certificates of all devices
+		WarnDays int      `json:"warn_days"` // How many days before expiry a certificate is flagged
+		// Certificates issued by Neba's own CA
+		ValidityDays int  `json:"validity_days"` // How long issued certificates are valid for
+		AutoRenew    bool `json:"auto_renew"`    // Whether to replace issued certificates before they expire
+		RenewDays    int  `json:"renew_days"`    // How many days before expiry issued certificates are replaced
+	} `json:"certificates"`
+	Snapshots struct {
+		TTL         Duration `json:"ttl"`         // How long a snapshot is served from the cache
+		Resolution  string   `json:"resolution"`  // Resolution of thumbnails, such as "320x180"
+		Compression int      `json:"compression"` // JPEG compression of thumbnails, from 0 to 100
+	} `json:"snapshots"`
+	LiveView struct {
+		MaxStreams          int      `json:"max_streams"`            // Streams Neba proxies at once
+		MaxStreamsPerDevice int      `json:"max_streams_per_device"` // Streams of a single device at once
+		MaxDuration         Duration `json:"max_duration"`           // How long a stream runs before it is stopped
+		Resolution          string   `json:"resolution"`             // Default resolution, such as "640x360"
+		FPS                 int      `json:"fps"`                    // Default frame rate
+		Compression         int      `json:"compression"`            // Default JPEG compression, from 0 to 100
+	} `json:"live_view"`
+	Events struct {
+		Enabled       bool     `json:"enabled"`        // Subscribe to the events of managed devices
+		TopicFilters  []string `json:"topic_filters"`  // Event topics to subscribe to
+		RetentionDays int      `json:"retention_days"` // How long events are kept
+	} `json:"events"`
 }
 
 // CManager is a struct that manages the configuration of the application.
 // It includes a mutex for read/write locking, a path to the configuration file,
-// and the application configuration itself.
+// the configuration as stored in the file, and the effective configuration
+// with relative paths resolved and environment overrides applied.
 type CManager struct {
-	mutex  sync.RWMutex
-	path   string
-	config AppConfig
+	mutex       sync.RWMutex
+	path        string
+	file        AppConfig
+	config      AppConfig
+	stamp       fileStamp
+	subscribers []func(old, new AppConfig)
+	pending     []configChange // Changes not yet passed to the subscribers
+	delivering  bool           // Whether a goroutine is passing on changes
 }
 
-// ConfigManager initializes and returns a new configuration manager.
-// It retrieves the executable path, constructs the configuration
-// file path, ensures the configuration file exists, and loads the configuration.
-// If any step fails, an error is returned.
+// ConfigManager initializes and returns a new configuration manager for the
+// configuration file at the default location, as determined by DefaultPath.
 //
 // Returns:
 //   - *CManager: A pointer to the initialized configuration manager.
 //   - error: An error if any step in the initialization process fails.
 func ConfigManager() (*CManager, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// ConfigManagerIn is like ConfigManager, but keeps the configuration file in
+// the given directory instead of next to the executable. It is used to run
+// Neba as a service with its data outside of the binary's folder.
+//
+// Parameters:
+//   - dir: The directory of the configuration file.
+//
+// Returns:
+//   - *CManager: A pointer to the initialized configuration manager.
+//   - error: An error if any step in the initialization process fails.
+func ConfigManagerIn(dir string) (*CManager, error) {
+	return Load(filepath.Join(dir, configFileName))
+}
+
+// Load initializes and returns a new configuration manager for the given
+// configuration file. The file is created with the default configuration if
+// it does not exist, and migrated if it was written by an older version of
+// Neba. Environment overrides are applied, and the result is validated.
+//
+// Parameters:
+//   - path: The path of the configuration file.
+//
+// Returns:
+//   - *CManager: A pointer to the initialized configuration manager.
+//   - error: An error if any step in the initialization process fails, or if
+//     the configuration is invalid.
+func Load(path string) (*CManager, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return nil, fmt.Errorf("get working directory: %w", err)
 	}
 
-	exePath, err := os.Executable() // TODO: Not safe for symlinks
+	configPath, err := filepath.Abs(path)
 	if err != nil {
-		panic(fmt.Errorf("cannot determine executable path: %w", err))
-	} // TODO: Maybe don't panic here?
-
-	configPath := filepath.Join(filepath.Dir(exePath), configFileName)
+		return nil, fmt.Errorf("resolve config path: %w", err)
+	}
 
 	cm := &CManager{path: configPath}
 
@@ -74,6 +166,16 @@ func ConfigManager() (*CManager, error) {
 		return nil, err
 	}
 
+	if err := cm.migrate(); err != nil {
+		return nil, err
+	}
+
+	config, err := cm.effective(cm.file)
+	if err != nil {
+		return nil, err
+	}
+	cm.config = config
+
 	slog.Debug("created config manager",
 		slog.String("cwd", cwd),
 		slog.String("path", configPath))
@@ -93,24 +195,108 @@ func (cm *CManager) Get() AppConfig {
 	return cm.config
 }
 
-// Update applies the provided update function to the AppConfig instance
-// managed by the CManager. It ensures that the update is performed in a
-// thread-safe manner by acquiring a lock before applying the update and
-// releasing it afterward. After the update function is executed, the
-// updated configuration is saved.
+// File retrieves the configuration as it is stored in the configuration file,
+// without relative paths resolved or environment overrides applied. It is
+// the configuration to edit with Update.
+//
+// Returns:
+//   - AppConfig: The configuration as stored in the file.
+func (cm *CManager) File() AppConfig {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.file.clone()
+}
+
+// Path returns the path of the configuration file.
+//
+// Returns:
+//   - string: The absolute path of the configuration file.
+func (cm *CManager) Path() string {
+	return cm.path
+}
+
+// Dir returns the directory that contains the configuration file. Files that
+// Neba generates on its own, such as the self-signed certificate, are stored
+// there.
+//
+// Returns:
+//   - string: The directory of the configuration file.
+func (cm *CManager) Dir() string {
+	return filepath.Dir(cm.path)
+}
+
+// Update applies the provided update function to the configuration as stored
+// in the file. It ensures that the update is performed in a thread-safe manner
+// by acquiring a lock before applying the update and releasing it afterward.
+// The updated configuration is validated before it is saved; if it is invalid,
+// nothing changes. Environment overrides stay in effect and are never written
+// to the file.
 //
 // Parameters:
 //   - updateFunc: A function that takes a pointer to an AppConfig instance
 //     and performs the desired updates.
 //
 // Returns:
-//   - error: An error if the save operation fails, otherwise nil.
+//   - error: An error if the updated configuration is invalid or the save
+//     operation fails, otherwise nil.
 func (cm *CManager) Update(updateFunc func(*AppConfig)) error {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
-	updateFunc(&cm.config)
-	return cm.save()
+	file := cm.file.clone()
+	updateFunc(&file)
+	file.Version = CurrentVersion
+
+	config, err := cm.effective(file)
+	if err != nil {
+		return err
+	}
+
+	if err := cm.save(file); err != nil {
+		return err
+	}
+	old := cm.config
+	cm.file = file
+	cm.config = config
+
+	cm.notify(old, config)
+	return nil
+}
+
+// effective returns the configuration that Neba runs with for the given
+// configuration as stored in the file: relative paths are resolved against the
+// directory of the configuration file, environment overrides are applied, and
+// the result is validated.
+func (cm *CManager) effective(file AppConfig) (AppConfig, error) {
+	config := file.clone()
+	config.Database.Path = cm.resolve(config.Database.Path)
+	config.Backup.Dir = cm.resolve(config.Backup.Dir)
+	config.Server.HTTPS.CertFile = cm.resolve(config.Server.HTTPS.CertFile)
+	config.Server.HTTPS.KeyFile = cm.resolve(config.Server.HTTPS.KeyFile)
+
+	envErr := applyEnv(&config, os.LookupEnv)
+	if err := errors.Join(envErr, config.Validate()); err != nil {
+		return AppConfig{}, fmt.Errorf("invalid configuration in %s:\n%w", cm.path, err)
+	}
+	return config, nil
+}
+
+// resolve returns the given path relative to the directory of the
+// configuration file, unless it is empty or absolute.
+func (cm *CManager) resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(cm.Dir(), path)
+}
+
+// clone returns a copy of the configuration that shares no slices with the
+// original.
+func (c AppConfig) clone() AppConfig {
+	c.Server.HTTP.Addresses = append([]string(nil), c.Server.HTTP.Addresses...)
+	c.Server.HTTPS.Addresses = append([]string(nil), c.Server.HTTPS.Addresses...)
+	c.Events.TopicFilters = append([]string(nil), c.Events.TopicFilters...)
+	return c
 }
 
 // ensureConfigFile ensures that the configuration file exists at the
@@ -142,8 +328,9 @@ func (cm *CManager) ensureConfigFile() error {
 }
 
 // load reads the configuration file from the specified path and unmarshals its content
-// into the CManager's config field. It returns an error if the file cannot be read or
-// if the content cannot be parsed as JSON.
+// into the CManager's file field. It returns an error if the file cannot be read, if
+// the content cannot be parsed as JSON, or if it contains unknown fields, which are
+// most likely misspelled.
 //
 // Returns:
 //   - error: An error if there is an issue reading the file or parsing its content.
@@ -153,22 +340,43 @@ func (cm *CManager) load() error {
 		return fmt.Errorf("read config file: %w", err)
 	}
 
-	if err := json.Unmarshal(data, &cm.config); err != nil {
-		return fmt.Errorf("parse config file: %w", err)
+	// Check the version first, since a newer file likely has fields this
+	// version of Neba does not know about.
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err == nil {
+		if err := cm.checkVersion(header.Version); err != nil {
+			return err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cm.file); err != nil {
+		return fmt.Errorf("parse config file %s: %w", cm.path, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("parse config file %s: unexpected data after the configuration", cm.path)
 	}
 
+	cm.stamp = stampOf(cm.path)
+
 	slog.Debug("loaded config",
 		slog.String("path", cm.path))
 	return nil
 }
 
-// save serializes the CManager's configuration to JSON format and writes it to a file.
-// It returns an error if the serialization or file writing fails.
+// save serializes the given configuration to JSON format and writes it to the
+// configuration file. It returns an error if the serialization or file writing fails.
+//
+// Parameters:
+//   - config: The configuration as it should be stored in the file.
 //
 // Returns:
 //   - error: An error if the serialization or file writing fails.
-func (cm *CManager) save() error {
-	data, err := json.MarshalIndent(cm.config, "", "  ")
+func (cm *CManager) save(config AppConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
@@ -177,6 +385,8 @@ func (cm *CManager) save() error {
 		return fmt.Errorf("write config file: %w", err)
 	}
 
+	cm.stamp = stampOf(cm.path)
+
 	slog.Debug("saved config",
 		slog.String("path", cm.path))
 	return nil
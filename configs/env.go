@@ -0,0 +1,151 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envOverride is a configuration value that can be overridden by an
+// environment variable.
+type envOverride struct {
+	name  string
+	apply func(config *AppConfig, value string) error
+}
+
+// envOverrides lists the environment variables that override values of the
+// configuration file: NEBA_HTTP_* and NEBA_HTTPS_* for the server, and
+// NEBA_<SECTION>_<FIELD> after the JSON names for every other setting.
+// Overrides only apply to the running process; they are never written to the
+// file. Lists are comma-separated.
+var envOverrides = []envOverride{
+	{"NEBA_HTTP_ADDRESS", setString(func(c *AppConfig) *string { return &c.Server.HTTP.Address })},
+	{"NEBA_HTTP_ADDRESSES", setList(func(c *AppConfig) *[]string { return &c.Server.HTTP.Addresses })},
+	{"NEBA_HTTP_PORT", setString(func(c *AppConfig) *string { return &c.Server.HTTP.Port })},
+	{"NEBA_HTTPS_ENABLED", setBool(func(c *AppConfig) *bool { return &c.Server.HTTPS.Enabled })},
+	{"NEBA_HTTPS_ADDRESS", setString(func(c *AppConfig) *string { return &c.Server.HTTPS.Address })},
+	{"NEBA_HTTPS_ADDRESSES", setList(func(c *AppConfig) *[]string { return &c.Server.HTTPS.Addresses })},
+	{"NEBA_HTTPS_PORT", setString(func(c *AppConfig) *string { return &c.Server.HTTPS.Port })},
+	{"NEBA_HTTPS_CERT_FILE", setString(func(c *AppConfig) *string { return &c.Server.HTTPS.CertFile })},
+	{"NEBA_HTTPS_KEY_FILE", setString(func(c *AppConfig) *string { return &c.Server.HTTPS.KeyFile })},
+	{"NEBA_HTTPS_REDIRECT_HTTP", setBool(func(c *AppConfig) *bool { return &c.Server.HTTPS.RedirectHTTP })},
+	{"NEBA_DATABASE_PATH", setString(func(c *AppConfig) *string { return &c.Database.Path })},
+	{"NEBA_DISCOVERY_TIMEOUT_SECONDS", setInt(func(c *AppConfig) *int { return &c.Discovery.TimeoutSeconds })},
+	{"NEBA_DISCOVERY_LOCAL_ADDRESS", setString(func(c *AppConfig) *string { return &c.Discovery.LocalAddress })},
+	{"NEBA_POLLING_SESSION_PURGE", setDuration(func(c *AppConfig) *Duration { return &c.Polling.SessionPurge })},
+	{"NEBA_POLLING_CLOCK_CHECK", setDuration(func(c *AppConfig) *Duration { return &c.Polling.ClockCheck })},
+	{"NEBA_POLLING_MAX_CLOCK_DRIFT", setDuration(func(c *AppConfig) *Duration { return &c.Polling.MaxClockDrift })},
+	{"NEBA_BACKUP_ENABLED", setBool(func(c *AppConfig) *bool { return &c.Backup.Enabled })},
+	{"NEBA_BACKUP_INTERVAL", setDuration(func(c *AppConfig) *Duration { return &c.Backup.Interval })},
+	{"NEBA_BACKUP_DIR", setString(func(c *AppConfig) *string { return &c.Backup.Dir })},
+	{"NEBA_BACKUP_KEEP", setInt(func(c *AppConfig) *int { return &c.Backup.Keep })},
+	{"NEBA_CERTIFICATES_SCAN", setDuration(func(c *AppConfig) *Duration { return &c.Certificates.Scan })},
+	{"NEBA_CERTIFICATES_WARN_DAYS", setInt(func(c *AppConfig) *int { return &c.Certificates.WarnDays })},
+	{"NEBA_CERTIFICATES_VALIDITY_DAYS", setInt(func(c *AppConfig) *int { return &c.Certificates.ValidityDays })},
+	{"NEBA_CERTIFICATES_AUTO_RENEW", setBool(func(c *AppConfig) *bool { return &c.Certificates.AutoRenew })},
+	{"NEBA_CERTIFICATES_RENEW_DAYS", setInt(func(c *AppConfig) *int { return &c.Certificates.RenewDays })},
+	{"NEBA_SNAPSHOTS_TTL", setDuration(func(c *AppConfig) *Duration { return &c.Snapshots.TTL })},
+	{"NEBA_SNAPSHOTS_RESOLUTION", setString(func(c *AppConfig) *string { return &c.Snapshots.Resolution })},
+	{"NEBA_SNAPSHOTS_COMPRESSION", setInt(func(c *AppConfig) *int { return &c.Snapshots.Compression })},
+	{"NEBA_LIVE_VIEW_MAX_STREAMS", setInt(func(c *AppConfig) *int { return &c.LiveView.MaxStreams })},
+	{"NEBA_LIVE_VIEW_MAX_STREAMS_PER_DEVICE", setInt(func(c *AppConfig) *int { return &c.LiveView.MaxStreamsPerDevice })},
+	{"NEBA_LIVE_VIEW_MAX_DURATION", setDuration(func(c *AppConfig) *Duration { return &c.LiveView.MaxDuration })},
+	{"NEBA_LIVE_VIEW_RESOLUTION", setString(func(c *AppConfig) *string { return &c.LiveView.Resolution })},
+	{"NEBA_LIVE_VIEW_FPS", setInt(func(c *AppConfig) *int { return &c.LiveView.FPS })},
+	{"NEBA_LIVE_VIEW_COMPRESSION", setInt(func(c *AppConfig) *int { return &c.LiveView.Compression })},
+	{"NEBA_EVENTS_ENABLED", setBool(func(c *AppConfig) *bool { return &c.Events.Enabled })},
+	{"NEBA_EVENTS_TOPIC_FILTERS", setList(func(c *AppConfig) *[]string { return &c.Events.TopicFilters })},
+	{"NEBA_EVENTS_RETENTION_DAYS", setInt(func(c *AppConfig) *int { return &c.Events.RetentionDays })},
+}
+
+// EnvOverrides returns the names of the environment variables that override
+// values of the configuration file.
+//
+// Returns:
+//   - []string: The names of the environment variables.
+func EnvOverrides() []string {
+	names := make([]string, 0, len(envOverrides))
+	for _, override := range envOverrides {
+		names = append(names, override.name)
+	}
+	return names
+}
+
+// applyEnv applies the environment overrides to the configuration. Relative
+// paths given in the environment are left as they are, and thus resolved
+// against the working directory, like paths given on the command line.
+//
+// Parameters:
+//   - config: The configuration to override values of.
+//   - lookup: The function that looks up environment variables, os.LookupEnv.
+//
+// Returns:
+//   - error: An error for every override with an invalid value.
+func applyEnv(config *AppConfig, lookup func(string) (string, bool)) error {
+	var errs []error
+	for _, override := range envOverrides {
+		value, ok := lookup(override.name)
+		if !ok {
+			continue
+		}
+		if err := override.apply(config, strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", override.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setString(field func(*AppConfig) *string) func(*AppConfig, string) error {
+	return func(config *AppConfig, value string) error {
+		*field(config) = value
+		return nil
+	}
+}
+
+func setList(field func(*AppConfig) *[]string) func(*AppConfig, string) error {
+	return func(config *AppConfig, value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(config) = items
+		return nil
+	}
+}
+
+func setBool(field func(*AppConfig) *bool) func(*AppConfig, string) error {
+	return func(config *AppConfig, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean, use true or false", value)
+		}
+		*field(config) = b
+		return nil
+	}
+}
+
+func setInt(field func(*AppConfig) *int) func(*AppConfig, string) error {
+	return func(config *AppConfig, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		*field(config) = n
+		return nil
+	}
+}
+
+func setDuration(field func(*AppConfig) *Duration) func(*AppConfig, string) error {
+	return func(config *AppConfig, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as \"1h\" or \"90s\"", value)
+		}
+		*field(config) = Duration(d)
+		return nil
+	}
+}
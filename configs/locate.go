@@ -0,0 +1,60 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// Location constants
+	configDirName = "neba"
+	ConfigPathEnv = "NEBA_CONFIG" // Overrides the default configuration file
+)
+
+// DefaultPath returns the path of the configuration file to use when none is
+// given on the command line. In order of precedence, this is:
+//
+//  1. The file named by the NEBA_CONFIG environment variable.
+//  2. The file next to the executable, if it exists. This keeps portable
+//     installations, which carry their configuration along, working.
+//  3. The file in the operating system's per-user configuration directory:
+//     $XDG_CONFIG_HOME/neba (~/.config/neba) on Linux and BSD,
+//     ~/Library/Application Support/neba on macOS, and %AppData%\neba on
+//     Windows.
+//
+// Returns:
+//   - string: The path of the configuration file, which may not exist yet.
+//   - error:  An error if no suitable location can be determined.
+func DefaultPath() (string, error) {
+	if path := os.Getenv(ConfigPathEnv); path != "" {
+		return path, nil
+	}
+
+	if exeDir, err := executableDir(); err == nil {
+		path := filepath.Join(exeDir, configFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("determine configuration directory: %w (use --config or %s)", err, ConfigPathEnv)
+	}
+	return filepath.Join(configDir, configDirName, configFileName), nil
+}
+
+// executableDir returns the directory of the running executable, with
+// symbolic links resolved, so that a symlink in a directory on the PATH does
+// not hide the configuration next to the actual binary.
+func executableDir() (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("determine executable path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+	return filepath.Dir(exePath), nil
+}
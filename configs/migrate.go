@@ -0,0 +1,208 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// CurrentVersion is the version of the configuration file format written
+	// by this version of Neba. Bump it and add a migration whenever a change
+	// to AppConfig needs existing files to be adjusted, for example to fill in
+	// the default of a new field that the zero value does not cover.
+	CurrentVersion = 9
+)
+
+// migrations upgrade the configuration file format. migrations[i] upgrades a
+// configuration of version i to version i+1.
+var migrations = []func(cm *CManager, config *AppConfig) error{
+	migrateToV1,
+	migrateToV2,
+	migrateToV3,
+	migrateToV4,
+	migrateToV5,
+	migrateToV6,
+	migrateToV7,
+	migrateToV8,
+	migrateToV9,
+}
+
+// migrateToV1 upgrades configurations written before the file format was
+// versioned. Version 1 resolves relative paths against the directory of the
+// configuration file instead of the working directory, so relative paths of
+// older files are made absolute to keep pointing at the same files. It also
+// fills in the HTTPS section, which the oldest files lack.
+func migrateToV1(cm *CManager, config *AppConfig) error {
+	for _, path := range []*string{&config.Database.Path, &config.Server.HTTPS.CertFile, &config.Server.HTTPS.KeyFile} {
+		if *path == "" || filepath.IsAbs(*path) {
+			continue
+		}
+		abs, err := filepath.Abs(*path)
+		if err != nil {
+			return fmt.Errorf("resolve %s: %w", *path, err)
+		}
+		*path = abs
+	}
+
+	if config.Server.HTTPS.Port == "" {
+		defaults, err := defaults()
+		if err != nil {
+			return err
+		}
+		config.Server.HTTPS = defaults.Server.HTTPS
+	}
+	return nil
+}
+
+// migrateToV2 fills in the discovery and polling sections added in version 2.
+func migrateToV2(cm *CManager, config *AppConfig) error {
+	defaults, err := defaults()
+	if err != nil {
+		return err
+	}
+	config.Discovery = defaults.Discovery
+	config.Polling = defaults.Polling
+	return nil
+}
+
+// migrateToV3 fills in the backup section added in version 3.
+func migrateToV3(cm *CManager, config *AppConfig) error {
+	defaults, err := defaults()
+	if err != nil {
+		return err
+	}
+	config.Backup = defaults.Backup
+	return nil
+}
+
+// migrateToV4 fills in the clock check settings added to the polling section
+// in version 4.
+func migrateToV4(cm *CManager, config *AppConfig) error {
+	defaults, err := defaults()
+	if err != nil {
+		return err
+	}
+	config.Polling.ClockCheck = defaults.Polling.ClockCheck
+	config.Polling.MaxClockDrift = defaults.Polling.MaxClockDrift
+	return nil
+}
+
+// migrateToV5 fills in the certificates section added in version 5.
+func migrateToV5(cm *CManager, config *AppConfig) error {
+	defaults, err := defaults()
+	if err != nil {
+		return err
+	}
+	config.Certificates = defaults.Certificates
+	return nil
+}
+
+// migrateToV6 fills in the settings of the built-in CA added to the
+// certificates section in version 6.
+func migrateToV6(cm *CManager, config *AppConfig) error {
+	defaults, err := defaults()
+	if err != nil {
+		return err
+	}
+	config.Certificates.ValidityDays = defaults.Certificates.ValidityDays
+	config.Certificates.AutoRenew = defaults.Certificates.AutoRenew
+	config.Certificates.RenewDays = defaults.Certificates.RenewDays
+	return nil
+}
+
+// migrateToV7 adds the snapshots section, added in version 7.
+func migrateToV7(cm *CManager, config *AppConfig) error {
+	defaults, err := defaults()
+	if err != nil {
+		return err
+	}
+	config.Snapshots = defaults.Snapshots
+	return nil
+}
+
+// migrateToV8 adds the live_view section, added in version 8.
+func migrateToV8(cm *CManager, config *AppConfig) error {
+	defaults, err := defaults()
+	if err != nil {
+		return err
+	}
+	config.LiveView = defaults.LiveView
+	return nil
+}
+
+// migrateToV9 adds the events section, added in version 9.
+func migrateToV9(cm *CManager, config *AppConfig) error {
+	defaults, err := defaults()
+	if err != nil {
+		return err
+	}
+	config.Events = defaults.Events
+	return nil
+}
+
+// migrate upgrades the loaded configuration to CurrentVersion. Before the
+// file is rewritten, the original is kept as a backup named after its
+// version, such as config.json.v0.bak.
+//
+// Returns:
+//   - error: An error if the file is newer than this version of Neba, or if
+//     a migration or writing the files fails.
+func (cm *CManager) migrate() error {
+	version := cm.file.Version
+	if version == CurrentVersion {
+		return nil
+	}
+	if err := cm.checkVersion(version); err != nil {
+		return err
+	}
+
+	config := cm.file.clone()
+	for v := version; v < CurrentVersion; v++ {
+		if err := migrations[v](cm, &config); err != nil {
+			return fmt.Errorf("migrate config file to version %d: %w", v+1, err)
+		}
+	}
+	config.Version = CurrentVersion
+
+	original, err := os.ReadFile(cm.path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	backupPath := fmt.Sprintf("%s.v%d.bak", cm.path, version)
+	if err := os.WriteFile(backupPath, original, 0644); err != nil {
+		return fmt.Errorf("back up config file: %w", err)
+	}
+	if err := cm.save(config); err != nil {
+		return err
+	}
+	cm.file = config
+
+	slog.Info("migrated config file",
+		slog.String("path", cm.path),
+		slog.Int("from", version),
+		slog.Int("to", CurrentVersion),
+		slog.String("backup", backupPath))
+	return nil
+}
+
+// checkVersion returns an error if the configuration file has a version this
+// version of Neba cannot read.
+func (cm *CManager) checkVersion(version int) error {
+	if version > CurrentVersion || version < 0 {
+		return fmt.Errorf("config file %s has version %d, but this version of Neba only supports up to version %d; upgrade Neba",
+			cm.path, version, CurrentVersion)
+	}
+	return nil
+}
+
+// defaults returns the default configuration.
+func defaults() (AppConfig, error) {
+	var config AppConfig
+	if err := json.Unmarshal(defaultConfig, &config); err != nil {
+		return AppConfig{}, fmt.Errorf("parse default config: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,261 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Validation constants
+	maxDiscoveryTimeout = 10 // SSDP allows at most 5 seconds, but slow networks may need more
+	minPollingInterval  = time.Minute
+	minClockDrift       = time.Second // Device clocks only report whole seconds
+	maxCertificateDays  = 3650
+	minSnapshotTTL      = time.Second
+	maxLiveViewFPS      = 60
+	maxEventRetention   = 3650
+)
+
+// Validate checks the configuration for values that would prevent Neba from
+// starting, so that they are reported up front and all at once, rather than
+// one by one as each part of the application trips over them.
+//
+// Returns:
+//   - error: An error listing every invalid value, or nil if there are none.
+func (c AppConfig) Validate() error {
+	var errs []error
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	http := c.Server.HTTP
+	check("server.http.port", validatePort(http.Port))
+	check("server.http.address", validateHost(http.Address, true))
+	for _, address := range http.Addresses {
+		check("server.http.addresses", validateHost(address, false))
+	}
+
+	if https := c.Server.HTTPS; https.Enabled {
+		check("server.https.port", validatePort(https.Port))
+		check("server.https.address", validateHost(https.Address, true))
+		for _, address := range https.Addresses {
+			check("server.https.addresses", validateHost(address, false))
+		}
+		if strings.TrimPrefix(https.Port, ":") == strings.TrimPrefix(http.Port, ":") && https.Address == http.Address {
+			check("server.https.port", fmt.Errorf("must differ from server.http.port"))
+		}
+		if (https.CertFile == "") != (https.KeyFile == "") {
+			check("server.https", fmt.Errorf("set both cert_file and key_file, or neither to use a self-signed certificate"))
+		} else if https.CertFile != "" {
+			check("server.https.cert_file", validateReadable(https.CertFile))
+			check("server.https.key_file", validateReadable(https.KeyFile))
+		}
+	}
+
+	check("database.path", validateWritable(c.Database.Path))
+
+	if c.Discovery.TimeoutSeconds < 1 || c.Discovery.TimeoutSeconds > maxDiscoveryTimeout {
+		check("discovery.timeout_seconds", fmt.Errorf("must be between 1 and %d", maxDiscoveryTimeout))
+	}
+	if c.Discovery.LocalAddress != "" && net.ParseIP(c.Discovery.LocalAddress) == nil {
+		check("discovery.local_address", fmt.Errorf("%q is not an IP address", c.Discovery.LocalAddress))
+	}
+
+	if c.Polling.SessionPurge.Duration() < minPollingInterval {
+		check("polling.session_purge", fmt.Errorf("must be at least %s", minPollingInterval))
+	}
+	if c.Polling.ClockCheck.Duration() < minPollingInterval {
+		check("polling.clock_check", fmt.Errorf("must be at least %s", minPollingInterval))
+	}
+	if c.Polling.MaxClockDrift.Duration() < minClockDrift {
+		check("polling.max_clock_drift", fmt.Errorf("must be at least %s", minClockDrift))
+	}
+
+	if c.Backup.Interval.Duration() < minPollingInterval {
+		check("backup.interval", fmt.Errorf("must be at least %s", minPollingInterval))
+	}
+	if c.Backup.Enabled {
+		if c.Backup.Dir == "" {
+			check("backup.dir", fmt.Errorf("must not be empty"))
+		} else {
+			check("backup.dir", validateWritable(filepath.Join(c.Backup.Dir, ".neba")))
+		}
+	}
+	if c.Backup.Keep < 0 {
+		check("backup.keep", fmt.Errorf("must not be negative"))
+	}
+
+	if c.Certificates.Scan.Duration() < minPollingInterval {
+		check("certificates.scan", fmt.Errorf("must be at least %s", minPollingInterval))
+	}
+	if c.Certificates.WarnDays < 1 {
+		check("certificates.warn_days", fmt.Errorf("must be at least 1"))
+	}
+	if c.Certificates.ValidityDays < 1 || c.Certificates.ValidityDays > maxCertificateDays {
+		check("certificates.validity_days", fmt.Errorf("must be between 1 and %d", maxCertificateDays))
+	}
+	if c.Certificates.RenewDays < 1 || c.Certificates.RenewDays >= c.Certificates.ValidityDays {
+		check("certificates.renew_days", fmt.Errorf("must be at least 1 and less than validity_days"))
+	}
+
+	if c.Snapshots.TTL.Duration() < minSnapshotTTL {
+		check("snapshots.ttl", fmt.Errorf("must be at least %s", minSnapshotTTL))
+	}
+	if err := ValidateResolution(c.Snapshots.Resolution); err != nil {
+		check("snapshots.resolution", err)
+	}
+	if c.Snapshots.Compression < 0 || c.Snapshots.Compression > 100 {
+		check("snapshots.compression", fmt.Errorf("must be between 0 and 100"))
+	}
+
+	if c.LiveView.MaxStreams < 1 {
+		check("live_view.max_streams", fmt.Errorf("must be at least 1"))
+	}
+	if c.LiveView.MaxStreamsPerDevice < 1 {
+		check("live_view.max_streams_per_device", fmt.Errorf("must be at least 1"))
+	}
+	if c.LiveView.MaxDuration.Duration() < minPollingInterval {
+		check("live_view.max_duration", fmt.Errorf("must be at least %s", minPollingInterval))
+	}
+	if err := ValidateResolution(c.LiveView.Resolution); err != nil {
+		check("live_view.resolution", err)
+	}
+	if c.LiveView.FPS < 1 || c.LiveView.FPS > maxLiveViewFPS {
+		check("live_view.fps", fmt.Errorf("must be between 1 and %d", maxLiveViewFPS))
+	}
+	if c.LiveView.Compression < 0 || c.LiveView.Compression > 100 {
+		check("live_view.compression", fmt.Errorf("must be between 0 and 100"))
+	}
+
+	if c.Events.Enabled && len(c.Events.TopicFilters) == 0 {
+		check("events.topic_filters", fmt.Errorf("must not be empty while events are enabled"))
+	}
+	for _, filter := range c.Events.TopicFilters {
+		if strings.TrimSpace(filter) == "" || strings.ContainsAny(filter, " \t\n") {
+			check("events.topic_filters", fmt.Errorf("%q is not a topic filter", filter))
+		}
+	}
+	if c.Events.RetentionDays < 1 || c.Events.RetentionDays > maxEventRetention {
+		check("events.retention_days", fmt.Errorf("must be between 1 and %d", maxEventRetention))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateResolution checks that the resolution has the form WIDTHxHEIGHT,
+// such as "320x180", as accepted by the image APIs of Axis devices.
+//
+// Parameters:
+//   - resolution: The resolution to check.
+//
+// Returns:
+//   - error: An error if the resolution is malformed or out of range.
+func ValidateResolution(resolution string) error {
+	width, height, ok := strings.Cut(resolution, "x")
+	w, errW := strconv.Atoi(width)
+	h, errH := strconv.Atoi(height)
+	if !ok || errW != nil || errH != nil || w < 1 || h < 1 || w > 7680 || h > 4320 {
+		return fmt.Errorf("%q is not a resolution such as 320x180", resolution)
+	}
+	return nil
+}
+
+// validatePort checks that the port is a number between 1 and 65535,
+// optionally with a leading colon.
+func validatePort(port string) error {
+	if port == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(port, ":"))
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%q is not a port number between 1 and 65535", port)
+	}
+	return nil
+}
+
+// validateHost checks that the address is an IP address, with or without
+// brackets, or a host name. An empty address, which listens on all
+// interfaces, is only allowed as the primary address.
+func validateHost(host string, allowEmpty bool) error {
+	if host == "" {
+		if allowEmpty {
+			return nil
+		}
+		return fmt.Errorf("must not contain empty addresses")
+	}
+
+	if net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")) != nil {
+		return nil
+	}
+	if strings.Contains(host, ":") {
+		return fmt.Errorf("%q is not an IP address or host name; configure the port separately", host)
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") ||
+			strings.IndexFunc(label, func(r rune) bool {
+				return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-')
+			}) >= 0 {
+			return fmt.Errorf("%q is not an IP address or host name", host)
+		}
+	}
+	return nil
+}
+
+// validateReadable checks that the file exists and can be read.
+func validateReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, errors.Unwrap(err))
+	}
+	return f.Close()
+}
+
+// validateWritable checks that a file can be created or written at the given
+// path. The nearest existing directory on the path must be writable, since
+// missing directories are created when the file is opened.
+func validateWritable(path string) error {
+	if path == "" {
+		return fmt.Errorf("must not be empty")
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		if info.Mode().Perm()&0200 == 0 {
+			return fmt.Errorf("%s is read-only", path)
+		}
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("no existing directory on %s", path)
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".neba-write-test-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable", dir)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
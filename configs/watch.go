@@ -0,0 +1,167 @@
+package configs
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"time"
+)
+
+// fileStamp identifies a version of the configuration file, so that external
+// edits can be told apart from the file as Neba last read or wrote it.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampOf(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// Subscribe registers a function that is called with the previous and the
+// new effective configuration whenever the configuration changes, either
+// through Update or because the file was edited and reloaded. Subsystems use
+// it to apply changes without a restart. The function is called after the
+// change has been made, without holding any lock, so it may call Get.
+//
+// Parameters:
+//   - fn: The function to call on changes.
+func (cm *CManager) Subscribe(fn func(old, new AppConfig)) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.subscribers = append(cm.subscribers, fn)
+}
+
+// configChange is a change of the effective configuration that has yet to be
+// passed to the subscribers.
+type configChange struct {
+	old, new AppConfig
+}
+
+// notify queues a call of the subscribers if the effective configuration has
+// changed. It must be called with the lock held, so the subscribers are
+// called from a separate goroutine, where they can call Get once the lock is
+// released. Changes are passed on one at a time, in the order they were made.
+func (cm *CManager) notify(old, new AppConfig) {
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+	cm.pending = append(cm.pending, configChange{old: old, new: new})
+	if !cm.delivering {
+		cm.delivering = true
+		go cm.deliver()
+	}
+}
+
+// deliver calls the subscribers with the queued changes until there are none
+// left.
+func (cm *CManager) deliver() {
+	for {
+		cm.mutex.Lock()
+		if len(cm.pending) == 0 {
+			cm.delivering = false
+			cm.mutex.Unlock()
+			return
+		}
+		change := cm.pending[0]
+		cm.pending = cm.pending[1:]
+		subscribers := append([]func(old, new AppConfig){}, cm.subscribers...)
+		cm.mutex.Unlock()
+
+		for _, fn := range subscribers {
+			fn(change.old, change.new)
+		}
+	}
+}
+
+// ReloadIfChanged reloads the configuration file if it has been changed since
+// it was last read or written by Neba, and notifies the subscribers. An
+// invalid file is reported and otherwise ignored, so that a half-finished
+// edit does not take down the running application; the previous
+// configuration stays in effect until the file is fixed. The file is never
+// rewritten, so one of an older version is not migrated until the next start.
+//
+// Returns:
+//   - bool:  True if a changed configuration has been loaded.
+//   - error: An error if the changed file cannot be loaded.
+func (cm *CManager) ReloadIfChanged() (bool, error) {
+	cm.mutex.RLock()
+	unchanged := stampOf(cm.path) == cm.stamp
+	cm.mutex.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	if _, err := os.Stat(cm.path); err != nil {
+		return false, err
+	}
+	reloaded := &CManager{path: cm.path}
+	err := reloaded.load()
+	if err == nil && reloaded.file.Version != CurrentVersion {
+		err = fmt.Errorf("config file %s has version %d; restart Neba to migrate it to version %d",
+			cm.path, reloaded.file.Version, CurrentVersion)
+	}
+	var config AppConfig
+	if err == nil {
+		config, err = cm.effective(reloaded.file)
+	}
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if err != nil {
+		// Do not report the same broken file again until it changes.
+		cm.stamp = stampOf(cm.path)
+		return false, err
+	}
+
+	old := cm.config
+	cm.file = reloaded.file
+	cm.config = config
+	cm.stamp = reloaded.stamp
+	cm.notify(old, cm.config)
+
+	slog.Info("reloaded config", slog.String("path", cm.path))
+	return true, nil
+}
+
+// RestartRequired returns the sections of the configuration that differ
+// between the two configurations and only take effect on restart: the
+// listeners of the server and the database.
+//
+// Parameters:
+//   - running: The configuration the application was started with.
+//   - current: The current configuration.
+//
+// Returns:
+//   - []string: The names of the changed sections, or nil if there are none.
+func RestartRequired(running, current AppConfig) []string {
+	var sections []string
+	if !reflect.DeepEqual(running.Server, current.Server) {
+		sections = append(sections, "server")
+	}
+	if running.Database != current.Database {
+		sections = append(sections, "database")
+	}
+	return sections
+}
+
+// ActiveEnvOverrides returns the names of the environment variables that
+// currently override values of the configuration file.
+//
+// Returns:
+//   - []string: The names of the set environment variables.
+func ActiveEnvOverrides() []string {
+	var names []string
+	for _, override := range envOverrides {
+		if _, ok := os.LookupEnv(override.name); ok {
+			names = append(names, override.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// Redacted replaces the values of secrets in audit entries.
+const Redacted = "[REDACTED]"
+
+// SecretParameter matches the names of parameters whose values must never end
+// up in the audit log.
+var SecretParameter = regexp.MustCompile(`(?i)pass|pwd|confirm|secret|token|key|credential`)
+
+// RedactParameters returns a copy of the given parameters in which the values
+// of those named like secrets are replaced with Redacted.
+//
+// Parameters:
+//   - params: The parameters to redact.
+//
+// Returns:
+//   - map[string]string: The redacted parameters, or nil if there were none.
+func RedactParameters(params map[string]string) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	redacted := make(map[string]string, len(params))
+	for name, value := range params {
+		if SecretParameter.MatchString(name) {
+			value = Redacted
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// RedactMessage returns the given message with the values of the parameters
+// named like secrets replaced with Redacted, since devices may echo rejected
+// values in their error messages.
+//
+// Parameters:
+//   - message: The message to redact.
+//   - params:  The parameters whose secret values must not appear.
+//
+// Returns:
+//   - string: The redacted message.
+func RedactMessage(message string, params map[string]string) string {
+	for name, value := range params {
+		if value != "" && SecretParameter.MatchString(name) {
+			message = strings.ReplaceAll(message, value, Redacted)
+		}
+	}
+	return message
+}
+
+// RedactLines returns the given Name=value lines with the values of those
+// named like secrets replaced with Redacted. Lines of any other form are kept
+// as they are.
+//
+// Parameters:
+//   - value: The lines to redact.
+//
+// Returns:
+//   - string: The redacted lines.
+func RedactLines(value string) string {
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		name, _, ok := strings.Cut(line, "=")
+		if ok && SecretParameter.MatchString(name) {
+			lines[i] = name + "=" + Redacted
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AppendAudit appends the given entry to the audit bucket. The entry's ID is
+// assigned from the bucket's sequence, so entries are kept in the order in
+// which they were recorded. There is deliberately no way to modify or delete
+// audit entries.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - entry: The AuditEntry to append.
+//
+// Returns:
+//   - error: An error if the entry could not be stored, otherwise nil.
+func AppendAudit(db *bbolt.DB, entry models.AuditEntry) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(AuditBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", AuditBucket)
+		}
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next audit sequence: %v", err)
+		}
+		entry.ID = id
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("marshal audit entry to JSON: %v", err)
+		}
+		return bucket.Put(sequenceKey(id), encoded)
+	})
+}
+
+// ListAudit retrieves the audit entries accepted by the given filter, newest
+// first. At most limit entries are returned; a limit of zero or less returns
+// all matching entries.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - filter: A function that reports whether an entry should be included, or nil to include all.
+//   - limit: The maximum number of entries to return.
+//
+// Returns:
+//   - A slice containing the matching entries.
+//   - An error if the bucket is not found or an entry cannot be decoded.
+func ListAudit(db *bbolt.DB, filter func(models.AuditEntry) bool, limit int) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(AuditBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", AuditBucket)
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var entry models.AuditEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return fmt.Errorf("unmarshal JSON of audit entry %d: %v", binary.BigEndian.Uint64(key), err)
+			}
+			if filter != nil && !filter(entry) {
+				continue
+			}
+			entries = append(entries, entry)
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// sequenceKey encodes a bucket sequence number as a big-endian key, so that
+// keys sort in numeric order.
+func sequenceKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
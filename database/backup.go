@@ -0,0 +1,206 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Backup file names are backupPrefix, a timestamp and backupSuffix.
+	backupPrefix     = "neba_"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102_150405"
+)
+
+// backupExcluded lists the buckets that are left out of backups. Sessions hold
+// live session and CSRF tokens, which must not leave the server, and
+// restoring them would only sign old browsers back in.
+var backupExcluded = []string{SessionsBucket}
+
+// WriteBackup writes a consistent copy of the database, without the buckets
+// in backupExcluded, to w. The copy is made in a read transaction, so the
+// database stays available while it is copied, and is staged in a temporary
+// file next to the database.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - w: The writer to copy the database to.
+//
+// Returns:
+//   - int64: The number of bytes written.
+//   - error: An error if the copy fails.
+func WriteBackup(db *bbolt.DB, w io.Writer) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(db.Path()), ".backup-*")
+	if err != nil {
+		return 0, fmt.Errorf("write backup: %v", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := copyDatabase(db, tmp.Name()); err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return 0, fmt.Errorf("write backup: %v", err)
+	}
+	defer f.Close()
+	n, err := io.Copy(w, f)
+	if err != nil {
+		return n, fmt.Errorf("write backup: %v", err)
+	}
+	return n, nil
+}
+
+// BackupToDir writes a backup of the database to a new timestamped file in
+// the given directory, such as neba_20250101_120000.db. The file only appears
+// under its final name once it is complete. Like WriteBackup, it leaves out
+// the buckets in backupExcluded.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - dir: The directory to store the backup in; it is created if needed.
+//
+// Returns:
+//   - string: The path of the backup file.
+//   - error: An error if the backup cannot be written.
+func BackupToDir(db *bbolt.DB, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("create backup directory: %v", err)
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().Format(backupTimeFormat)+backupSuffix)
+	tmp, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return "", fmt.Errorf("create backup file: %v", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := copyDatabase(db, tmp.Name()); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return "", fmt.Errorf("write backup: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("write backup: %v", err)
+	}
+	return path, nil
+}
+
+// copyDatabase copies every bucket of the database, except those in
+// backupExcluded, to a new database at the given path.
+func copyDatabase(db *bbolt.DB, path string) error {
+	out, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return fmt.Errorf("write backup: %v", err)
+	}
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		return out.Update(func(outTx *bbolt.Tx) error {
+			return tx.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
+				if slices.Contains(backupExcluded, string(name)) {
+					return nil
+				}
+				dst, err := outTx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(bucket, dst)
+			})
+		})
+	})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write backup: %v", err)
+	}
+	return nil
+}
+
+// copyBucket copies the keys, nested buckets and sequence of src to dst.
+func copyBucket(src, dst *bbolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(key, value []byte) error {
+		if value != nil {
+			return dst.Put(key, value)
+		}
+		nested, err := dst.CreateBucket(key)
+		if err != nil {
+			return err
+		}
+		return copyBucket(src.Bucket(key), nested)
+	})
+}
+
+// ListBackups returns the backup files in the given directory, newest first.
+//
+// Parameters:
+//   - dir: The backup directory.
+//
+// Returns:
+//   - []string: The paths of the backup files.
+//   - error: An error if the directory cannot be read. A missing directory
+//     has no backups and is not an error.
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read backup directory: %v", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	// Timestamps sort chronologically.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// PruneBackups deletes all but the newest keep backups in the given
+// directory. A keep of zero or less keeps every backup.
+//
+// Parameters:
+//   - dir: The backup directory.
+//   - keep: The number of backups to keep.
+//
+// Returns:
+//   - int: The number of deleted backups.
+//   - error: An error if a backup cannot be deleted.
+func PruneBackups(dir string, keep int) (int, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+	backups, err := ListBackups(dir)
+	if err != nil || len(backups) <= keep {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, path := range backups[keep:] {
+		if err := os.Remove(path); err != nil {
+			return deleted, fmt.Errorf("delete old backup: %v", err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -1,36 +1,88 @@
 package database
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/furkansuleymana/neba/database/models"
 	"go.etcd.io/bbolt"
 )
 
-// Open opens a BoltDB database at the specified path and ensures that the specified bucket exists.
-// If the database or bucket does not exist, they will be created.
+const (
+	// Bucket names
+	MetaBucket        = "meta"
+	DevicesBucket     = "devices"
+	UsersBucket       = "users"
+	SessionsBucket    = "sessions"
+	AuditBucket       = "audit"
+	CredentialsBucket = "credentials"
+	JobsBucket        = "jobs"
+	SettingsBucket    = "settings"
+	EventsBucket      = "events"
+
+	// openTimeout is how long Open waits for another process to release the database file.
+	openTimeout = 2 * time.Second
+)
+
+// ErrLocked is returned by Open when another process, usually a running Neba
+// server, holds the database file.
+var ErrLocked = errors.New("database is locked by another process")
+
+// Buckets lists every bucket used by Neba. Passing it to Open ensures that
+// the application can rely on all of them being present.
+var Buckets = []string{
+	MetaBucket,
+	DevicesBucket,
+	UsersBucket,
+	SessionsBucket,
+	AuditBucket,
+	CredentialsBucket,
+	JobsBucket,
+	SettingsBucket,
+	EventsBucket,
+}
+
+// Open opens a BoltDB database at the specified path and ensures that the specified buckets exist.
+// If the database or buckets do not exist, they will be created. A database written by an older
+// version of Neba is backed up and migrated to the current schema version; see Migrate.
 //
 // Parameters:
 //   - dbPath: The file path to the BoltDB database.
-//   - bucketName: The name of the bucket to ensure exists.
+//   - bucketNames: The names of the buckets to ensure exist.
 //
 // Returns:
 //   - *bbolt.DB: A pointer to the opened BoltDB database.
-//   - error: An error if the database or bucket could not be opened or created.
-func Open(dbPath string, bucketName string) (*bbolt.DB, error) {
-	db, err := bbolt.Open(dbPath, 0600, nil)
+//   - error: An error if the database or buckets could not be opened or created.
+func Open(dbPath string, bucketNames ...string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(dbPath, 0600, &bbolt.Options{Timeout: openTimeout})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("open database %s: %w (is Neba already running?)", dbPath, ErrLocked)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("open database, %v", err)
 	}
 
+	// Migrate before creating buckets, so that the backup taken before
+	// migrating is a copy of the file as it was.
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	if err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
-		if err != nil {
-			return fmt.Errorf("create bucket: %v", err)
+		if err := initSchemaVersion(tx); err != nil {
+			return err
+		}
+		for _, bucketName := range bucketNames {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+			if err != nil {
+				return fmt.Errorf("create bucket %s: %v", bucketName, err)
+			}
 		}
 		return nil
 	}); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("set up bucket, %v", err)
 	}
 
@@ -49,17 +101,7 @@ func Open(dbPath string, bucketName string) (*bbolt.DB, error) {
 // Returns:
 //   - error: An error if the update operation fails, otherwise nil.
 func Update(db *bbolt.DB, bucketName string, device models.AxisDevice) error {
-	return db.Update(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket([]byte(bucketName))
-		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", bucketName)
-		}
-		encoded, err := json.Marshal(device)
-		if err != nil {
-			return fmt.Errorf("marshal device to JSON: %v", err)
-		}
-		return bucket.Put([]byte(device.SerialNumber), encoded)
-	})
+	return deviceRepository(db, bucketName).Put(device)
 }
 
 // View retrieves an AxisDevice from the specified bucket in the BoltDB database
@@ -74,27 +116,39 @@ func Update(db *bbolt.DB, bucketName string, device models.AxisDevice) error {
 //   - A pointer to the AxisDevice if found, or nil if not found.
 //   - An error if the bucket or device is not found, or if there is an issue unmarshalling the JSON data.
 func View(db *bbolt.DB, bucketName string, serialNumber string) (*models.AxisDevice, error) {
-	var device models.AxisDevice
+	return deviceRepository(db, bucketName).Get(serialNumber)
+}
 
-	err := db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket([]byte(bucketName))
-		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", bucketName)
-		}
-		value := bucket.Get([]byte(serialNumber))
-		if value == nil {
-			return fmt.Errorf("device %s not found", serialNumber)
-		}
-		if err := json.Unmarshal(value, &device); err != nil {
-			return fmt.Errorf("unmarshal JSON: %v", err)
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
+// List retrieves every AxisDevice stored in the specified bucket, ordered by serial number.
+//
+// Parameters:
+//   - db: A pointer to the BoltDB database.
+//   - bucketName: The name of the bucket to list the devices of.
+//
+// Returns:
+//   - A slice containing all devices in the bucket.
+//   - An error if the bucket is not found or a record cannot be decoded.
+func List(db *bbolt.DB, bucketName string) ([]models.AxisDevice, error) {
+	return deviceRepository(db, bucketName).List()
+}
+
+// Delete removes the AxisDevice with the given serial number from the specified bucket.
+// Deleting a device that does not exist is not an error.
+//
+// Parameters:
+//   - db: A pointer to the BoltDB database.
+//   - bucketName: The name of the bucket to delete the device from.
+//   - serialNumber: The serial number of the device to delete.
+//
+// Returns:
+//   - error: An error if the bucket is not found or the delete operation fails.
+func Delete(db *bbolt.DB, bucketName string, serialNumber string) error {
+	return deviceRepository(db, bucketName).Delete(serialNumber)
+}
 
-	return &device, nil
+// deviceRepository returns a repository of devices in the given bucket.
+func deviceRepository(db *bbolt.DB, bucketName string) Repository[models.AxisDevice] {
+	return NewRepository(db, bucketName, "device", func(d models.AxisDevice) string { return d.SerialNumber })
 }
 
 // CloseDB closes the given bbolt database.
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// AppendEvents appends the given events to the events bucket. Like audit
+// entries, events are keyed by the bucket's sequence, so they are kept in the
+// order in which they arrived. Concurrent calls are batched into a single
+// transaction, so that bursts of events across many devices do not hold the
+// database for a write each.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - events: The events to append.
+//
+// Returns:
+//   - error: An error if the events could not be stored, otherwise nil.
+func AppendEvents(db *bbolt.DB, events ...models.Event) error {
+	return db.Batch(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(EventsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", EventsBucket)
+		}
+		for _, event := range events {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return fmt.Errorf("next event sequence: %v", err)
+			}
+			event.ID = id
+			encoded, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("marshal event to JSON: %v", err)
+			}
+			if err := bucket.Put(sequenceKey(id), encoded); err != nil {
+				return fmt.Errorf("store event: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListEvents retrieves the events accepted by the given filter, newest first.
+// At most limit events are returned; a limit of zero or less returns all
+// matching events. Since events are stored in order of arrival, the scan
+// stops at the first event that arrived before since.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - since: The time before which events are left out; the zero time to include all.
+//   - filter: A function that reports whether an event should be included, or nil to include all.
+//   - limit: The maximum number of events to return.
+//
+// Returns:
+//   - A slice containing the matching events.
+//   - An error if the bucket is not found or an event cannot be decoded.
+func ListEvents(db *bbolt.DB, since time.Time, filter func(models.Event) bool, limit int) ([]models.Event, error) {
+	var events []models.Event
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(EventsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", EventsBucket)
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var event models.Event
+			if err := json.Unmarshal(value, &event); err != nil {
+				return fmt.Errorf("unmarshal JSON of event %d: %v", binary.BigEndian.Uint64(key), err)
+			}
+			if event.Time.Before(since) {
+				break
+			}
+			if filter != nil && !filter(event) {
+				continue
+			}
+			events = append(events, event)
+			if limit > 0 && len(events) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// PruneEvents removes the events that arrived before the given time. Since
+// events are stored in order of arrival, pruning stops at the first event
+// that is recent enough.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - before: The time before which events are removed.
+//
+// Returns:
+//   - int: The number of removed events.
+//   - error: An error if the events bucket cannot be read or updated.
+func PruneEvents(db *bbolt.DB, before time.Time) (int, error) {
+	pruned := 0
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(EventsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", EventsBucket)
+		}
+		var expired [][]byte
+		cursor := bucket.Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			var event models.Event
+			if err := json.Unmarshal(value, &event); err == nil && !event.Time.Before(before) {
+				break
+			}
+			expired = append(expired, key)
+		}
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("delete event: %v", err)
+			}
+		}
+		pruned = len(expired)
+		return nil
+	})
+
+	return pruned, err
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+)
+
+func TestListEvents(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "neba.db"), EventsBucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	for i, serial := range []string{"A", "B", "A", "B", "A"} {
+		event := models.Event{SerialNumber: serial, Summary: string(rune('1' + i)), Time: start.Add(time.Duration(i) * time.Hour)}
+		if err := AppendEvents(db, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		since  time.Time
+		filter func(models.Event) bool
+		limit  int
+		want   []string // Summaries, newest first
+	}{
+		{name: "all", want: []string{"5", "4", "3", "2", "1"}},
+		{name: "limit", limit: 2, want: []string{"5", "4"}},
+		{name: "since", since: start.Add(2 * time.Hour), want: []string{"5", "4", "3"}},
+		{name: "since after the newest event", since: start.Add(5 * time.Hour), want: nil},
+		{
+			name:   "filter",
+			filter: func(event models.Event) bool { return event.SerialNumber == "B" },
+			want:   []string{"4", "2"},
+		},
+		{
+			name:   "filter, since and limit",
+			since:  start.Add(time.Hour),
+			filter: func(event models.Event) bool { return event.SerialNumber == "A" },
+			limit:  5,
+			want:   []string{"5", "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := ListEvents(db, tt.since, tt.filter, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var summaries []string
+			for _, event := range events {
+				summaries = append(summaries, event.Summary)
+			}
+			if !slices.Equal(summaries, tt.want) {
+				t.Errorf("ListEvents() = %v, want %v", summaries, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendEventsConcurrently(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "neba.db"), EventsBucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const writers, perWriter = 8, 25
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWriter {
+				if err := AppendEvents(db, models.Event{Time: time.Now()}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	events, err := ListEvents(db, time.Time{}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != writers*perWriter {
+		t.Fatalf("stored %d events, want %d", len(events), writers*perWriter)
+	}
+	for i, event := range events {
+		if want := uint64(len(events) - i); event.ID != want {
+			t.Fatalf("events[%d].ID = %d, want %d", i, event.ID, want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package database
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// Inventory formats
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// ImportMode decides what happens when an imported device already exists.
+type ImportMode string
+
+// Import modes
+const (
+	ImportSkip      ImportMode = "skip"      // Keep the existing device
+	ImportOverwrite ImportMode = "overwrite" // Replace it with the imported one
+	ImportMerge     ImportMode = "merge"     // Fill in empty fields and add tags
+)
+
+// ImportModes lists every import mode.
+var ImportModes = []ImportMode{ImportSkip, ImportOverwrite, ImportMerge}
+
+// inventoryColumns are the CSV columns of the inventory, in order.
+var inventoryColumns = []string{"serial_number", "ip_address", "model", "site", "tags", "credential_profile", "warranty_expires"}
+
+// InventoryRecord is a device as it appears in an inventory export. It holds
+// no credentials; devices refer to a credential profile by name instead.
+type InventoryRecord struct {
+	SerialNumber      string   `json:"serial_number"`
+	IPAddress         string   `json:"ip_address"`
+	Model             string   `json:"model"`
+	Site              string   `json:"site,omitempty"`
+	Tags              []string `json:"tags,omitempty"`
+	CredentialProfile string   `json:"credential_profile,omitempty"`
+	WarrantyExpires   string   `json:"warranty_expires,omitempty"`
+}
+
+// ImportResult summarizes an inventory import.
+type ImportResult struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Skipped   []string `json:"skipped"`
+	Unmatched []string `json:"unmatched_profiles,omitempty"` // Credential profiles that do not exist
+	Total     int      `json:"total"`
+}
+
+// String summarizes the result in a sentence.
+func (r ImportResult) String() string {
+	s := fmt.Sprintf("Imported %d devices: %d created, %d updated, %d skipped.",
+		r.Total, len(r.Created), len(r.Updated), len(r.Skipped))
+	if len(r.Unmatched) > 0 {
+		s += fmt.Sprintf(" Unknown credential profiles: %s.", strings.Join(r.Unmatched, ", "))
+	}
+	return s
+}
+
+// ExportInventory writes every device to w in the given format.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - w: The writer to write the inventory to.
+//   - format: FormatJSON or FormatCSV.
+//
+// Returns:
+//   - error: An error if the devices cannot be read or written.
+func ExportInventory(db *bbolt.DB, w io.Writer, format string) error {
+	devices, err := Devices(db).List()
+	if err != nil {
+		return err
+	}
+
+	records := make([]InventoryRecord, 0, len(devices))
+	for _, device := range devices {
+		records = append(records, InventoryRecord{
+			SerialNumber:      device.SerialNumber,
+			IPAddress:         device.IPAddress,
+			Model:             device.Model,
+			Site:              device.Site,
+			Tags:              device.Tags,
+			CredentialProfile: device.CredentialProfile,
+			WarrantyExpires:   device.WarrantyExpires,
+		})
+	}
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		writer.Write(inventoryColumns)
+		for _, r := range records {
+			writer.Write([]string{r.SerialNumber, r.IPAddress, r.Model, r.Site, strings.Join(r.Tags, ";"), r.CredentialProfile, r.WarrantyExpires})
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// ParseInventory reads an inventory in the given format, as written by
+// ExportInventory. CSV files must have a header row; columns may come in any
+// order, and tags are separated by semicolons.
+//
+// Parameters:
+//   - r: The reader to read the inventory from.
+//   - format: FormatJSON or FormatCSV.
+//
+// Returns:
+//   - []InventoryRecord: The records.
+//   - error: An error if the inventory cannot be parsed or a record has no serial number.
+func ParseInventory(r io.Reader, format string) ([]InventoryRecord, error) {
+	var records []InventoryRecord
+
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&records); err != nil {
+			return nil, fmt.Errorf("parse JSON: %v", err)
+		}
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.TrimLeadingSpace = true
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("parse CSV: %v", err)
+		}
+		if len(rows) == 0 {
+			return nil, fmt.Errorf("parse CSV: missing header row")
+		}
+		index := make(map[string]int)
+		for i, column := range rows[0] {
+			index[strings.ToLower(strings.TrimSpace(column))] = i
+		}
+		if _, ok := index["serial_number"]; !ok {
+			return nil, fmt.Errorf("parse CSV: missing serial_number column")
+		}
+		field := func(row []string, column string) string {
+			if i, ok := index[column]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		for _, row := range rows[1:] {
+			records = append(records, InventoryRecord{
+				SerialNumber:      field(row, "serial_number"),
+				IPAddress:         field(row, "ip_address"),
+				Model:             field(row, "model"),
+				Site:              field(row, "site"),
+				Tags:              splitTags(field(row, "tags")),
+				CredentialProfile: field(row, "credential_profile"),
+				WarrantyExpires:   field(row, "warranty_expires"),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	for i, record := range records {
+		if record.SerialNumber == "" {
+			return nil, fmt.Errorf("record %d has no serial number", i+1)
+		}
+		if err := ValidateDate(record.WarrantyExpires); err != nil {
+			return nil, fmt.Errorf("record %d: warranty_expires: %v", i+1, err)
+		}
+	}
+	return records, nil
+}
+
+// ImportInventory stores the records as devices, in a single transaction.
+// Devices that use a credential profile get its username and password; a
+// profile that does not exist is reported in the result, and the device is
+// imported without credentials.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - records: The records to import.
+//   - mode: What to do with devices that already exist.
+//
+// Returns:
+//   - ImportResult: What happened to each device.
+//   - error: An error if the mode is unknown or the devices cannot be stored.
+func ImportInventory(db *bbolt.DB, records []InventoryRecord, mode ImportMode) (ImportResult, error) {
+	result := ImportResult{Total: len(records)}
+	if !slices.Contains(ImportModes, mode) {
+		return result, fmt.Errorf("unknown import mode %q", mode)
+	}
+
+	devices := Devices(db)
+	credentials := Credentials(db)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		// Start over if the transaction is retried.
+		result = ImportResult{Total: len(records)}
+
+		for _, record := range records {
+			var existing *models.AxisDevice
+			if value := tx.Bucket([]byte(DevicesBucket)).Get([]byte(record.SerialNumber)); value != nil {
+				existing = &models.AxisDevice{}
+				if err := devices.decode(record.SerialNumber, value, existing); err != nil {
+					return err
+				}
+			}
+
+			var profile *models.Credential
+			if record.CredentialProfile != "" {
+				if value := tx.Bucket([]byte(CredentialsBucket)).Get([]byte(record.CredentialProfile)); value != nil {
+					profile = &models.Credential{}
+					if err := credentials.decode(record.CredentialProfile, value, profile); err != nil {
+						return err
+					}
+				} else if !slices.Contains(result.Unmatched, record.CredentialProfile) {
+					result.Unmatched = append(result.Unmatched, record.CredentialProfile)
+				}
+			}
+
+			var device models.AxisDevice
+			switch {
+			case existing == nil:
+				device = record.device(models.AxisDevice{}, profile)
+				result.Created = append(result.Created, record.SerialNumber)
+			case mode == ImportSkip:
+				result.Skipped = append(result.Skipped, record.SerialNumber)
+				continue
+			case mode == ImportOverwrite:
+				device = record.device(*existing, profile)
+				result.Updated = append(result.Updated, record.SerialNumber)
+			case mode == ImportMerge:
+				device = record.merge(*existing, profile)
+				result.Updated = append(result.Updated, record.SerialNumber)
+			}
+
+			if err := devices.PutTx(tx, device); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{Total: len(records)}, err
+	}
+	return result, nil
+}
+
+// device returns the record as a device, replacing the inventory fields of
+// base. Credentials are taken from the profile if there is one, and kept from
+// base otherwise.
+func (r InventoryRecord) device(base models.AxisDevice, profile *models.Credential) models.AxisDevice {
+	base.SerialNumber = r.SerialNumber
+	base.IPAddress = r.IPAddress
+	base.Model = r.Model
+	base.Site = r.Site
+	base.Tags = r.Tags
+	base.CredentialProfile = r.CredentialProfile
+	base.WarrantyExpires = r.WarrantyExpires
+	if profile != nil {
+		base.Username = profile.Username
+		base.Password = profile.Password
+	}
+	return base
+}
+
+// merge returns the existing device with its empty fields filled in from the
+// record and the record's tags added.
+func (r InventoryRecord) merge(existing models.AxisDevice, profile *models.Credential) models.AxisDevice {
+	if existing.IPAddress == "" {
+		existing.IPAddress = r.IPAddress
+	}
+	if existing.Model == "" {
+		existing.Model = r.Model
+	}
+	if existing.Site == "" {
+		existing.Site = r.Site
+	}
+	if existing.WarrantyExpires == "" {
+		existing.WarrantyExpires = r.WarrantyExpires
+	}
+	for _, tag := range r.Tags {
+		if !slices.Contains(existing.Tags, tag) {
+			existing.Tags = append(existing.Tags, tag)
+		}
+	}
+	if existing.CredentialProfile == "" && profile != nil {
+		existing.CredentialProfile = profile.Name
+		if existing.Password == "" {
+			existing.Username = profile.Username
+			existing.Password = profile.Password
+		}
+	}
+	return existing
+}
+
+// ValidateDate checks that the value is empty or a date in YYYY-MM-DD format,
+// as used for warranty expiry dates.
+func ValidateDate(value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		return fmt.Errorf("%q is not a date in YYYY-MM-DD format", value)
+	}
+	return nil
+}
+
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ";") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// ListJobs retrieves the most recent jobs, newest first.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - limit: The maximum number of jobs to return, or 0 for all.
+//
+// Returns:
+//   - []models.Job: The jobs.
+//   - error: An error if the jobs cannot be read.
+func ListJobs(db *bbolt.DB, limit int) ([]models.Job, error) {
+	jobs, err := Jobs(db).List()
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(jobs, func(a, b models.Job) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// FailInterruptedJobs marks jobs that were still pending or running when Neba
+// stopped as failed, since nothing will ever finish them.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - []models.Job: The jobs that were marked as failed.
+//   - error: An error if the jobs cannot be read or updated.
+func FailInterruptedJobs(db *bbolt.DB) ([]models.Job, error) {
+	repo := Jobs(db)
+	jobs, err := repo.Filter(func(job models.Job) bool { return !job.Done() })
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		jobs[i].Status = models.JobFailed
+		jobs[i].Message = "Interrupted because Neba stopped. Check the devices that have no result."
+		jobs[i].FinishedAt = time.Now()
+		if err := repo.Put(jobs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+// PruneJobs removes the jobs that finished before the given time. Jobs that
+// are still pending or running are kept.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - before: The time before which finished jobs are removed.
+//
+// Returns:
+//   - int: The number of removed jobs.
+//   - error: An error if the jobs bucket cannot be read or updated.
+func PruneJobs(db *bbolt.DB, before time.Time) (int, error) {
+	pruned := 0
+	repo := Jobs(db)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := repo.open(tx)
+		if err != nil {
+			return err
+		}
+		var expired [][]byte
+		err = bucket.ForEach(func(key, value []byte) error {
+			var job models.Job
+			if err := repo.decode(string(key), value, &job); err != nil {
+				return err
+			}
+			if job.Done() && job.FinishedAt.Before(before) {
+				expired = append(expired, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("delete job: %v", err)
+			}
+		}
+		pruned = len(expired)
+		return nil
+	})
+
+	return pruned, err
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// PurgeExpiredSessions removes every expired session from the sessions bucket.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - int: The number of removed sessions.
+//   - error: An error if the sessions bucket cannot be read or updated.
+func PurgeExpiredSessions(db *bbolt.DB) (int, error) {
+	purged := 0
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(SessionsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", SessionsBucket)
+		}
+		var expired [][]byte
+		err := bucket.ForEach(func(key, value []byte) error {
+			var session models.Session
+			if err := json.Unmarshal(value, &session); err != nil || session.Expired() {
+				expired = append(expired, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("delete session: %v", err)
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+
+	return purged, err
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// SchemaVersion is the version of the database schema written by this
+	// version of Neba. Bump it and add a migration whenever a stored record
+	// gains or changes a field: records are decoded strictly, so older
+	// versions of Neba must refuse to open the database instead of failing
+	// to read the records.
+	SchemaVersion = 3
+
+	// schemaVersionKey is the key of the schema version in the meta bucket.
+	schemaVersionKey = "schema_version"
+)
+
+// migration upgrades the database schema by one version.
+type migration struct {
+	description string
+	apply       func(tx *bbolt.Tx) error
+}
+
+// migrations upgrade the database schema, in order. migrations[i] upgrades a
+// database of version i to version i+1. Each migration runs in its own
+// transaction together with the version bump, so an interrupted migration
+// leaves the database at the last completed version.
+var migrations = []migration{
+	{"assign the admin role to accounts created before roles existed", migrateUserRoles},
+	{"add credential profiles to devices", addOptionalFields},
+	{"add warranty expiry dates to devices", addOptionalFields},
+}
+
+// addOptionalFields is the migration for new fields that may be left empty.
+// Stored records need no changes; bumping the version is what keeps older
+// versions of Neba from reading records with fields they do not know.
+func addOptionalFields(tx *bbolt.Tx) error {
+	return nil
+}
+
+// migrateUserRoles gives accounts without a role the admin role. Before roles
+// were introduced, every account could do everything.
+func migrateUserRoles(tx *bbolt.Tx) error {
+	bucket := tx.Bucket([]byte(UsersBucket))
+	if bucket == nil {
+		return nil
+	}
+	users := Users(nil) // Only used with the migration's transaction
+
+	var updated []models.User
+	err := bucket.ForEach(func(key, value []byte) error {
+		var user models.User
+		if err := users.decode(string(key), value, &user); err != nil {
+			return err
+		}
+		if user.Role == "" {
+			user.Role = "admin" // auth.RoleAdmin
+			updated = append(updated, user)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, user := range updated {
+		if err := users.PutTx(tx, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrate upgrades the database to SchemaVersion. Before the first migration
+// runs, the database file is copied to a backup next to it, named after the
+// old version and the time, such as db.db.v0.20250101T120000.bak.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - error: An error if the database is newer than this version of Neba, or
+//     if the backup or a migration fails.
+func Migrate(db *bbolt.DB) error {
+	var empty bool
+	if err := db.View(func(tx *bbolt.Tx) (err error) {
+		empty, err = isEmpty(tx)
+		return err
+	}); err != nil {
+		return err
+	}
+	if empty {
+		// A new database is marked with the current version once its
+		// buckets are created.
+		return nil
+	}
+
+	version, err := ReadSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version == SchemaVersion {
+		return nil
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database %s has schema version %d, but this version of Neba only supports up to version %d; upgrade Neba",
+			db.Path(), version, SchemaVersion)
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d.%s.bak", db.Path(), version, time.Now().Format("20060102T150405"))
+	if err := db.View(func(tx *bbolt.Tx) error {
+		return tx.CopyFile(backupPath, 0600)
+	}); err != nil {
+		return fmt.Errorf("back up database before migrating: %v", err)
+	}
+	slog.Info("backed up database before migrating",
+		slog.String("path", db.Path()),
+		slog.String("backup", backupPath))
+
+	for v := version; v < SchemaVersion; v++ {
+		m := migrations[v]
+		err := db.Update(func(tx *bbolt.Tx) error {
+			if err := m.apply(tx); err != nil {
+				return err
+			}
+			return writeSchemaVersion(tx, v+1)
+		})
+		if err != nil {
+			return fmt.Errorf("migrate database to version %d (%s): %v; a backup is at %s", v+1, m.description, err, backupPath)
+		}
+		slog.Info("migrated database",
+			slog.Int("version", v+1),
+			slog.String("migration", m.description))
+	}
+	return nil
+}
+
+// ReadSchemaVersion returns the schema version of the database.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - int:   The schema version, or 0 for a database written before schema
+//     versions were introduced.
+//   - error: An error if the stored version cannot be read.
+func ReadSchemaVersion(db *bbolt.DB) (int, error) {
+	version := 0
+	err := db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(MetaBucket))
+		if bucket == nil {
+			return nil
+		}
+		value := bucket.Get([]byte(schemaVersionKey))
+		if value == nil {
+			return nil
+		}
+		v, err := strconv.Atoi(string(value))
+		if err != nil {
+			return fmt.Errorf("invalid schema version %q: %v", value, err)
+		}
+		version = v
+		return nil
+	})
+	return version, err
+}
+
+// initSchemaVersion marks a newly created database with the current schema
+// version, so that it is not mistaken for one that predates schema versions
+// and needs to be migrated. It must run before any bucket is created.
+func initSchemaVersion(tx *bbolt.Tx) error {
+	empty, err := isEmpty(tx)
+	if err != nil || !empty {
+		return err
+	}
+	if _, err := tx.CreateBucketIfNotExists([]byte(MetaBucket)); err != nil {
+		return fmt.Errorf("create bucket %s: %v", MetaBucket, err)
+	}
+	return writeSchemaVersion(tx, SchemaVersion)
+}
+
+// isEmpty reports whether the database has no buckets, which means that it
+// has just been created.
+func isEmpty(tx *bbolt.Tx) (bool, error) {
+	empty := true
+	err := tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+		empty = false
+		return nil
+	})
+	return empty, err
+}
+
+func writeSchemaVersion(tx *bbolt.Tx, version int) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(MetaBucket))
+	if err != nil {
+		return fmt.Errorf("create bucket %s: %v", MetaBucket, err)
+	}
+	return bucket.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(version)))
+}
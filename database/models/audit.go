@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Audit entry outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry records a single mutating request made through Neba: who did
+// what to which devices, and how it went. Secrets are redacted from the
+// parameters before the entry is stored.
+type AuditEntry struct {
+	ID         uint64            `json:"id"`
+	Time       time.Time         `json:"time"`
+	Username   string            `json:"username"`
+	SourceIP   string            `json:"source_ip"`
+	Action     string            `json:"action"`
+	Targets    []string          `json:"targets,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Status     int               `json:"status"`
+	Message    string            `json:"message,omitempty"`
+}
@@ -2,12 +2,22 @@ package models
 
 // AxisDevice represents a device with its attributes.
 type AxisDevice struct {
-	SerialNumber string `json:"serial_number"`
-	Model        string `json:"model"`
-	IPAddress    string `json:"ip_address"`
-	OSVersion    string `json:"os_version"`
-	Username     string `json:"username"`
-	Password     string `json:"password"` // This is a bad idea.
+	SerialNumber string   `json:"serial_number"`
+	Model        string   `json:"model"`
+	IPAddress    string   `json:"ip_address"`
+	OSVersion    string   `json:"os_version"`
+	Username     string   `json:"username"`
+	Password     string   `json:"password"` // This is a bad idea.
+	Site         string   `json:"site,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+
+	// CredentialProfile names the credential profile that the username and
+	// password were taken from, if any.
+	CredentialProfile string `json:"credential_profile,omitempty"`
+
+	// WarrantyExpires is the last day of the device's warranty, in
+	// YYYY-MM-DD format, if known.
+	WarrantyExpires string `json:"warranty_expires,omitempty"`
 }
 
 // TODO: Add a method to validate the device.
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Credential is a named set of device credentials, such as the root password
+// used across a site. Profiles are used to provision and manage devices
+// without typing the password for each of them.
+type Credential struct {
+	Name        string    `json:"name"`
+	Username    string    `json:"username"`
+	Password    string    `json:"password"` // Stored in plain text, like device passwords.
+	Description string    `json:"description,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
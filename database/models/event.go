@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Event categories
+const (
+	EventTampering = "tampering"
+	EventMotion    = "motion"
+	EventIO        = "io"
+	EventStorage   = "storage"
+	EventHardware  = "hardware"
+	EventOther     = "other"
+)
+
+// Event is an event emitted by a device, normalized from the topic and
+// message of the device's notification.
+type Event struct {
+	ID           uint64            `json:"id"`
+	Time         time.Time         `json:"time"`
+	SerialNumber string            `json:"serial_number"`
+	Topic        string            `json:"topic"`
+	Category     string            `json:"category"`
+	Summary      string            `json:"summary"`
+	Source       map[string]string `json:"source,omitempty"`
+	Data         map[string]string `json:"data,omitempty"`
+}
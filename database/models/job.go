@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job records a long-running operation on one or more devices, such as a
+// bulk password rotation, so that its progress and outcome survive page
+// reloads and restarts.
+type Job struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Username   string            `json:"username"` // Who started the job
+	Targets    []string          `json:"targets,omitempty"`
+	Status     string            `json:"status"`
+	Results    map[string]string `json:"results,omitempty"` // Outcome per target
+	Message    string            `json:"message,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  time.Time         `json:"started_at,omitempty"`
+	FinishedAt time.Time         `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
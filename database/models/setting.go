@@ -0,0 +1,15 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Setting is a value that Neba manages in the database rather than in the
+// configuration file, such as a schedule set up in the web UI. The value is
+// kept as raw JSON so that each setting can have its own type.
+type Setting struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// User represents a local account that can sign in to the Neba web UI.
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role"`
+	Scopes       []string  `json:"scopes,omitempty"` // Sites or tags the user is limited to; empty means all.
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Session represents a signed-in browser session. The token is stored in a
+// cookie, while the CSRF token must accompany every state-changing request.
+type Session struct {
+	Token     string    `json:"token"`
+	Username  string    `json:"username"`
+	CSRFToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the session is no longer valid.
+func (s Session) Expired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
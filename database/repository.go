@@ -0,0 +1,273 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned by repositories when a record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Repository stores records of type T as JSON in a single bucket, keyed by a
+// field of the record. Records are decoded strictly: a stored field that T
+// does not know about is an error instead of being silently dropped, since
+// it means that the record was written by a newer schema or was not migrated.
+type Repository[T any] struct {
+	db     *bbolt.DB
+	bucket string
+	noun   string // What a record is called in error messages
+	key    func(T) string
+}
+
+// NewRepository returns a repository for the records in the given bucket.
+//
+// Parameters:
+//   - db:     A pointer to the bbolt.DB instance.
+//   - bucket: The name of the bucket holding the records.
+//   - noun:   What a record is called in error messages, such as "device".
+//   - key:    A function that returns the key of a record.
+//
+// Returns:
+//   - Repository[T]: The repository.
+func NewRepository[T any](db *bbolt.DB, bucket, noun string, key func(T) string) Repository[T] {
+	return Repository[T]{db: db, bucket: bucket, noun: noun, key: key}
+}
+
+// Devices returns the repository of managed devices, keyed by serial number.
+func Devices(db *bbolt.DB) Repository[models.AxisDevice] {
+	return NewRepository(db, DevicesBucket, "device", func(d models.AxisDevice) string { return d.SerialNumber })
+}
+
+// Users returns the repository of Neba accounts, keyed by username.
+func Users(db *bbolt.DB) Repository[models.User] {
+	return NewRepository(db, UsersBucket, "user", func(u models.User) string { return u.Username })
+}
+
+// Sessions returns the repository of browser sessions, keyed by token.
+func Sessions(db *bbolt.DB) Repository[models.Session] {
+	return NewRepository(db, SessionsBucket, "session", func(s models.Session) string { return s.Token })
+}
+
+// Credentials returns the repository of credential profiles, keyed by name.
+func Credentials(db *bbolt.DB) Repository[models.Credential] {
+	return NewRepository(db, CredentialsBucket, "credential profile", func(c models.Credential) string { return c.Name })
+}
+
+// Jobs returns the repository of background job records, keyed by ID.
+func Jobs(db *bbolt.DB) Repository[models.Job] {
+	return NewRepository(db, JobsBucket, "job", func(j models.Job) string { return j.ID })
+}
+
+// Settings returns the repository of settings stored in the database, keyed
+// by name. Unlike the configuration file, these are settings that are
+// managed entirely through Neba.
+func Settings(db *bbolt.DB) Repository[models.Setting] {
+	return NewRepository(db, SettingsBucket, "setting", func(s models.Setting) string { return s.Key })
+}
+
+// Get retrieves the record with the given key.
+//
+// Parameters:
+//   - key: The key of the record.
+//
+// Returns:
+//   - *T:    A pointer to the record if found, or nil if not found.
+//   - error: An error wrapping ErrNotFound if there is no such record, or
+//     an error if the record cannot be decoded.
+func (r Repository[T]) Get(key string) (*T, error) {
+	var record T
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		bucket, err := r.open(tx)
+		if err != nil {
+			return err
+		}
+		value := bucket.Get([]byte(key))
+		if value == nil {
+			return fmt.Errorf("%s %s %w", r.noun, key, ErrNotFound)
+		}
+		return r.decode(key, value, &record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// Put stores the record, overwriting any record with the same key.
+//
+// Parameters:
+//   - record: The record to store.
+//
+// Returns:
+//   - error: An error if the record has no key or cannot be stored.
+func (r Repository[T]) Put(record T) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		return r.PutTx(tx, record)
+	})
+}
+
+// PutTx is like Put, but stores the record as part of the given writable
+// transaction, so that several changes can be made atomically.
+func (r Repository[T]) PutTx(tx *bbolt.Tx, record T) error {
+	key := r.key(record)
+	if key == "" {
+		return fmt.Errorf("%s has no key", r.noun)
+	}
+	bucket, err := r.open(tx)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal %s to JSON: %v", r.noun, err)
+	}
+	return bucket.Put([]byte(key), encoded)
+}
+
+// Modify loads the record with the given key, applies fn to it, and stores
+// the result, all in one transaction. If fn returns an error, nothing is
+// stored.
+//
+// Parameters:
+//   - key: The key of the record.
+//   - fn:  The function that changes the record.
+//
+// Returns:
+//   - error: An error wrapping ErrNotFound if there is no such record, the
+//     error returned by fn, or an error if the record cannot be stored.
+func (r Repository[T]) Modify(key string, fn func(*T) error) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := r.open(tx)
+		if err != nil {
+			return err
+		}
+		value := bucket.Get([]byte(key))
+		if value == nil {
+			return fmt.Errorf("%s %s %w", r.noun, key, ErrNotFound)
+		}
+		var record T
+		if err := r.decode(key, value, &record); err != nil {
+			return err
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+		if r.key(record) != key {
+			if err := bucket.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return r.PutTx(tx, record)
+	})
+}
+
+// Delete removes the record with the given key. Deleting a record that does
+// not exist is not an error.
+//
+// Parameters:
+//   - key: The key of the record.
+//
+// Returns:
+//   - error: An error if the delete operation fails, otherwise nil.
+func (r Repository[T]) Delete(key string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := r.open(tx)
+		if err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(key))
+	})
+}
+
+// List retrieves every record, ordered by key.
+//
+// Returns:
+//   - []T:   A slice containing all records.
+//   - error: An error if the bucket is not found or a record cannot be decoded.
+func (r Repository[T]) List() ([]T, error) {
+	return r.Filter(nil)
+}
+
+// Filter retrieves the records accepted by the given function, ordered by key.
+//
+// Parameters:
+//   - keep: A function that reports whether a record should be included, or
+//     nil to include all.
+//
+// Returns:
+//   - []T:   A slice containing the accepted records.
+//   - error: An error if the bucket is not found or a record cannot be decoded.
+func (r Repository[T]) Filter(keep func(T) bool) ([]T, error) {
+	var records []T
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		bucket, err := r.open(tx)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var record T
+			if err := r.decode(string(key), value, &record); err != nil {
+				return err
+			}
+			if keep == nil || keep(record) {
+				records = append(records, record)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Count returns the number of records.
+//
+// Returns:
+//   - int:   The number of records.
+//   - error: An error if the bucket is not found.
+func (r Repository[T]) Count() (int, error) {
+	count := 0
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		bucket, err := r.open(tx)
+		if err != nil {
+			return err
+		}
+		count = bucket.Stats().KeyN
+		return nil
+	})
+
+	return count, err
+}
+
+// isNotFound reports whether err means that a record does not exist.
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+func (r Repository[T]) open(tx *bbolt.Tx) (*bbolt.Bucket, error) {
+	bucket := tx.Bucket([]byte(r.bucket))
+	if bucket == nil {
+		return nil, fmt.Errorf("bucket %s not found", r.bucket)
+	}
+	return bucket, nil
+}
+
+func (r Repository[T]) decode(key string, value []byte, record *T) error {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(record); err != nil {
+		return fmt.Errorf("decode %s %s: %v", r.noun, key, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// UpdateSession stores the given session in the sessions bucket, using the session token as the key.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - session: The Session to store.
+//
+// Returns:
+//   - error: An error if the update operation fails, otherwise nil.
+func UpdateSession(db *bbolt.DB, session models.Session) error {
+	return Sessions(db).Put(session)
+}
+
+// ViewSession retrieves the session with the given token from the sessions bucket.
+// Expired sessions are removed and reported as not found.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - token: The session token, as sent by the browser cookie.
+//
+// Returns:
+//   - A pointer to the Session if found, or nil if not found.
+//   - An error if the session is not found, expired, or cannot be decoded.
+func ViewSession(db *bbolt.DB, token string) (*models.Session, error) {
+	session, err := Sessions(db).Get(token)
+	if isNotFound(err) {
+		// Do not repeat the token in the error.
+		return nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Expired() {
+		if err := DeleteSession(db, token); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("session expired")
+	}
+
+	return session, nil
+}
+
+// DeleteSession removes the session with the given token from the sessions bucket.
+// Deleting a session that does not exist is not an error.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - token: The token of the session to delete.
+//
+// Returns:
+//   - error: An error if the delete operation fails, otherwise nil.
+func DeleteSession(db *bbolt.DB, token string) error {
+	return Sessions(db).Delete(token)
+}
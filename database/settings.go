@@ -0,0 +1,95 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// GetSetting decodes the setting with the given key into value. If the
+// setting has never been stored, value is left unchanged, so it can be
+// initialized with the default beforehand.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - key: The key of the setting.
+//   - value: A pointer to the value to decode the setting into.
+//
+// Returns:
+//   - error: An error if the setting cannot be read or decoded.
+func GetSetting(db *bbolt.DB, key string, value any) error {
+	setting, err := Settings(db).Get(key)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(setting.Value, value); err != nil {
+		return fmt.Errorf("decode setting %s: %v", key, err)
+	}
+	return nil
+}
+
+// PutSetting stores value as the setting with the given key.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - key: The key of the setting.
+//   - value: The value to store; it must be encodable as JSON.
+//
+// Returns:
+//   - error: An error if the value cannot be encoded or stored.
+func PutSetting(db *bbolt.DB, key string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode setting %s: %v", key, err)
+	}
+	return Settings(db).Put(models.Setting{Key: key, Value: encoded, UpdatedAt: time.Now()})
+}
+
+// UpdateSetting decodes the setting with the given key into value, calls fn
+// to change it, and stores value again, all in one transaction, so that
+// concurrent updates of the same setting do not overwrite each other. If the
+// setting has never been stored, value is left unchanged before fn is called.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - key: The key of the setting.
+//   - value: A pointer to the value to decode the setting into and store.
+//   - fn: The function that changes value.
+//
+// Returns:
+//   - error: The error returned by fn, or an error if the setting cannot be
+//     read, decoded, encoded, or stored.
+func UpdateSetting(db *bbolt.DB, key string, value any, fn func() error) error {
+	repo := Settings(db)
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := repo.open(tx)
+		if err != nil {
+			return err
+		}
+		if raw := bucket.Get([]byte(key)); raw != nil {
+			var setting models.Setting
+			if err := repo.decode(key, raw, &setting); err != nil {
+				return err
+			}
+			if err := json.Unmarshal(setting.Value, value); err != nil {
+				return fmt.Errorf("decode setting %s: %v", key, err)
+			}
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("encode setting %s: %v", key, err)
+		}
+		return repo.PutTx(tx, models.Setting{Key: key, Value: encoded, UpdatedAt: time.Now()})
+	})
+}
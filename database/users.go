@@ -0,0 +1,99 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// UpdateUser stores the given user in the users bucket, using the username as the key.
+// An existing user with the same username is overwritten.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - user: The User to store.
+//
+// Returns:
+//   - error: An error if the update operation fails, otherwise nil.
+func UpdateUser(db *bbolt.DB, user models.User) error {
+	return Users(db).Put(user)
+}
+
+// ViewUser retrieves the user with the given username from the users bucket.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - username: The username of the user to retrieve.
+//
+// Returns:
+//   - A pointer to the User if found, or nil if not found.
+//   - An error if the bucket or user is not found, or if the JSON data cannot be decoded.
+func ViewUser(db *bbolt.DB, username string) (*models.User, error) {
+	return Users(db).Get(username)
+}
+
+// ListUsers retrieves every user stored in the users bucket, ordered by username.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - A slice containing all users.
+//   - An error if the bucket is not found or a record cannot be decoded.
+func ListUsers(db *bbolt.DB) ([]models.User, error) {
+	return Users(db).List()
+}
+
+// DeleteUser removes the user with the given username from the users bucket.
+// Deleting a user that does not exist is not an error.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - username: The username of the user to delete.
+//
+// Returns:
+//   - error: An error if the delete operation fails, otherwise nil.
+func DeleteUser(db *bbolt.DB, username string) error {
+	return Users(db).Delete(username)
+}
+
+// CountUsers returns the number of user accounts stored in the database.
+// It is used to decide whether the first-run setup still has to be completed.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - int: The number of stored users.
+//   - error: An error if the users bucket cannot be read.
+func CountUsers(db *bbolt.DB) (int, error) {
+	return Users(db).Count()
+}
+
+// CreateFirstUser stores the given user if no user exists yet. Counting and
+// storing happen in one transaction, so that concurrent first-run setups
+// cannot both create an account.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//   - user: The User to store.
+//
+// Returns:
+//   - bool: true if the user was stored, false if an account already existed.
+//   - error: An error if the users bucket cannot be read or written.
+func CreateFirstUser(db *bbolt.DB, user models.User) (bool, error) {
+	created := false
+	err := db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(UsersBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", UsersBucket)
+		}
+		if key, _ := bucket.Cursor().First(); key != nil {
+			return nil
+		}
+		created = true
+		return Users(nil).PutTx(tx, user) // Only used with this transaction
+	})
+	return created, err
+}
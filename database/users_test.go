@@ -0,0 +1,83 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/furkansuleymana/neba/database/models"
+)
+
+func TestCreateFirstUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  []string // Usernames stored before
+		want      bool
+		wantCount int
+	}{
+		{name: "no accounts", want: true, wantCount: 1},
+		{name: "an account exists", existing: []string{"admin"}, want: false, wantCount: 1},
+		{name: "several accounts exist", existing: []string{"admin", "viewer"}, want: false, wantCount: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(filepath.Join(t.TempDir(), "neba.db"), UsersBucket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			for _, username := range tt.existing {
+				if err := UpdateUser(db, models.User{Username: username}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			created, err := CreateFirstUser(db, models.User{Username: "first"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created != tt.want {
+				t.Errorf("CreateFirstUser() = %v, want %v", created, tt.want)
+			}
+			if count, _ := CountUsers(db); count != tt.wantCount {
+				t.Errorf("%d accounts stored, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestCreateFirstUserConcurrently(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "neba.db"), UsersBucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var (
+		wg      sync.WaitGroup
+		created atomic.Int32
+	)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := CreateFirstUser(db, models.User{Username: fmt.Sprintf("admin%d", i)})
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				created.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created.Load() != 1 {
+		t.Errorf("%d setups created an account, want 1", created.Load())
+	}
+	if count, _ := CountUsers(db); count != 1 {
+		t.Errorf("%d accounts stored, want 1", count)
+	}
+}
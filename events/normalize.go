@@ -0,0 +1,171 @@
+// Package events subscribes to the events of managed Axis devices and turns
+// them into the device-independent events of the event log.
+package events
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+)
+
+// Categories lists the event categories in the order they are offered as
+// filters.
+var Categories = []string{
+	models.EventTampering,
+	models.EventMotion,
+	models.EventIO,
+	models.EventStorage,
+	models.EventHardware,
+	models.EventOther,
+}
+
+// stateItems are the data items that carry the state of an event, in order of
+// preference.
+var stateItems = []string{"active", "state", "State", "LogicalState", "disruption", "tampering", "triggered"}
+
+// Normalize turns the notification of a device into an event: the topic is
+// stripped of its namespaces, and the category and summary are derived from
+// the topic and message, so that events look alike across models and
+// firmware versions.
+//
+// Parameters:
+//   - serial:       The serial number of the device.
+//   - notification: The notification of the device.
+//   - received:     When Neba received the notification.
+//
+// Returns:
+//   - models.Event: The normalized event.
+func Normalize(serial string, notification network.EventNotification, received time.Time) models.Event {
+	topic := Topic(notification.Topic)
+	source := notification.Source
+	if len(notification.Key) > 0 {
+		source = maps.Clone(notification.Key)
+		maps.Copy(source, notification.Source)
+	}
+	event := models.Event{
+		Time:         received,
+		SerialNumber: serial,
+		Topic:        topic,
+		Source:       source,
+		Data:         notification.Data,
+	}
+
+	segments := strings.Split(topic, "/")
+	name := segments[len(segments)-1]
+	lower := strings.ToLower(topic)
+	active, known := state(notification.Data)
+
+	switch {
+	case strings.Contains(lower, "tampering"):
+		event.Category = models.EventTampering
+		event.Summary = "Tampering detected"
+		if known && !active {
+			event.Summary = "Tampering cleared"
+		}
+	case strings.Contains(lower, "motion") || strings.Contains(lower, "/vmd"):
+		event.Category = models.EventMotion
+		event.Summary = onOff(known, active, "Motion detected", "Motion stopped")
+		if strings.Contains(lower, "/vmd/") {
+			event.Summary += " (" + name + ")"
+		}
+	case strings.Contains(lower, "storage"):
+		event.Category = models.EventStorage
+		disk := firstOf(source, "disk_id", "disk", "Disk")
+		event.Summary = onOff(known, active, "Storage disrupted", "Storage recovered")
+		if disk != "" {
+			event.Summary += " on " + disk
+		}
+	case strings.Contains(lower, "hardwarefailure"):
+		event.Category = models.EventHardware
+		event.Summary = onOff(known, active, words(name)+" reported", words(name)+" cleared")
+	case strings.Contains(lower, "device/io/") || strings.Contains(lower, "digitalinput") || strings.Contains(lower, "relay"):
+		event.Category = models.EventIO
+		label := words(name)
+		if port := firstOf(source, "port", "InputToken", "RelayToken", "port_id"); port != "" {
+			label += " " + port
+		}
+		event.Summary = onOff(known, active, label+" active", label+" inactive")
+	default:
+		event.Category = models.EventOther
+		event.Summary = words(name)
+		if known {
+			event.Summary = onOff(true, active, event.Summary+" on", event.Summary+" off")
+		}
+	}
+	return event
+}
+
+// Topic strips the namespace prefixes from the segments of an event topic,
+// turning "tns1:Device/tnsaxis:IO/Port" into "Device/IO/Port".
+func Topic(topic string) string {
+	segments := strings.Split(topic, "/")
+	for i, segment := range segments {
+		if _, name, ok := strings.Cut(segment, ":"); ok {
+			segments[i] = name
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Describe formats the items of an event as "name=value" pairs, sorted by
+// name.
+func Describe(items map[string]string) string {
+	pairs := make([]string, 0, len(items))
+	for name, value := range items {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, value))
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// state reports whether the event is active, if its data carries a state.
+func state(data map[string]string) (bool, bool) {
+	for _, item := range stateItems {
+		if value, ok := data[item]; ok {
+			switch strings.ToLower(value) {
+			case "1", "true", "active", "on":
+				return true, true
+			case "0", "false", "inactive", "off":
+				return false, true
+			}
+		}
+	}
+	return false, false
+}
+
+// onOff picks the summary for the state of an event. Events without a state
+// are treated as active, since most of them are one-off occurrences.
+func onOff(known, active bool, on, off string) string {
+	if known && !active {
+		return off
+	}
+	return on
+}
+
+// firstOf returns the first non-empty value of the given items.
+func firstOf(items map[string]string, names ...string) string {
+	for _, name := range names {
+		if value := items[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// words splits a topic segment such as "VirtualInput" into "Virtual input".
+func words(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+			b.WriteRune(' ')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name         string
+		notification network.EventNotification
+		wantTopic    string
+		wantCategory string
+		wantSummary  string
+	}{
+		{
+			name: "tampering",
+			notification: network.EventNotification{
+				Topic: "tns1:VideoSource/tnsaxis:Tampering",
+				Data:  map[string]string{"tampering": "1"},
+			},
+			wantTopic:    "VideoSource/Tampering",
+			wantCategory: models.EventTampering,
+			wantSummary:  "Tampering detected",
+		},
+		{
+			name: "tampering cleared",
+			notification: network.EventNotification{
+				Topic: "tns1:VideoSource/tnsaxis:Tampering",
+				Data:  map[string]string{"tampering": "0"},
+			},
+			wantTopic:    "VideoSource/Tampering",
+			wantCategory: models.EventTampering,
+			wantSummary:  "Tampering cleared",
+		},
+		{
+			name: "motion alarm",
+			notification: network.EventNotification{
+				Topic: "tns1:VideoSource/MotionAlarm",
+				Data:  map[string]string{"State": "true"},
+			},
+			wantTopic:    "VideoSource/MotionAlarm",
+			wantCategory: models.EventMotion,
+			wantSummary:  "Motion detected",
+		},
+		{
+			name: "motion detection profile",
+			notification: network.EventNotification{
+				Topic: "tnsaxis:CameraApplicationPlatform/VMD/Camera1Profile1",
+				Data:  map[string]string{"active": "0"},
+			},
+			wantTopic:    "CameraApplicationPlatform/VMD/Camera1Profile1",
+			wantCategory: models.EventMotion,
+			wantSummary:  "Motion stopped (Camera1Profile1)",
+		},
+		{
+			name: "storage disruption",
+			notification: network.EventNotification{
+				Topic:  "tnsaxis:Storage/Disruption",
+				Source: map[string]string{"disk_id": "SD_DISK"},
+				Data:   map[string]string{"disruption": "1"},
+			},
+			wantTopic:    "Storage/Disruption",
+			wantCategory: models.EventStorage,
+			wantSummary:  "Storage disrupted on SD_DISK",
+		},
+		{
+			name: "hardware failure",
+			notification: network.EventNotification{
+				Topic: "tns1:Device/tnsaxis:HardwareFailure/PowerSupplyFailure/PTZPowerFailure",
+				Data:  map[string]string{"Failed": "1"},
+			},
+			wantTopic:    "Device/HardwareFailure/PowerSupplyFailure/PTZPowerFailure",
+			wantCategory: models.EventHardware,
+			wantSummary:  "PTZPower failure reported",
+		},
+		{
+			name: "input port with the port in the key",
+			notification: network.EventNotification{
+				Topic: "tns1:Device/tnsaxis:IO/Port",
+				Key:   map[string]string{"port": "1"},
+				Data:  map[string]string{"state": "1"},
+			},
+			wantTopic:    "Device/IO/Port",
+			wantCategory: models.EventIO,
+			wantSummary:  "Port 1 active",
+		},
+		{
+			name: "virtual input",
+			notification: network.EventNotification{
+				Topic:  "tns1:Device/tnsaxis:IO/VirtualInput",
+				Source: map[string]string{"port": "3"},
+				Data:   map[string]string{"active": "0"},
+			},
+			wantTopic:    "Device/IO/VirtualInput",
+			wantCategory: models.EventIO,
+			wantSummary:  "Virtual input 3 inactive",
+		},
+		{
+			name: "other event with a state",
+			notification: network.EventNotification{
+				Topic: "tns1:Device/tnsaxis:Light/Status",
+				Data:  map[string]string{"state": "ON"},
+			},
+			wantTopic:    "Device/Light/Status",
+			wantCategory: models.EventOther,
+			wantSummary:  "Status on",
+		},
+		{
+			name: "other event without a state",
+			notification: network.EventNotification{
+				Topic: "tns1:Device/tnsaxis:Status/SystemReady",
+				Data:  map[string]string{"ready": "1"},
+			},
+			wantTopic:    "Device/Status/SystemReady",
+			wantCategory: models.EventOther,
+			wantSummary:  "System ready",
+		},
+	}
+	received := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := Normalize("ACCC8E000001", tt.notification, received)
+			if event.Topic != tt.wantTopic {
+				t.Errorf("topic = %q, want %q", event.Topic, tt.wantTopic)
+			}
+			if event.Category != tt.wantCategory {
+				t.Errorf("category = %q, want %q", event.Category, tt.wantCategory)
+			}
+			if event.Summary != tt.wantSummary {
+				t.Errorf("summary = %q, want %q", event.Summary, tt.wantSummary)
+			}
+			if event.SerialNumber != "ACCC8E000001" || !event.Time.Equal(received) {
+				t.Errorf("serial number and time = %s %s, want the given ones", event.SerialNumber, event.Time)
+			}
+		})
+	}
+}
+
+func TestNormalizeKeepsNotification(t *testing.T) {
+	notification := network.EventNotification{
+		Topic:  "tns1:Device/tnsaxis:IO/Port",
+		Key:    map[string]string{"port": "1"},
+		Source: map[string]string{"port": "2", "name": "door"},
+	}
+	event := Normalize("ACCC8E000001", notification, time.Now())
+	if event.Source["port"] != "2" || event.Source["name"] != "door" {
+		t.Errorf("source = %v, want the source items over the key items", event.Source)
+	}
+	if len(notification.Key) != 1 {
+		t.Errorf("the key of the notification was changed: %v", notification.Key)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		items map[string]string
+		want  string
+	}{
+		{nil, ""},
+		{map[string]string{"state": "1"}, "state=1"},
+		{map[string]string{"port": "1", "name": "door"}, "name=door, port=1"},
+	}
+	for _, tt := range tests {
+		if got := Describe(tt.items); got != tt.want {
+			t.Errorf("Describe(%v) = %q, want %q", tt.items, got, tt.want)
+		}
+	}
+}
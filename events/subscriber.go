@@ -0,0 +1,229 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Reconnection constants
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 5 * time.Minute
+
+	// replayWindow is how long after subscribing notifications are treated
+	// as the device reporting its current states, which are only stored if
+	// they changed since the previous subscription.
+	replayWindow = 5 * time.Second
+)
+
+// Status is the state of the event subscription of a device.
+type Status struct {
+	Connected bool
+	Since     time.Time // When the subscription connected or failed
+	LastEvent time.Time
+	Error     string
+}
+
+// Subscriber keeps an event subscription open to every managed device and
+// stores the events it receives. Subscriptions that fail are retried with an
+// increasing delay.
+type Subscriber struct {
+	db       *bbolt.DB
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+	running  map[string]*subscription
+	statuses map[string]Status
+}
+
+// subscription is the running subscription of a single device.
+type subscription struct {
+	cancel context.CancelFunc
+	key    string // What the subscription was started with; see subscriptionKey
+}
+
+// NewSubscriber returns a subscriber that stores events in the given
+// database. It does not subscribe to any device until Sync is called.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - *Subscriber: The subscriber.
+func NewSubscriber(db *bbolt.DB) *Subscriber {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Subscriber{
+		db:       db,
+		ctx:      ctx,
+		cancel:   cancel,
+		running:  map[string]*subscription{},
+		statuses: map[string]Status{},
+	}
+}
+
+// Sync starts subscriptions for devices that have none, restarts those whose
+// address, credentials, or topic filters changed, and stops those of devices
+// that are no longer managed. If events are disabled, every subscription is
+// stopped.
+//
+// Parameters:
+//   - enabled:      Whether to subscribe to events at all.
+//   - topicFilters: The topics to subscribe to.
+//
+// Returns:
+//   - error: An error if the devices cannot be listed.
+func (s *Subscriber) Sync(enabled bool, topicFilters []string) error {
+	var devices []models.AxisDevice
+	if enabled {
+		var err error
+		if devices, err = database.Devices(s.db).List(); err != nil {
+			return err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx.Err() != nil {
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		wanted[device.SerialNumber] = true
+		key := subscriptionKey(device, topicFilters)
+		if running, ok := s.running[device.SerialNumber]; ok {
+			if running.key == key {
+				continue
+			}
+			running.cancel()
+		}
+
+		ctx, cancel := context.WithCancel(s.ctx)
+		s.running[device.SerialNumber] = &subscription{cancel: cancel, key: key}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(ctx, device, slices.Clone(topicFilters))
+		}()
+	}
+	for serial, running := range s.running {
+		if !wanted[serial] {
+			running.cancel()
+			delete(s.running, serial)
+			delete(s.statuses, serial)
+		}
+	}
+	return nil
+}
+
+// Statuses returns the subscription status of every subscribed device, by
+// serial number.
+func (s *Subscriber) Statuses() map[string]Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return maps.Clone(s.statuses)
+}
+
+// Stop ends every subscription and waits for them to finish.
+func (s *Subscriber) Stop() {
+	s.mutex.Lock()
+	s.cancel()
+	s.mutex.Unlock()
+	s.wg.Wait()
+}
+
+// run keeps the subscription of the device open until ctx is done.
+func (s *Subscriber) run(ctx context.Context, device models.AxisDevice, topicFilters []string) {
+	client := network.NewClient(device.IPAddress, device.Username, device.Password)
+	states := map[string]string{} // Last data of each topic and source
+	delay := minRetryDelay
+
+	for ctx.Err() == nil {
+		stream, err := network.OpenEventStream(ctx, client, topicFilters)
+		if err == nil {
+			delay = minRetryDelay
+			s.setStatus(ctx, device.SerialNumber, func(status *Status) {
+				*status = Status{Connected: true, Since: time.Now(), LastEvent: status.LastEvent}
+			})
+			err = s.receive(ctx, device.SerialNumber, stream, states)
+			stream.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Warn("device event subscription failed", slog.String("serial", device.SerialNumber),
+			slog.Any("error", err), slog.Duration("retry", delay))
+		s.setStatus(ctx, device.SerialNumber, func(status *Status) {
+			*status = Status{Since: time.Now(), LastEvent: status.LastEvent, Error: err.Error()}
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// receive stores the events of the stream until it ends. Right after
+// subscribing, devices report the current state of their stateful events;
+// states that did not change since the previous subscription are skipped,
+// so that reconnecting does not fill the event log.
+func (s *Subscriber) receive(ctx context.Context, serial string, stream *network.EventStream, states map[string]string) error {
+	replayUntil := time.Now().Add(replayWindow)
+	for {
+		notification, err := stream.Next()
+		if err != nil {
+			return err
+		}
+		received := time.Now()
+
+		key := notification.Topic + "\x00" + Describe(notification.Source) + "\x00" + Describe(notification.Key)
+		data := Describe(notification.Data)
+		previous, seen := states[key]
+		states[key] = data
+		if seen && previous == data && received.Before(replayUntil) {
+			continue
+		}
+
+		event := Normalize(serial, notification, received)
+		if err := database.AppendEvents(s.db, event); err != nil {
+			slog.Error("failed to store device event", slog.String("serial", serial), slog.Any("error", err))
+			continue
+		}
+		s.setStatus(ctx, serial, func(status *Status) {
+			status.LastEvent = received
+		})
+	}
+}
+
+// setStatus updates the status of the device, unless its subscription has
+// been stopped in the meantime.
+func (s *Subscriber) setStatus(ctx context.Context, serial string, update func(status *Status)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	status := s.statuses[serial]
+	update(&status)
+	s.statuses[serial] = status
+}
+
+// subscriptionKey identifies what a subscription depends on, so that Sync can
+// tell when it must be restarted.
+func subscriptionKey(device models.AxisDevice, topicFilters []string) string {
+	return strings.Join(append([]string{device.IPAddress, device.Username, device.Password}, topicFilters...), "\x00")
+}
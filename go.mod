@@ -6,6 +6,7 @@ require (
 	github.com/koron/go-ssdp v0.0.5
 	github.com/pkg/browser v0.0.0-20240102092130-5ac0b6a4141c
 	go.etcd.io/bbolt v1.3.11
+	golang.org/x/crypto v0.32.0
 )
 
 require (
@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Password rotation constants
+	rotatedPasswordLength = 20
+	passwordAlphabet      = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789-_."
+)
+
+var (
+	accountsTmpl *template.Template
+
+	// passwordLocks serializes password changes per device, so that a change
+	// cannot roll back the password another one has just set.
+	passwordLocks = struct {
+		sync.Mutex
+		m map[string]*sync.Mutex
+	}{m: make(map[string]*sync.Mutex)}
+)
+
+// AccountsPageData contains the data for the /manage/{serial}/accounts page
+type AccountsPageData struct {
+	Device   models.AxisDevice
+	Accounts []network.DeviceAccount
+	Roles    []string
+	Result   *ActionResult
+}
+
+// FleetAccountsPageData contains the data for the /accounts page
+type FleetAccountsPageData struct {
+	Sites  []string
+	Tags   []string
+	Job    *models.Job
+	Result *ActionResult
+}
+
+func RegisterAccountsRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, jm *jobs.Manager) {
+	var err error
+	accountsTmpl, err = template.New("accounts.html").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).ParseFS(ui.FS, "accounts.html", "jobs.html", "snapshot.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /manage/{serial}/accounts", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		renderAccounts(w, r, *device, nil)
+	}))
+	mux.Handle("POST /manage/{serial}/accounts", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleAddAccount(w, r, db)
+	}))
+	mux.Handle("POST /manage/{serial}/accounts/{account}", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateAccount(w, r, db)
+	}))
+	mux.Handle("DELETE /manage/{serial}/accounts/{account}", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveAccount(w, r, db)
+	}))
+
+	mux.Handle("GET /accounts", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		renderFleetAccounts(w, r, db, FleetAccountsPageData{})
+	}))
+	mux.Handle("POST /accounts/rotate", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleRotatePasswords(w, r, db, jm)
+	}))
+	mux.Handle("POST /accounts/remove", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveAccountEverywhere(w, r, db, jm)
+	}))
+}
+
+func handleAddAccount(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Add device account")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	account := network.DeviceAccount{
+		Name: strings.TrimSpace(r.FormValue("name")),
+		Role: r.FormValue("role"),
+		PTZ:  r.FormValue("ptz") != "",
+	}
+	auditTargets(r, device.SerialNumber, account.Name)
+
+	password := r.FormValue("password")
+	if account.Name == "" || password == "" {
+		renderAccounts(w, r, *device, &ActionResult{Message: "Name and password must not be empty."})
+		return
+	}
+	if err := network.AddAccount(deviceClient(*device), account, password); err != nil {
+		renderAccounts(w, r, *device, &ActionResult{Message: err.Error()})
+		return
+	}
+	renderAccounts(w, r, *device, &ActionResult{Success: true, Message: fmt.Sprintf("Added %s to %s.", account.Name, device.SerialNumber)})
+}
+
+// handleUpdateAccount changes the role and, optionally, the password of an
+// account. A new password for the account Neba signs in with is stored as
+// well, and the role of that account cannot be lowered, since Neba would
+// lose access to the device.
+func handleUpdateAccount(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Update device account")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	account := network.DeviceAccount{
+		Name: r.PathValue("account"),
+		Role: r.FormValue("role"),
+		PTZ:  r.FormValue("ptz") != "",
+	}
+	auditTargets(r, device.SerialNumber, account.Name)
+	password := r.FormValue("password")
+
+	if account.Name == device.Username {
+		if account.Role != network.AccountAdmin {
+			renderAccounts(w, r, *device, &ActionResult{Message: fmt.Sprintf("Neba signs in as %s, so it must remain an admin.", account.Name)})
+			return
+		}
+		if err := network.UpdateAccount(deviceClient(*device), account, ""); err != nil {
+			renderAccounts(w, r, *device, &ActionResult{Message: err.Error()})
+			return
+		}
+		if password != "" {
+			if _, err := changeDevicePassword(db, device.SerialNumber, password); err != nil {
+				renderAccounts(w, r, *device, &ActionResult{Message: err.Error()})
+				return
+			}
+			updated, err := database.Devices(db).Get(device.SerialNumber)
+			if err != nil {
+				renderAccounts(w, r, *device, &ActionResult{Message: fmt.Sprintf("Updated %s on %s and changed its password, but reading the device failed: %v", account.Name, device.SerialNumber, err)})
+				return
+			}
+			device = updated
+		}
+	} else if err := network.UpdateAccount(deviceClient(*device), account, password); err != nil {
+		renderAccounts(w, r, *device, &ActionResult{Message: err.Error()})
+		return
+	}
+	renderAccounts(w, r, *device, &ActionResult{Success: true, Message: fmt.Sprintf("Updated %s on %s.", account.Name, device.SerialNumber)})
+}
+
+func handleRemoveAccount(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Remove device account")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	name := r.PathValue("account")
+	auditTargets(r, device.SerialNumber, name)
+
+	if name == device.Username {
+		renderAccounts(w, r, *device, &ActionResult{Message: fmt.Sprintf("Neba signs in as %s, so it cannot be removed.", name)})
+		return
+	}
+	if err := network.RemoveAccount(deviceClient(*device), name); err != nil {
+		renderAccounts(w, r, *device, &ActionResult{Message: err.Error()})
+		return
+	}
+	renderAccounts(w, r, *device, &ActionResult{Success: true, Message: fmt.Sprintf("Removed %s from %s.", name, device.SerialNumber)})
+}
+
+// handleRotatePasswords starts a job that gives every selected device a new,
+// unique password for the account Neba signs in with.
+func handleRotatePasswords(w http.ResponseWriter, r *http.Request, db *bbolt.DB, jm *jobs.Manager) {
+	auditAction(r, "Rotate device passwords")
+	devices, ok := selectedDevices(w, r, db)
+	if !ok {
+		return
+	}
+
+	user, _ := UserFromContext(r.Context())
+	job, err := jobs.Start(db, jm, "Rotate passwords", user.Username, serialNumbers(devices),
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			for _, device := range devices {
+				if ctx.Err() != nil {
+					return "Stopped because Neba is shutting down.", nil
+				}
+				password, err := generatePassword(rotatedPasswordLength)
+				if err != nil {
+					return "", err
+				}
+				previous, err := changeDevicePassword(db, device.SerialNumber, password)
+				if err != nil {
+					report.Failed(device.SerialNumber, "%v", err)
+					continue
+				}
+				if previous.CredentialProfile != "" {
+					report.Succeeded(device.SerialNumber, "Rotated the password of %s; the device no longer uses profile %s.",
+						previous.Username, previous.CredentialProfile)
+				} else {
+					report.Succeeded(device.SerialNumber, "Rotated the password of %s.", previous.Username)
+				}
+			}
+			return fmt.Sprintf("Processed %d devices.", len(devices)), nil
+		})
+	finishFleetAction(w, r, db, job, err)
+}
+
+// handleRemoveAccountEverywhere starts a job that removes an account from
+// every selected device that has it.
+func handleRemoveAccountEverywhere(w http.ResponseWriter, r *http.Request, db *bbolt.DB, jm *jobs.Manager) {
+	auditAction(r, "Remove device account everywhere")
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		renderFleetAccounts(w, r, db, FleetAccountsPageData{Result: &ActionResult{Message: "Enter the name of the account to remove."}})
+		return
+	}
+	devices, ok := selectedDevices(w, r, db)
+	if !ok {
+		return
+	}
+
+	user, _ := UserFromContext(r.Context())
+	job, err := jobs.Start(db, jm, fmt.Sprintf("Remove account %s", name), user.Username, serialNumbers(devices),
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			for _, device := range devices {
+				if ctx.Err() != nil {
+					return "Stopped because Neba is shutting down.", nil
+				}
+				if name == device.Username {
+					report.Failed(device.SerialNumber, "Neba signs in as %s.", name)
+					continue
+				}
+				client := deviceClient(device)
+				accounts, err := network.ListAccounts(client)
+				if err != nil {
+					report.Failed(device.SerialNumber, "%v", err)
+					continue
+				}
+				if !slices.ContainsFunc(accounts, func(a network.DeviceAccount) bool { return a.Name == name }) {
+					report.Succeeded(device.SerialNumber, "No such account.")
+					continue
+				}
+				if err := network.RemoveAccount(client, name); err != nil {
+					report.Failed(device.SerialNumber, "%v", err)
+					continue
+				}
+				report.Succeeded(device.SerialNumber, "Removed.")
+			}
+			return fmt.Sprintf("Processed %d devices.", len(devices)), nil
+		})
+	finishFleetAction(w, r, db, job, err)
+}
+
+// changeDevicePassword changes the password of the account Neba signs in
+// with on the device, keeping the stored credentials in step. The device is
+// read afresh while holding its password lock, and the new password is stored
+// first, so that it is never lost; if the device does not take it, the stored
+// credentials are rolled back to those read here, unless they have been
+// changed since.
+//
+// Parameters:
+//   - db:       A pointer to the bbolt.DB instance.
+//   - serial:   The serial number of the device.
+//   - password: The new password.
+//
+// Returns:
+//   - *models.AxisDevice: The device as it was before the change.
+//   - error:              An error if the password was not changed on the device.
+func changeDevicePassword(db *bbolt.DB, serial, password string) (*models.AxisDevice, error) {
+	unlock := lockDevicePassword(serial)
+	defer unlock()
+
+	var previous models.AxisDevice
+	devices := database.Devices(db)
+	err := devices.Modify(serial, func(d *models.AxisDevice) error {
+		previous = *d
+		d.Password = password
+		d.CredentialProfile = "" // The device no longer shares the profile's password.
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store new password: %w", err)
+	}
+
+	setErr := network.SetAccountPassword(deviceClient(previous), previous.Username, password)
+	if setErr == nil {
+		return &previous, nil
+	}
+
+	// The device may have taken the password even though no answer arrived.
+	changed := previous
+	changed.Password = password
+	if _, err := network.GetBasicDeviceInfo(deviceClient(changed)); err == nil {
+		return &previous, nil
+	}
+
+	err = devices.Modify(serial, func(d *models.AxisDevice) error {
+		if d.Password != password {
+			return errors.New("the stored password has been changed since")
+		}
+		d.Password = previous.Password
+		d.CredentialProfile = previous.CredentialProfile
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%v; rolling back the stored password failed: %v", setErr, err)
+	}
+	return nil, fmt.Errorf("%v; the stored password was rolled back", setErr)
+}
+
+// lockDevicePassword locks password changes of the device with the given
+// serial number, and returns the function that unlocks them.
+func lockDevicePassword(serial string) func() {
+	passwordLocks.Lock()
+	lock, ok := passwordLocks.m[serial]
+	if !ok {
+		lock = &sync.Mutex{}
+		passwordLocks.m[serial] = lock
+	}
+	passwordLocks.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// generatePassword returns a random password of the given length that
+// contains upper- and lower-case letters and digits. Characters that are
+// easily confused, such as l and 1, are left out.
+func generatePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for {
+		password := make([]byte, length)
+		for i := range password {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", fmt.Errorf("generate password: %w", err)
+			}
+			password[i] = passwordAlphabet[n.Int64()]
+		}
+		p := string(password)
+		if strings.ContainsAny(p, "ABCDEFGHJKLMNPQRSTUVWXYZ") && strings.ContainsAny(p, "abcdefghijkmnopqrstuvwxyz") &&
+			strings.ContainsAny(p, "23456789") {
+			return p, nil
+		}
+	}
+}
+
+// selectDevices returns the devices matching the comma-separated selectors
+// in the "devices" form value, or every device the user may see if there are
+// none.
+//
+// Returns:
+//   - []models.AxisDevice: The selected devices.
+//   - error: An error if the devices cannot be read or none match.
+func selectDevices(r *http.Request, db *bbolt.DB) ([]models.AxisDevice, error) {
+	devices, err := scopedDevices(r, db)
+	if err != nil {
+		return nil, err
+	}
+	devices = matchDevices(devices, parseList(r.FormValue("devices")))
+	if len(devices) == 0 {
+		return nil, errors.New("no devices match the selection")
+	}
+	return devices, nil
+}
+
+// selectedDevices is like selectDevices, but renders the error on the
+// /accounts page and returns false.
+func selectedDevices(w http.ResponseWriter, r *http.Request, db *bbolt.DB) ([]models.AxisDevice, bool) {
+	devices, err := selectDevices(r, db)
+	if err != nil {
+		renderFleetAccounts(w, r, db, FleetAccountsPageData{Result: &ActionResult{Message: err.Error()}})
+		return nil, false
+	}
+	return devices, true
+}
+
+// matchDevices returns the devices whose serial number, site, or one of whose
+// tags is among the selectors. Without selectors, every device matches.
+func matchDevices(devices []models.AxisDevice, selectors []string) []models.AxisDevice {
+	if len(selectors) == 0 {
+		return devices
+	}
+	return slices.DeleteFunc(devices, func(device models.AxisDevice) bool {
+		for _, selector := range selectors {
+			if strings.EqualFold(selector, device.SerialNumber) || selector == device.Site || slices.Contains(device.Tags, selector) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func serialNumbers(devices []models.AxisDevice) []string {
+	serials := make([]string, len(devices))
+	for i, device := range devices {
+		serials[i] = device.SerialNumber
+	}
+	return serials
+}
+
+// finishFleetAction renders the fleet page with the started job, or with the
+// error that kept it from starting.
+func finishFleetAction(w http.ResponseWriter, r *http.Request, db *bbolt.DB, job *models.Job, err error) {
+	if err != nil {
+		renderFleetAccounts(w, r, db, FleetAccountsPageData{Result: &ActionResult{Message: fmt.Sprintf("Starting the job failed: %v", err)}})
+		return
+	}
+	auditTargets(r, job.Targets...)
+	renderFleetAccounts(w, r, db, FleetAccountsPageData{
+		Job:    job,
+		Result: &ActionResult{Success: true, Message: fmt.Sprintf("Started: %s on %d devices.", job.Kind, len(job.Targets))},
+	})
+}
+
+func renderAccounts(w http.ResponseWriter, r *http.Request, device models.AxisDevice, result *ActionResult) {
+	data := AccountsPageData{Device: device, Roles: network.AccountRoles, Result: result}
+	accounts, err := network.ListAccounts(deviceClient(device))
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+	data.Accounts = accounts
+
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+	if err := accountsTmpl.ExecuteTemplate(w, "accounts.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func renderFleetAccounts(w http.ResponseWriter, r *http.Request, db *bbolt.DB, data FleetAccountsPageData) {
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+
+	devices, err := scopedDevices(r, db)
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+	for _, device := range devices {
+		if device.Site != "" && !slices.Contains(data.Sites, device.Site) {
+			data.Sites = append(data.Sites, device.Site)
+		}
+		for _, tag := range device.Tags {
+			if !slices.Contains(data.Tags, tag) {
+				data.Tags = append(data.Tags, tag)
+			}
+		}
+	}
+	slices.Sort(data.Sites)
+	slices.Sort(data.Tags)
+
+	if err := accountsTmpl.ExecuteTemplate(w, "fleet-accounts", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,561 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/events"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	actionsTmpl *template.Template
+
+	// actionRuleTopics are suggested as the trigger and conditions of new
+	// action rules
+	actionRuleTopics = []string{
+		"tns1:VideoSource/tnsaxis:Tampering",
+		"tns1:VideoSource/MotionAlarm",
+		"tns1:Device/tnsaxis:IO/Port",
+		"tns1:Device/tnsaxis:IO/VirtualInput",
+		"tns1:Device/tnsaxis:HardwareFailure/StorageFailure",
+		"tns1:Storage/Disruption",
+		"tns1:Device/tnsaxis:Status/SystemReady",
+	}
+)
+
+// ActionRulesPageData contains the data for the /manage/{serial}/actions page
+type ActionRulesPageData struct {
+	Device  models.AxisDevice
+	RuleSet *network.ActionRuleSet
+	Forms   []ActionConfigurationForm
+	Topics  []string
+	Job     *models.Job
+	Result  *ActionResult
+}
+
+// ActionConfigurationForm is the form to add an action or recipient
+// configuration, which only differ in their templates
+type ActionConfigurationForm struct {
+	Title     string
+	Path      string
+	Serial    string
+	Templates []network.ActionTemplate
+}
+
+func RegisterActionRulesRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, jm *jobs.Manager) {
+	var err error
+	actionsTmpl, err = template.New("actions.html").Funcs(template.FuncMap{
+		"topic":          events.Topic,
+		"parameters":     formatActionParameters,
+		"parameterNames": templateParameterNames,
+	}).ParseFS(ui.FS, "actions.html", "jobs.html", "snapshot.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /manage/{serial}/actions", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		renderActionRules(w, r, *device, ActionRulesPageData{})
+	}))
+	mux.Handle("POST /manage/{serial}/actions/rules", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleAddActionRule(w, r, db)
+	}))
+	mux.Handle("DELETE /manage/{serial}/actions/rules/{id}", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveActionItem(w, r, db, "Remove action rule", network.RemoveActionRule)
+	}))
+	mux.Handle("POST /manage/{serial}/actions/configurations", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleAddActionConfiguration(w, r, db)
+	}))
+	mux.Handle("DELETE /manage/{serial}/actions/configurations/{id}", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveActionItem(w, r, db, "Remove action", network.RemoveActionConfiguration)
+	}))
+	mux.Handle("POST /manage/{serial}/actions/recipients", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleAddRecipient(w, r, db)
+	}))
+	mux.Handle("DELETE /manage/{serial}/actions/recipients/{id}", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveActionItem(w, r, db, "Remove recipient", network.RemoveRecipientConfiguration)
+	}))
+	mux.Handle("POST /manage/{serial}/actions/copy", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleCopyActionRules(w, r, db, jm)
+	}))
+}
+
+// handleAddActionRule adds a rule that triggers an existing action when an
+// event occurs, provided that every condition holds. Conditions are given as
+// the parallel form values condition_topic and condition_content.
+func handleAddActionRule(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Add action rule")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	r.ParseForm()
+	rule := network.ActionRule{
+		Name:          strings.TrimSpace(r.FormValue("name")),
+		Enabled:       r.FormValue("enabled") != "",
+		PrimaryAction: r.FormValue("action"),
+		StartEvent: &network.EventCondition{
+			Topic:          strings.TrimSpace(r.FormValue("topic")),
+			MessageContent: strings.TrimSpace(r.FormValue("content")),
+		},
+	}
+	auditTargets(r, device.SerialNumber, rule.Name)
+	contents := r.Form["condition_content"]
+	for i, topic := range r.Form["condition_topic"] {
+		if topic = strings.TrimSpace(topic); topic == "" {
+			continue
+		}
+		condition := network.EventCondition{Topic: topic}
+		if i < len(contents) {
+			condition.MessageContent = strings.TrimSpace(contents[i])
+		}
+		rule.Conditions = append(rule.Conditions, condition)
+	}
+
+	if rule.Name == "" || rule.StartEvent.Topic == "" || rule.PrimaryAction == "" {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: "Name, trigger, and action must not be empty."}})
+		return
+	}
+	if _, err := network.AddActionRule(deviceClient(*device), rule); err != nil {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Success: true, Message: fmt.Sprintf("Added rule %s to %s.", rule.Name, device.SerialNumber)}})
+}
+
+// handleAddActionConfiguration adds an action made from one of the device's
+// action templates.
+func handleAddActionConfiguration(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Add action")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	action := network.ActionConfiguration{
+		Name:     strings.TrimSpace(r.FormValue("name")),
+		Template: r.FormValue("template"),
+	}
+	auditTargets(r, device.SerialNumber, action.Name)
+	parameters, err := parseActionParameters(r.FormValue("parameters"))
+	if err != nil {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	if action.Name == "" || action.Template == "" {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: "Name and template must not be empty."}})
+		return
+	}
+	action.Parameters = parameters
+
+	if _, err := network.AddActionConfiguration(deviceClient(*device), action); err != nil {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Success: true, Message: fmt.Sprintf("Added action %s to %s.", action.Name, device.SerialNumber)}})
+}
+
+// handleAddRecipient adds a recipient made from one of the device's recipient
+// templates.
+func handleAddRecipient(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Add recipient")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	recipient := network.RecipientConfiguration{
+		Name:     strings.TrimSpace(r.FormValue("name")),
+		Template: r.FormValue("template"),
+	}
+	auditTargets(r, device.SerialNumber, recipient.Name)
+	parameters, err := parseActionParameters(r.FormValue("parameters"))
+	if err != nil {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	if recipient.Name == "" || recipient.Template == "" {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: "Name and template must not be empty."}})
+		return
+	}
+	recipient.Parameters = parameters
+
+	if _, err := network.AddRecipientConfiguration(deviceClient(*device), recipient); err != nil {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Success: true, Message: fmt.Sprintf("Added recipient %s to %s.", recipient.Name, device.SerialNumber)}})
+}
+
+// handleRemoveActionItem removes the rule, action, or recipient with the ID
+// in the path with the given function.
+func handleRemoveActionItem(w http.ResponseWriter, r *http.Request, db *bbolt.DB, action string, remove func(c *network.Client, id string) error) {
+	auditAction(r, action)
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	name := r.FormValue("name")
+	auditTargets(r, device.SerialNumber, name)
+
+	if err := remove(deviceClient(*device), id); err != nil {
+		renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	renderActionRules(w, r, *device, ActionRulesPageData{Result: &ActionResult{Success: true, Message: fmt.Sprintf("Removed %s from %s.", name, device.SerialNumber)}})
+}
+
+// handleCopyActionRules starts a job that copies the selected rules of the
+// device, with the actions they trigger, and the selected recipients to the
+// selected devices.
+func handleCopyActionRules(w http.ResponseWriter, r *http.Request, db *bbolt.DB, jm *jobs.Manager) {
+	auditAction(r, "Copy action rules")
+	source, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	r.ParseForm()
+	set, err := network.ReadActionRuleSet(deviceClient(*source))
+	if err != nil {
+		renderActionRules(w, r, *source, ActionRulesPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	rules := slices.DeleteFunc(slices.Clone(set.Rules), func(rule network.ActionRule) bool {
+		return !slices.Contains(r.Form["rules"], rule.ID)
+	})
+	recipients := slices.DeleteFunc(slices.Clone(set.Recipients), func(recipient network.RecipientConfiguration) bool {
+		return !slices.Contains(r.Form["recipients"], recipient.ID)
+	})
+	if len(rules) == 0 && len(recipients) == 0 {
+		renderActionRules(w, r, *source, ActionRulesPageData{Result: &ActionResult{Message: "Select the rules and recipients to copy."}})
+		return
+	}
+
+	devices, err := selectDevices(r, db)
+	if err != nil {
+		renderActionRules(w, r, *source, ActionRulesPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	devices = slices.DeleteFunc(devices, func(device models.AxisDevice) bool {
+		return device.SerialNumber == source.SerialNumber
+	})
+	if len(devices) == 0 {
+		renderActionRules(w, r, *source, ActionRulesPageData{Result: &ActionResult{Message: fmt.Sprintf("No devices other than %s match the selection.", source.SerialNumber)}})
+		return
+	}
+
+	user, _ := UserFromContext(r.Context())
+	job, err := jobs.Start(db, jm, fmt.Sprintf("Copy action rules from %s", source.SerialNumber), user.Username, serialNumbers(devices),
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			for _, device := range devices {
+				if ctx.Err() != nil {
+					return "Stopped because Neba is shutting down.", nil
+				}
+				summary, err := copyActionRules(deviceClient(device), set, rules, recipients)
+				if err != nil {
+					report.Failed(device.SerialNumber, "%v", err)
+					continue
+				}
+				report.Succeeded(device.SerialNumber, "%s", summary)
+			}
+			return fmt.Sprintf("Processed %d devices.", len(devices)), nil
+		})
+	if err != nil {
+		renderActionRules(w, r, *source, ActionRulesPageData{Result: &ActionResult{Message: fmt.Sprintf("Starting the job failed: %v", err)}})
+		return
+	}
+	auditTargets(r, append([]string{source.SerialNumber}, job.Targets...)...)
+	renderActionRules(w, r, *source, ActionRulesPageData{
+		Job:    job,
+		Result: &ActionResult{Success: true, Message: fmt.Sprintf("Started: %s to %d devices.", job.Kind, len(job.Targets))},
+	})
+}
+
+// actionItem is a rule, action, or recipient on a device.
+type actionItem struct {
+	kind string // "rule", "action", or "recipient"
+	id   string
+	name string
+}
+
+func (item actionItem) String() string {
+	return item.kind + " " + item.name
+}
+
+// removeActionItems are the functions that remove an item of each kind.
+var removeActionItems = map[string]func(c *network.Client, id string) error{
+	"rule":      network.RemoveActionRule,
+	"action":    network.RemoveActionConfiguration,
+	"recipient": network.RemoveRecipientConfiguration,
+}
+
+// actionCopyPlan is what copying rules and recipients to a device adds and
+// replaces.
+type actionCopyPlan struct {
+	actions  []network.ActionConfiguration // Actions the rules trigger, in order of first use
+	replaced []actionItem                  // Items with the names of the copies, in the order they can be removed
+}
+
+// planActionCopy works out which actions the rules trigger, and which items
+// on the target the copies replace. Rules are removed before the actions,
+// which cannot be removed while a rule uses them.
+//
+// Parameters:
+//   - source:     The rule set the rules come from, to look up their actions.
+//   - target:     The rule set of the target device.
+//   - rules:      The rules to copy.
+//   - recipients: The recipients to copy.
+//
+// Returns:
+//   - actionCopyPlan: The actions to add and the items to replace.
+//   - error:          An error if a rule uses an action that does not exist,
+//     or an action to replace is still used by a rule that is kept.
+func planActionCopy(source, target *network.ActionRuleSet, rules []network.ActionRule, recipients []network.RecipientConfiguration) (actionCopyPlan, error) {
+	var plan actionCopyPlan
+	var remaining []network.ActionRule
+	for _, existing := range target.Rules {
+		if slices.ContainsFunc(rules, func(rule network.ActionRule) bool { return rule.Name == existing.Name }) {
+			plan.replaced = append(plan.replaced, actionItem{"rule", existing.ID, existing.Name})
+		} else {
+			remaining = append(remaining, existing)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		for _, id := range []string{rule.PrimaryAction, rule.FailoverAction} {
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			action := source.Action(id)
+			if action == nil {
+				return actionCopyPlan{}, fmt.Errorf("rule %s uses action %s, which does not exist", rule.Name, id)
+			}
+			plan.actions = append(plan.actions, *action)
+
+			for _, existing := range target.Actions {
+				if existing.Name != action.Name {
+					continue
+				}
+				for _, other := range remaining {
+					if other.PrimaryAction == existing.ID || other.FailoverAction == existing.ID {
+						return actionCopyPlan{}, fmt.Errorf("action %s is used by rule %s on the device", existing.Name, other.Name)
+					}
+				}
+				plan.replaced = append(plan.replaced, actionItem{"action", existing.ID, existing.Name})
+			}
+		}
+	}
+
+	for _, existing := range target.Recipients {
+		if slices.ContainsFunc(recipients, func(recipient network.RecipientConfiguration) bool { return recipient.Name == existing.Name }) {
+			plan.replaced = append(plan.replaced, actionItem{"recipient", existing.ID, existing.Name})
+		}
+	}
+	return plan, nil
+}
+
+// remapActions returns the rule with the IDs of its actions on the source
+// replaced with the IDs of their copies on the target.
+func remapActions(rule network.ActionRule, actionIDs map[string]string) network.ActionRule {
+	rule.PrimaryAction = actionIDs[rule.PrimaryAction]
+	rule.FailoverAction = actionIDs[rule.FailoverAction]
+	return rule
+}
+
+// copyActionRules copies rules, with the actions they trigger, and
+// recipients to a device. Rules, actions, and recipients with the same name
+// on the device are replaced, so that copying again updates them instead of
+// adding duplicates. Since the device tells items apart by ID, every copy is
+// added next to the items it replaces first, and those are only removed once
+// all copies are in place: if adding fails, the copies added so far are
+// removed again and the device keeps its rules as they were. An action with
+// the same name that other rules on the device still use is not replaced;
+// the copy fails before anything is changed instead.
+//
+// Parameters:
+//   - c:          The client of the target device.
+//   - source:     The rule set the rules come from, to look up their actions.
+//   - rules:      The rules to copy.
+//   - recipients: The recipients to copy.
+//
+// Returns:
+//   - string: A summary of what was copied.
+//   - error:  An error if reading or changing the device failed, listing
+//     what was left changed on the device.
+func copyActionRules(c *network.Client, source *network.ActionRuleSet, rules []network.ActionRule, recipients []network.RecipientConfiguration) (string, error) {
+	target, err := network.ReadActionRuleSet(c)
+	if err != nil {
+		return "", err
+	}
+	plan, err := planActionCopy(source, target, rules, recipients)
+	if err != nil {
+		return "", err
+	}
+
+	var added []actionItem
+	add := func(kind, name string, add func() (string, error)) (string, error) {
+		id, err := add()
+		if err != nil {
+			return "", fmt.Errorf("%v; %s", err, removeActionCopies(c, added))
+		}
+		added = append(added, actionItem{kind, id, name})
+		return id, nil
+	}
+
+	for _, recipient := range recipients {
+		if _, err := add("recipient", recipient.Name, func() (string, error) {
+			return network.AddRecipientConfiguration(c, recipient)
+		}); err != nil {
+			return "", err
+		}
+	}
+	actionIDs := map[string]string{} // From the IDs on the source to those on the target
+	for _, action := range plan.actions {
+		id, err := add("action", action.Name, func() (string, error) {
+			return network.AddActionConfiguration(c, action)
+		})
+		if err != nil {
+			return "", err
+		}
+		actionIDs[action.ID] = id
+	}
+	for _, rule := range rules {
+		if _, err := add("rule", rule.Name, func() (string, error) {
+			return network.AddActionRule(c, remapActions(rule, actionIDs))
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	for i, item := range plan.replaced {
+		if err := removeActionItems[item.kind](c, item.id); err != nil {
+			message := fmt.Sprintf("copied everything, but removing the replaced %s failed: %v", item, err)
+			if i > 0 {
+				message += fmt.Sprintf("; removed %s", joinActionItems(plan.replaced[:i]))
+			}
+			return "", fmt.Errorf("%s; still on the device next to the copies: %s", message, joinActionItems(plan.replaced[i:]))
+		}
+	}
+
+	summary := fmt.Sprintf("Copied %d rules, %d actions, and %d recipients.", len(rules), len(plan.actions), len(recipients))
+	if len(plan.replaced) > 0 {
+		summary += fmt.Sprintf(" Replaced %d with the same name.", len(plan.replaced))
+	}
+	return summary, nil
+}
+
+// removeActionCopies removes the copies added before a copy failed, newest
+// first, so that rules are removed before the actions they use.
+//
+// Returns:
+//   - string: What the device is left with, for the error message.
+func removeActionCopies(c *network.Client, added []actionItem) string {
+	if len(added) == 0 {
+		return "nothing was changed"
+	}
+	var left []actionItem
+	for i := len(added) - 1; i >= 0; i-- {
+		if err := removeActionItems[added[i].kind](c, added[i].id); err != nil {
+			left = append(left, added[i])
+		}
+	}
+	if len(left) > 0 {
+		return fmt.Sprintf("nothing was replaced, but these copies could not be removed again: %s", joinActionItems(left))
+	}
+	return "the copies added so far were removed again, and nothing was replaced"
+}
+
+// joinActionItems lists the items, separated by commas.
+func joinActionItems(items []actionItem) string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseActionParameters parses parameters given as one "name=value" pair per
+// line, as read by parseNameValueLines.
+func parseActionParameters(value string) ([]network.ActionParameter, error) {
+	pairs, err := parseNameValueLines(value)
+	if err != nil {
+		return nil, fmt.Errorf("parameters %w", err)
+	}
+	var parameters []network.ActionParameter
+	for _, pair := range pairs {
+		parameters = append(parameters, network.ActionParameter{Name: pair.Name, Value: pair.Value})
+	}
+	return parameters, nil
+}
+
+// formatActionParameters formats the parameters of an action or recipient
+// for display, with secrets redacted.
+func formatActionParameters(parameters []network.ActionParameter) string {
+	pairs := make([]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		value := parameter.Value
+		if database.SecretParameter.MatchString(parameter.Name) && value != "" {
+			value = database.Redacted
+		}
+		pairs = append(pairs, parameter.Name+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// templateParameterNames returns the names of the parameters of a template,
+// separated by commas.
+func templateParameterNames(t network.ActionTemplate) string {
+	names := make([]string, len(t.Parameters))
+	for i, parameter := range t.Parameters {
+		names[i] = parameter.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func renderActionRules(w http.ResponseWriter, r *http.Request, device models.AxisDevice, data ActionRulesPageData) {
+	data.Device = device
+	data.Topics = actionRuleTopics
+	client := deviceClient(device)
+	set, err := network.ReadActionRuleSet(client)
+	var actionTemplates, recipientTemplates []network.ActionTemplate
+	if err == nil {
+		data.RuleSet = set
+		actionTemplates, err = network.ListActionTemplates(client)
+	}
+	if err == nil {
+		recipientTemplates, err = network.ListRecipientTemplates(client)
+	}
+	if err == nil {
+		data.Forms = []ActionConfigurationForm{
+			{Title: "Action", Path: "configurations", Serial: device.SerialNumber, Templates: actionTemplates},
+			{Title: "Recipient", Path: "recipients", Serial: device.SerialNumber, Templates: recipientTemplates},
+		}
+	}
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+	if err := actionsTmpl.ExecuteTemplate(w, "actions.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/network"
+)
+
+func TestParseActionParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []network.ActionParameter
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: nil},
+		{
+			name:  "one per line",
+			value: "upload_url=http://vms.local/event\nlogin=neba",
+			want:  []network.ActionParameter{{Name: "upload_url", Value: "http://vms.local/event"}, {Name: "login", Value: "neba"}},
+		},
+		{
+			name:  "CRLF and blank lines",
+			value: "message=Tampering\r\n\r\n  \nparameters=\r\n",
+			want:  []network.ActionParameter{{Name: "message", Value: "Tampering"}, {Name: "parameters", Value: ""}},
+		},
+		{
+			name:  "names trimmed, values kept",
+			value: " password = s3cret=1 ",
+			want:  []network.ActionParameter{{Name: "password", Value: " s3cret=1 "}},
+		},
+		{
+			name:  "comments skipped",
+			value: "# sent to the VMS\nmessage=Tampering",
+			want:  []network.ActionParameter{{Name: "message", Value: "Tampering"}},
+		},
+		{name: "no equals sign", value: "message", wantErr: true},
+		{name: "no name", value: "=value", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseActionParameters(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseActionParameters() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseActionParameters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatActionParameters(t *testing.T) {
+	got := formatActionParameters([]network.ActionParameter{
+		{Name: "upload_url", Value: "http://vms.local"},
+		{Name: "password", Value: "s3cret"},
+		{Name: "token", Value: ""},
+	})
+	if want := "upload_url=http://vms.local password=" + database.Redacted + " token="; got != want {
+		t.Errorf("formatActionParameters() = %q, want %q", got, want)
+	}
+}
+
+func TestPlanActionCopy(t *testing.T) {
+	source := &network.ActionRuleSet{
+		Rules: []network.ActionRule{
+			{ID: "1", Name: "Tampering", PrimaryAction: "10", FailoverAction: "11"},
+			{ID: "2", Name: "Door", PrimaryAction: "10"},
+			{ID: "3", Name: "Broken", PrimaryAction: "99"},
+		},
+		Actions: []network.ActionConfiguration{
+			{ID: "10", Name: "Notify VMS"},
+			{ID: "11", Name: "Email"},
+		},
+		Recipients: []network.RecipientConfiguration{{ID: "20", Name: "VMS"}},
+	}
+
+	tests := []struct {
+		name         string
+		target       network.ActionRuleSet
+		rules        []string // Names of the rules of the source to copy
+		recipients   bool     // Whether the recipient is copied
+		wantActions  []string
+		wantReplaced []string
+		wantErr      string
+	}{
+		{
+			name:        "empty target",
+			rules:       []string{"Tampering", "Door"},
+			wantActions: []string{"Notify VMS", "Email"},
+		},
+		{
+			name: "items with the same name replaced, rules before actions",
+			target: network.ActionRuleSet{
+				Rules:      []network.ActionRule{{ID: "5", Name: "Tampering", PrimaryAction: "6"}, {ID: "7", Name: "Motion", PrimaryAction: "8"}},
+				Actions:    []network.ActionConfiguration{{ID: "6", Name: "Notify VMS"}, {ID: "8", Name: "Siren"}},
+				Recipients: []network.RecipientConfiguration{{ID: "9", Name: "VMS"}, {ID: "4", Name: "NAS"}},
+			},
+			rules:        []string{"Tampering"},
+			recipients:   true,
+			wantActions:  []string{"Notify VMS", "Email"},
+			wantReplaced: []string{"rule Tampering", "action Notify VMS", "recipient VMS"},
+		},
+		{
+			name: "action still used by a kept rule",
+			target: network.ActionRuleSet{
+				Rules:   []network.ActionRule{{ID: "7", Name: "Motion", PrimaryAction: "6"}},
+				Actions: []network.ActionConfiguration{{ID: "6", Name: "Email"}},
+			},
+			rules:   []string{"Tampering"},
+			wantErr: "action Email is used by rule Motion",
+		},
+		{
+			name:    "missing action",
+			rules:   []string{"Broken"},
+			wantErr: "uses action 99, which does not exist",
+		},
+		{
+			name:         "only recipients",
+			target:       network.ActionRuleSet{Recipients: []network.RecipientConfiguration{{ID: "9", Name: "VMS"}}},
+			recipients:   true,
+			wantReplaced: []string{"recipient VMS"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []network.ActionRule
+			for _, rule := range source.Rules {
+				if slices.Contains(tt.rules, rule.Name) {
+					rules = append(rules, rule)
+				}
+			}
+			var recipients []network.RecipientConfiguration
+			if tt.recipients {
+				recipients = source.Recipients
+			}
+
+			plan, err := planActionCopy(source, &tt.target, rules, recipients)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("planActionCopy() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planActionCopy() error = %v", err)
+			}
+
+			var actions, replaced []string
+			for _, action := range plan.actions {
+				actions = append(actions, action.Name)
+			}
+			for _, item := range plan.replaced {
+				replaced = append(replaced, item.String())
+			}
+			if !slices.Equal(actions, tt.wantActions) {
+				t.Errorf("actions = %v, want %v", actions, tt.wantActions)
+			}
+			if !slices.Equal(replaced, tt.wantReplaced) {
+				t.Errorf("replaced = %v, want %v", replaced, tt.wantReplaced)
+			}
+		})
+	}
+}
+
+func TestRemapActions(t *testing.T) {
+	actionIDs := map[string]string{"10": "3", "11": "4"}
+
+	tests := []struct {
+		name         string
+		rule         network.ActionRule
+		wantPrimary  string
+		wantFailover string
+	}{
+		{
+			name:         "primary and failover",
+			rule:         network.ActionRule{Name: "Tampering", PrimaryAction: "10", FailoverAction: "11"},
+			wantPrimary:  "3",
+			wantFailover: "4",
+		},
+		{
+			name:        "no failover",
+			rule:        network.ActionRule{Name: "Door", PrimaryAction: "11"},
+			wantPrimary: "4",
+		},
+		{
+			name: "unknown action",
+			rule: network.ActionRule{Name: "Broken", PrimaryAction: "99"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := remapActions(tt.rule, actionIDs)
+			if got.PrimaryAction != tt.wantPrimary || got.FailoverAction != tt.wantFailover {
+				t.Errorf("remapActions() = %q, %q, want %q, %q", got.PrimaryAction, got.FailoverAction, tt.wantPrimary, tt.wantFailover)
+			}
+			if got.Name != tt.rule.Name {
+				t.Errorf("remapActions() changed the name to %q", got.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,476 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Audit constants
+	auditPageLimit   = 500
+	maxAuditBodySize = 64 << 10 // Larger JSON bodies are not recorded
+)
+
+var (
+	auditTmpl *template.Template
+
+	// lineParameters are the form fields that hold device parameters as
+	// Name=value lines, whose secret lines are redacted one by one.
+	lineParameters = map[string]bool{
+		"baseline":   true,
+		"parameters": true,
+	}
+)
+
+// auditContextKey is the context key under which Audit stores the entry of the
+// current request, so that handlers can add details to it.
+type auditContextKey struct{}
+
+// AuditPageData contains the data for the /audit page
+type AuditPageData struct {
+	Entries    []models.AuditEntry
+	Filter     AuditFilter
+	ExportCSV  string
+	ExportJSON string
+	Limit      int
+	Error      string
+}
+
+// AuditFilter holds the criteria for the audit view and export
+type AuditFilter struct {
+	Username string
+	Action   string
+	Target   string
+	Outcome  string
+	From     string
+	To       string
+}
+
+func RegisterAuditRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB) {
+	var err error
+	auditTmpl, err = template.New("audit.html").Funcs(template.FuncMap{
+		"join":   strings.Join,
+		"params": formatParameters,
+	}).ParseFS(ui.FS, "audit.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /audit", AuthorizeFunc(auth.PermViewAudit, func(w http.ResponseWriter, r *http.Request) {
+		handleAudit(w, r, db)
+	}))
+	mux.Handle("GET /audit/export", AuthorizeFunc(auth.PermViewAudit, func(w http.ResponseWriter, r *http.Request) {
+		handleAuditExport(w, r, db)
+	}))
+}
+
+// Audit wraps the given handler so that every state-changing request is
+// recorded in the audit log once it has been handled. It must sit between
+// RequireAuth and the ServeMux, so that the signed-in user is known and the
+// matched path values can be read afterwards.
+func Audit(db *bbolt.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isSafeMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body := readJSONBody(r)
+		entry := &models.AuditEntry{Time: time.Now()}
+		r = r.WithContext(context.WithValue(r.Context(), auditContextKey{}, entry))
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		completeAuditEntry(entry, r, rec.status, body)
+		if err := database.AppendAudit(db, *entry); err != nil {
+			log.Printf("Failed to write audit entry: %v", err)
+		}
+	})
+}
+
+// auditRejected records a state-changing request that was rejected before it
+// reached Audit, such as one with an invalid CSRF token.
+//
+// Parameters:
+//   - db: The database to write the audit entry to.
+//   - r: The rejected request.
+//   - username: The user the request's session belongs to.
+//   - status: The status code the request was rejected with.
+//   - message: Why the request was rejected.
+func auditRejected(db *bbolt.DB, r *http.Request, username string, status int, message string) {
+	entry := &models.AuditEntry{
+		Time:     time.Now(),
+		Username: username,
+		Outcome:  models.AuditFailure,
+		Message:  message,
+	}
+	body := readJSONBody(r)
+	r.ParseForm() // Multipart uploads are not parsed, so their fields are not recorded
+	completeAuditEntry(entry, r, status, body)
+	if err := database.AppendAudit(db, *entry); err != nil {
+		log.Printf("Failed to write audit entry: %v", err)
+	}
+}
+
+// auditDownload records a GET request that hands out sensitive data, such as
+// a database backup. Audit only records requests that change state, so such
+// handlers call it themselves.
+//
+// Parameters:
+//   - db: The database to write the audit entry to.
+//   - r: The request.
+//   - action: What the request did.
+//   - err: The error the request failed with, or nil.
+func auditDownload(db *bbolt.DB, r *http.Request, action string, err error) {
+	entry := &models.AuditEntry{Time: time.Now(), Action: action}
+	status := http.StatusOK
+	if err != nil {
+		entry.Outcome = models.AuditFailure
+		entry.Message = err.Error()
+		status = http.StatusInternalServerError
+	}
+	completeAuditEntry(entry, r, status, nil)
+	if err := database.AppendAudit(db, *entry); err != nil {
+		log.Printf("Failed to write audit entry: %v", err)
+	}
+}
+
+// readJSONBody returns the body of a JSON request, so that it can be recorded
+// in the audit log, and puts it back for the handler to read. It returns nil
+// for other requests and for bodies larger than maxAuditBodySize.
+func readJSONBody(r *http.Request) []byte {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" || r.Body == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBodySize+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxAuditBodySize {
+		return nil
+	}
+	return body
+}
+
+// auditAction names the action of the current request in the audit log,
+// replacing the default of method and path.
+func auditAction(r *http.Request, action string) {
+	if entry, ok := r.Context().Value(auditContextKey{}).(*models.AuditEntry); ok {
+		entry.Action = action
+	}
+}
+
+// auditTargets sets the devices or accounts the current request acts on.
+func auditTargets(r *http.Request, targets ...string) {
+	if entry, ok := r.Context().Value(auditContextKey{}).(*models.AuditEntry); ok {
+		entry.Targets = targets
+	}
+}
+
+// auditFailure marks the current request as failed in the audit log, even if
+// the response status indicates success. Handlers that render errors as part
+// of an otherwise successful page use it to record what went wrong.
+func auditFailure(r *http.Request, message string) {
+	if entry, ok := r.Context().Value(auditContextKey{}).(*models.AuditEntry); ok {
+		entry.Outcome = models.AuditFailure
+		entry.Message = message
+	}
+}
+
+// completeAuditEntry fills in everything the handler did not set itself.
+func completeAuditEntry(entry *models.AuditEntry, r *http.Request, status int, body []byte) {
+	entry.Status = status
+	entry.SourceIP = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		entry.SourceIP = host
+	}
+
+	if user, ok := UserFromContext(r.Context()); ok {
+		entry.Username = user.Username
+	} else if entry.Username == "" {
+		// Sign-in attempts happen before there is a user in the context.
+		entry.Username = r.FormValue("username")
+	}
+
+	if entry.Action == "" {
+		entry.Action = r.Method + " " + r.URL.Path
+	}
+	if len(entry.Targets) == 0 {
+		for _, name := range []string{"serial", "username"} {
+			if value := r.PathValue(name); value != "" {
+				entry.Targets = append(entry.Targets, value)
+			}
+		}
+	}
+
+	entry.Parameters = redactParameters(r)
+	for name, value := range redactJSON(body) {
+		if entry.Parameters == nil {
+			entry.Parameters = map[string]string{}
+		}
+		entry.Parameters[name] = value
+	}
+
+	if entry.Outcome == "" {
+		entry.Outcome = models.AuditSuccess
+		if status >= http.StatusBadRequest {
+			entry.Outcome = models.AuditFailure
+		}
+	}
+}
+
+// redactParameters returns the form and query parameters of the request with
+// secrets replaced, including secret lines of device parameters, and without
+// the CSRF token.
+func redactParameters(r *http.Request) map[string]string {
+	values := r.Form
+	if r.MultipartForm != nil {
+		values = r.MultipartForm.Value
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	params := make(map[string]string, len(values))
+	for name, value := range values {
+		switch {
+		case name == auth.CSRFFormField:
+			continue
+		case database.SecretParameter.MatchString(name):
+			params[name] = database.Redacted
+		case lineParameters[name]:
+			params[name] = database.RedactLines(strings.Join(value, "\n"))
+		default:
+			params[name] = strings.Join(value, ",")
+		}
+	}
+	return params
+}
+
+// redactJSON returns the fields of a JSON request body as parameters named
+// after their path, such as "server.address", with secrets replaced. Lists of
+// plain values are joined with commas, like repeated form values.
+func redactJSON(body []byte) map[string]string {
+	if len(body) == 0 {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil
+	}
+
+	params := map[string]string{}
+	flattenJSON(params, "", value)
+	return params
+}
+
+func flattenJSON(params map[string]string, name string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if name != "" {
+				key = name + "." + key
+			}
+			flattenJSON(params, key, item)
+		}
+		return
+	case []any:
+		values := make([]string, 0, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				flattenJSON(params, fmt.Sprintf("%s[%d]", name, i), item)
+			default:
+				values = append(values, jsonValue(item))
+			}
+		}
+		if len(values) == 0 {
+			return
+		}
+		value = strings.Join(values, ",")
+	}
+
+	if name == "" {
+		name = "body"
+	}
+	if database.SecretParameter.MatchString(name) {
+		params[name] = database.Redacted
+		return
+	}
+	params[name] = jsonValue(value)
+}
+
+// jsonValue formats a plain JSON value without the quotes around strings.
+func jsonValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func handleAudit(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	filter := parseAuditFilter(r)
+	data := AuditPageData{
+		Filter:     filter,
+		ExportCSV:  auditExportURL(r, "csv"),
+		ExportJSON: auditExportURL(r, "json"),
+		Limit:      auditPageLimit,
+	}
+
+	entries, err := database.ListAudit(db, filter.Match, auditPageLimit)
+	if err != nil {
+		data.Error = err.Error()
+	}
+	data.Entries = entries
+
+	if err := auditTmpl.ExecuteTemplate(w, "audit.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func handleAuditExport(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	filter := parseAuditFilter(r)
+
+	entries, err := database.ListAudit(db, filter.Match, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := "neba_audit_" + time.Now().Format("20060102_150405")
+	switch r.FormValue("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if entries == nil {
+			entries = []models.AuditEntry{}
+		}
+		if err := encoder.Encode(entries); err != nil {
+			log.Printf("Failed to encode audit export: %v", err)
+		}
+	case "csv", "":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"id", "time", "username", "source_ip", "action", "targets", "parameters", "outcome", "status", "message"})
+		for _, entry := range entries {
+			writer.Write([]string{
+				strconv.FormatUint(entry.ID, 10),
+				entry.Time.Format(time.RFC3339),
+				entry.Username,
+				entry.SourceIP,
+				entry.Action,
+				strings.Join(entry.Targets, " "),
+				formatParameters(entry.Parameters),
+				entry.Outcome,
+				strconv.Itoa(entry.Status),
+				entry.Message,
+			})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			log.Printf("Failed to write audit export: %v", err)
+		}
+	default:
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+	}
+}
+
+// auditExportURL returns the export URL for the given format that applies the
+// same filter as the current request.
+func auditExportURL(r *http.Request, format string) string {
+	query := r.URL.Query()
+	query.Set("format", format)
+	return "/audit/export?" + query.Encode()
+}
+
+func parseAuditFilter(r *http.Request) AuditFilter {
+	return AuditFilter{
+		Username: strings.TrimSpace(r.FormValue("username")),
+		Action:   strings.TrimSpace(r.FormValue("action")),
+		Target:   strings.TrimSpace(r.FormValue("target")),
+		Outcome:  r.FormValue("outcome"),
+		From:     r.FormValue("from"),
+		To:       r.FormValue("to"),
+	}
+}
+
+// Match reports whether the entry satisfies every criterion of the filter.
+// Text criteria match case-insensitive substrings; dates are inclusive days.
+func (f AuditFilter) Match(entry models.AuditEntry) bool {
+	if f.Username != "" && !containsFold(entry.Username, f.Username) {
+		return false
+	}
+	if f.Action != "" && !containsFold(entry.Action, f.Action) {
+		return false
+	}
+	if f.Target != "" && !containsFold(strings.Join(entry.Targets, " "), f.Target) {
+		return false
+	}
+	if f.Outcome != "" && entry.Outcome != f.Outcome {
+		return false
+	}
+	if from, err := time.ParseInLocation(time.DateOnly, f.From, time.Local); err == nil && entry.Time.Before(from) {
+		return false
+	}
+	if to, err := time.ParseInLocation(time.DateOnly, f.To, time.Local); err == nil && !entry.Time.Before(to.AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+// formatParameters renders parameters as sorted key=value pairs.
+func formatParameters(params map[string]string) string {
+	pairs := make([]string, 0, len(params))
+	for name, value := range params {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
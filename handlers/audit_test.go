@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+)
+
+func TestRedactParameters(t *testing.T) {
+	tests := []struct {
+		name string
+		form url.Values
+		want map[string]string
+	}{
+		{
+			name: "secret fields",
+			form: url.Values{"username": {"vic"}, "password": {"hunter2"}, "confirm": {"hunter2"}},
+			want: map[string]string{"username": "vic", "password": database.Redacted, "confirm": database.Redacted},
+		},
+		{
+			name: "CSRF token left out",
+			form: url.Values{auth.CSRFFormField: {"token"}, "devices": {"A", "B"}},
+			want: map[string]string{"devices": "A,B"},
+		},
+		{
+			name: "secret lines of the baseline",
+			form: url.Values{"baseline": {"Network.SSH.Enabled=no\nSNMP.V3Password=s3cret"}},
+			want: map[string]string{"baseline": "Network.SSH.Enabled=no\nSNMP.V3Password=" + database.Redacted},
+		},
+		{
+			name: "secret lines of action parameters",
+			form: url.Values{"name": {"VMS"}, "parameters": {"upload_url=http://vms.local\r\nlogin=neba\r\npassword=s3cret"}},
+			want: map[string]string{"name": "VMS", "parameters": "upload_url=http://vms.local\r\nlogin=neba\r\npassword=" + database.Redacted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			got := redactParameters(r)
+			if len(got) != len(tt.want) {
+				t.Errorf("redactParameters() = %q, want %q", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("parameter %s = %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// dummyPasswordHash is a bcrypt hash, of the default cost, that sign-ins
+	// with an unknown username are checked against, so that they take as long
+	// as those with a wrong password and do not reveal which accounts exist.
+	dummyPasswordHash = "$2a$10$FO7AJTR6KdfobjPY2Fj7ge5KAgCktPBeumiWwco0TyOP6F2SvLOmi"
+)
+
+var (
+	loginTmpl *template.Template
+	setupTmpl *template.Template
+)
+
+// sessionContextKey and userContextKey are the context keys under which
+// RequireAuth stores the session and the account of the signed-in user.
+type (
+	sessionContextKey struct{}
+	userContextKey    struct{}
+)
+
+// AuthPageData contains the data for the /login and /setup pages
+type AuthPageData struct {
+	Username          string
+	MinPasswordLength int
+	Error             string
+}
+
+func RegisterAuthRoutes(fs http.Handler, mux *http.ServeMux, db *bbolt.DB) {
+	var err error
+	loginTmpl, err = template.ParseFS(ui.FS, "login.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+	setupTmpl, err = template.ParseFS(ui.FS, "setup.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
+		renderAuthPage(w, loginTmpl, "login.html", AuthPageData{})
+	})
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		handleLogin(w, r, db)
+	})
+	mux.HandleFunc("POST /logout", func(w http.ResponseWriter, r *http.Request) {
+		handleLogout(w, r, db)
+	})
+	mux.HandleFunc("GET /setup", func(w http.ResponseWriter, r *http.Request) {
+		if setupDone(w, r, db) {
+			return
+		}
+		renderAuthPage(w, setupTmpl, "setup.html", AuthPageData{MinPasswordLength: auth.MinPasswordLength})
+	})
+	mux.HandleFunc("POST /setup", func(w http.ResponseWriter, r *http.Request) {
+		handleSetup(w, r, db)
+	})
+}
+
+// RequireAuth wraps the given handler so that every request, except for the
+// static assets and the sign-in pages, needs a valid session. Until the first
+// account has been created all requests are sent to the setup page. Requests
+// that change state must carry the session's CSRF token either in the
+// X-CSRF-Token header, which htmx sends, or in the csrf_token form field.
+func RequireAuth(db *bbolt.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		count, err := database.CountUsers(db)
+		if err != nil {
+			log.Printf("Failed to count users: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if count == 0 {
+			redirect(w, r, "/setup")
+			return
+		}
+
+		session := requestSession(r, db)
+		if session == nil {
+			redirect(w, r, "/login")
+			return
+		}
+
+		if !isSafeMethod(r.Method) {
+			token := r.Header.Get(auth.CSRFHeaderName)
+			if token == "" {
+				token = r.FormValue(auth.CSRFFormField)
+			}
+			if !auth.ValidCSRFToken(*session, token) {
+				auditRejected(db, r, session.Username, http.StatusForbidden, "Invalid CSRF token")
+				http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+
+		user, err := database.ViewUser(db, session.Username)
+		if err != nil {
+			// The account has been deleted since the session was created.
+			if err := database.DeleteSession(db, session.Token); err != nil {
+				log.Printf("Failed to delete session: %v", err)
+			}
+			redirect(w, r, "/login")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), sessionContextKey{}, *session)
+		ctx = context.WithValue(ctx, userContextKey{}, *user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authorize wraps the given handler so that it only runs if the signed-in
+// user's role grants the given permission. It must be used behind RequireAuth.
+func Authorize(perm auth.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := UserFromContext(r.Context())
+		if !ok || !auth.Can(user, perm) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthorizeFunc is like Authorize, but takes a handler function.
+func AuthorizeFunc(perm auth.Permission, next http.HandlerFunc) http.Handler {
+	return Authorize(perm, next)
+}
+
+// SessionFromContext returns the session that RequireAuth attached to the
+// request context, and whether there was one.
+func SessionFromContext(ctx context.Context) (models.Session, bool) {
+	session, ok := ctx.Value(sessionContextKey{}).(models.Session)
+	return session, ok
+}
+
+// UserFromContext returns the account that RequireAuth attached to the request
+// context, and whether there was one.
+func UserFromContext(ctx context.Context) (models.User, bool) {
+	user, ok := ctx.Value(userContextKey{}).(models.User)
+	return user, ok
+}
+
+// permissionsFromContext returns the permissions of the signed-in user, for
+// use in templates.
+func permissionsFromContext(ctx context.Context) auth.Permissions {
+	user, _ := UserFromContext(ctx)
+	return auth.PermissionsFor(auth.Role(user.Role))
+}
+
+func handleLogin(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Sign in")
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+
+	hash := dummyPasswordHash
+	user, err := database.ViewUser(db, username)
+	if err == nil {
+		hash = user.PasswordHash
+	}
+	if !auth.CheckPassword(hash, password) || err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		renderAuthPage(w, loginTmpl, "login.html", AuthPageData{
+			Username: username,
+			Error:    "Invalid username or password.",
+		})
+		return
+	}
+
+	if err := startSession(w, r, db, user.Username); err != nil {
+		log.Printf("Failed to start session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Sign out")
+	if session, ok := SessionFromContext(r.Context()); ok {
+		if err := database.DeleteSession(db, session.Token); err != nil {
+			log.Printf("Failed to delete session: %v", err)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	redirect(w, r, "/login")
+}
+
+func handleSetup(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Set up admin account")
+	if setupDone(w, r, db) {
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	data := AuthPageData{Username: username, MinPasswordLength: auth.MinPasswordLength}
+
+	if username == "" {
+		data.Error = "Username must not be empty."
+	} else if password != r.FormValue("confirm") {
+		data.Error = "Passwords do not match."
+	}
+	if data.Error != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		renderAuthPage(w, setupTmpl, "setup.html", data)
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		data.Error = err.Error()
+		renderAuthPage(w, setupTmpl, "setup.html", data)
+		return
+	}
+
+	user := models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         string(auth.RoleAdmin),
+		CreatedAt:    time.Now(),
+	}
+	created, err := database.CreateFirstUser(db, user)
+	if err != nil {
+		log.Printf("Failed to create user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		// Another setup request created an account in the meantime.
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if err := startSession(w, r, db, user.Username); err != nil {
+		log.Printf("Failed to start session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// setupDone redirects to the login page and returns true if an account
+// already exists, so that the setup flow can only ever run once.
+func setupDone(w http.ResponseWriter, r *http.Request, db *bbolt.DB) bool {
+	count, err := database.CountUsers(db)
+	if err != nil {
+		log.Printf("Failed to count users: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return true
+	}
+	if count > 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return true
+	}
+	return false
+}
+
+// startSession creates and stores a new session for the given user and sets
+// the session cookie on the response.
+func startSession(w http.ResponseWriter, r *http.Request, db *bbolt.DB, username string) error {
+	session, err := auth.NewSession(username)
+	if err != nil {
+		return err
+	}
+	if err := database.UpdateSession(db, session); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookieName,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// requestSession returns the stored session referenced by the request's
+// session cookie, or nil if there is none or it has expired.
+func requestSession(r *http.Request, db *bbolt.DB) *models.Session {
+	cookie, err := r.Cookie(auth.SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	session, err := database.ViewSession(db, cookie.Value)
+	if err != nil {
+		return nil
+	}
+	return session
+}
+
+// redirect sends the client to the given URL. htmx requests receive an
+// HX-Redirect header so that the whole page is replaced instead of only the
+// swapped fragment.
+func redirect(w http.ResponseWriter, r *http.Request, url string) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", url)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+func renderAuthPage(w http.ResponseWriter, tmpl *template.Template, name string, data AuthPageData) {
+	if err := tmpl.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func isPublicPath(path string) bool {
+	return strings.HasPrefix(path, "/static/") || path == "/login" || path == "/setup"
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
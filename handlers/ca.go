@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/certs"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/pki"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// CA constants
+	caValidity      = 10 * 365 * 24 * time.Hour
+	maxCAUploadSize = 64 << 10
+)
+
+var (
+	caTmpl *template.Template
+)
+
+// CAPageData contains the data for the /ca page
+type CAPageData struct {
+	CA          *pki.CA
+	Settings    CASettings
+	Permissions auth.Permissions
+	Job         *models.Job
+	Result      *ActionResult
+}
+
+// CASettings are the settings of the CA, as configured in the certificates
+// section of the configuration file
+type CASettings struct {
+	ValidityDays int
+	AutoRenew    bool
+	RenewDays    int
+}
+
+func RegisterCARoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, cm *configs.CManager, jm *jobs.Manager) {
+	var err error
+	caTmpl, err = template.ParseFS(ui.FS, "ca.html", "jobs.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /ca", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		renderCA(w, r, cm, CAPageData{})
+	}))
+	mux.Handle("GET /ca/certificate", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		ca, err := certs.LoadCA(cm.Dir())
+		if err != nil || ca == nil {
+			http.Error(w, "No CA", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, certs.CACertFile))
+		w.Write(ca.CertPEM)
+	}))
+	mux.Handle("POST /ca/create", AuthorizeFunc(auth.PermManageCreds, func(w http.ResponseWriter, r *http.Request) {
+		auditAction(r, "Create CA")
+		name := strings.TrimSpace(r.FormValue("common_name"))
+		if name == "" {
+			renderCA(w, r, cm, CAPageData{Result: &ActionResult{Message: "The name of the CA must not be empty."}})
+			return
+		}
+		if message := confirmReplaceCA(r, cm); message != "" {
+			renderCA(w, r, cm, CAPageData{Result: &ActionResult{Message: message}})
+			return
+		}
+		certPath, keyPath := certs.CAPaths(cm.Dir())
+		if _, err := pki.CreateCA(certPath, keyPath, name, caValidity); err != nil {
+			renderCA(w, r, cm, CAPageData{Result: &ActionResult{Message: err.Error()}})
+			return
+		}
+		renderCA(w, r, cm, CAPageData{Result: &ActionResult{Success: true, Message: fmt.Sprintf("Created the CA %s.", name)}})
+	}))
+	mux.Handle("POST /ca/import", AuthorizeFunc(auth.PermManageCreds, func(w http.ResponseWriter, r *http.Request) {
+		handleImportCA(w, r, cm)
+	}))
+	mux.Handle("POST /ca/issue", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleIssueCertificates(w, r, db, cm, jm)
+	}))
+	mux.Handle("POST /manage/{serial}/certificates/issue", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		auditAction(r, "Issue certificate")
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		auditTargets(r, device.SerialNumber)
+
+		result := &ActionResult{Message: "Neba has no CA yet."}
+		ca, err := certs.LoadCA(cm.Dir())
+		if err != nil {
+			result = &ActionResult{Message: err.Error()}
+		} else if ca != nil {
+			if message, err := certs.Issue(db, *device, ca, cm.Get()); err != nil {
+				result = &ActionResult{Message: err.Error()}
+			} else {
+				result = &ActionResult{Success: true, Message: message}
+			}
+		}
+		renderDeviceCertificates(w, r, db, cm, *device, DeviceCertificatesPageData{Result: result})
+	}))
+}
+
+func handleImportCA(w http.ResponseWriter, r *http.Request, cm *configs.CManager) {
+	auditAction(r, "Import CA")
+	fail := func(message string) {
+		renderCA(w, r, cm, CAPageData{Result: &ActionResult{Message: message}})
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCAUploadSize)
+	read := func(field string) ([]byte, error) {
+		file, _, err := r.FormFile(field)
+		if err != nil {
+			return nil, fmt.Errorf("choose a file for the %s", strings.ReplaceAll(field, "_", " "))
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+	certPEM, err := read("certificate")
+	if err != nil {
+		fail(fmt.Sprintf("Import failed: %v", err))
+		return
+	}
+	keyPEM, err := read("private_key")
+	if err != nil {
+		fail(fmt.Sprintf("Import failed: %v", err))
+		return
+	}
+
+	if message := confirmReplaceCA(r, cm); message != "" {
+		fail(message)
+		return
+	}
+	certPath, keyPath := certs.CAPaths(cm.Dir())
+	ca, err := pki.ImportCA(certPath, keyPath, certPEM, keyPEM)
+	if err != nil {
+		fail(fmt.Sprintf("Import failed: %v", err))
+		return
+	}
+	renderCA(w, r, cm, CAPageData{Result: &ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Imported the CA %s.", ca.Certificate.Subject.CommonName),
+	}})
+}
+
+// handleIssueCertificates starts a job that issues a new HTTPS certificate
+// to every selected device.
+func handleIssueCertificates(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager, jm *jobs.Manager) {
+	auditAction(r, "Issue certificates")
+	ca, err := certs.LoadCA(cm.Dir())
+	if err != nil {
+		renderCA(w, r, cm, CAPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	if ca == nil {
+		renderCA(w, r, cm, CAPageData{Result: &ActionResult{Message: "Create or import a CA first."}})
+		return
+	}
+	devices, err := selectDevices(r, db)
+	if err != nil {
+		renderCA(w, r, cm, CAPageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+
+	config := cm.Get()
+	user, _ := UserFromContext(r.Context())
+	job, err := jobs.Start(db, jm, "Issue certificates", user.Username, serialNumbers(devices),
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			certs.IssueAll(ctx, db, devices, ca, config, report)
+			return fmt.Sprintf("Processed %d devices.", len(devices)), nil
+		})
+	if err != nil {
+		renderCA(w, r, cm, CAPageData{Result: &ActionResult{Message: fmt.Sprintf("Starting the job failed: %v", err)}})
+		return
+	}
+	auditTargets(r, job.Targets...)
+	renderCA(w, r, cm, CAPageData{
+		Job:    job,
+		Result: &ActionResult{Success: true, Message: fmt.Sprintf("Started: %s on %d devices.", job.Kind, len(job.Targets))},
+	})
+}
+
+// confirmReplaceCA returns why the request may not replace Neba's CA, or an
+// empty string if it may: there is no usable CA, or the request confirms the
+// replacement with replace=yes, since the certificates issued by the current
+// CA are no longer renewed afterwards.
+func confirmReplaceCA(r *http.Request, cm *configs.CManager) string {
+	ca, err := certs.LoadCA(cm.Dir())
+	if err == nil && ca != nil && r.FormValue("replace") != "yes" {
+		return fmt.Sprintf("Neba already has the CA %s. Confirm that it should be replaced; the certificates it issued are no longer renewed afterwards.",
+			ca.Certificate.Subject.CommonName)
+	}
+	return ""
+}
+
+func renderCA(w http.ResponseWriter, r *http.Request, cm *configs.CManager, data CAPageData) {
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+	config := cm.Get().Certificates
+	data.Settings = CASettings{ValidityDays: config.ValidityDays, AutoRenew: config.AutoRenew, RenewDays: config.RenewDays}
+	data.Permissions = permissionsFromContext(r.Context())
+
+	ca, err := certs.LoadCA(cm.Dir())
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+	data.CA = ca
+
+	if err := caTmpl.ExecuteTemplate(w, "ca.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
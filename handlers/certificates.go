@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/certs"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/pki"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Certificate constants
+	maxCertificateSize = 64 << 10 // A certificate with its chain
+)
+
+var (
+	certificatesTmpl *template.Template
+
+	// certificateIDPattern matches the IDs devices accept for certificates.
+	certificateIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// CertificatesPageData contains the data for the /certificates page
+type CertificatesPageData struct {
+	Rows        []CertificateRow
+	Unreachable []certs.Scan
+	Expiring    int
+	Expired     int
+	WarnDays    int
+	Permissions auth.Permissions
+	Result      *ActionResult
+}
+
+// CertificateRow is a certificate on the expiry dashboard
+type CertificateRow struct {
+	Device      models.AxisDevice
+	Certificate network.DeviceCertificate
+	CA          bool
+	HTTPS       bool
+	Status      string
+	DaysLeft    int
+}
+
+// DeviceCertificatesPageData contains the data for the
+// /manage/{serial}/certificates page
+type DeviceCertificatesPageData struct {
+	Device   models.AxisDevice
+	Scan     certs.Scan
+	WarnDays int
+	CSR      string
+	Form     CSRForm
+	CAName   string // Name of Neba's CA, empty if there is none
+	Result   *ActionResult
+}
+
+// CSRForm contains the values of the form to generate a CSR
+type CSRForm struct {
+	ID         string
+	CommonName string
+	Names      string
+}
+
+func RegisterCertificatesRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, cm *configs.CManager) {
+	var err error
+	certificatesTmpl, err = template.New("certificates.html").Funcs(template.FuncMap{
+		"join": strings.Join,
+		"status": func(cert network.DeviceCertificate) string {
+			return certs.Status(cert, cm.Get().Certificates.WarnDays, time.Now())
+		},
+	}).ParseFS(ui.FS, "certificates.html", "snapshot.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /certificates", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		renderCertificates(w, r, db, cm, nil)
+	}))
+	mux.Handle("POST /certificates/scan", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		var result *ActionResult
+		if _, err := certs.ScanAll(db); err != nil {
+			result = &ActionResult{Message: err.Error()}
+		}
+		renderCertificates(w, r, db, cm, result)
+	}))
+
+	mux.Handle("GET /manage/{serial}/certificates", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		renderDeviceCertificates(w, r, db, cm, *device, DeviceCertificatesPageData{})
+	}))
+	mux.Handle("POST /manage/{serial}/certificates/csr", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleCreateCSR(w, r, db, cm)
+	}))
+	mux.Handle("POST /manage/{serial}/certificates", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleInstallCertificate(w, r, db, cm)
+	}))
+	mux.Handle("POST /manage/{serial}/certificates/{id}/https", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleBindCertificate(w, r, db, cm)
+	}))
+	mux.Handle("DELETE /manage/{serial}/certificates/{id}", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteCertificate(w, r, db, cm)
+	}))
+}
+
+func handleCreateCSR(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager) {
+	auditAction(r, "Create certificate signing request")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	form := CSRForm{
+		ID:         strings.TrimSpace(r.FormValue("id")),
+		CommonName: strings.TrimSpace(r.FormValue("common_name")),
+		Names:      r.FormValue("names"),
+	}
+	auditTargets(r, device.SerialNumber, form.ID)
+	data := DeviceCertificatesPageData{Form: form}
+
+	request := network.CSRRequest{ID: form.ID, CommonName: form.CommonName}
+	for _, name := range parseList(strings.ReplaceAll(form.Names, "\n", ",")) {
+		if net.ParseIP(name) != nil {
+			request.IPAddresses = append(request.IPAddresses, name)
+		} else {
+			request.DNSNames = append(request.DNSNames, name)
+		}
+	}
+
+	switch {
+	case !certificateIDPattern.MatchString(form.ID):
+		data.Result = &ActionResult{Message: "The ID must consist of up to 64 letters, digits, dashes, and underscores."}
+	case form.CommonName == "":
+		data.Result = &ActionResult{Message: "The common name must not be empty."}
+	default:
+		csr, err := network.CreateCSR(deviceClient(*device), request)
+		if err != nil {
+			data.Result = &ActionResult{Message: err.Error()}
+		} else {
+			data.CSR = csr
+			data.Result = &ActionResult{Success: true, Message: fmt.Sprintf(
+				"Created a key and a signing request on %s. Have your CA sign the request, then install the certificate as %s.",
+				device.SerialNumber, form.ID)}
+		}
+	}
+	renderDeviceCertificates(w, r, db, cm, *device, data)
+}
+
+// handleInstallCertificate installs a signed certificate, or a CA
+// certificate if the "ca" box is checked.
+func handleInstallCertificate(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager) {
+	auditAction(r, "Install certificate")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+
+	fail := func(message string) {
+		renderDeviceCertificates(w, r, db, cm, *device, DeviceCertificatesPageData{Result: &ActionResult{Message: message}})
+	}
+
+	// The certificate is either pasted or uploaded as a file.
+	r.Body = http.MaxBytesReader(w, r.Body, maxCertificateSize)
+	pem := strings.TrimSpace(r.FormValue("certificate"))
+	id := strings.TrimSpace(r.FormValue("id"))
+	ca := r.FormValue("ca") != ""
+	auditTargets(r, device.SerialNumber, id)
+	if file, _, err := r.FormFile("file"); err == nil {
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			fail(fmt.Sprintf("Reading the uploaded file failed: %v", err))
+			return
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			pem = strings.TrimSpace(string(data))
+		}
+	}
+	if !certificateIDPattern.MatchString(id) {
+		fail("The ID must consist of up to 64 letters, digits, dashes, and underscores.")
+		return
+	}
+	cert, err := pki.ParseCertificatePEM([]byte(pem))
+	if err != nil {
+		fail(fmt.Sprintf("Invalid certificate: %v", err))
+		return
+	}
+	if time.Now().After(cert.NotAfter) {
+		fail(fmt.Sprintf("The certificate expired on %s.", cert.NotAfter.Format(time.DateOnly)))
+		return
+	}
+	if ca && !cert.IsCA {
+		fail("This is not a CA certificate.")
+		return
+	}
+
+	if ca {
+		err = network.InstallCACertificate(deviceClient(*device), id, pem)
+	} else {
+		err = network.InstallCertificate(deviceClient(*device), id, pem)
+	}
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	renderDeviceCertificates(w, r, db, cm, *device, DeviceCertificatesPageData{Result: &ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Installed %s, valid until %s.", id, cert.NotAfter.Format(time.DateOnly)),
+	}})
+}
+
+func handleBindCertificate(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager) {
+	auditAction(r, "Use certificate for HTTPS")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	auditTargets(r, device.SerialNumber, id)
+
+	result := &ActionResult{Success: true, Message: fmt.Sprintf("%s now uses %s for HTTPS.", device.SerialNumber, id)}
+	if err := network.BindHTTPSCertificate(deviceClient(*device), id); err != nil {
+		result = &ActionResult{Message: err.Error()}
+	}
+	renderDeviceCertificates(w, r, db, cm, *device, DeviceCertificatesPageData{Result: result})
+}
+
+func handleDeleteCertificate(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager) {
+	auditAction(r, "Delete certificate")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	auditTargets(r, device.SerialNumber, id)
+
+	result := &ActionResult{Success: true, Message: fmt.Sprintf("Deleted %s from %s.", id, device.SerialNumber)}
+	client := deviceClient(*device)
+	if https, err := network.HTTPSCertificate(client); err != nil {
+		result = &ActionResult{Message: err.Error()}
+	} else if https == id {
+		result = &ActionResult{Message: fmt.Sprintf("%s is used for HTTPS; use another certificate first.", id)}
+	} else if err := network.DeleteCertificate(client, id); err != nil {
+		result = &ActionResult{Message: err.Error()}
+	}
+	renderDeviceCertificates(w, r, db, cm, *device, DeviceCertificatesPageData{Result: result})
+}
+
+func renderCertificates(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager, result *ActionResult) {
+	data := CertificatesPageData{
+		WarnDays:    cm.Get().Certificates.WarnDays,
+		Permissions: permissionsFromContext(r.Context()),
+		Result:      result,
+	}
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+
+	devices, err := scopedDevices(r, db)
+	if err == nil {
+		var scans map[string]certs.Scan
+		if scans, err = certs.StoredScans(db); err == nil {
+			data.addRows(devices, scans, time.Now())
+		}
+	}
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+
+	if err := certificatesTmpl.ExecuteTemplate(w, "certificates.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// addRows adds the certificates of the devices to the dashboard, those that
+// expire first at the top.
+func (data *CertificatesPageData) addRows(devices []models.AxisDevice, scans map[string]certs.Scan, now time.Time) {
+	for _, device := range devices {
+		scan, ok := scans[device.SerialNumber]
+		if !ok {
+			continue
+		}
+		if scan.Error != "" {
+			data.Unreachable = append(data.Unreachable, scan)
+		}
+		add := func(cert network.DeviceCertificate, ca bool) {
+			row := CertificateRow{
+				Device:      device,
+				Certificate: cert,
+				CA:          ca,
+				HTTPS:       !ca && cert.ID == scan.HTTPS,
+				Status:      certs.Status(cert, data.WarnDays, now),
+				DaysLeft:    int(cert.NotAfter.Sub(now).Hours() / 24),
+			}
+			switch row.Status {
+			case certs.StatusExpiring:
+				data.Expiring++
+			case certs.StatusExpired:
+				data.Expired++
+			}
+			data.Rows = append(data.Rows, row)
+		}
+		for _, cert := range scan.Certificates {
+			add(cert, false)
+		}
+		for _, cert := range scan.CACertificates {
+			add(cert, true)
+		}
+	}
+	slices.SortStableFunc(data.Rows, func(a, b CertificateRow) int {
+		return cmp.Compare(a.Certificate.NotAfter.Unix(), b.Certificate.NotAfter.Unix())
+	})
+}
+
+func renderDeviceCertificates(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager, device models.AxisDevice, data DeviceCertificatesPageData) {
+	data.Device = device
+	data.WarnDays = cm.Get().Certificates.WarnDays
+	if data.Form.ID == "" {
+		host := network.Host(device.IPAddress)
+		data.Form = CSRForm{ID: "neba", CommonName: host, Names: host}
+	}
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+	if ca, err := certs.LoadCA(cm.Dir()); err == nil && ca != nil {
+		data.CAName = ca.Certificate.Subject.CommonName
+	}
+
+	data.Scan = certs.ScanDevice(device)
+	if data.Scan.Error != "" && data.Result == nil {
+		data.Result = &ActionResult{Message: data.Scan.Error}
+	}
+	if err := certs.StoreScan(db, data.Scan); err != nil {
+		log.Printf("Failed to store certificates of %s: %v", device.SerialNumber, err)
+	}
+
+	if err := certificatesTmpl.ExecuteTemplate(w, "device-certificates", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
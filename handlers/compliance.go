@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/compliance"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Compliance check constants
+	complianceReportsSetting = "compliance.reports"
+	compliancePolicySetting  = "compliance.policy"
+	complianceWorkers        = 16
+)
+
+var (
+	complianceTmpl *template.Template
+)
+
+// CompliancePageData contains the data for the /compliance page
+type CompliancePageData struct {
+	Rows        []ComplianceRow
+	Rules       []RuleSummary
+	Checked     int // Devices with a report
+	Unreachable int // Devices whose settings could not be read
+	Compliant   int // Devices that passed every known finding
+	Score       int // Average score of the checked devices
+	Permissions auth.Permissions
+	Result      *ActionResult
+}
+
+// ComplianceRow is a device with its last compliance report, if any
+type ComplianceRow struct {
+	Device models.AxisDevice
+	Report *compliance.Report
+}
+
+// RuleSummary is an enabled rule with the number of devices that fail it
+type RuleSummary struct {
+	Rule     compliance.Rule
+	Severity compliance.Severity
+	Failed   int
+}
+
+// DeviceCompliancePageData contains the data for the
+// /manage/{serial}/compliance page
+type DeviceCompliancePageData struct {
+	Device      models.AxisDevice
+	Report      compliance.Report
+	Rules       map[string]compliance.Rule // Rules by ID, for their remedies
+	Permissions auth.Permissions
+	Result      *ActionResult
+}
+
+// RemediationPageData contains the data for the /compliance/remediate page
+type RemediationPageData struct {
+	Rules    []string // IDs of the rules to remedy; empty for every rule
+	Previews []RemediationPreview
+	Devices  string // Serial numbers of the devices to change, for the form
+	Changes  int    // Number of parameter changes of all devices
+	Job      *models.Job
+	Result   *ActionResult
+}
+
+// RemediationPreview is how the failed findings of a device would be
+// remedied
+type RemediationPreview struct {
+	Device      models.AxisDevice
+	Plan        []compliance.Remediation
+	Fingerprint string // Of the plan, to apply only what was previewed
+	Error       string
+}
+
+// PolicyPageData contains the data for the /compliance/policy page
+type PolicyPageData struct {
+	Rules                []PolicyRow
+	MaxFirmwareAgeDays   int
+	ProbeDefaultPassword bool
+	Severities           []compliance.Severity
+	Result               *ActionResult
+}
+
+// PolicyRow is a rule with its policy
+type PolicyRow struct {
+	Rule   compliance.Rule
+	Policy compliance.RulePolicy
+}
+
+func RegisterComplianceRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, jm *jobs.Manager) {
+	var err error
+	complianceTmpl, err = template.ParseFS(ui.FS, "compliance.html", "jobs.html", "snapshot.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /compliance", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		renderCompliance(w, r, db, CompliancePageData{})
+	}))
+	mux.Handle("POST /compliance/check", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		data := CompliancePageData{}
+		if _, err := CheckCompliance(db); err != nil {
+			data.Result = &ActionResult{Message: err.Error()}
+		}
+		renderCompliance(w, r, db, data)
+	}))
+	mux.Handle("GET /compliance/policy", AuthorizeFunc(auth.PermManageSettings, func(w http.ResponseWriter, r *http.Request) {
+		renderPolicy(w, r, db, nil, nil)
+	}))
+	mux.Handle("POST /compliance/policy", AuthorizeFunc(auth.PermManageSettings, func(w http.ResponseWriter, r *http.Request) {
+		handleSavePolicy(w, r, db)
+	}))
+	mux.Handle("GET /compliance/remediate", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handlePreviewRemediation(w, r, db)
+	}))
+	mux.Handle("POST /compliance/remediate", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleRemediate(w, r, db, jm)
+	}))
+	mux.Handle("GET /manage/{serial}/compliance", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		renderDeviceCompliance(w, r, db, *device, nil)
+	}))
+}
+
+// CheckCompliance checks every device against the compliance policy, and
+// stores the reports to show on the compliance page.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - []compliance.Report: The report per device, ordered by serial number.
+//   - error:               An error if the devices or the policy cannot be
+//     read, or the reports cannot be stored.
+func CheckCompliance(db *bbolt.DB) ([]compliance.Report, error) {
+	devices, err := database.Devices(db).List()
+	if err != nil {
+		return nil, fmt.Errorf("check compliance: %w", err)
+	}
+	policy, err := compliancePolicy(db)
+	if err != nil {
+		return nil, fmt.Errorf("check compliance: %w", err)
+	}
+
+	reports := make([]compliance.Report, len(devices))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range min(complianceWorkers, len(devices)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				reports[i] = compliance.Check(deviceClient(devices[i]), devices[i].SerialNumber, policy)
+			}
+		}()
+	}
+	for i := range devices {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	slices.SortFunc(reports, func(a, b compliance.Report) int { return strings.Compare(a.SerialNumber, b.SerialNumber) })
+	stored := make(map[string]compliance.Report, len(reports))
+	for _, report := range reports {
+		stored[report.SerialNumber] = report
+	}
+	if err := database.PutSetting(db, complianceReportsSetting, stored); err != nil {
+		return nil, fmt.Errorf("store compliance reports: %w", err)
+	}
+	return reports, nil
+}
+
+// storeComplianceReport replaces the stored report of a single device.
+func storeComplianceReport(db *bbolt.DB, report compliance.Report) error {
+	reports, err := storedComplianceReports(db)
+	if err != nil {
+		return err
+	}
+	reports[report.SerialNumber] = report
+	return database.PutSetting(db, complianceReportsSetting, reports)
+}
+
+// storedComplianceReports returns the last compliance reports by serial
+// number.
+func storedComplianceReports(db *bbolt.DB) (map[string]compliance.Report, error) {
+	reports := map[string]compliance.Report{}
+	if err := database.GetSetting(db, complianceReportsSetting, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// compliancePolicy returns the stored compliance policy, or the default
+// policy if it has never been changed.
+func compliancePolicy(db *bbolt.DB) (compliance.Policy, error) {
+	policy := compliance.DefaultPolicy()
+	if err := database.GetSetting(db, compliancePolicySetting, &policy); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+func handleSavePolicy(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Update compliance policy")
+	if err := r.ParseForm(); err != nil {
+		renderPolicy(w, r, db, nil, &ActionResult{Message: "Invalid form."})
+		return
+	}
+
+	policy := compliance.DefaultPolicy()
+	for _, rule := range compliance.Rules {
+		severity := compliance.Severity(r.Form.Get(rule.ID + ".severity"))
+		if severity == "" {
+			severity = rule.Severity
+		}
+		policy.Rules[rule.ID] = compliance.RulePolicy{Enabled: r.Form.Get(rule.ID+".enabled") != "", Severity: severity}
+	}
+	maxAge, err := strconv.Atoi(strings.TrimSpace(r.Form.Get("max_firmware_age_days")))
+	if err != nil {
+		renderPolicy(w, r, db, &policy, &ActionResult{Message: "The maximum firmware age must be a number of days."})
+		return
+	}
+	policy.MaxFirmwareAgeDays = maxAge
+	policy.ProbeDefaultPassword = r.Form.Get("probe_default_password") != ""
+	if err := policy.Validate(); err != nil {
+		renderPolicy(w, r, db, &policy, &ActionResult{Message: fmt.Sprintf("Invalid policy: %v.", err)})
+		return
+	}
+
+	if err := database.PutSetting(db, compliancePolicySetting, policy); err != nil {
+		renderPolicy(w, r, db, &policy, &ActionResult{Message: fmt.Sprintf("Saving the policy failed: %v", err)})
+		return
+	}
+	renderPolicy(w, r, db, &policy, &ActionResult{Success: true, Message: "Saved the policy. It applies from the next check."})
+}
+
+// handlePreviewRemediation reads the settings of the devices to remedy and
+// shows the changes that would be made, without making them. Without
+// selected devices, the devices whose last report fails one of the rules are
+// previewed.
+func handlePreviewRemediation(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	rules := r.URL.Query()["rule"]
+	data := RemediationPageData{Rules: rules}
+	devices, err := remediationDevices(r, db, rules)
+	var policy compliance.Policy
+	if err == nil {
+		policy, err = compliancePolicy(db)
+	}
+	if err != nil {
+		data.Result = &ActionResult{Message: err.Error()}
+		renderRemediation(w, r, data)
+		return
+	}
+
+	previews := make([]RemediationPreview, len(devices))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range min(complianceWorkers, len(devices)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				previews[i] = previewRemediation(devices[i], policy, rules)
+			}
+		}()
+	}
+	for i := range devices {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	var serials []string
+	for _, preview := range previews {
+		changes := 0
+		for _, remediation := range preview.Plan {
+			changes += len(remediation.Changes)
+		}
+		if changes > 0 {
+			serials = append(serials, preview.Device.SerialNumber)
+			data.Changes += changes
+		}
+		if preview.Error != "" || len(preview.Plan) > 0 {
+			data.Previews = append(data.Previews, preview)
+		}
+	}
+	data.Devices = strings.Join(serials, ",")
+	renderRemediation(w, r, data)
+}
+
+// previewRemediation reads the settings of the device and plans how to
+// remedy its failed findings.
+func previewRemediation(device models.AxisDevice, policy compliance.Policy, rules []string) RemediationPreview {
+	preview := RemediationPreview{Device: device}
+	settings, err := compliance.ReadSettings(deviceClient(device), false)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	preview.Plan = compliance.Plan(settings, policy, rules)
+	preview.Fingerprint = compliance.Fingerprint(preview.Plan)
+	return preview
+}
+
+// remediationDevices returns the selected devices, or, without a selection,
+// the devices whose last report fails one of the rules.
+func remediationDevices(r *http.Request, db *bbolt.DB, rules []string) ([]models.AxisDevice, error) {
+	if r.FormValue("devices") != "" {
+		return selectDevices(r, db)
+	}
+
+	devices, err := scopedDevices(r, db)
+	if err != nil {
+		return nil, err
+	}
+	reports, err := storedComplianceReports(db)
+	if err != nil {
+		return nil, err
+	}
+	devices = slices.DeleteFunc(devices, func(device models.AxisDevice) bool {
+		return !slices.ContainsFunc(reports[device.SerialNumber].Failed(), func(finding compliance.Finding) bool {
+			return len(rules) == 0 || slices.Contains(rules, finding.Rule)
+		})
+	})
+	if len(devices) == 0 {
+		return nil, errors.New("no device fails the selected rules in its last check")
+	}
+	return devices, nil
+}
+
+// handleRemediate starts a job that remedies the failed findings of every
+// selected device, and checks the device again afterwards. Each device comes
+// with the fingerprint of its previewed plan, as "serial:fingerprint", and
+// is left unchanged if its plan differs by the time the job gets to it.
+func handleRemediate(w http.ResponseWriter, r *http.Request, db *bbolt.DB, jm *jobs.Manager) {
+	auditAction(r, "Remediate compliance findings")
+	if err := r.ParseForm(); err != nil {
+		renderRemediation(w, r, RemediationPageData{Result: &ActionResult{Message: "Invalid form."}})
+		return
+	}
+	rules := r.PostForm["rule"]
+	data := RemediationPageData{Rules: rules}
+	previewed := map[string]string{}
+	for _, plan := range r.PostForm["plan"] {
+		if serial, fingerprint, ok := strings.Cut(plan, ":"); ok {
+			previewed[serial] = fingerprint
+		}
+	}
+
+	devices, err := selectDevices(r, db)
+	var policy compliance.Policy
+	if err == nil {
+		policy, err = compliancePolicy(db)
+	}
+	if err != nil {
+		data.Result = &ActionResult{Message: err.Error()}
+		renderRemediation(w, r, data)
+		return
+	}
+
+	user, _ := UserFromContext(r.Context())
+	job, err := jobs.Start(db, jm, "Remediate findings", user.Username, serialNumbers(devices),
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			for _, device := range devices {
+				if ctx.Err() != nil {
+					return "Stopped because Neba is shutting down.", nil
+				}
+				message, err := remediate(db, device, policy, rules, previewed[device.SerialNumber])
+				if err != nil {
+					report.Failed(device.SerialNumber, "%v", err)
+					continue
+				}
+				report.Succeeded(device.SerialNumber, "%s", message)
+			}
+			return fmt.Sprintf("Processed %d devices.", len(devices)), nil
+		})
+	if err != nil {
+		data.Result = &ActionResult{Message: fmt.Sprintf("Starting the job failed: %v", err)}
+		renderRemediation(w, r, data)
+		return
+	}
+	auditTargets(r, job.Targets...)
+	data.Job = job
+	data.Result = &ActionResult{Success: true, Message: fmt.Sprintf("Started: %s on %d devices.", job.Kind, len(job.Targets))}
+	renderRemediation(w, r, data)
+}
+
+// remediate remedies the failed findings of the device, checks it again, and
+// stores the new report. The settings are read again, and the device is left
+// unchanged if they changed since the preview, so that only the previewed
+// changes are made.
+//
+// Parameters:
+//   - db:          A pointer to the bbolt.DB instance.
+//   - device:      The device to remedy.
+//   - policy:      The policy the device is checked against.
+//   - rules:       The IDs of the rules to remedy; empty for every rule.
+//   - fingerprint: The fingerprint of the previewed plan of the device.
+//
+// Returns:
+//   - string: A description of what was changed and the new score.
+//   - error:  An error if the plan differs from the preview, the changes
+//     fail, or the findings still fail afterwards.
+func remediate(db *bbolt.DB, device models.AxisDevice, policy compliance.Policy, rules []string, fingerprint string) (string, error) {
+	client := deviceClient(device)
+	settings, err := compliance.ReadSettings(client, false)
+	if err != nil {
+		return "", err
+	}
+
+	plan := compliance.Plan(settings, policy, rules)
+	if fingerprint == "" {
+		return "", errors.New("the changes were not previewed; preview them first")
+	}
+	if compliance.Fingerprint(plan) != fingerprint {
+		return "", errors.New("the settings changed since the preview, so nothing was changed; preview the changes again")
+	}
+	var remedied, manual []string
+	for _, remediation := range plan {
+		if len(remediation.Changes) > 0 {
+			remedied = append(remedied, remediation.Rule)
+		} else {
+			manual = append(manual, remediation.Title)
+		}
+	}
+	if len(remedied) == 0 {
+		if len(manual) > 0 {
+			return fmt.Sprintf("Nothing to change; remedy by hand: %s.", strings.Join(manual, ", ")), nil
+		}
+		return "Nothing to change.", nil
+	}
+
+	changed, err := compliance.Apply(client, plan)
+	if err != nil {
+		return "", err
+	}
+
+	check := compliance.Check(client, device.SerialNumber, policy)
+	if err := storeComplianceReport(db, check); err != nil {
+		log.Printf("Failed to store compliance report of %s: %v", device.SerialNumber, err)
+	}
+	if check.Error != "" {
+		return "", fmt.Errorf("changed %d parameters, but checking again failed: %s", changed, check.Error)
+	}
+
+	var fixed, failing []string
+	for _, finding := range check.Findings {
+		if !slices.Contains(remedied, finding.Rule) {
+			continue
+		}
+		if finding.Status == compliance.StatusFailed {
+			failing = append(failing, finding.Title)
+		} else {
+			fixed = append(fixed, finding.Title)
+		}
+	}
+	if len(failing) > 0 {
+		return "", fmt.Errorf("changed %d parameters, but still failing: %s; score %d%%", changed, strings.Join(failing, ", "), check.Score)
+	}
+	message := fmt.Sprintf("Changed %d parameters; fixed %s. Score %d%%.", changed, strings.Join(fixed, ", "), check.Score)
+	if len(manual) > 0 {
+		message += fmt.Sprintf(" Remedy by hand: %s.", strings.Join(manual, ", "))
+	}
+	return message, nil
+}
+
+func renderRemediation(w http.ResponseWriter, r *http.Request, data RemediationPageData) {
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+	if err := complianceTmpl.ExecuteTemplate(w, "compliance-remediation", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func renderCompliance(w http.ResponseWriter, r *http.Request, db *bbolt.DB, data CompliancePageData) {
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+	data.Permissions = permissionsFromContext(r.Context())
+
+	devices, err := scopedDevices(r, db)
+	var policy compliance.Policy
+	if err == nil {
+		policy, err = compliancePolicy(db)
+	}
+	var reports map[string]compliance.Report
+	if err == nil {
+		reports, err = storedComplianceReports(db)
+	}
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+
+	failed := map[string]int{}
+	total := 0
+	for _, device := range devices {
+		row := ComplianceRow{Device: device}
+		if report, ok := reports[device.SerialNumber]; ok {
+			row.Report = &report
+			data.Checked++
+			if report.Error != "" {
+				data.Unreachable++
+			} else {
+				total += report.Score
+				if report.Score == 100 {
+					data.Compliant++
+				}
+				for _, finding := range report.Failed() {
+					failed[finding.Rule]++
+				}
+			}
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	if reachable := data.Checked - data.Unreachable; reachable > 0 {
+		data.Score = total / reachable
+	}
+	for _, rule := range compliance.Rules {
+		if rulePolicy := policy.Rule(rule.ID); rulePolicy.Enabled {
+			data.Rules = append(data.Rules, RuleSummary{Rule: rule, Severity: rulePolicy.Severity, Failed: failed[rule.ID]})
+		}
+	}
+
+	if err := complianceTmpl.ExecuteTemplate(w, "compliance.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// renderDeviceCompliance checks the device against the policy, stores the
+// report, and shows its findings.
+func renderDeviceCompliance(w http.ResponseWriter, r *http.Request, db *bbolt.DB, device models.AxisDevice, result *ActionResult) {
+	data := DeviceCompliancePageData{
+		Device:      device,
+		Rules:       map[string]compliance.Rule{},
+		Permissions: permissionsFromContext(r.Context()),
+		Result:      result,
+	}
+	for _, rule := range compliance.Rules {
+		data.Rules[rule.ID] = rule
+	}
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+
+	policy, err := compliancePolicy(db)
+	if err != nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	} else {
+		data.Report = compliance.Check(deviceClient(device), device.SerialNumber, policy)
+		if data.Report.Error != "" && data.Result == nil {
+			data.Result = &ActionResult{Message: fmt.Sprintf("Reading the settings failed: %s", data.Report.Error)}
+		}
+		if err := storeComplianceReport(db, data.Report); err != nil {
+			log.Printf("Failed to store compliance report of %s: %v", device.SerialNumber, err)
+		}
+	}
+
+	if err := complianceTmpl.ExecuteTemplate(w, "device-compliance", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// renderPolicy shows the given policy, or the stored policy if it is nil.
+func renderPolicy(w http.ResponseWriter, r *http.Request, db *bbolt.DB, policy *compliance.Policy, result *ActionResult) {
+	data := PolicyPageData{Severities: compliance.Severities, Result: result}
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+
+	if policy == nil {
+		stored, err := compliancePolicy(db)
+		if err != nil && data.Result == nil {
+			data.Result = &ActionResult{Message: err.Error()}
+		}
+		policy = &stored
+	}
+	data.MaxFirmwareAgeDays = policy.MaxFirmwareAgeDays
+	data.ProbeDefaultPassword = policy.ProbeDefaultPassword
+	for _, rule := range compliance.Rules {
+		data.Rules = append(data.Rules, PolicyRow{Rule: rule, Policy: policy.Rule(rule.ID)})
+	}
+
+	if err := complianceTmpl.ExecuteTemplate(w, "compliance-policy", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
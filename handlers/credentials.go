@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	credentialsTmpl *template.Template
+)
+
+// CredentialsPageData contains the data for the /credentials page
+type CredentialsPageData struct {
+	Profiles []models.Credential
+	Usage    map[string]int // Number of devices using each profile
+	Result   *ActionResult
+}
+
+func RegisterCredentialsRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB) {
+	var err error
+	credentialsTmpl, err = template.ParseFS(ui.FS, "credentials.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /credentials", AuthorizeFunc(auth.PermManageCreds, func(w http.ResponseWriter, r *http.Request) {
+		renderCredentials(w, r, db, nil)
+	}))
+	mux.Handle("POST /credentials", AuthorizeFunc(auth.PermManageCreds, func(w http.ResponseWriter, r *http.Request) {
+		handleCreateCredential(w, r, db)
+	}))
+	mux.Handle("POST /credentials/{name}", AuthorizeFunc(auth.PermManageCreds, func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateCredential(w, r, db)
+	}))
+	mux.Handle("DELETE /credentials/{name}", AuthorizeFunc(auth.PermManageCreds, func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteCredential(w, r, db)
+	}))
+}
+
+func handleCreateCredential(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Create credential profile")
+	name := strings.TrimSpace(r.FormValue("name"))
+	auditTargets(r, name)
+
+	profile := models.Credential{
+		Name:        name,
+		Username:    strings.TrimSpace(r.FormValue("username")),
+		Password:    r.FormValue("password"),
+		Description: strings.TrimSpace(r.FormValue("description")),
+		UpdatedAt:   time.Now(),
+	}
+	switch {
+	case name == "":
+		renderCredentials(w, r, db, &ActionResult{Message: "Name must not be empty."})
+		return
+	case strings.ContainsAny(name, "/?#%"):
+		renderCredentials(w, r, db, &ActionResult{Message: "Name must not contain /, ?, #, or %."})
+		return
+	case profile.Username == "" || profile.Password == "":
+		renderCredentials(w, r, db, &ActionResult{Message: "Username and password must not be empty."})
+		return
+	}
+	if _, err := database.Credentials(db).Get(name); err == nil {
+		renderCredentials(w, r, db, &ActionResult{Message: fmt.Sprintf("Credential profile %s already exists.", name)})
+		return
+	}
+
+	if err := database.Credentials(db).Put(profile); err != nil {
+		renderCredentials(w, r, db, &ActionResult{Message: fmt.Sprintf("Creating %s failed: %v", name, err)})
+		return
+	}
+	renderCredentials(w, r, db, &ActionResult{Success: true, Message: fmt.Sprintf("Created %s.", name)})
+}
+
+// handleUpdateCredential changes the username, description, or password of
+// a profile. Devices that use the profile keep their current credentials.
+func handleUpdateCredential(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Update credential profile")
+	name := r.PathValue("name")
+	auditTargets(r, name)
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	if username == "" {
+		renderCredentials(w, r, db, &ActionResult{Message: "Username must not be empty."})
+		return
+	}
+	err := database.Credentials(db).Modify(name, func(profile *models.Credential) error {
+		profile.Username = username
+		profile.Description = strings.TrimSpace(r.FormValue("description"))
+		if password := r.FormValue("password"); password != "" {
+			profile.Password = password
+		}
+		profile.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		renderCredentials(w, r, db, &ActionResult{Message: fmt.Sprintf("Updating %s failed: %v", name, err)})
+		return
+	}
+	renderCredentials(w, r, db, &ActionResult{Success: true, Message: fmt.Sprintf("Updated %s.", name)})
+}
+
+func handleDeleteCredential(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Delete credential profile")
+	name := r.PathValue("name")
+	auditTargets(r, name)
+
+	if err := database.Credentials(db).Delete(name); err != nil {
+		renderCredentials(w, r, db, &ActionResult{Message: fmt.Sprintf("Deleting %s failed: %v", name, err)})
+		return
+	}
+	renderCredentials(w, r, db, &ActionResult{Success: true, Message: fmt.Sprintf("Deleted %s.", name)})
+}
+
+func renderCredentials(w http.ResponseWriter, r *http.Request, db *bbolt.DB, result *ActionResult) {
+	if result != nil && !result.Success {
+		auditFailure(r, result.Message)
+	}
+
+	data := CredentialsPageData{Usage: make(map[string]int), Result: result}
+	profiles, err := database.Credentials(db).List()
+	if err == nil {
+		var devices []models.AxisDevice
+		devices, err = database.Devices(db).List()
+		for _, device := range devices {
+			if device.CredentialProfile != "" {
+				data.Usage[device.CredentialProfile]++
+			}
+		}
+	}
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+	data.Profiles = profiles
+
+	if err := credentialsTmpl.ExecuteTemplate(w, "credentials.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/configs"
 	"github.com/furkansuleymana/neba/network"
 	"github.com/furkansuleymana/neba/ui"
 )
@@ -15,29 +17,41 @@ var (
 
 // DevicePageData contains the data for the /discover page
 type DevicePageData struct {
-	Devices     []map[string]string
-	DeviceCount int
-	Error       string
+	Devices       []map[string]string
+	DeviceCount   int
+	Unprovisioned int
+	Permissions   auth.Permissions
+	Error         string
 }
 
-func RegisterDiscoverDevicesRoute(fs http.Handler, mux *http.ServeMux) {
+func RegisterDiscoverDevicesRoute(fs http.Handler, mux *http.ServeMux, cm *configs.CManager) {
 	var err error
 	discoverDevicesTmpl, err = template.ParseFS(ui.FS, "discover.html")
 	if err != nil {
 		log.Fatalf("Failed to parse templates: %v", err)
 	}
 
-	mux.HandleFunc("/discover", handleDiscoverDevices)
+	mux.Handle("/discover", AuthorizeFunc(auth.PermDiscoverDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleDiscoverDevices(w, r, cm)
+	}))
 }
 
-func handleDiscoverDevices(w http.ResponseWriter, r *http.Request) {
-	data := DevicePageData{}
+func handleDiscoverDevices(w http.ResponseWriter, r *http.Request, cm *configs.CManager) {
+	data := DevicePageData{Permissions: permissionsFromContext(r.Context())}
 
-	deviceList, err := network.DiscoverSSDP()
+	discovery := cm.Get().Discovery
+	deviceList, err := network.DiscoverSSDP(discovery.TimeoutSeconds, discovery.LocalAddress)
 	if err != nil {
 		data.Error = err.Error()
 	}
 
+	network.MarkUnprovisioned(deviceList)
+	for _, device := range deviceList {
+		if device["Unprovisioned"] == "true" {
+			data.Unprovisioned++
+		}
+	}
+
 	data.Devices = deviceList
 	data.DeviceCount = len(deviceList)
 
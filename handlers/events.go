@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/events"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Event log constants
+	eventPageLimit     = 500
+	defaultEventPeriod = "24h"
+)
+
+var (
+	eventsTmpl *template.Template
+
+	// eventPeriods are the time ranges the event timeline can be limited to
+	eventPeriods = []EventPeriod{
+		{Value: "1h", Label: "Last hour", Duration: time.Hour},
+		{Value: "24h", Label: "Last 24 hours", Duration: 24 * time.Hour},
+		{Value: "7d", Label: "Last 7 days", Duration: 7 * 24 * time.Hour},
+		{Value: "30d", Label: "Last 30 days", Duration: 30 * 24 * time.Hour},
+		{Value: "all", Label: "All kept events"},
+	}
+
+	// eventCategoryClasses are the badge colors of the event categories
+	eventCategoryClasses = map[string]string{
+		models.EventTampering: "text-bg-danger",
+		models.EventMotion:    "text-bg-warning",
+		models.EventIO:        "text-bg-info",
+		models.EventStorage:   "text-bg-danger",
+		models.EventHardware:  "text-bg-danger",
+		models.EventOther:     "text-bg-secondary",
+	}
+)
+
+// EventsPageData contains the data for the /events and
+// /manage/{serial}/events pages
+type EventsPageData struct {
+	Events     []models.Event
+	Filter     EventFilter
+	Device     *models.AxisDevice // Set on the page of a single device
+	Devices    []models.AxisDevice
+	Categories []string
+	Periods    []EventPeriod
+	Statuses   map[string]events.Status
+	Connected  int
+	Enabled    bool
+	Limit      int
+	Error      string
+}
+
+// EventFilter holds the criteria for the event timeline
+type EventFilter struct {
+	Device   string
+	Category string
+	Period   string
+	Search   string
+}
+
+// EventPeriod is a time range the event timeline can be limited to
+type EventPeriod struct {
+	Value    string
+	Label    string
+	Duration time.Duration // Zero for no limit
+}
+
+func RegisterEventsRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, cm *configs.CManager, subscriber *events.Subscriber) {
+	var err error
+	eventsTmpl, err = template.New("events.html").Funcs(template.FuncMap{
+		"items":         events.Describe,
+		"categoryClass": func(category string) string { return eventCategoryClasses[category] },
+	}).ParseFS(ui.FS, "events.html", "snapshot.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /events", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleEvents(w, r, db, cm, subscriber, nil, "events.html")
+	}))
+	mux.Handle("GET /events/timeline", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleEvents(w, r, db, cm, subscriber, nil, "event-timeline")
+	}))
+	mux.Handle("GET /manage/{serial}/events", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		handleEvents(w, r, db, cm, subscriber, device, "events.html")
+	}))
+	mux.Handle("GET /manage/{serial}/events/timeline", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		handleEvents(w, r, db, cm, subscriber, device, "event-timeline")
+	}))
+}
+
+// handleEvents renders the event timeline of the devices in the user's scope,
+// or of a single device if one is given.
+func handleEvents(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager, subscriber *events.Subscriber, device *models.AxisDevice, name string) {
+	filter := parseEventFilter(r)
+	data := EventsPageData{
+		Filter:     filter,
+		Device:     device,
+		Categories: events.Categories,
+		Periods:    eventPeriods,
+		Statuses:   subscriber.Statuses(),
+		Enabled:    cm.Get().Events.Enabled,
+		Limit:      eventPageLimit,
+	}
+	if device != nil {
+		data.Filter.Device = device.SerialNumber
+		data.Devices = []models.AxisDevice{*device}
+	} else {
+		devices, err := scopedDevices(r, db)
+		if err != nil {
+			data.Error = err.Error()
+		}
+		data.Devices = devices
+	}
+
+	scope := make(map[string]bool, len(data.Devices))
+	for _, scoped := range data.Devices {
+		scope[scoped.SerialNumber] = true
+		if data.Statuses[scoped.SerialNumber].Connected {
+			data.Connected++
+		}
+	}
+	var since time.Time
+	for _, period := range eventPeriods {
+		if period.Value == data.Filter.Period && period.Duration > 0 {
+			since = time.Now().Add(-period.Duration)
+		}
+	}
+
+	list, err := database.ListEvents(db, since, func(event models.Event) bool {
+		return scope[event.SerialNumber] && data.Filter.Match(event)
+	}, eventPageLimit)
+	if err != nil {
+		data.Error = err.Error()
+	}
+	data.Events = list
+
+	if err := eventsTmpl.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// parseEventFilter reads the event filter from the query of the request.
+func parseEventFilter(r *http.Request) EventFilter {
+	query := r.URL.Query()
+	filter := EventFilter{
+		Device:   strings.TrimSpace(query.Get("device")),
+		Category: query.Get("category"),
+		Period:   query.Get("period"),
+		Search:   strings.TrimSpace(query.Get("search")),
+	}
+	if filter.Period == "" {
+		filter.Period = defaultEventPeriod
+	}
+	return filter
+}
+
+// Match reports whether the event meets the device, category, and search
+// criteria of the filter. The time range is checked by the caller.
+func (f EventFilter) Match(event models.Event) bool {
+	if f.Device != "" && !strings.EqualFold(event.SerialNumber, f.Device) {
+		return false
+	}
+	if f.Category != "" && event.Category != f.Category {
+		return false
+	}
+	if f.Search != "" {
+		text := strings.Join([]string{event.SerialNumber, event.Summary, event.Topic,
+			events.Describe(event.Source), events.Describe(event.Data)}, " ")
+		if !strings.Contains(strings.ToLower(text), strings.ToLower(f.Search)) {
+			return false
+		}
+	}
+	return true
+}
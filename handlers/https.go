@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+)
+
+// RedirectHTTPS returns a handler that permanently redirects every request to
+// the same host and path on the given HTTPS port, e.g. ":8443".
+func RedirectHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+
+		port := httpsPort
+		if port == ":443" {
+			port = ""
+		}
+
+		http.Redirect(w, r, "https://"+host+port+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/furkansuleymana/neba/auth"
 	"github.com/furkansuleymana/neba/ui"
 )
 
@@ -12,6 +13,14 @@ var (
 	rootTmpl *template.Template
 )
 
+// RootPageData contains the data for the / page
+type RootPageData struct {
+	Username    string
+	Role        string
+	CSRFToken   string
+	Permissions auth.Permissions
+}
+
 func RegisterRootRoute(fs http.Handler, mux *http.ServeMux) {
 	var err error
 	rootTmpl, err = template.ParseFS(ui.FS, "index.html")
@@ -23,7 +32,17 @@ func RegisterRootRoute(fs http.Handler, mux *http.ServeMux) {
 }
 
 func handleRoot(w http.ResponseWriter, r *http.Request) {
-	if err := rootTmpl.ExecuteTemplate(w, "index.html", nil); err != nil {
+	data := RootPageData{}
+	if session, ok := SessionFromContext(r.Context()); ok {
+		data.Username = session.Username
+		data.CSRFToken = session.CSRFToken
+	}
+	if user, ok := UserFromContext(r.Context()); ok {
+		data.Role = user.Role
+	}
+	data.Permissions = permissionsFromContext(r.Context())
+
+	if err := rootTmpl.ExecuteTemplate(w, "index.html", data); err != nil {
 		log.Fatalf("Template execution error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
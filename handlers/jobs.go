@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Job constants
+	jobPageLimit = 50
+)
+
+var (
+	jobsTmpl *template.Template
+)
+
+// JobsPageData contains the data for the /jobs page
+type JobsPageData struct {
+	Jobs  []models.Job
+	Error string
+}
+
+func RegisterJobsRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB) {
+	var err error
+	jobsTmpl, err = template.New("jobs.html").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).ParseFS(ui.FS, "jobs.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /jobs", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleJobs(w, r, db)
+	}))
+	mux.Handle("GET /jobs/{id}", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		job, err := database.Jobs(db).Get(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		visible, err := jobVisibility(r, db)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !visible(*job) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		renderJobs(w, "job", job)
+	}))
+}
+
+// handleJobs lists the most recent jobs the signed-in user may see.
+func handleJobs(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	data := JobsPageData{}
+	visible, err := jobVisibility(r, db)
+	if err != nil {
+		data.Error = err.Error()
+		renderJobs(w, "jobs.html", data)
+		return
+	}
+	all, err := database.ListJobs(db, 0)
+	if err != nil {
+		data.Error = err.Error()
+	}
+	for _, job := range all {
+		if len(data.Jobs) == jobPageLimit {
+			break
+		}
+		if visible(job) {
+			data.Jobs = append(data.Jobs, job)
+		}
+	}
+	renderJobs(w, "jobs.html", data)
+}
+
+// jobVisibility returns a function that reports whether the signed-in user
+// may see a job: users whose access is limited by scopes only see the jobs
+// they started and those that act on devices in their scope alone, so that
+// the results of other devices are not revealed.
+func jobVisibility(r *http.Request, db *bbolt.DB) (func(models.Job) bool, error) {
+	user, _ := UserFromContext(r.Context())
+	if auth.Unscoped(user) {
+		return func(models.Job) bool { return true }, nil
+	}
+
+	devices, err := scopedDevices(r, db)
+	if err != nil {
+		return nil, err
+	}
+	scope := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		scope[device.SerialNumber] = true
+	}
+	return func(job models.Job) bool {
+		if job.Username == user.Username {
+			return true
+		}
+		for _, target := range job.Targets {
+			if !scope[target] {
+				return false
+			}
+		}
+		return len(job.Targets) > 0
+	}, nil
+}
+
+func renderJobs(w http.ResponseWriter, name string, data any) {
+	if err := jobsTmpl.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Live view constants
+	streamBufferSize = 32 << 10
+)
+
+var (
+	liveViewTmpl *template.Template
+
+	// Choices of the live view page, besides the configured defaults
+	liveViewResolutions = []string{"320x180", "640x360", "1280x720", "1920x1080"}
+	liveViewFrameRates  = []int{1, 5, 10, 15, 30}
+
+	// Errors of streamLimiter.acquire
+	errStreamLimit       = errors.New("too many live streams")
+	errDeviceStreamLimit = errors.New("too many live streams of the device")
+)
+
+// streamLimiter counts the live streams Neba proxies, overall and per device,
+// to protect both the cameras and the Neba host.
+type streamLimiter struct {
+	mutex     sync.Mutex
+	total     int
+	perDevice map[string]int
+}
+
+var liveStreams = &streamLimiter{perDevice: map[string]int{}}
+
+// LiveViewListData contains the data for the /live page
+type LiveViewListData struct {
+	Devices []models.AxisDevice
+	Error   string
+}
+
+// LiveViewPageData contains the data for the /live/{serial} page
+type LiveViewPageData struct {
+	Device      models.AxisDevice
+	Resolutions []string
+	Resolution  string
+	FrameRates  []int
+	FPS         int
+	MaxDuration time.Duration
+}
+
+func RegisterLiveViewRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, cm *configs.CManager) {
+	var err error
+	liveViewTmpl, err = template.ParseFS(ui.FS, "live.html", "snapshot.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /live", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		data := LiveViewListData{}
+		devices, err := scopedDevices(r, db)
+		if err != nil {
+			data.Error = err.Error()
+		}
+		data.Devices = devices
+		if err := liveViewTmpl.ExecuteTemplate(w, "live.html", data); err != nil {
+			log.Printf("Template execution error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}))
+	mux.Handle("GET /live/{serial}", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		config := cm.Get().LiveView
+		data := LiveViewPageData{
+			Device:      *device,
+			Resolutions: liveViewResolutions,
+			Resolution:  config.Resolution,
+			FrameRates:  liveViewFrameRates,
+			FPS:         config.FPS,
+			MaxDuration: config.MaxDuration.Duration(),
+		}
+		if !slices.Contains(data.Resolutions, data.Resolution) {
+			data.Resolutions = append([]string{data.Resolution}, data.Resolutions...)
+		}
+		if !slices.Contains(data.FrameRates, data.FPS) {
+			data.FrameRates = append(slices.Clone(data.FrameRates), data.FPS)
+			slices.Sort(data.FrameRates)
+		}
+		if err := liveViewTmpl.ExecuteTemplate(w, "live-view", data); err != nil {
+			log.Printf("Template execution error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}))
+	mux.Handle("GET /live/{serial}/stream", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleLiveStream(w, r, db, cm)
+	}))
+}
+
+// handleLiveStream proxies the MJPEG stream of the device with the stored
+// credentials, so they never reach the browser. The resolution, frame rate,
+// and compression default to the live view settings and may be overridden
+// with the query parameters resolution, fps, and compression. The stream
+// ends when the browser disconnects or after the maximum duration, and
+// frees its slot for other viewers either way.
+func handleLiveStream(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager) {
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+
+	config := cm.Get().LiveView
+	options, err := videoOptions(r, config.Resolution, config.FPS, config.Compression)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	release, err := liveStreams.acquire(device.SerialNumber, config.MaxStreams, config.MaxStreamsPerDevice)
+	switch {
+	case errors.Is(err, errDeviceStreamLimit):
+		http.Error(w, fmt.Sprintf("%s is already streaming to %d viewers, the most it streams to at once",
+			device.SerialNumber, config.MaxStreamsPerDevice), http.StatusTooManyRequests)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Neba is already showing %d live streams, the most it shows at once",
+			config.MaxStreams), http.StatusTooManyRequests)
+		return
+	}
+	defer release()
+
+	maxDuration := config.MaxDuration.Duration()
+	ctx, cancel := context.WithTimeout(r.Context(), maxDuration)
+	defer cancel()
+	resp, err := network.OpenMJPEG(ctx, deviceClient(*device), options)
+	if err != nil {
+		log.Printf("Failed to open live stream of %s: %v", device.SerialNumber, err)
+		http.Error(w, "Live stream not available", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	// The stream outlives the write timeout of the server.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(maxDuration + time.Minute)); err != nil {
+		log.Printf("Failed to extend the write deadline of a live stream: %v", err)
+	}
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	user, _ := UserFromContext(r.Context())
+	started := time.Now()
+	log.Printf("Live stream of %s started for %s", device.SerialNumber, user.Username)
+
+	buf := make([]byte, streamBufferSize)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				break
+			}
+			rc.Flush()
+		}
+		if err != nil {
+			break
+		}
+	}
+	log.Printf("Live stream of %s for %s ended after %s", device.SerialNumber, user.Username, time.Since(started).Round(time.Second))
+}
+
+// videoOptions reads the video options of the request, falling back to the
+// given defaults.
+func videoOptions(r *http.Request, resolution string, fps, compression int) (network.VideoOptions, error) {
+	options := network.VideoOptions{Resolution: resolution, FPS: fps, Compression: compression}
+	query := r.URL.Query()
+	if value := query.Get("resolution"); value != "" {
+		if err := configs.ValidateResolution(value); err != nil {
+			return options, err
+		}
+		options.Resolution = value
+	}
+	if value := query.Get("fps"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 60 {
+			return options, fmt.Errorf("the frame rate must be between 1 and 60")
+		}
+		options.FPS = n
+	}
+	if value := query.Get("compression"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > 100 {
+			return options, fmt.Errorf("the compression must be between 0 and 100")
+		}
+		options.Compression = n
+	}
+	return options, nil
+}
+
+// acquire takes a slot for a stream of the device, if neither limit has been
+// reached.
+//
+// Parameters:
+//   - serial:       The serial number of the device.
+//   - maxTotal:     How many streams may run at once.
+//   - maxPerDevice: How many streams of a single device may run at once.
+//
+// Returns:
+//   - func(): Frees the slot; calling it more than once has no effect.
+//   - error:  errStreamLimit or errDeviceStreamLimit if a limit has been reached.
+func (l *streamLimiter) acquire(serial string, maxTotal, maxPerDevice int) (func(), error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.total >= maxTotal {
+		return nil, errStreamLimit
+	}
+	if l.perDevice[serial] >= maxPerDevice {
+		return nil, errDeviceStreamLimit
+	}
+	l.total++
+	l.perDevice[serial]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			l.total--
+			if l.perDevice[serial]--; l.perDevice[serial] == 0 {
+				delete(l.perDevice, serial)
+			}
+		})
+	}, nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStreamLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		running []string // Serial numbers of the streams already running
+		serial  string
+		wantErr error
+	}{
+		{name: "free", serial: "A"},
+		{name: "other device streaming", running: []string{"B", "B"}, serial: "A"},
+		{name: "device limit", running: []string{"A", "A"}, serial: "A", wantErr: errDeviceStreamLimit},
+		{name: "total limit", running: []string{"A", "B", "C"}, serial: "D", wantErr: errStreamLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &streamLimiter{perDevice: map[string]int{}}
+			for _, serial := range tt.running {
+				if _, err := limiter.acquire(serial, 3, 2); err != nil {
+					t.Fatal(err)
+				}
+			}
+			release, err := limiter.acquire(tt.serial, 3, 2)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("acquire() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			release()
+			release()
+			if limiter.total != len(tt.running) || limiter.perDevice[tt.serial] != 0 {
+				t.Errorf("after release, %d streams and %d of %s are counted", limiter.total, limiter.perDevice[tt.serial], tt.serial)
+			}
+		})
+	}
+}
@@ -1,31 +1,187 @@
 package handlers
 
 import (
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/timesync"
 	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
 )
 
 var (
 	manageDevicesTmpl *template.Template
 )
 
-func RegisterManageDevicesRoute(fs http.Handler, mux *http.ServeMux) {
+// ManagePageData contains the data for the /manage page
+type ManagePageData struct {
+	Devices     []models.AxisDevice
+	DeviceCount int
+	ClockChecks map[string]timesync.ClockCheck // Outcome of the last clock check by serial number
+	Permissions auth.Permissions
+	Error       string
+}
+
+// ActionResult contains the outcome of a device action, shown above the
+// device table on the /manage page
+type ActionResult struct {
+	Success bool
+	Message string
+	Removed string // Serial number of a device whose row should be removed
+}
+
+func RegisterManageDevicesRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB) {
 	var err error
-	manageDevicesTmpl, err = template.ParseFS(ui.FS, "manage.html")
+	manageDevicesTmpl, err = template.ParseFS(ui.FS, "manage.html", "snapshot.html")
 	if err != nil {
 		log.Fatalf("Failed to parse templates: %v", err)
 	}
 
-	mux.HandleFunc("/manage", handleManageDevices)
+	mux.Handle("GET /manage", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleManageDevices(w, r, db)
+	}))
+	mux.Handle("POST /manage/{serial}/restart", AuthorizeFunc(auth.PermRestartDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleDeviceAction(w, r, db, "Restart", network.Restart)
+	}))
+	mux.Handle("POST /manage/{serial}/factory-default", AuthorizeFunc(auth.PermFactoryReset, func(w http.ResponseWriter, r *http.Request) {
+		hard := r.FormValue("mode") == "hard"
+		name := "Soft factory default"
+		if hard {
+			name = "Hard factory default"
+		}
+		handleDeviceAction(w, r, db, name, func(c *network.Client) error {
+			return network.FactoryDefault(c, hard)
+		})
+	}))
+	mux.Handle("GET /manage/{serial}/server-report", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleServerReport(w, r, db)
+	}))
+	mux.Handle("DELETE /manage/{serial}", AuthorizeFunc(auth.PermManageDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveDevice(w, r, db)
+	}))
 }
 
-func handleManageDevices(w http.ResponseWriter, r *http.Request) {
-	if err := manageDevicesTmpl.ExecuteTemplate(w, "manage.html", nil); err != nil {
-		log.Fatalf("Template execution error: %v", err)
+func handleManageDevices(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	data := ManagePageData{Permissions: permissionsFromContext(r.Context())}
+
+	devices, err := database.List(db, database.DevicesBucket)
+	if err != nil {
+		data.Error = err.Error()
+	}
+
+	user, _ := UserFromContext(r.Context())
+	for _, device := range devices {
+		if auth.InScope(user, device) {
+			data.Devices = append(data.Devices, device)
+		}
+	}
+	data.DeviceCount = len(data.Devices)
+
+	if data.ClockChecks, err = timesync.StoredChecks(db); err != nil && data.Error == "" {
+		data.Error = err.Error()
+	}
+
+	if err := manageDevicesTmpl.ExecuteTemplate(w, "manage.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 }
+
+// handleDeviceAction runs the given action against the device named in the
+// request path and renders the outcome.
+func handleDeviceAction(w http.ResponseWriter, r *http.Request, db *bbolt.DB, name string, action func(*network.Client) error) {
+	auditAction(r, name)
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+
+	result := ActionResult{Success: true, Message: fmt.Sprintf("%s of %s requested.", name, device.SerialNumber)}
+	if err := action(deviceClient(*device)); err != nil {
+		result = ActionResult{Message: fmt.Sprintf("%s of %s failed: %v", name, device.SerialNumber, err)}
+	}
+
+	renderActionResult(w, r, result)
+}
+
+func handleServerReport(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+
+	report, err := network.ServerReport(deviceClient(*device))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="serverreport_%s.zip"`, device.SerialNumber))
+	w.Write(report)
+}
+
+func handleRemoveDevice(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Remove device")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+
+	if err := database.Delete(db, database.DevicesBucket, device.SerialNumber); err != nil {
+		renderActionResult(w, r, ActionResult{Message: fmt.Sprintf("Removing %s failed: %v", device.SerialNumber, err)})
+		return
+	}
+
+	renderActionResult(w, r, ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Removed %s.", device.SerialNumber),
+		Removed: device.SerialNumber,
+	})
+}
+
+// lookupDevice loads the device named by the {serial} path value. It writes a
+// 404 response and returns false if the device does not exist or lies outside
+// of the signed-in user's scope, so that the two cases are indistinguishable.
+func lookupDevice(w http.ResponseWriter, r *http.Request, db *bbolt.DB) (*models.AxisDevice, bool) {
+	serial := r.PathValue("serial")
+
+	device, err := database.View(db, database.DevicesBucket, serial)
+	if err != nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	user, _ := UserFromContext(r.Context())
+	if !auth.InScope(user, *device) {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return device, true
+}
+
+// deviceClient returns a VAPIX client for the given device.
+func deviceClient(device models.AxisDevice) *network.Client {
+	return network.NewClient(device.IPAddress, device.Username, device.Password)
+}
+
+// renderActionResult renders the outcome of a device action and records
+// failures in the audit log.
+func renderActionResult(w http.ResponseWriter, r *http.Request, result ActionResult) {
+	if !result.Success {
+		auditFailure(r, result.Message)
+	}
+	if err := manageDevicesTmpl.ExecuteTemplate(w, "action-result", result); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Network settings constants
+	verifyTimeout  = 2 * time.Minute // How long a device may take to come back after re-addressing
+	verifyInterval = 5 * time.Second
+	probeTimeout   = 3 * time.Second
+)
+
+var (
+	networkTmpl *template.Template
+
+	// hostnameLabel matches a single label of a hostname.
+	hostnameLabel = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+)
+
+// NetworkPageData contains the data for the /manage/{serial}/network page
+type NetworkPageData struct {
+	Device models.AxisDevice
+	Info   *network.NetworkInfo
+	Iface  *network.NetworkDevice
+	Form   NetworkForm
+	Job    *models.Job
+	Result *ActionResult
+}
+
+// NetworkForm holds the network settings as entered in the form
+type NetworkForm struct {
+	Iface         string
+	IPv4Mode      string
+	Address       string // CIDR notation, e.g. 192.168.0.90/24
+	Gateway       string
+	LinkLocal     string
+	HostnameDHCP  bool
+	Hostname      string
+	DNSDHCP       bool
+	NameServers   string
+	SearchDomains string
+	DomainName    string
+}
+
+func RegisterNetworkRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, cm *configs.CManager, jm *jobs.Manager) {
+	var err error
+	networkTmpl, err = template.New("network.html").Funcs(template.FuncMap{
+		"addresses": formatAddresses,
+		"join":      strings.Join,
+	}).ParseFS(ui.FS, "network.html", "jobs.html", "snapshot.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /manage/{serial}/network", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleNetworkSettings(w, r, db)
+	}))
+	mux.Handle("POST /manage/{serial}/network", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateNetworkSettings(w, r, db, cm, jm)
+	}))
+}
+
+func handleNetworkSettings(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+
+	data := NetworkPageData{Device: *device}
+	if err := loadNetworkInfo(&data); err != nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	} else {
+		data.Form = networkFormFrom(data.Info, data.Iface)
+	}
+	renderNetwork(w, r, data)
+}
+
+// handleUpdateNetworkSettings applies the submitted settings. The hostname
+// and DNS settings are applied first, since they do not affect connectivity.
+// The IPv4 settings are applied last; if they change, a job then looks for
+// the device at its new address until it answers with the same serial
+// number, and only then updates the inventory.
+func handleUpdateNetworkSettings(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager, jm *jobs.Manager) {
+	auditAction(r, "Change network settings")
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+
+	data := NetworkPageData{Device: *device, Form: parseNetworkForm(r)}
+	fail := func(message string) {
+		data.Result = &ActionResult{Message: message}
+		renderNetwork(w, r, data)
+	}
+
+	if err := loadNetworkInfo(&data); err != nil {
+		fail(err.Error())
+		return
+	}
+	settings, err := validateNetworkForm(data.Form)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	// The settings are applied to the posted interface, so they must be
+	// compared to that interface's, not those of the one the page picked.
+	iface := findNetworkInterface(data.Info, data.Form.Iface)
+	if iface == nil {
+		fail(fmt.Sprintf("%s has no network interface named %q.", device.SerialNumber, data.Form.Iface))
+		return
+	}
+	data.Iface = iface
+
+	client := deviceClient(*device)
+	current := networkFormFrom(data.Info, data.Iface)
+	var applied []string
+
+	if data.Form.HostnameDHCP != current.HostnameDHCP || data.Form.Hostname != current.Hostname {
+		if err := network.SetHostname(client, data.Form.HostnameDHCP, data.Form.Hostname); err != nil {
+			fail(err.Error())
+			return
+		}
+		applied = append(applied, "hostname")
+	}
+	if data.Form.DNSDHCP != current.DNSDHCP || data.Form.NameServers != current.NameServers ||
+		data.Form.SearchDomains != current.SearchDomains || data.Form.DomainName != current.DomainName {
+		if err := network.SetResolver(client, data.Form.DNSDHCP, parseList(data.Form.NameServers),
+			parseList(data.Form.SearchDomains), data.Form.DomainName); err != nil {
+			fail(fmt.Sprintf("Applied %s, but %v", describeApplied(applied), err))
+			return
+		}
+		applied = append(applied, "DNS settings")
+	}
+
+	ipv4Changed := data.Form.IPv4Mode != current.IPv4Mode || data.Form.LinkLocal != current.LinkLocal ||
+		(data.Form.IPv4Mode == network.ConfigStatic && (data.Form.Address != current.Address || data.Form.Gateway != current.Gateway))
+	if !ipv4Changed {
+		data.Result = &ActionResult{Success: true, Message: fmt.Sprintf("Applied %s to %s.", describeApplied(applied), device.SerialNumber)}
+		if len(applied) == 0 {
+			data.Result.Message = "Nothing to change."
+		}
+		renderNetwork(w, r, data)
+		return
+	}
+
+	// The new address must not belong to anything else, or the device would
+	// become unreachable and the inventory would point at the wrong device.
+	host := network.Host(device.IPAddress)
+	newHost := host
+	if settings.Mode == network.ConfigStatic {
+		newHost = settings.Address.Address
+	}
+	if newHost != host {
+		if err := checkAddressFree(db, *device, newHost); err != nil {
+			fail(err.Error())
+			return
+		}
+	}
+
+	// The device may drop the connection as soon as the address changes,
+	// so a failed request is only an error if the device is still there.
+	if err := network.SetIPv4(client, data.Form.Iface, settings); err != nil && !isConnectionError(err) {
+		fail(fmt.Sprintf("Applied %s, but %v", describeApplied(applied), err))
+		return
+	}
+
+	var localAddr string
+	if cm != nil {
+		localAddr = cm.Get().Discovery.LocalAddress
+	}
+	dhcp := settings.Mode == network.ConfigDHCP
+	user, _ := UserFromContext(r.Context())
+	job, err := jobs.Start(db, jm, "Verify network settings of "+device.SerialNumber, user.Username, []string{device.SerialNumber},
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			return "", verifyNetworkSettings(ctx, db, report, *device, host, newHost, dhcp, localAddr)
+		})
+	if err != nil {
+		fail(fmt.Sprintf("The IPv4 settings were sent, but starting their verification failed: %v", err))
+		return
+	}
+	data.Job = job
+	data.Result = &ActionResult{Success: true, Message: fmt.Sprintf("Applied the network settings. Started: %s.", job.Kind)}
+	renderNetwork(w, r, data)
+}
+
+// verifyNetworkSettings looks for the device after its IPv4 settings have
+// changed, and updates its address in the inventory once it answers.
+func verifyNetworkSettings(ctx context.Context, db *bbolt.DB, report *jobs.Reporter, device models.AxisDevice,
+	host, newHost string, dhcp bool, localAddr string) error {
+	found, err := waitForDevice(ctx, device, host, newHost, dhcp, localAddr)
+	if err != nil {
+		report.Failed(device.SerialNumber, "The IPv4 settings were sent, but the device could not be verified: %v. "+
+			"The inventory still lists %s; check the device and update its address if needed.", err, device.IPAddress)
+		return nil
+	}
+	if found == host {
+		report.Succeeded(device.SerialNumber, "Answers at %s.", found)
+		return nil
+	}
+
+	updated := replaceHost(device.IPAddress, found)
+	if err := database.Devices(db).Modify(device.SerialNumber, func(d *models.AxisDevice) error {
+		d.IPAddress = updated
+		return nil
+	}); err != nil {
+		report.Failed(device.SerialNumber, "Answers at %s, but updating the inventory failed: %v", found, err)
+		return nil
+	}
+	report.Succeeded(device.SerialNumber, "Answers at %s. The inventory now lists %s instead of %s.", found, updated, device.IPAddress)
+	return nil
+}
+
+// loadNetworkInfo reads the network configuration of the device and picks
+// the interface that carries the device's address, or the first one.
+func loadNetworkInfo(data *NetworkPageData) error {
+	info, err := network.GetNetworkInfo(deviceClient(data.Device))
+	if err != nil {
+		return fmt.Errorf("read the network settings of %s: %v", data.Device.SerialNumber, err)
+	}
+	if len(info.Devices) == 0 {
+		return fmt.Errorf("%s reports no network interfaces", data.Device.SerialNumber)
+	}
+
+	data.Info = info
+	data.Iface = &info.Devices[0]
+	host := network.Host(data.Device.IPAddress)
+	for i, iface := range info.Devices {
+		if slices.ContainsFunc(iface.IPv4.Addresses, func(a network.IPv4Address) bool { return a.Address == host }) {
+			data.Iface = &info.Devices[i]
+			break
+		}
+	}
+	return nil
+}
+
+// findNetworkInterface returns the interface of the device with the given
+// name, or nil if the device has no such interface.
+func findNetworkInterface(info *network.NetworkInfo, name string) *network.NetworkDevice {
+	for i := range info.Devices {
+		if info.Devices[i].Name == name {
+			return &info.Devices[i]
+		}
+	}
+	return nil
+}
+
+// networkFormFrom fills the form with the configured settings of the device.
+func networkFormFrom(info *network.NetworkInfo, iface *network.NetworkDevice) NetworkForm {
+	form := NetworkForm{
+		Iface:         iface.Name,
+		IPv4Mode:      iface.IPv4.ConfigurationMode,
+		Gateway:       iface.IPv4.StaticDefaultRouter,
+		LinkLocal:     iface.IPv4.LinkLocalMode,
+		HostnameDHCP:  info.System.Hostname.UseDHCPHostname,
+		Hostname:      info.System.Hostname.StaticHostname,
+		DNSDHCP:       info.System.Resolver.UseDHCPResolverInfo,
+		NameServers:   strings.Join(info.System.Resolver.StaticNameServers, ", "),
+		SearchDomains: strings.Join(info.System.Resolver.StaticSearchDomains, ", "),
+		DomainName:    info.System.Resolver.StaticDomainName,
+	}
+	if len(iface.IPv4.StaticAddressConfigurations) > 0 {
+		form.Address = iface.IPv4.StaticAddressConfigurations[0].String()
+	}
+	return form
+}
+
+func parseNetworkForm(r *http.Request) NetworkForm {
+	return NetworkForm{
+		Iface:         r.FormValue("iface"),
+		IPv4Mode:      r.FormValue("ipv4_mode"),
+		Address:       strings.TrimSpace(r.FormValue("address")),
+		Gateway:       strings.TrimSpace(r.FormValue("gateway")),
+		LinkLocal:     r.FormValue("link_local"),
+		HostnameDHCP:  r.FormValue("hostname_mode") == network.ConfigDHCP,
+		Hostname:      strings.TrimSpace(r.FormValue("hostname")),
+		DNSDHCP:       r.FormValue("dns_mode") == network.ConfigDHCP,
+		NameServers:   strings.Join(parseList(r.FormValue("name_servers")), ", "),
+		SearchDomains: strings.Join(parseList(r.FormValue("search_domains")), ", "),
+		DomainName:    strings.TrimSpace(r.FormValue("domain_name")),
+	}
+}
+
+// validateNetworkForm checks the form and returns the IPv4 settings to apply.
+// Every problem is reported at once, as sentences.
+func validateNetworkForm(form NetworkForm) (network.IPv4Settings, error) {
+	settings := network.IPv4Settings{Mode: form.IPv4Mode, LinkLocal: form.LinkLocal}
+	var problems []string
+
+	if form.Iface == "" {
+		problems = append(problems, "No network interface selected.")
+	}
+	if form.LinkLocal != network.LinkLocalOn && form.LinkLocal != network.LinkLocalOff {
+		problems = append(problems, "Link-local addressing must be on or off.")
+	}
+
+	switch form.IPv4Mode {
+	case network.ConfigDHCP:
+	case network.ConfigStatic:
+		ip, subnet, err := net.ParseCIDR(form.Address)
+		if err != nil || ip.To4() == nil {
+			problems = append(problems, fmt.Sprintf("IP address %q must be an IPv4 address with a prefix length, e.g. 192.168.0.90/24.", form.Address))
+			break
+		}
+		prefix, _ := subnet.Mask.Size()
+		if prefix == 0 || prefix > 30 || ip.Equal(subnet.IP) || ip.IsLoopback() || ip.IsMulticast() {
+			problems = append(problems, fmt.Sprintf("%s is not a usable host address.", form.Address))
+		}
+		settings.Address = network.IPv4Address{Address: ip.String(), PrefixLength: prefix}
+		if form.Gateway != "" {
+			gateway := net.ParseIP(form.Gateway)
+			switch {
+			case gateway == nil || gateway.To4() == nil:
+				problems = append(problems, fmt.Sprintf("Gateway %q must be an IPv4 address.", form.Gateway))
+			case !subnet.Contains(gateway):
+				problems = append(problems, fmt.Sprintf("Gateway %s is not in the subnet %s.", form.Gateway, subnet))
+			case gateway.Equal(ip):
+				problems = append(problems, "Gateway must not be the device's own address.")
+			}
+		}
+		settings.DefaultRouter = form.Gateway
+	default:
+		problems = append(problems, "IPv4 configuration must be DHCP or static.")
+	}
+
+	if !form.HostnameDHCP || form.Hostname != "" {
+		if len(form.Hostname) > 253 || form.Hostname == "" {
+			problems = append(problems, "Hostname must be between 1 and 253 characters.")
+		} else {
+			for _, label := range strings.Split(form.Hostname, ".") {
+				if !hostnameLabel.MatchString(label) {
+					problems = append(problems, fmt.Sprintf("Hostname %q may only contain letters, digits, hyphens, and dots.", form.Hostname))
+					break
+				}
+			}
+		}
+	}
+	for _, server := range parseList(form.NameServers) {
+		if net.ParseIP(server) == nil {
+			problems = append(problems, fmt.Sprintf("DNS server %q must be an IP address.", server))
+		}
+	}
+
+	if len(problems) > 0 {
+		return settings, errors.New(strings.Join(problems, " "))
+	}
+	return settings, nil
+}
+
+// checkAddressFree returns an error if another managed device uses the
+// address, or if something already answers at it.
+func checkAddressFree(db *bbolt.DB, device models.AxisDevice, host string) error {
+	devices, err := database.Devices(db).List()
+	if err != nil {
+		return err
+	}
+	for _, other := range devices {
+		if other.SerialNumber != device.SerialNumber && network.Host(other.IPAddress) == host {
+			return fmt.Errorf("%s is already used by %s", host, other.SerialNumber)
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, "80"), probeTimeout)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is already in use on the network; choose a free address", host)
+	}
+	return nil
+}
+
+// waitForDevice looks for the device until it answers with its own serial
+// number, the verification times out, or the context is cancelled, and
+// returns the address it answered at. With DHCP, the new address is unknown,
+// so the device is looked for at its old address and by discovery.
+func waitForDevice(ctx context.Context, device models.AxisDevice, oldHost, newHost string, dhcp bool, localAddr string) (string, error) {
+	deadline := time.Now().Add(verifyTimeout)
+	lastErr := errors.New("no answer")
+
+	// Give the device time to apply the settings, so that it is not found at
+	// its old address before a DHCP lease changes it.
+	if err := sleepContext(ctx, verifyInterval); err != nil {
+		return "", err
+	}
+	for {
+		candidates := []string{newHost}
+		if dhcp {
+			candidates = append(candidates, discoverAddress(device.SerialNumber, localAddr)...)
+		}
+		for _, host := range candidates {
+			candidate := device
+			candidate.IPAddress = replaceHost(device.IPAddress, host)
+			client := deviceClient(candidate)
+			client.HTTP.Timeout = probeTimeout
+
+			info, err := network.GetBasicDeviceInfo(client)
+			switch {
+			case err != nil:
+				lastErr = err
+			case !strings.EqualFold(info.SerialNumber, device.SerialNumber):
+				lastErr = fmt.Errorf("%s answers with serial number %s", host, info.SerialNumber)
+			default:
+				return host, nil
+			}
+		}
+
+		if time.Now().Add(verifyInterval).After(deadline) {
+			return "", fmt.Errorf("not found within %s, last error: %v", verifyTimeout, lastErr)
+		}
+		if err := sleepContext(ctx, verifyInterval); err != nil {
+			return "", err
+		}
+	}
+}
+
+// sleepContext waits for the given duration, or returns the context's error
+// if it is cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// discoverAddress returns the addresses at which devices with the given
+// serial number answer to discovery.
+func discoverAddress(serial, localAddr string) []string {
+	found, err := network.DiscoverSSDP(network.SSDPMaxWaitTimeSec, localAddr)
+	if err != nil {
+		return nil
+	}
+	var hosts []string
+	for _, device := range found {
+		if !strings.EqualFold(device["SerialNumber"], serial) {
+			continue
+		}
+		if u, err := url.Parse(device["PresentationURL"]); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	return hosts
+}
+
+// replaceHost replaces the host of a device address, keeping its scheme and
+// port.
+func replaceHost(address, host string) string {
+	if strings.Contains(address, "://") {
+		if u, err := url.Parse(address); err == nil {
+			if port := u.Port(); port != "" {
+				u.Host = net.JoinHostPort(host, port)
+			} else {
+				u.Host = host
+			}
+			return u.String()
+		}
+	}
+	if _, port, err := net.SplitHostPort(address); err == nil {
+		return net.JoinHostPort(host, port)
+	}
+	return host
+}
+
+// isConnectionError reports whether the error means that no answer was
+// received, as opposed to the device rejecting the request.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	var opErr *net.OpError
+	return errors.As(err, &netErr) || errors.As(err, &opErr) || strings.Contains(err.Error(), "EOF") ||
+		strings.Contains(err.Error(), "connection reset")
+}
+
+func describeApplied(applied []string) string {
+	if len(applied) == 0 {
+		return "nothing"
+	}
+	return strings.Join(applied, " and ")
+}
+
+func renderNetwork(w http.ResponseWriter, r *http.Request, data NetworkPageData) {
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+	if err := networkTmpl.ExecuteTemplate(w, "network.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// formatAddresses lists addresses in CIDR notation.
+func formatAddresses(addresses []network.IPv4Address) string {
+	parts := make([]string, len(addresses))
+	for i, a := range addresses {
+		parts[i] = a.String()
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/furkansuleymana/neba/network"
+)
+
+func TestFindNetworkInterface(t *testing.T) {
+	info := &network.NetworkInfo{Devices: []network.NetworkDevice{{Name: "eth0"}, {Name: "eth1"}}}
+
+	tests := []struct {
+		name  string
+		iface string
+		want  string // Name of the found interface; empty if none
+	}{
+		{name: "first", iface: "eth0", want: "eth0"},
+		{name: "other than the first", iface: "eth1", want: "eth1"},
+		{name: "unknown", iface: "wlan0"},
+		{name: "empty", iface: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findNetworkInterface(info, tt.iface)
+			switch {
+			case got == nil && tt.want != "":
+				t.Errorf("findNetworkInterface(%q) = nil, want %s", tt.iface, tt.want)
+			case got != nil && got.Name != tt.want:
+				t.Errorf("findNetworkInterface(%q) = %s, want %q", tt.iface, got.Name, tt.want)
+			case got != nil && got != &info.Devices[0] && got != &info.Devices[1]:
+				t.Errorf("findNetworkInterface(%q) returned a copy", tt.iface)
+			}
+		})
+	}
+}
@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Onboarding constants
+	maxOnboardingSize    = 5 << 20 // 5 MiB
+	maxOnboardingRows    = 1000
+	onboardingTTL        = 30 * time.Minute
+	onboardingWorkers    = 16
+	onboardingTimeout    = 10 * time.Second // Per device, shorter than usual so that the preview stays quick
+	defaultDeviceAccount = "root"
+)
+
+var (
+	onboardTmpl *template.Template
+
+	// onboardingBatches holds uploaded spreadsheets between the steps of the
+	// wizard, so that passwords are not sent back and forth with every step.
+	onboardingBatches = struct {
+		sync.Mutex
+		m map[string]*onboardingBatch
+	}{m: make(map[string]*onboardingBatch)}
+)
+
+// OnboardField is a device field that a spreadsheet column can be mapped to
+type OnboardField struct {
+	Name     string
+	Label    string
+	Required bool
+	aliases  []string // Lower-case header names that map to the field
+}
+
+// onboardFields lists the fields that spreadsheet columns can be mapped to.
+// Model and firmware are not among them, since they are read from the device.
+var onboardFields = []OnboardField{
+	{"ip_address", "IP address", true, []string{"ip", "ip address", "ipaddress", "ip_address", "address", "host", "hostname"}},
+	{"username", "Username", false, []string{"username", "user", "user name", "login"}},
+	{"password", "Password", false, []string{"password", "pass", "pwd", "root password"}},
+	{"credential_profile", "Credential profile", false, []string{"credential_profile", "credential profile", "credentials", "profile"}},
+	{"serial_number", "Serial number", false, []string{"serial", "serial number", "serialnumber", "serial_number", "sn", "mac", "mac address"}},
+	{"site", "Site", false, []string{"site", "location", "building"}},
+	{"tags", "Tags", false, []string{"tags", "tag", "labels", "group"}},
+	{"warranty_expires", "Warranty expires", false, []string{"warranty", "warranty expires", "warranty_expires", "warranty end", "warranty until"}},
+}
+
+// onboardingBatch is an uploaded spreadsheet going through the wizard. The
+// mapping and results change between the steps and are guarded by mu, which
+// lookupBatch locks; Expires is guarded by the onboardingBatches mutex.
+type onboardingBatch struct {
+	ID       string
+	Owner    string
+	Filename string
+	Headers  []string
+	Rows     [][]string
+	Mapping  map[string]int // Field name to column index
+	Results  []OnboardRow
+	Expires  time.Time
+
+	mu sync.Mutex
+}
+
+// OnboardRow is the validation result of one spreadsheet row
+type OnboardRow struct {
+	Line   int // Line in the file, counting the header
+	Device models.AxisDevice
+	Exists bool // Whether the device is already managed and will be updated
+	Error  string
+}
+
+// OnboardPageData contains the data for the /onboard pages
+type OnboardPageData struct {
+	Batch   *onboardingBatch
+	Fields  []OnboardField
+	Samples [][]string
+	Valid   int
+	Result  *ActionResult
+}
+
+func RegisterOnboardRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB) {
+	var err error
+	onboardTmpl, err = template.New("onboard.html").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).ParseFS(ui.FS, "onboard.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /onboard", AuthorizeFunc(auth.PermManageDevices, func(w http.ResponseWriter, r *http.Request) {
+		renderOnboard(w, r, "onboard-upload", OnboardPageData{})
+	}))
+	mux.Handle("POST /onboard", AuthorizeFunc(auth.PermManageDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleOnboardUpload(w, r)
+	}))
+	mux.Handle("POST /onboard/{id}/validate", AuthorizeFunc(auth.PermManageDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleOnboardValidate(w, r, db)
+	}))
+	mux.Handle("POST /onboard/{id}/save", AuthorizeFunc(auth.PermManageDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleOnboardSave(w, r, db)
+	}))
+}
+
+// handleOnboardUpload reads the uploaded spreadsheet and shows the column
+// mapping step, with columns mapped by their headers where possible.
+func handleOnboardUpload(w http.ResponseWriter, r *http.Request) {
+	auditAction(r, "Upload onboarding spreadsheet")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxOnboardingSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		onboardFailure(w, r, "onboard-upload", OnboardPageData{}, fmt.Sprintf("Reading the uploaded file failed: %v", err))
+		return
+	}
+	defer file.Close()
+
+	headers, rows, err := readSpreadsheet(file)
+	if err != nil {
+		onboardFailure(w, r, "onboard-upload", OnboardPageData{}, fmt.Sprintf("%s: %v", header.Filename, err))
+		return
+	}
+
+	user, _ := UserFromContext(r.Context())
+	batch := &onboardingBatch{
+		ID:       randomID(),
+		Owner:    user.Username,
+		Filename: header.Filename,
+		Headers:  headers,
+		Rows:     rows,
+		Mapping:  guessMapping(headers),
+		Expires:  time.Now().Add(onboardingTTL),
+	}
+	storeBatch(batch)
+
+	renderOnboard(w, r, "onboard-map", mappingPageData(batch))
+}
+
+// handleOnboardValidate applies the column mapping and connects to every
+// device to read its basic device information, then shows the preview.
+func handleOnboardValidate(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Validate onboarding spreadsheet")
+	batch, ok := lookupBatch(w, r)
+	if !ok {
+		return
+	}
+	defer batch.mu.Unlock()
+
+	mapping := make(map[string]int)
+	for _, field := range onboardFields {
+		column, err := strconv.Atoi(r.FormValue("map_" + field.Name))
+		if err != nil || column < 0 || column >= len(batch.Headers) {
+			continue
+		}
+		mapping[field.Name] = column
+	}
+	batch.Mapping = mapping
+	if _, ok := mapping["ip_address"]; !ok {
+		onboardFailure(w, r, "onboard-map", mappingPageData(batch), "Map a column to the IP address.")
+		return
+	}
+
+	results, err := validateBatch(db, batch)
+	if err != nil {
+		onboardFailure(w, r, "onboard-map", mappingPageData(batch), err.Error())
+		return
+	}
+	batch.Results = results
+
+	renderOnboard(w, r, "onboard-preview", previewPageData(batch))
+}
+
+// handleOnboardSave stores the selected devices that passed validation.
+// Devices that are already managed keep the fields that were not mapped to a
+// column, such as their site and tags.
+func handleOnboardSave(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Onboard devices")
+	batch, ok := lookupBatch(w, r)
+	if !ok {
+		return
+	}
+	defer batch.mu.Unlock()
+	if batch.Results == nil {
+		onboardFailure(w, r, "onboard-map", mappingPageData(batch), "Validate the devices before saving them.")
+		return
+	}
+
+	r.ParseForm()
+	selected := r.Form["line"]
+	var saved []string
+	devices := database.Devices(db)
+	for _, row := range batch.Results {
+		if row.Error != "" || !slices.Contains(selected, strconv.Itoa(row.Line)) {
+			continue
+		}
+		device := row.Device
+		existing, err := devices.Get(device.SerialNumber)
+		if err == nil {
+			device = batch.merge(*existing, device)
+		}
+		if err == nil || errors.Is(err, database.ErrNotFound) {
+			err = devices.Put(device)
+		}
+		if err != nil {
+			auditTargets(r, saved...)
+			onboardFailure(w, r, "onboard-preview", previewPageData(batch),
+				fmt.Sprintf("Saving %s failed after saving %d devices: %v", row.Device.SerialNumber, len(saved), err))
+			return
+		}
+		saved = append(saved, row.Device.SerialNumber)
+	}
+	auditTargets(r, saved...)
+
+	deleteBatch(batch.ID)
+	renderOnboard(w, r, "onboard-upload", OnboardPageData{
+		Result: &ActionResult{Success: true, Message: fmt.Sprintf("Saved %d devices from %s.", len(saved), batch.Filename)},
+	})
+}
+
+// merge returns the managed device updated with the device built from a
+// row. Fields that no column was mapped to, or whose cell was empty, keep
+// their stored values, and so does the credential profile as long as the
+// row's credentials are the ones already stored.
+func (b *onboardingBatch) merge(existing, device models.AxisDevice) models.AxisDevice {
+	mapped := func(field string) bool {
+		_, ok := b.Mapping[field]
+		return ok
+	}
+
+	existing.IPAddress = device.IPAddress
+	existing.Model = device.Model
+	existing.OSVersion = device.OSVersion
+	if mapped("site") && device.Site != "" {
+		existing.Site = device.Site
+	}
+	if mapped("tags") && len(device.Tags) > 0 {
+		existing.Tags = device.Tags
+	}
+	if mapped("warranty_expires") && device.WarrantyExpires != "" {
+		existing.WarrantyExpires = device.WarrantyExpires
+	}
+	if device.CredentialProfile != "" || device.Username != existing.Username || device.Password != existing.Password {
+		existing.CredentialProfile = device.CredentialProfile
+	}
+	existing.Username = device.Username
+	existing.Password = device.Password
+	return existing
+}
+
+// validateBatch builds a device from every row and checks it by connecting
+// to it, a few devices at a time.
+func validateBatch(db *bbolt.DB, batch *onboardingBatch) ([]OnboardRow, error) {
+	managed, err := database.Devices(db).List()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := database.Credentials(db).List()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]OnboardRow, len(batch.Rows))
+	seen := make(map[string]int)
+	for i, row := range batch.Rows {
+		results[i] = buildOnboardRow(batch, i, row, profiles)
+		address := results[i].Device.IPAddress
+		if line, ok := seen[address]; ok && address != "" && results[i].Error == "" {
+			results[i].Error = fmt.Sprintf("Same IP address as line %d.", line)
+		}
+		seen[address] = results[i].Line
+	}
+
+	var wg sync.WaitGroup
+	work := make(chan *OnboardRow)
+	for range onboardingWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range work {
+				checkOnboardRow(row)
+			}
+		}()
+	}
+	for i := range results {
+		if results[i].Error == "" {
+			work <- &results[i]
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	// Devices must be unique by serial number, which is only known now.
+	bySerial := make(map[string]int)
+	for i := range results {
+		row := &results[i]
+		if row.Error != "" {
+			continue
+		}
+		if line, ok := bySerial[row.Device.SerialNumber]; ok {
+			row.Error = fmt.Sprintf("Same device as line %d.", line)
+			continue
+		}
+		bySerial[row.Device.SerialNumber] = row.Line
+		row.Exists = slices.ContainsFunc(managed, func(d models.AxisDevice) bool {
+			return d.SerialNumber == row.Device.SerialNumber
+		})
+	}
+	return results, nil
+}
+
+// buildOnboardRow maps the cells of a row to a device, without connecting to it.
+func buildOnboardRow(batch *onboardingBatch, index int, row []string, profiles []models.Credential) OnboardRow {
+	cell := func(field string) string {
+		if column, ok := batch.Mapping[field]; ok && column < len(row) {
+			return strings.TrimSpace(row[column])
+		}
+		return ""
+	}
+
+	result := OnboardRow{
+		Line: index + 2,
+		Device: models.AxisDevice{
+			IPAddress:       cell("ip_address"),
+			Username:        cell("username"),
+			Password:        cell("password"),
+			SerialNumber:    strings.ToUpper(strings.ReplaceAll(cell("serial_number"), ":", "")),
+			Site:            cell("site"),
+			Tags:            splitTags(cell("tags")),
+			WarrantyExpires: cell("warranty_expires"),
+		},
+	}
+
+	if name := cell("credential_profile"); name != "" {
+		i := slices.IndexFunc(profiles, func(c models.Credential) bool { return c.Name == name })
+		if i < 0 {
+			result.Error = fmt.Sprintf("Unknown credential profile %s.", name)
+			return result
+		}
+		result.Device.CredentialProfile = name
+		if result.Device.Password == "" {
+			result.Device.Username = profiles[i].Username
+			result.Device.Password = profiles[i].Password
+		}
+	}
+	if result.Device.Username == "" {
+		result.Device.Username = defaultDeviceAccount
+	}
+
+	switch {
+	case result.Device.IPAddress == "":
+		result.Error = "No IP address."
+	case result.Device.Password == "":
+		result.Error = "No password or credential profile."
+	case database.ValidateDate(result.Device.WarrantyExpires) != nil:
+		result.Error = fmt.Sprintf("Warranty expiry %s is not a date in YYYY-MM-DD format.", result.Device.WarrantyExpires)
+	}
+	return result
+}
+
+// checkOnboardRow connects to the device of the row and fills in its serial
+// number, model, and firmware version, or records why that failed.
+func checkOnboardRow(row *OnboardRow) {
+	client := deviceClient(row.Device)
+	client.HTTP.Timeout = onboardingTimeout
+
+	info, err := network.GetBasicDeviceInfo(client)
+	if err != nil {
+		row.Error = err.Error()
+		return
+	}
+	if row.Device.SerialNumber != "" && !strings.EqualFold(row.Device.SerialNumber, info.SerialNumber) {
+		row.Error = fmt.Sprintf("Serial number mismatch: the spreadsheet says %s, but the device reports %s.",
+			row.Device.SerialNumber, info.SerialNumber)
+		return
+	}
+	row.Device.SerialNumber = info.SerialNumber
+	row.Device.Model = info.ProdNbr
+	row.Device.OSVersion = info.Version
+}
+
+// readSpreadsheet reads a CSV file as exported by spreadsheet applications:
+// an optional byte order mark, a header row, and commas, semicolons, or tabs
+// as separators, whichever the header row uses.
+func readSpreadsheet(r io.Reader) ([]string, [][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = ','
+	for _, sep := range []rune{';', '\t'} {
+		if bytes.Count(firstLine, []byte(string(sep))) > bytes.Count(firstLine, []byte(string(reader.Comma))) {
+			reader.Comma = sep
+		}
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CSV: %v", err)
+	}
+
+	var rows [][]string
+	for _, record := range records {
+		if slices.ContainsFunc(record, func(cell string) bool { return strings.TrimSpace(cell) != "" }) {
+			rows = append(rows, record)
+		}
+	}
+	if len(rows) < 2 {
+		return nil, nil, fmt.Errorf("expected a header row and at least one device")
+	}
+	if len(rows)-1 > maxOnboardingRows {
+		return nil, nil, fmt.Errorf("too many rows, at most %d devices can be onboarded at once", maxOnboardingRows)
+	}
+	return rows[0], rows[1:], nil
+}
+
+// guessMapping maps columns to fields by their headers.
+func guessMapping(headers []string) map[string]int {
+	mapping := make(map[string]int)
+	for i, header := range headers {
+		header = strings.ToLower(strings.TrimSpace(header))
+		for _, field := range onboardFields {
+			if _, taken := mapping[field.Name]; !taken && slices.Contains(field.aliases, header) {
+				mapping[field.Name] = i
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+func mappingPageData(batch *onboardingBatch) OnboardPageData {
+	samples := batch.Rows
+	if len(samples) > 3 {
+		samples = samples[:3]
+	}
+	return OnboardPageData{Batch: batch, Fields: onboardFields, Samples: samples}
+}
+
+func previewPageData(batch *onboardingBatch) OnboardPageData {
+	data := OnboardPageData{Batch: batch}
+	for _, row := range batch.Results {
+		if row.Error == "" {
+			data.Valid++
+		}
+	}
+	return data
+}
+
+// Column returns the index of the column mapped to the field, or -1.
+func (b *onboardingBatch) Column(field string) int {
+	if column, ok := b.Mapping[field]; ok {
+		return column
+	}
+	return -1
+}
+
+// MappingValues returns the column mapping as form values in JSON, so that
+// the batch can be validated again with the same mapping.
+func (b *onboardingBatch) MappingValues() string {
+	values := make(map[string]string, len(b.Mapping))
+	for field, column := range b.Mapping {
+		values["map_"+field] = strconv.Itoa(column)
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+func storeBatch(batch *onboardingBatch) {
+	onboardingBatches.Lock()
+	defer onboardingBatches.Unlock()
+
+	now := time.Now()
+	for id, b := range onboardingBatches.m {
+		if now.After(b.Expires) {
+			delete(onboardingBatches.m, id)
+		}
+	}
+	onboardingBatches.m[batch.ID] = batch
+}
+
+func deleteBatch(id string) {
+	onboardingBatches.Lock()
+	defer onboardingBatches.Unlock()
+	delete(onboardingBatches.m, id)
+}
+
+// lookupBatch returns the batch named in the request path, locked; the
+// caller must unlock batch.mu. It renders the upload step with an error and
+// returns false if the batch does not exist, has expired, or was uploaded by
+// someone else.
+func lookupBatch(w http.ResponseWriter, r *http.Request) (*onboardingBatch, bool) {
+	user, _ := UserFromContext(r.Context())
+	now := time.Now()
+
+	onboardingBatches.Lock()
+	batch, ok := onboardingBatches.m[r.PathValue("id")]
+	ok = ok && batch.Owner == user.Username && !now.After(batch.Expires)
+	if ok {
+		batch.Expires = now.Add(onboardingTTL)
+	}
+	onboardingBatches.Unlock()
+
+	if !ok {
+		onboardFailure(w, r, "onboard-upload", OnboardPageData{}, "The upload has expired. Please upload the file again.")
+		return nil, false
+	}
+	batch.mu.Lock()
+	return batch, true
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// splitTags splits a spreadsheet cell into tags, which may be separated by
+// commas or semicolons.
+func splitTags(value string) []string {
+	return parseList(strings.ReplaceAll(value, ";", ","))
+}
+
+func onboardFailure(w http.ResponseWriter, r *http.Request, name string, data OnboardPageData, message string) {
+	auditFailure(r, message)
+	data.Result = &ActionResult{Message: message}
+	renderOnboard(w, r, name, data)
+}
+
+func renderOnboard(w http.ResponseWriter, r *http.Request, name string, data OnboardPageData) {
+	if err := onboardTmpl.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
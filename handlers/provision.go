@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Provisioning constants
+	maxProvisionDevices = 256
+	provisionWorkers    = 8
+	provisionTimeout    = 10 * time.Second
+	provisionRetries    = 3 // A new account can take a moment to become usable
+	baselineSetting     = "provision.baseline"
+)
+
+var (
+	provisionTmpl *template.Template
+)
+
+// ProvisionPageData contains the data for the /provision page
+type ProvisionPageData struct {
+	Addresses string
+	Profiles  []models.Credential
+	Profile   string
+	Site      string
+	Tags      string
+	Baseline  string
+	Job       *models.Job
+	Result    *ActionResult
+}
+
+// provisionResult is the outcome of provisioning a single device
+type provisionResult struct {
+	Address string
+	Device  models.AxisDevice
+	Status  string
+	Error   string
+	Warning string
+}
+
+func RegisterProvisionRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, jm *jobs.Manager) {
+	var err error
+	provisionTmpl, err = template.ParseFS(ui.FS, "provision.html", "jobs.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /provision", AuthorizeFunc(auth.PermManageDevices, func(w http.ResponseWriter, r *http.Request) {
+		data := ProvisionPageData{Addresses: strings.Join(r.URL.Query()["address"], "\n")}
+		var stored string
+		if err := database.GetSetting(db, baselineSetting, &stored); err != nil {
+			data.Result = &ActionResult{Message: err.Error()}
+		}
+		// Earlier versions of Neba stored secrets with the baseline.
+		if data.Baseline = storableBaseline(stored); data.Baseline != stored {
+			if err := database.PutSetting(db, baselineSetting, data.Baseline); err != nil {
+				log.Printf("Failed to remove secrets from the provisioning baseline: %v", err)
+			}
+		}
+		renderProvision(w, r, db, data)
+	}))
+	mux.Handle("POST /provision", AuthorizeFunc(auth.PermManageDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleProvision(w, r, db, jm)
+	}))
+}
+
+// handleProvision starts a job that sets the initial password of every listed
+// device from a credential profile, applies the baseline parameters, and
+// saves the devices that were provisioned to the inventory.
+func handleProvision(w http.ResponseWriter, r *http.Request, db *bbolt.DB, jm *jobs.Manager) {
+	auditAction(r, "Provision devices")
+	data := ProvisionPageData{
+		Addresses: r.FormValue("addresses"),
+		Profile:   r.FormValue("profile"),
+		Site:      strings.TrimSpace(r.FormValue("site")),
+		Tags:      r.FormValue("tags"),
+		Baseline:  r.FormValue("baseline"),
+	}
+	fail := func(message string) {
+		data.Result = &ActionResult{Message: message}
+		renderProvision(w, r, db, data)
+	}
+
+	addresses := parseAddresses(data.Addresses)
+	switch {
+	case len(addresses) == 0:
+		fail("Enter at least one device address.")
+		return
+	case len(addresses) > maxProvisionDevices:
+		fail(fmt.Sprintf("At most %d devices can be provisioned at once.", maxProvisionDevices))
+		return
+	}
+	profile, err := database.Credentials(db).Get(data.Profile)
+	if err != nil {
+		fail("Choose a credential profile.")
+		return
+	}
+	baseline, err := parseBaseline(data.Baseline)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	if err := database.PutSetting(db, baselineSetting, storableBaseline(data.Baseline)); err != nil {
+		log.Printf("Failed to store the provisioning baseline: %v", err)
+	}
+
+	auditTargets(r, addresses...)
+
+	tags := parseList(data.Tags)
+	user, _ := UserFromContext(r.Context())
+	job, err := jobs.Start(db, jm, "Provision devices", user.Username, addresses,
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			saved := provisionDevices(ctx, db, report, addresses, *profile, baseline, data.Site, tags)
+			if ctx.Err() != nil {
+				return "Stopped because Neba is shutting down.", nil
+			}
+			return fmt.Sprintf("Provisioned and saved %d of %d devices.", saved, len(addresses)), nil
+		})
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	data.Job = job
+	data.Result = &ActionResult{Success: true, Message: fmt.Sprintf("Started: %s on %d devices.", job.Kind, len(job.Targets))}
+	renderProvision(w, r, db, data)
+}
+
+// provisionDevices provisions the devices at the given addresses in parallel,
+// saves those that were provisioned to the inventory, and reports the outcome
+// of each address.
+//
+// Returns:
+//   - int: The number of devices that were provisioned and saved.
+func provisionDevices(ctx context.Context, db *bbolt.DB, report *jobs.Reporter, addresses []string,
+	profile models.Credential, baseline map[string]string, site string, tags []string) int {
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		saved int
+	)
+	work := make(chan string)
+	for range provisionWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for address := range work {
+				result := provisionDevice(address, profile, baseline)
+				if result.Error != "" {
+					report.Failed(address, "%s", result.Error)
+					continue
+				}
+				result.Device.Site = site
+				result.Device.Tags = tags
+				if err := saveProvisionedDevice(db, result.Device); err != nil {
+					report.Failed(address, "%s %s, but saving failed: %v", result.Status, result.Device.SerialNumber, err)
+					continue
+				}
+				mutex.Lock()
+				saved++
+				mutex.Unlock()
+
+				message := fmt.Sprintf("%s, saved as %s (%s, AXIS OS %s).", result.Status,
+					result.Device.SerialNumber, result.Device.Model, result.Device.OSVersion)
+				if result.Warning != "" {
+					message += " " + result.Warning
+				}
+				report.Succeeded(address, "%s", message)
+			}
+		}()
+	}
+	for _, address := range addresses {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- address
+	}
+	close(work)
+	wg.Wait()
+	return saved
+}
+
+// provisionDevice sets the initial password of a factory-new device and reads
+// its identity. Devices that already have an account are accepted if the
+// profile's credentials work, so that a partly failed run can be repeated.
+func provisionDevice(address string, profile models.Credential, baseline map[string]string) provisionResult {
+	result := provisionResult{Address: address}
+	client := network.NewClient(address, profile.Username, profile.Password)
+	client.HTTP.Timeout = provisionTimeout
+
+	unprovisioned, err := network.IsUnprovisioned(client)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if unprovisioned {
+		if err := network.SetInitialPassword(client, profile.Username, profile.Password); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Status = "Password set"
+	} else {
+		result.Status = "Already provisioned"
+	}
+
+	var info *network.BasicDeviceInfo
+	for attempt := 1; ; attempt++ {
+		info, err = network.GetBasicDeviceInfo(client)
+		if err == nil || attempt == provisionRetries {
+			break
+		}
+		time.Sleep(2 * time.Second)
+	}
+	if err != nil {
+		if !unprovisioned {
+			result.Error = fmt.Sprintf("The device already has a password, and the credentials of %s do not work: %v", profile.Name, err)
+		} else {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	result.Device = models.AxisDevice{
+		SerialNumber:      info.SerialNumber,
+		Model:             info.ProdNbr,
+		IPAddress:         address,
+		OSVersion:         info.Version,
+		Username:          profile.Username,
+		Password:          profile.Password,
+		CredentialProfile: profile.Name,
+	}
+
+	if len(baseline) > 0 {
+		if err := network.SetParams(client, baseline); err != nil {
+			result.Warning = "Baseline not applied: " + database.RedactMessage(err.Error(), baseline)
+		}
+	}
+	return result
+}
+
+// saveProvisionedDevice stores the device. A device that is already managed
+// keeps its site and tags unless new ones are given.
+func saveProvisionedDevice(db *bbolt.DB, device models.AxisDevice) error {
+	devices := database.Devices(db)
+	if existing, err := devices.Get(device.SerialNumber); err == nil {
+		if device.Site == "" {
+			device.Site = existing.Site
+		}
+		if len(device.Tags) == 0 {
+			device.Tags = existing.Tags
+		}
+		device.WarrantyExpires = existing.WarrantyExpires
+	}
+	return devices.Put(device)
+}
+
+// parseAddresses splits a list of addresses separated by whitespace or commas,
+// dropping duplicates.
+func parseAddresses(value string) []string {
+	var addresses []string
+	for _, address := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	}) {
+		if !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// nameValue is a parameter given on a name=value line.
+type nameValue struct {
+	Name  string
+	Value string
+}
+
+// parseNameValueLines reads parameters given as one name=value pair per
+// line. Names are trimmed, values are kept as they are. Empty lines and lines
+// starting with # are skipped.
+func parseNameValueLines(value string) ([]nameValue, error) {
+	var pairs []nameValue
+	for i, line := range strings.Split(value, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if text := strings.TrimSpace(line); text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			return nil, fmt.Errorf("line %d is not of the form name=value", i+1)
+		}
+		pairs = append(pairs, nameValue{Name: name, Value: value})
+	}
+	return pairs, nil
+}
+
+// parseBaseline reads parameters given as Name=value lines, with their values
+// trimmed.
+func parseBaseline(value string) (map[string]string, error) {
+	pairs, err := parseNameValueLines(value)
+	if err != nil {
+		return nil, fmt.Errorf("baseline %w", err)
+	}
+	params := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		params[pair.Name] = strings.TrimSpace(pair.Value)
+	}
+	return params, nil
+}
+
+// storableBaseline returns the baseline without the values of parameters
+// named like secrets, which must not be stored in plain text. Their lines
+// are kept as comments, so that the form shows which values to enter again,
+// and a baseline submitted unchanged does not apply them.
+func storableBaseline(value string) string {
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		name, secret, ok := strings.Cut(strings.TrimRight(line, "\r"), "=")
+		name = strings.TrimSpace(name)
+		if ok && strings.TrimSpace(secret) != "" && !strings.HasPrefix(name, "#") && database.SecretParameter.MatchString(name) {
+			lines[i] = fmt.Sprintf("# %s= (not stored; enter the value again)", name)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderProvision(w http.ResponseWriter, r *http.Request, db *bbolt.DB, data ProvisionPageData) {
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+
+	profiles, err := database.Credentials(db).List()
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+	data.Profiles = profiles
+
+	if err := provisionTmpl.ExecuteTemplate(w, "provision.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseNameValueLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []nameValue
+		wantErr string
+	}{
+		{name: "empty", value: ""},
+		{
+			name:  "names trimmed, values kept",
+			value: " Network.Bonjour.Enabled = no \r\n\r\n# comment\nroot.Pwd=a=b",
+			want:  []nameValue{{Name: "Network.Bonjour.Enabled", Value: " no "}, {Name: "root.Pwd", Value: "a=b"}},
+		},
+		{name: "no equals sign", value: "a=1\nb", wantErr: "line 2 is not of the form name=value"},
+		{name: "no name", value: " =1", wantErr: "line 1 is not of the form name=value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNameValueLines(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseNameValueLines() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseNameValueLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBaseline(t *testing.T) {
+	got, err := parseBaseline("Network.Bonjour.Enabled = no \nNetwork.UPnP.Enabled=yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Network.Bonjour.Enabled": "no", "Network.UPnP.Enabled": "yes"}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseBaseline() = %v, want %v", got, want)
+	}
+
+	if _, err := parseBaseline("Network.Bonjour.Enabled"); err == nil || !strings.HasPrefix(err.Error(), "baseline line 1") {
+		t.Errorf("parseBaseline() error = %v, want one for baseline line 1", err)
+	}
+}
+
+func TestStorableBaseline(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "no secrets", value: "Network.Bonjour.Enabled=no", want: "Network.Bonjour.Enabled=no"},
+		{
+			name:  "secret removed",
+			value: "Network.Bonjour.Enabled=no\r\nSNMP.V3.Password = s3cret\r\n",
+			want:  "Network.Bonjour.Enabled=no\r\n# SNMP.V3.Password= (not stored; enter the value again)\n",
+		},
+		{name: "empty secret kept", value: "SNMP.V3.Password=", want: "SNMP.V3.Password="},
+		{
+			name:  "already removed",
+			value: "# SNMP.V3.Password= (not stored; enter the value again)",
+			want:  "# SNMP.V3.Password= (not stored; enter the value again)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := storableBaseline(tt.value)
+			if got != tt.want {
+				t.Errorf("storableBaseline() = %q, want %q", got, tt.want)
+			}
+			if _, err := parseBaseline(got); err != nil {
+				t.Errorf("stored baseline does not parse: %v", err)
+			}
+			if strings.Contains(got, "s3cret") {
+				t.Errorf("stored baseline contains the secret: %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/reports"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	reportsTmpl *template.Template
+)
+
+// ReportsPageData contains the data for the /reports page
+type ReportsPageData struct {
+	Report   *reports.Report
+	Columns  []reports.Column
+	Selected []string
+	GroupBy  []string
+	Error    string
+}
+
+func RegisterReportsRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB) {
+	var err error
+	reportsTmpl, err = template.New("reports.html").Funcs(template.FuncMap{
+		"contains": slices.Contains[[]string],
+	}).ParseFS(ui.FS, "reports.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /reports", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleReports(w, r, db)
+	}))
+	mux.Handle("GET /reports/inventory", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleInventoryReport(w, r, db)
+	}))
+}
+
+func handleReports(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	data := ReportsPageData{
+		Columns:  reports.Columns,
+		Selected: reports.DefaultColumns,
+		GroupBy:  reports.GroupBy,
+	}
+
+	devices, err := scopedDevices(r, db)
+	if err == nil {
+		data.Report, err = reports.NewInventoryReport(devices, reports.Options{}, time.Now())
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+
+	if err := reportsTmpl.ExecuteTemplate(w, "reports.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// handleInventoryReport generates an inventory report of the devices the
+// user can see. Columns are given as repeated column parameters or as a
+// comma-separated columns parameter. CSV and JSON reports are downloaded;
+// HTML reports are shown in the browser, ready to be printed.
+func handleInventoryReport(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	format := r.FormValue("format")
+	if format == "" {
+		format = reports.FormatCSV
+	}
+	if !slices.Contains(reports.Formats, format) {
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+		return
+	}
+
+	r.ParseForm()
+	opts := reports.Options{
+		Columns: append(r.Form["column"], reports.ParseColumns(r.FormValue("columns"))...),
+		GroupBy: r.FormValue("group"),
+	}
+
+	devices, err := scopedDevices(r, db)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	report, err := reports.NewInventoryReport(devices, opts, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Render into a buffer first, so that errors can still be reported.
+	var buf bytes.Buffer
+	if err := report.Write(&buf, format); err != nil {
+		log.Printf("Failed to write inventory report: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", reports.ContentType(format))
+	if format != reports.FormatHTML {
+		filename := fmt.Sprintf("neba_inventory_%s.%s", now.Format("20060102_150405"), format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	}
+	buf.WriteTo(w)
+}
+
+// scopedDevices returns the devices that the signed-in user may see.
+func scopedDevices(r *http.Request, db *bbolt.DB) ([]models.AxisDevice, error) {
+	devices, err := database.Devices(db).List()
+	if err != nil {
+		return nil, err
+	}
+	user, _ := UserFromContext(r.Context())
+	return slices.DeleteFunc(devices, func(device models.AxisDevice) bool {
+		return !auth.InScope(user, device)
+	}), nil
+}
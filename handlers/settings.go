@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/ui"
+)
+
+var (
+	settingsTmpl *template.Template
+)
+
+// SettingsPageData contains the data for the /settings page
+type SettingsPageData struct {
+	Config          configs.AppConfig // As stored in the configuration file
+	Path            string
+	EnvOverrides    []string
+	RestartRequired []string
+	Result          *ActionResult
+}
+
+// SettingsResponse is the body of the settings API responses
+type SettingsResponse struct {
+	Path            string            `json:"path"`
+	Config          configs.AppConfig `json:"config"`    // As stored in the configuration file
+	Effective       configs.AppConfig `json:"effective"` // With paths resolved and overrides applied
+	EnvOverrides    []string          `json:"env_overrides"`
+	RestartRequired []string          `json:"restart_required"`
+	Error           string            `json:"error,omitempty"`
+}
+
+func RegisterSettingsRoute(fs http.Handler, mux *http.ServeMux, cm *configs.CManager) {
+	var err error
+	settingsTmpl, err = template.New("settings.html").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).ParseFS(ui.FS, "settings.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	// The configuration the server was started with, to tell which changes
+	// only take effect after a restart.
+	running := cm.Get()
+
+	mux.Handle("GET /settings", AuthorizeFunc(auth.PermManageSettings, func(w http.ResponseWriter, r *http.Request) {
+		renderSettings(w, r, cm, running, nil)
+	}))
+	mux.Handle("POST /settings", AuthorizeFunc(auth.PermManageSettings, func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateSettings(w, r, cm, running)
+	}))
+	mux.Handle("GET /api/settings", AuthorizeFunc(auth.PermManageSettings, func(w http.ResponseWriter, r *http.Request) {
+		writeSettingsResponse(w, http.StatusOK, cm, running, "")
+	}))
+	mux.Handle("PUT /api/settings", AuthorizeFunc(auth.PermManageSettings, func(w http.ResponseWriter, r *http.Request) {
+		handleReplaceSettings(w, r, cm, running)
+	}))
+}
+
+func handleUpdateSettings(w http.ResponseWriter, r *http.Request, cm *configs.CManager, running configs.AppConfig) {
+	auditAction(r, "Update settings")
+
+	timeout, err := strconv.Atoi(r.FormValue("discovery_timeout"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "Discovery timeout must be a whole number of seconds."})
+		return
+	}
+	sessionPurge, err := time.ParseDuration(r.FormValue("session_purge"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: fmt.Sprintf("Invalid session purge interval: %v", err)})
+		return
+	}
+	clockCheck, err := time.ParseDuration(r.FormValue("clock_check"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: fmt.Sprintf("Invalid clock check interval: %v", err)})
+		return
+	}
+	maxClockDrift, err := time.ParseDuration(r.FormValue("max_clock_drift"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: fmt.Sprintf("Invalid maximum clock drift: %v", err)})
+		return
+	}
+
+	backupInterval, err := time.ParseDuration(r.FormValue("backup_interval"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: fmt.Sprintf("Invalid backup interval: %v", err)})
+		return
+	}
+	backupKeep, err := strconv.Atoi(r.FormValue("backup_keep"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The number of backups to keep must be a whole number."})
+		return
+	}
+	certificatesScan, err := time.ParseDuration(r.FormValue("certificates_scan"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: fmt.Sprintf("Invalid certificate scan interval: %v", err)})
+		return
+	}
+	certificatesWarnDays, err := strconv.Atoi(r.FormValue("certificates_warn_days"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The days to warn before certificates expire must be a whole number."})
+		return
+	}
+	certificatesValidityDays, err := strconv.Atoi(r.FormValue("certificates_validity_days"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The validity of issued certificates must be a whole number of days."})
+		return
+	}
+	certificatesRenewDays, err := strconv.Atoi(r.FormValue("certificates_renew_days"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The days to renew before certificates expire must be a whole number."})
+		return
+	}
+	snapshotsTTL, err := time.ParseDuration(r.FormValue("snapshots_ttl"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: fmt.Sprintf("Invalid snapshot cache duration: %v", err)})
+		return
+	}
+	snapshotsCompression, err := strconv.Atoi(r.FormValue("snapshots_compression"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The snapshot compression must be a whole number."})
+		return
+	}
+	liveViewMaxStreams, err := strconv.Atoi(r.FormValue("live_view_max_streams"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The maximum number of live streams must be a whole number."})
+		return
+	}
+	liveViewMaxStreamsPerDevice, err := strconv.Atoi(r.FormValue("live_view_max_streams_per_device"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The maximum number of live streams per device must be a whole number."})
+		return
+	}
+	liveViewMaxDuration, err := time.ParseDuration(r.FormValue("live_view_max_duration"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: fmt.Sprintf("Invalid maximum live stream duration: %v", err)})
+		return
+	}
+	liveViewFPS, err := strconv.Atoi(r.FormValue("live_view_fps"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The live view frame rate must be a whole number."})
+		return
+	}
+	liveViewCompression, err := strconv.Atoi(r.FormValue("live_view_compression"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The live view compression must be a whole number."})
+		return
+	}
+	eventsRetentionDays, err := strconv.Atoi(r.FormValue("events_retention_days"))
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: "The event retention must be a whole number of days."})
+		return
+	}
+
+	err = cm.Update(func(c *configs.AppConfig) {
+		c.Server.HTTP.Address = strings.TrimSpace(r.FormValue("http_address"))
+		c.Server.HTTP.Addresses = parseList(r.FormValue("http_addresses"))
+		c.Server.HTTP.Port = strings.TrimSpace(r.FormValue("http_port"))
+		c.Server.HTTPS.Enabled = r.FormValue("https_enabled") == "on"
+		c.Server.HTTPS.Address = strings.TrimSpace(r.FormValue("https_address"))
+		c.Server.HTTPS.Addresses = parseList(r.FormValue("https_addresses"))
+		c.Server.HTTPS.Port = strings.TrimSpace(r.FormValue("https_port"))
+		c.Server.HTTPS.CertFile = strings.TrimSpace(r.FormValue("https_cert_file"))
+		c.Server.HTTPS.KeyFile = strings.TrimSpace(r.FormValue("https_key_file"))
+		c.Server.HTTPS.RedirectHTTP = r.FormValue("https_redirect_http") == "on"
+		c.Database.Path = strings.TrimSpace(r.FormValue("database_path"))
+		c.Discovery.TimeoutSeconds = timeout
+		c.Discovery.LocalAddress = strings.TrimSpace(r.FormValue("discovery_local_address"))
+		c.Polling.SessionPurge = configs.Duration(sessionPurge)
+		c.Polling.ClockCheck = configs.Duration(clockCheck)
+		c.Polling.MaxClockDrift = configs.Duration(maxClockDrift)
+		c.Backup.Enabled = r.FormValue("backup_enabled") == "on"
+		c.Backup.Interval = configs.Duration(backupInterval)
+		c.Backup.Dir = strings.TrimSpace(r.FormValue("backup_dir"))
+		c.Backup.Keep = backupKeep
+		c.Certificates.Scan = configs.Duration(certificatesScan)
+		c.Certificates.WarnDays = certificatesWarnDays
+		c.Certificates.ValidityDays = certificatesValidityDays
+		c.Certificates.AutoRenew = r.FormValue("certificates_auto_renew") == "on"
+		c.Certificates.RenewDays = certificatesRenewDays
+		c.Snapshots.TTL = configs.Duration(snapshotsTTL)
+		c.Snapshots.Resolution = strings.TrimSpace(r.FormValue("snapshots_resolution"))
+		c.Snapshots.Compression = snapshotsCompression
+		c.LiveView.MaxStreams = liveViewMaxStreams
+		c.LiveView.MaxStreamsPerDevice = liveViewMaxStreamsPerDevice
+		c.LiveView.MaxDuration = configs.Duration(liveViewMaxDuration)
+		c.LiveView.Resolution = strings.TrimSpace(r.FormValue("live_view_resolution"))
+		c.LiveView.FPS = liveViewFPS
+		c.LiveView.Compression = liveViewCompression
+		c.Events.Enabled = r.FormValue("events_enabled") == "on"
+		c.Events.TopicFilters = strings.Fields(r.FormValue("events_topic_filters"))
+		c.Events.RetentionDays = eventsRetentionDays
+	})
+	if err != nil {
+		renderSettings(w, r, cm, running, &ActionResult{Message: err.Error()})
+		return
+	}
+
+	renderSettings(w, r, cm, running, &ActionResult{Success: true, Message: "Saved the settings."})
+}
+
+func handleReplaceSettings(w http.ResponseWriter, r *http.Request, cm *configs.CManager, running configs.AppConfig) {
+	auditAction(r, "Update settings")
+
+	var config configs.AppConfig
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		auditFailure(r, err.Error())
+		writeSettingsResponse(w, http.StatusBadRequest, cm, running, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	if err := cm.Update(func(c *configs.AppConfig) { *c = config }); err != nil {
+		auditFailure(r, err.Error())
+		writeSettingsResponse(w, http.StatusUnprocessableEntity, cm, running, err.Error())
+		return
+	}
+
+	writeSettingsResponse(w, http.StatusOK, cm, running, "")
+}
+
+func writeSettingsResponse(w http.ResponseWriter, status int, cm *configs.CManager, running configs.AppConfig, message string) {
+	effective := cm.Get()
+	response := SettingsResponse{
+		Path:            cm.Path(),
+		Config:          cm.File(),
+		Effective:       effective,
+		EnvOverrides:    configs.ActiveEnvOverrides(),
+		RestartRequired: configs.RestartRequired(running, effective),
+		Error:           message,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode settings: %v", err)
+	}
+}
+
+func renderSettings(w http.ResponseWriter, r *http.Request, cm *configs.CManager, running configs.AppConfig, result *ActionResult) {
+	if result != nil && !result.Success {
+		auditFailure(r, result.Message)
+	}
+
+	data := SettingsPageData{
+		Config:          cm.File(),
+		Path:            cm.Path(),
+		EnvOverrides:    configs.ActiveEnvOverrides(),
+		RestartRequired: configs.RestartRequired(running, cm.Get()),
+		Result:          result,
+	}
+
+	if err := settingsTmpl.ExecuteTemplate(w, "settings.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
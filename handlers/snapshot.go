@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"container/list"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/network"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Snapshot cache limits
+	snapshotCacheEntries = 1024
+	snapshotCacheBytes   = 64 << 20
+)
+
+var (
+	// snapshotResolutions are the resolutions that may be asked for besides
+	// the thumbnail resolution, so that callers cannot fill the cache by
+	// varying it.
+	snapshotResolutions = []string{"320x180", "640x360", "1280x720", "1920x1080"}
+	// snapshotCompressions are the compressions that may be asked for besides
+	// the thumbnail compression.
+	snapshotCompressions = []int{10, 30, 50}
+)
+
+// snapshotCache keeps the snapshots fetched from devices for a while, so that
+// lists of many devices and repeated page loads do not hit every camera
+// each time. Failures are kept too, so unreachable devices are not asked
+// again on every page load. Once the cache holds too many snapshots or
+// bytes, the least recently used snapshots are dropped.
+type snapshotCache struct {
+	mutex      sync.Mutex
+	entries    map[string]*list.Element // Values are *snapshotEntry
+	recent     *list.List               // Most recently used at the front
+	bytes      int                      // Size of the cached images
+	maxEntries int
+	maxBytes   int
+}
+
+// snapshotEntry is a cached snapshot. Its mutex is held while the snapshot
+// is fetched, so concurrent requests for the same snapshot fetch it once.
+type snapshotEntry struct {
+	key  string
+	size int // Size of image as counted by the cache; guarded by the cache mutex
+
+	mutex     sync.Mutex
+	image     []byte
+	err       error
+	fetchedAt time.Time
+}
+
+var snapshots = newSnapshotCache(snapshotCacheEntries, snapshotCacheBytes)
+
+// newSnapshotCache returns an empty cache with the given limits.
+func newSnapshotCache(maxEntries, maxBytes int) *snapshotCache {
+	return &snapshotCache{
+		entries:    map[string]*list.Element{},
+		recent:     list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}
+}
+
+func RegisterSnapshotRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, cm *configs.CManager) {
+	mux.Handle("GET /manage/{serial}/snapshot", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		handleSnapshot(w, r, db, cm)
+	}))
+}
+
+// handleSnapshot serves a snapshot of the device with the stored
+// credentials, so they never reach the browser. The resolution and
+// compression default to the thumbnail settings and may be overridden with
+// the query parameters of the same names, with one of the allowed values.
+func handleSnapshot(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager) {
+	device, ok := lookupDevice(w, r, db)
+	if !ok {
+		return
+	}
+
+	config := cm.Get().Snapshots
+	options := network.SnapshotOptions{Resolution: config.Resolution, Compression: config.Compression}
+	if resolution := r.URL.Query().Get("resolution"); resolution != "" && resolution != options.Resolution {
+		if !slices.Contains(snapshotResolutions, resolution) {
+			allowed := snapshotResolutions
+			if !slices.Contains(allowed, options.Resolution) {
+				allowed = append([]string{options.Resolution}, allowed...)
+			}
+			http.Error(w, fmt.Sprintf("The resolution must be one of %s.", strings.Join(allowed, ", ")), http.StatusBadRequest)
+			return
+		}
+		options.Resolution = resolution
+	}
+	if compression := r.URL.Query().Get("compression"); compression != "" {
+		n, err := strconv.Atoi(compression)
+		if err != nil || (n != options.Compression && !slices.Contains(snapshotCompressions, n)) {
+			var allowed []string
+			if !slices.Contains(snapshotCompressions, options.Compression) {
+				allowed = append(allowed, strconv.Itoa(options.Compression))
+			}
+			for _, compression := range snapshotCompressions {
+				allowed = append(allowed, strconv.Itoa(compression))
+			}
+			http.Error(w, fmt.Sprintf("The compression must be one of %s.", strings.Join(allowed, ", ")), http.StatusBadRequest)
+			return
+		}
+		options.Compression = n
+	}
+
+	ttl := config.TTL.Duration()
+	key := fmt.Sprintf("%s/%s/%d", device.SerialNumber, options.Resolution, options.Compression)
+	image, fetchedAt, err := snapshots.get(key, ttl, func() ([]byte, error) {
+		image, err := network.Snapshot(deviceClient(*device), options)
+		if err != nil {
+			log.Printf("Failed to fetch snapshot of %s: %v", device.SerialNumber, err)
+		}
+		return image, err
+	})
+	if err != nil {
+		http.Error(w, "Snapshot not available", http.StatusBadGateway)
+		return
+	}
+
+	maxAge := max(0, int((ttl - time.Since(fetchedAt)).Seconds()))
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	w.Header().Set("Last-Modified", fetchedAt.UTC().Format(http.TimeFormat))
+	w.Write(image)
+}
+
+// get returns the cached snapshot with the given key, or fetches it if it is
+// missing or older than the TTL.
+//
+// Parameters:
+//   - key:   The device and options of the snapshot.
+//   - ttl:   How long a snapshot is served from the cache.
+//   - fetch: The function to fetch the snapshot from the device.
+//
+// Returns:
+//   - []byte:    The JPEG image.
+//   - time.Time: When the snapshot was fetched.
+//   - error:     The error of the last fetch, if it failed.
+func (c *snapshotCache) get(key string, ttl time.Duration, fetch func() ([]byte, error)) ([]byte, time.Time, error) {
+	c.mutex.Lock()
+	element, ok := c.entries[key]
+	if ok {
+		c.recent.MoveToFront(element)
+	} else {
+		c.prune(ttl)
+		element = c.recent.PushFront(&snapshotEntry{key: key})
+		c.entries[key] = element
+		c.evict()
+	}
+	entry := element.Value.(*snapshotEntry)
+	c.mutex.Unlock()
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.fetchedAt.IsZero() || time.Since(entry.fetchedAt) > ttl {
+		entry.image, entry.err = fetch()
+		entry.fetchedAt = time.Now()
+		c.resize(entry, len(entry.image))
+	}
+	return entry.image, entry.fetchedAt, entry.err
+}
+
+// resize records the new size of the image of the entry, and drops the
+// least recently used snapshots if the cache holds too many bytes now.
+func (c *snapshotCache) resize(entry *snapshotEntry, size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[entry.key]; !ok || element.Value != entry {
+		return // Dropped while it was fetched
+	}
+	c.bytes += size - entry.size
+	entry.size = size
+	c.evict()
+}
+
+// evict drops the least recently used snapshots until the cache is within
+// its limits. Snapshots that are being fetched are skipped. The caller must
+// hold the mutex of the cache.
+func (c *snapshotCache) evict() {
+	element := c.recent.Back()
+	for element != nil && (c.recent.Len() > c.maxEntries || c.bytes > c.maxBytes) {
+		previous := element.Prev()
+		entry := element.Value.(*snapshotEntry)
+		if entry.mutex.TryLock() {
+			c.remove(element)
+			entry.mutex.Unlock()
+		}
+		element = previous
+	}
+}
+
+// prune removes the snapshots older than the TTL. The caller must hold the
+// mutex of the cache.
+func (c *snapshotCache) prune(ttl time.Duration) {
+	for element := c.recent.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*snapshotEntry)
+		if entry.mutex.TryLock() {
+			if !entry.fetchedAt.IsZero() && time.Since(entry.fetchedAt) > ttl {
+				c.remove(element)
+			}
+			entry.mutex.Unlock()
+		}
+		element = next
+	}
+}
+
+// remove drops the snapshot of the element. The caller must hold the mutex
+// of the cache.
+func (c *snapshotCache) remove(element *list.Element) {
+	entry := c.recent.Remove(element).(*snapshotEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
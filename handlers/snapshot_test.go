@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSnapshotCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int
+		gets       []string // Keys in the order they are asked for
+		sizes      map[string]int
+		want       []string // Cached keys, from the most recently used
+		wantBytes  int
+		wantFetch  int
+	}{
+		{
+			name:       "repeated snapshots are fetched once",
+			maxEntries: 4,
+			maxBytes:   100,
+			gets:       []string{"a", "b", "a", "b"},
+			want:       []string{"b", "a"},
+			wantBytes:  20,
+			wantFetch:  2,
+		},
+		{
+			name:       "least recently used dropped by count",
+			maxEntries: 2,
+			maxBytes:   100,
+			gets:       []string{"a", "b", "a", "c"},
+			want:       []string{"c", "a"},
+			wantBytes:  20,
+			wantFetch:  3,
+		},
+		{
+			name:       "least recently used dropped by size",
+			maxEntries: 10,
+			maxBytes:   40,
+			gets:       []string{"a", "b", "c", "b", "big"},
+			sizes:      map[string]int{"big": 25},
+			want:       []string{"big", "b"},
+			wantBytes:  35,
+			wantFetch:  4,
+		},
+		{
+			name:       "dropped snapshots are fetched again",
+			maxEntries: 1,
+			maxBytes:   100,
+			gets:       []string{"a", "b", "a"},
+			want:       []string{"a"},
+			wantBytes:  10,
+			wantFetch:  3,
+		},
+		{
+			name:       "failures are cached without bytes",
+			maxEntries: 4,
+			maxBytes:   100,
+			gets:       []string{"a", "failing", "failing"},
+			sizes:      map[string]int{"failing": 0},
+			want:       []string{"failing", "a"},
+			wantBytes:  10,
+			wantFetch:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newSnapshotCache(tt.maxEntries, tt.maxBytes)
+			fetches := 0
+			for _, key := range tt.gets {
+				size, ok := tt.sizes[key]
+				if !ok {
+					size = 10
+				}
+				image, _, _ := cache.get(key, time.Minute, func() ([]byte, error) {
+					fetches++
+					return make([]byte, size), nil
+				})
+				if len(image) != size {
+					t.Fatalf("get(%q) returned %d bytes, want %d", key, len(image), size)
+				}
+			}
+
+			var keys []string
+			for element := cache.recent.Front(); element != nil; element = element.Next() {
+				keys = append(keys, element.Value.(*snapshotEntry).key)
+			}
+			if !slices.Equal(keys, tt.want) {
+				t.Errorf("cached keys = %v, want %v", keys, tt.want)
+			}
+			if len(cache.entries) != len(tt.want) {
+				t.Errorf("%d entries indexed, want %d", len(cache.entries), len(tt.want))
+			}
+			if cache.bytes != tt.wantBytes {
+				t.Errorf("cached bytes = %d, want %d", cache.bytes, tt.wantBytes)
+			}
+			if fetches != tt.wantFetch {
+				t.Errorf("fetched %d times, want %d", fetches, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestSnapshotCachePrune(t *testing.T) {
+	cache := newSnapshotCache(10, 100)
+	fetch := func() ([]byte, error) { return make([]byte, 10), nil }
+	cache.get("old", time.Minute, fetch)
+	cache.entries["old"].Value.(*snapshotEntry).fetchedAt = time.Now().Add(-time.Hour)
+	cache.get("new", time.Minute, fetch)
+
+	if _, ok := cache.entries["old"]; ok {
+		t.Error("the expired snapshot is still cached")
+	}
+	if cache.bytes != 10 {
+		t.Errorf("cached bytes = %d, want 10", cache.bytes)
+	}
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zones are validated on hosts without a time zone database, too
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/jobs"
+	"github.com/furkansuleymana/neba/network"
+	"github.com/furkansuleymana/neba/timesync"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// NTP modes of the time settings form
+	ntpUnchanged = ""
+	ntpDHCP      = "dhcp"
+	ntpStatic    = "static"
+	ntpOff       = "off"
+)
+
+var (
+	timeTmpl *template.Template
+)
+
+// TimePageData contains the data for the /time page
+type TimePageData struct {
+	Rows        []ClockRow
+	MaxDrift    time.Duration
+	Flagged     int
+	Fields      TimeFields
+	Permissions auth.Permissions
+	Job         *models.Job
+	Result      *ActionResult
+}
+
+// ClockRow is a device with the outcome of its last clock check, if any
+type ClockRow struct {
+	Device models.AxisDevice
+	Check  *timesync.ClockCheck
+}
+
+// DeviceTimePageData contains the data for the /manage/{serial}/time page
+type DeviceTimePageData struct {
+	Device   models.AxisDevice
+	Info     *network.TimeInfo
+	NTP      *network.NTPInfo
+	Check    timesync.ClockCheck
+	MaxDrift time.Duration
+	Fields   TimeFields
+	Result   *ActionResult
+}
+
+// TimeFields contains the initial values of the time settings form
+type TimeFields struct {
+	Bulk       bool // Whether the form applies to many devices, leaving empty settings unchanged
+	TimeZone   string
+	NTPMode    string
+	NTPServers string
+}
+
+// timeSettings are the time settings to apply to one or more devices. Empty
+// fields are left unchanged.
+type timeSettings struct {
+	TimeZone   string
+	NTPMode    string
+	NTPServers []string
+	SetClock   bool // Whether to set the clock to the time of the Neba host
+}
+
+func RegisterTimeRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB, cm *configs.CManager, jm *jobs.Manager) {
+	var err error
+	timeTmpl, err = template.New("time.html").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).ParseFS(ui.FS, "time.html", "jobs.html", "snapshot.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /time", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		renderTime(w, r, db, cm, TimePageData{})
+	}))
+	mux.Handle("POST /time/check", AuthorizeFunc(auth.PermViewDevices, func(w http.ResponseWriter, r *http.Request) {
+		data := TimePageData{}
+		if _, err := timesync.CheckClocks(db, cm.Get().Polling.MaxClockDrift.Duration()); err != nil {
+			data.Result = &ActionResult{Message: err.Error()}
+		}
+		renderTime(w, r, db, cm, data)
+	}))
+	mux.Handle("POST /time", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		handleConfigureTime(w, r, db, cm, jm)
+	}))
+
+	mux.Handle("GET /manage/{serial}/time", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		renderDeviceTime(w, r, cm, *device, nil)
+	}))
+	mux.Handle("POST /manage/{serial}/time", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		auditAction(r, "Update time settings")
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		auditTargets(r, device.SerialNumber)
+
+		settings, err := parseTimeSettings(r)
+		if err == nil {
+			err = applyTimeSettings(deviceClient(*device), settings)
+		}
+		if err != nil {
+			renderDeviceTime(w, r, cm, *device, &ActionResult{Message: err.Error()})
+			return
+		}
+		renderDeviceTime(w, r, cm, *device, &ActionResult{Success: true, Message: "Saved the time settings."})
+	}))
+	mux.Handle("POST /manage/{serial}/time/sync", AuthorizeFunc(auth.PermConfigureDevice, func(w http.ResponseWriter, r *http.Request) {
+		auditAction(r, "Set device clock")
+		device, ok := lookupDevice(w, r, db)
+		if !ok {
+			return
+		}
+		auditTargets(r, device.SerialNumber)
+
+		if err := network.SetDateTime(deviceClient(*device), time.Now()); err != nil {
+			renderDeviceTime(w, r, cm, *device, &ActionResult{Message: err.Error()})
+			return
+		}
+		renderDeviceTime(w, r, cm, *device, &ActionResult{Success: true, Message: "Set the clock to the time of the Neba host."})
+	}))
+}
+
+// handleConfigureTime starts a job that applies time settings to every
+// selected device, and checks its clock afterwards.
+func handleConfigureTime(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager, jm *jobs.Manager) {
+	auditAction(r, "Update time settings")
+	settings, err := parseTimeSettings(r)
+	if err == nil && settings.TimeZone == "" && settings.NTPMode == ntpUnchanged && !settings.SetClock {
+		err = errors.New("choose at least one setting to change")
+	}
+	if err != nil {
+		renderTime(w, r, db, cm, TimePageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+	devices, err := selectDevices(r, db)
+	if err != nil {
+		renderTime(w, r, db, cm, TimePageData{Result: &ActionResult{Message: err.Error()}})
+		return
+	}
+
+	maxDrift := cm.Get().Polling.MaxClockDrift.Duration()
+	user, _ := UserFromContext(r.Context())
+	job, err := jobs.Start(db, jm, "Configure time", user.Username, serialNumbers(devices),
+		func(ctx context.Context, report *jobs.Reporter) (string, error) {
+			for _, device := range devices {
+				if ctx.Err() != nil {
+					return "Stopped because Neba is shutting down.", nil
+				}
+				if err := applyTimeSettings(deviceClient(device), settings); err != nil {
+					report.Failed(device.SerialNumber, "%v", err)
+					continue
+				}
+				check := timesync.CheckClock(device, maxDrift)
+				switch {
+				case check.Error != "":
+					report.Succeeded(device.SerialNumber, "Applied; checking the clock failed: %s", check.Error)
+				case check.Exceeded:
+					report.Failed(device.SerialNumber, "Applied, but the clock is %s.", check.Describe())
+				default:
+					report.Succeeded(device.SerialNumber, "Applied; clock %s.", strings.ToLower(check.Describe()))
+				}
+			}
+			return fmt.Sprintf("Processed %d devices.", len(devices)), nil
+		})
+	if err != nil {
+		renderTime(w, r, db, cm, TimePageData{Result: &ActionResult{Message: fmt.Sprintf("Starting the job failed: %v", err)}})
+		return
+	}
+	auditTargets(r, job.Targets...)
+	renderTime(w, r, db, cm, TimePageData{
+		Job:    job,
+		Result: &ActionResult{Success: true, Message: fmt.Sprintf("Started: %s on %d devices.", job.Kind, len(job.Targets))},
+	})
+}
+
+// parseTimeSettings reads the time settings form. The time zone must be an
+// IANA time zone, and NTP servers host names or IP addresses.
+func parseTimeSettings(r *http.Request) (timeSettings, error) {
+	settings := timeSettings{
+		TimeZone:   strings.TrimSpace(r.FormValue("time_zone")),
+		NTPMode:    r.FormValue("ntp"),
+		NTPServers: parseList(r.FormValue("ntp_servers")),
+		SetClock:   r.FormValue("set_clock") != "",
+	}
+
+	if settings.TimeZone != "" {
+		if _, err := time.LoadLocation(settings.TimeZone); err != nil || settings.TimeZone == "Local" {
+			return settings, fmt.Errorf("%q is not a time zone, use a name such as Europe/Stockholm or UTC", settings.TimeZone)
+		}
+	}
+	switch settings.NTPMode {
+	case ntpUnchanged, ntpDHCP, ntpOff:
+	case ntpStatic:
+		if len(settings.NTPServers) == 0 {
+			return settings, errors.New("enter at least one NTP server")
+		}
+	default:
+		return settings, fmt.Errorf("unknown NTP mode %q", settings.NTPMode)
+	}
+	for _, server := range settings.NTPServers {
+		if net.ParseIP(server) == nil && strings.ContainsAny(server, " /:@") {
+			return settings, fmt.Errorf("%q is not a host name or IP address", server)
+		}
+	}
+	return settings, nil
+}
+
+// applyTimeSettings applies the time settings to the device. The clock is
+// set last, so that it is not undone by an NTP change.
+func applyTimeSettings(c *network.Client, settings timeSettings) error {
+	if settings.TimeZone != "" {
+		if err := network.SetTimeZone(c, settings.TimeZone); err != nil {
+			return err
+		}
+	}
+	switch settings.NTPMode {
+	case ntpDHCP:
+		if err := network.SetNTP(c, true, network.NTPServersDHCP, settings.NTPServers); err != nil {
+			return err
+		}
+	case ntpStatic:
+		if err := network.SetNTP(c, true, network.NTPServersStatic, settings.NTPServers); err != nil {
+			return err
+		}
+	case ntpOff:
+		if err := network.SetNTP(c, false, network.NTPServersStatic, settings.NTPServers); err != nil {
+			return err
+		}
+	}
+	if settings.SetClock {
+		if err := network.SetDateTime(c, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderTime(w http.ResponseWriter, r *http.Request, db *bbolt.DB, cm *configs.CManager, data TimePageData) {
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+	data.MaxDrift = cm.Get().Polling.MaxClockDrift.Duration()
+	data.Fields = TimeFields{Bulk: true}
+	data.Permissions = permissionsFromContext(r.Context())
+
+	devices, err := scopedDevices(r, db)
+	if err == nil {
+		var checks map[string]timesync.ClockCheck
+		if checks, err = timesync.StoredChecks(db); err == nil {
+			for _, device := range devices {
+				row := ClockRow{Device: device}
+				if check, ok := checks[device.SerialNumber]; ok {
+					row.Check = &check
+					if check.Exceeded {
+						data.Flagged++
+					}
+				}
+				data.Rows = append(data.Rows, row)
+			}
+		}
+	}
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+
+	if err := timeTmpl.ExecuteTemplate(w, "time.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func renderDeviceTime(w http.ResponseWriter, r *http.Request, cm *configs.CManager, device models.AxisDevice, result *ActionResult) {
+	data := DeviceTimePageData{
+		Device:   device,
+		MaxDrift: cm.Get().Polling.MaxClockDrift.Duration(),
+		Result:   result,
+	}
+	if data.Result != nil && !data.Result.Success {
+		auditFailure(r, data.Result.Message)
+	}
+
+	client := deviceClient(device)
+	drift, info, err := network.ClockDrift(client)
+	if err == nil {
+		data.Info = info
+		data.Check = timesync.ClockCheck{SerialNumber: device.SerialNumber, CheckedAt: time.Now(), Drift: drift, Exceeded: drift.Abs() > data.MaxDrift}
+		data.NTP, err = network.GetNTPInfo(client)
+	}
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+	if data.Info != nil {
+		data.Fields.TimeZone = data.Info.TimeZone
+	}
+	if data.NTP != nil {
+		data.Fields.NTPServers = strings.Join(data.NTP.Client.StaticServers, ", ")
+		switch {
+		case !data.NTP.Client.Enabled:
+			data.Fields.NTPMode = ntpOff
+		case data.NTP.Client.ServersSource == network.NTPServersDHCP:
+			data.Fields.NTPMode = ntpDHCP
+		default:
+			data.Fields.NTPMode = ntpStatic
+		}
+	}
+
+	if err := timeTmpl.ExecuteTemplate(w, "device-time", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
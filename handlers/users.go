@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/auth"
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/ui"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	usersTmpl *template.Template
+)
+
+// UsersPageData contains the data for the /users page
+type UsersPageData struct {
+	Users             []models.User
+	Roles             []auth.Role
+	CurrentUser       string
+	MinPasswordLength int
+	Result            *ActionResult
+}
+
+func RegisterUsersRoute(fs http.Handler, mux *http.ServeMux, db *bbolt.DB) {
+	var err error
+	usersTmpl, err = template.New("users.html").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).ParseFS(ui.FS, "users.html")
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	mux.Handle("GET /users", AuthorizeFunc(auth.PermManageUsers, func(w http.ResponseWriter, r *http.Request) {
+		renderUsers(w, r, db, nil)
+	}))
+	mux.Handle("POST /users", AuthorizeFunc(auth.PermManageUsers, func(w http.ResponseWriter, r *http.Request) {
+		handleCreateUser(w, r, db)
+	}))
+	mux.Handle("POST /users/{username}", AuthorizeFunc(auth.PermManageUsers, func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateUser(w, r, db)
+	}))
+	mux.Handle("DELETE /users/{username}", AuthorizeFunc(auth.PermManageUsers, func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteUser(w, r, db)
+	}))
+}
+
+func handleCreateUser(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Create user")
+	username := strings.TrimSpace(r.FormValue("username"))
+	role := auth.Role(r.FormValue("role"))
+	auditTargets(r, username)
+
+	if username == "" {
+		renderUsers(w, r, db, &ActionResult{Message: "Username must not be empty."})
+		return
+	}
+	if _, err := database.ViewUser(db, username); err == nil {
+		renderUsers(w, r, db, &ActionResult{Message: fmt.Sprintf("User %s already exists.", username)})
+		return
+	}
+	if err := auth.ValidateRole(role); err != nil {
+		renderUsers(w, r, db, &ActionResult{Message: err.Error()})
+		return
+	}
+	hash, err := auth.HashPassword(r.FormValue("password"))
+	if err != nil {
+		renderUsers(w, r, db, &ActionResult{Message: err.Error()})
+		return
+	}
+
+	user := models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         string(role),
+		Scopes:       parseList(r.FormValue("scopes")),
+		CreatedAt:    time.Now(),
+	}
+	if err := database.UpdateUser(db, user); err != nil {
+		renderUsers(w, r, db, &ActionResult{Message: fmt.Sprintf("Creating %s failed: %v", username, err)})
+		return
+	}
+
+	renderUsers(w, r, db, &ActionResult{Success: true, Message: fmt.Sprintf("Created %s.", username)})
+}
+
+func handleUpdateUser(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Update user")
+	user, err := database.ViewUser(db, r.PathValue("username"))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	role := auth.Role(r.FormValue("role"))
+	if err := auth.ValidateRole(role); err != nil {
+		renderUsers(w, r, db, &ActionResult{Message: err.Error()})
+		return
+	}
+	if auth.Role(user.Role) == auth.RoleAdmin && role != auth.RoleAdmin {
+		if last, err := isLastAdmin(db, user.Username); err != nil || last {
+			renderUsers(w, r, db, &ActionResult{Message: "At least one admin account must remain."})
+			return
+		}
+	}
+
+	user.Role = string(role)
+	user.Scopes = parseList(r.FormValue("scopes"))
+	if password := r.FormValue("password"); password != "" {
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			renderUsers(w, r, db, &ActionResult{Message: err.Error()})
+			return
+		}
+		user.PasswordHash = hash
+	}
+
+	if err := database.UpdateUser(db, *user); err != nil {
+		renderUsers(w, r, db, &ActionResult{Message: fmt.Sprintf("Updating %s failed: %v", user.Username, err)})
+		return
+	}
+
+	renderUsers(w, r, db, &ActionResult{Success: true, Message: fmt.Sprintf("Updated %s.", user.Username)})
+}
+
+func handleDeleteUser(w http.ResponseWriter, r *http.Request, db *bbolt.DB) {
+	auditAction(r, "Delete user")
+	username := r.PathValue("username")
+
+	if current, _ := UserFromContext(r.Context()); current.Username == username {
+		renderUsers(w, r, db, &ActionResult{Message: "You cannot delete your own account."})
+		return
+	}
+	if err := database.DeleteUser(db, username); err != nil {
+		renderUsers(w, r, db, &ActionResult{Message: fmt.Sprintf("Deleting %s failed: %v", username, err)})
+		return
+	}
+
+	renderUsers(w, r, db, &ActionResult{Success: true, Message: fmt.Sprintf("Deleted %s.", username)})
+}
+
+// isLastAdmin reports whether the given user is the only remaining admin.
+func isLastAdmin(db *bbolt.DB, username string) (bool, error) {
+	users, err := database.ListUsers(db)
+	if err != nil {
+		return false, err
+	}
+	for _, user := range users {
+		if auth.Role(user.Role) == auth.RoleAdmin && user.Username != username {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func renderUsers(w http.ResponseWriter, r *http.Request, db *bbolt.DB, result *ActionResult) {
+	if result != nil && !result.Success {
+		auditFailure(r, result.Message)
+	}
+
+	data := UsersPageData{
+		Roles:             auth.Roles,
+		MinPasswordLength: auth.MinPasswordLength,
+		Result:            result,
+	}
+	if current, ok := UserFromContext(r.Context()); ok {
+		data.CurrentUser = current.Username
+	}
+
+	users, err := database.ListUsers(db)
+	if err != nil && data.Result == nil {
+		data.Result = &ActionResult{Message: err.Error()}
+	}
+	data.Users = users
+
+	if err := usersTmpl.ExecuteTemplate(w, "users.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// parseList splits a comma-separated form value into its trimmed, non-empty
+// elements.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
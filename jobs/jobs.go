@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Manager runs background jobs and keeps track of them, so that the
+// application can wait for running jobs to finish before it exits.
+type Manager struct {
+	mutex    sync.Mutex
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	stopped  bool
+}
+
+// NewManager returns a new job manager.
+//
+// Returns:
+//   - *Manager: A pointer to the new job manager.
+func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:      ctx,
+		cancel:   cancel,
+		stopping: make(chan struct{}),
+	}
+}
+
+// Go runs fn in the background. The context passed to fn is only cancelled if
+// the manager is shut down and the job does not finish in time, so jobs that
+// are already running are allowed to complete.
+//
+// Parameters:
+//   - name: A short description of the job, used in log messages.
+//   - fn:   The job to run.
+//
+// Returns:
+//   - error: An error if the manager is shutting down.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stopped {
+		return fmt.Errorf("job %s not started: shutting down", name)
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		start := time.Now()
+		slog.Debug("job started", slog.String("job", name))
+		fn(m.ctx)
+		slog.Debug("job finished",
+			slog.String("job", name),
+			slog.Duration("duration", time.Since(start)))
+	}()
+	return nil
+}
+
+// Schedule controls a job started with Every.
+type Schedule struct {
+	ticker *time.Ticker
+}
+
+// Reset changes the interval of the job. The next run happens one full new
+// interval from now.
+//
+// Parameters:
+//   - interval: The new time between two runs.
+func (s *Schedule) Reset(interval time.Duration) {
+	s.ticker.Reset(interval)
+}
+
+// Every runs fn in the background once per interval until the manager is shut
+// down. A run that is in progress when the shutdown starts is drained like any
+// other job.
+//
+// Parameters:
+//   - name:     A short description of the job, used in log messages.
+//   - interval: The time between two runs.
+//   - fn:       The job to run.
+//
+// Returns:
+//   - *Schedule: The schedule of the job, to change its interval.
+//   - error:     An error if the manager is shutting down.
+func (m *Manager) Every(name string, interval time.Duration, fn func(ctx context.Context)) (*Schedule, error) {
+	ticker := time.NewTicker(interval)
+	err := m.Go(name, func(ctx context.Context) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopping:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	})
+	if err != nil {
+		ticker.Stop()
+		return nil, err
+	}
+	return &Schedule{ticker: ticker}, nil
+}
+
+// Shutdown stops accepting new jobs and waits for running jobs to finish. If
+// the given context expires first, the jobs' context is cancelled and the
+// context's error is returned.
+//
+// Parameters:
+//   - ctx: The context that limits how long to wait for running jobs.
+//
+// Returns:
+//   - error: The context's error if the jobs did not finish in time, otherwise nil.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mutex.Lock()
+	if !m.stopped {
+		m.stopped = true
+		close(m.stopping)
+	}
+	m.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		m.cancel()
+		return nil
+	case <-ctx.Done():
+		m.cancel()
+		return ctx.Err()
+	}
+}
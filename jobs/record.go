@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"go.etcd.io/bbolt"
+)
+
+// Reporter records the outcome of a recorded job per target. It is safe for
+// concurrent use.
+type Reporter struct {
+	db     *bbolt.DB
+	id     string
+	mutex  sync.Mutex
+	failed int
+}
+
+// Start records a job and runs it in the background, so that its progress
+// can be followed under Jobs. The job fails if run returns an error or
+// reports a failed target; the message returned by run is recorded either
+// way.
+//
+// Parameters:
+//   - db:       A pointer to the bbolt.DB instance.
+//   - jm:       The job manager to run the job with.
+//   - kind:     A short description of the job, e.g. "Rotate passwords".
+//   - username: The user who started the job.
+//   - targets:  The devices the job acts on.
+//   - run:      The job, which reports the outcome of each target.
+//
+// Returns:
+//   - *models.Job: The recorded job.
+//   - error:       An error if the job cannot be recorded or started.
+func Start(db *bbolt.DB, jm *Manager, kind, username string, targets []string,
+	run func(ctx context.Context, report *Reporter) (string, error)) (*models.Job, error) {
+	job := models.Job{
+		ID:        newID(),
+		Kind:      kind,
+		Username:  username,
+		Targets:   targets,
+		Status:    models.JobPending,
+		Results:   make(map[string]string),
+		CreatedAt: time.Now(),
+	}
+	repo := database.Jobs(db)
+	if err := repo.Put(job); err != nil {
+		return nil, err
+	}
+
+	report := &Reporter{db: db, id: job.ID}
+	err := jm.Go(kind, func(ctx context.Context) {
+		report.update(func(j *models.Job) {
+			j.Status = models.JobRunning
+			j.StartedAt = time.Now()
+		})
+
+		message, err := run(ctx, report)
+		report.update(func(j *models.Job) {
+			j.Status = models.JobSucceeded
+			j.Message = message
+			switch {
+			case err != nil:
+				j.Status = models.JobFailed
+				j.Message = err.Error()
+			case ctx.Err() != nil:
+				j.Status = models.JobCancelled
+			case report.failed > 0:
+				j.Status = models.JobFailed
+			}
+			j.FinishedAt = time.Now()
+		})
+	})
+	if err != nil {
+		report.update(func(j *models.Job) {
+			j.Status = models.JobFailed
+			j.Message = err.Error()
+			j.FinishedAt = time.Now()
+		})
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Succeeded records the successful outcome of a target.
+func (r *Reporter) Succeeded(target, format string, args ...any) {
+	r.update(func(j *models.Job) { j.Results[target] = fmt.Sprintf(format, args...) })
+}
+
+// Failed records the failed outcome of a target.
+func (r *Reporter) Failed(target, format string, args ...any) {
+	r.mutex.Lock()
+	r.failed++
+	r.mutex.Unlock()
+	r.update(func(j *models.Job) { j.Results[target] = "Failed: " + fmt.Sprintf(format, args...) })
+}
+
+func (r *Reporter) update(fn func(*models.Job)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	err := database.Jobs(r.db).Modify(r.id, func(j *models.Job) error {
+		if j.Results == nil {
+			j.Results = make(map[string]string)
+		}
+		fn(j)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to record job progress", slog.String("job", r.id), slog.Any("error", err))
+	}
+}
+
+// newID returns a random, URL-safe job ID.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
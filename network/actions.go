@@ -0,0 +1,395 @@
+package network
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// ActionRule triggers an action when an event occurs on the device, provided
+// that every condition holds at the time.
+type ActionRule struct {
+	ID                string           `xml:"RuleID"`
+	Name              string           `xml:"Name"`
+	Enabled           bool             `xml:"Enabled"`
+	StartEvent        *EventCondition  `xml:"StartEvent"`
+	Conditions        []EventCondition `xml:"Conditions>Condition"`
+	ActivationTimeout string           `xml:"ActivationTimeout"` // An xsd:duration, such as "PT30S"
+	PrimaryAction     string           `xml:"PrimaryAction"`     // The ID of an action configuration
+	FailoverAction    string           `xml:"FailoverAction"`
+}
+
+// EventCondition selects events by topic, such as
+// "tns1:VideoSource/tnsaxis:Tampering", and optionally by the items of their
+// message, such as `boolean(//SimpleItem[@Name="port" and @Value="1"])`.
+type EventCondition struct {
+	Topic          string `xml:"TopicExpression"`
+	MessageContent string `xml:"MessageContent"`
+}
+
+// ActionConfiguration is an action, such as sending an HTTP notification,
+// made from one of the action templates of the device.
+type ActionConfiguration struct {
+	ID         string            `xml:"ConfigurationID"`
+	Name       string            `xml:"Name"`
+	Template   string            `xml:"TemplateToken"`
+	Parameters []ActionParameter `xml:"Parameters>Parameter"`
+}
+
+// RecipientConfiguration is a recipient of notifications, such as an HTTP
+// server or an email address, made from one of the recipient templates of the
+// device.
+type RecipientConfiguration ActionConfiguration
+
+// ActionParameter is a parameter of an action or recipient configuration.
+type ActionParameter struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+// ActionTemplate describes the parameters of an action or recipient template.
+type ActionTemplate struct {
+	Token      string              `xml:"TemplateToken"`
+	Parameters []TemplateParameter `xml:"Parameters>Parameter"`
+}
+
+// TemplateParameter is a parameter of an action or recipient template.
+type TemplateParameter struct {
+	Name string `xml:"Name,attr"`
+	Type string `xml:"Type,attr"`
+}
+
+// ActionRuleSet is everything the action rules of a device consist of.
+type ActionRuleSet struct {
+	Rules      []ActionRule
+	Actions    []ActionConfiguration
+	Recipients []RecipientConfiguration
+}
+
+// Action returns the action configuration with the given ID, or nil if there
+// is none.
+func (s ActionRuleSet) Action(id string) *ActionConfiguration {
+	for i := range s.Actions {
+		if s.Actions[i].ID == id {
+			return &s.Actions[i]
+		}
+	}
+	return nil
+}
+
+// wireTopic is a topic expression or message content filter in a request.
+type wireTopic struct {
+	Dialect string `xml:"Dialect,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// wireCondition is an EventCondition in a request.
+type wireCondition struct {
+	Topic          wireTopic  `xml:"http://docs.oasis-open.org/wsn/b-2 TopicExpression"`
+	MessageContent *wireTopic `xml:"http://docs.oasis-open.org/wsn/b-2 MessageContent,omitempty"`
+}
+
+// wireParameters are the parameters of a configuration in a request.
+type wireParameters struct {
+	Parameters []ActionParameter `xml:"Parameter"`
+}
+
+// wireConfiguration is an action or recipient configuration in a request.
+type wireConfiguration struct {
+	Name       string         `xml:"Name"`
+	Template   string         `xml:"TemplateToken"`
+	Parameters wireParameters `xml:"Parameters"`
+}
+
+func newWireCondition(condition EventCondition) wireCondition {
+	wire := wireCondition{Topic: wireTopic{Dialect: concreteDialect, Value: condition.Topic}}
+	if condition.MessageContent != "" {
+		wire.MessageContent = &wireTopic{Dialect: itemFilterDialect, Value: condition.MessageContent}
+	}
+	return wire
+}
+
+// ReadActionRuleSet reads the action rules, action configurations, and
+// recipient configurations of the device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - *ActionRuleSet: The rules, actions, and recipients.
+//   - error:          An error if the request fails or the device reports an error.
+func ReadActionRuleSet(c *Client) (*ActionRuleSet, error) {
+	var set ActionRuleSet
+	var err error
+	if set.Rules, err = ListActionRules(c); err != nil {
+		return nil, err
+	}
+	if set.Actions, err = ListActionConfigurations(c); err != nil {
+		return nil, err
+	}
+	if set.Recipients, err = ListRecipientConfigurations(c); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ListActionRules reads the action rules of the device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []ActionRule: The action rules.
+//   - error:        An error if the request fails or the device reports an error.
+func ListActionRules(c *Client) ([]ActionRule, error) {
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 GetActionRules"`
+	}{}
+	var response struct {
+		Rules []ActionRule `xml:"ActionRules>ActionRule"`
+	}
+	if err := callSOAP(c, request, &response); err != nil {
+		return nil, fmt.Errorf("get action rules: %w", err)
+	}
+	return response.Rules, nil
+}
+
+// AddActionRule adds an action rule to the device. The ID of the rule is
+// ignored; the device assigns a new one.
+//
+// Parameters:
+//   - c:    The client of the device.
+//   - rule: The rule to add.
+//
+// Returns:
+//   - string: The ID of the new rule.
+//   - error:  An error if the request fails or the device reports an error.
+func AddActionRule(c *Client, rule ActionRule) (string, error) {
+	type newRule struct {
+		Name              string          `xml:"Name"`
+		Enabled           bool            `xml:"Enabled"`
+		StartEvent        *wireCondition  `xml:"StartEvent,omitempty"`
+		Conditions        []wireCondition `xml:"Conditions>Condition,omitempty"`
+		ActivationTimeout string          `xml:"ActivationTimeout,omitempty"`
+		PrimaryAction     string          `xml:"PrimaryAction"`
+		FailoverAction    string          `xml:"FailoverAction,omitempty"`
+	}
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 AddActionRule"`
+		Rule    newRule  `xml:"NewActionRule"`
+	}{Rule: newRule{
+		Name:              rule.Name,
+		Enabled:           rule.Enabled,
+		ActivationTimeout: rule.ActivationTimeout,
+		PrimaryAction:     rule.PrimaryAction,
+		FailoverAction:    rule.FailoverAction,
+	}}
+	if rule.StartEvent != nil {
+		start := newWireCondition(*rule.StartEvent)
+		request.Rule.StartEvent = &start
+	}
+	for _, condition := range rule.Conditions {
+		request.Rule.Conditions = append(request.Rule.Conditions, newWireCondition(condition))
+	}
+
+	var response struct {
+		ID string `xml:"RuleID"`
+	}
+	if err := callSOAP(c, request, &response); err != nil {
+		return "", fmt.Errorf("add action rule %s: %w", rule.Name, err)
+	}
+	return response.ID, nil
+}
+
+// RemoveActionRule removes an action rule from the device.
+//
+// Parameters:
+//   - c:  The client of the device.
+//   - id: The ID of the rule.
+//
+// Returns:
+//   - error: An error if the request fails or the device reports an error.
+func RemoveActionRule(c *Client, id string) error {
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 RemoveActionRule"`
+		ID      string   `xml:"RuleID"`
+	}{ID: id}
+	if err := callSOAP(c, request, nil); err != nil {
+		return fmt.Errorf("remove action rule %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListActionConfigurations reads the action configurations of the device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []ActionConfiguration: The action configurations.
+//   - error:                 An error if the request fails or the device reports an error.
+func ListActionConfigurations(c *Client) ([]ActionConfiguration, error) {
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 GetActionConfigurations"`
+	}{}
+	var response struct {
+		Actions []ActionConfiguration `xml:"ActionConfigurations>ActionConfiguration"`
+	}
+	if err := callSOAP(c, request, &response); err != nil {
+		return nil, fmt.Errorf("get action configurations: %w", err)
+	}
+	return response.Actions, nil
+}
+
+// AddActionConfiguration adds an action configuration to the device. The ID
+// of the configuration is ignored; the device assigns a new one.
+//
+// Parameters:
+//   - c:      The client of the device.
+//   - action: The action configuration to add.
+//
+// Returns:
+//   - string: The ID of the new configuration.
+//   - error:  An error if the request fails or the device reports an error.
+func AddActionConfiguration(c *Client, action ActionConfiguration) (string, error) {
+	request := struct {
+		XMLName       xml.Name          `xml:"http://www.axis.com/vapix/ws/action1 AddActionConfiguration"`
+		Configuration wireConfiguration `xml:"NewActionConfiguration"`
+	}{Configuration: wireConfiguration{Name: action.Name, Template: action.Template, Parameters: wireParameters{action.Parameters}}}
+	var response struct {
+		ID string `xml:"ConfigurationID"`
+	}
+	if err := callSOAP(c, request, &response); err != nil {
+		return "", fmt.Errorf("add action %s: %w", action.Name, err)
+	}
+	return response.ID, nil
+}
+
+// RemoveActionConfiguration removes an action configuration from the device.
+// Configurations used by an action rule cannot be removed.
+//
+// Parameters:
+//   - c:  The client of the device.
+//   - id: The ID of the configuration.
+//
+// Returns:
+//   - error: An error if the request fails or the device reports an error.
+func RemoveActionConfiguration(c *Client, id string) error {
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 RemoveActionConfiguration"`
+		ID      string   `xml:"ConfigurationID"`
+	}{ID: id}
+	if err := callSOAP(c, request, nil); err != nil {
+		return fmt.Errorf("remove action %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListRecipientConfigurations reads the recipient configurations of the
+// device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []RecipientConfiguration: The recipient configurations.
+//   - error:                    An error if the request fails or the device reports an error.
+func ListRecipientConfigurations(c *Client) ([]RecipientConfiguration, error) {
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 GetRecipientConfigurations"`
+	}{}
+	var response struct {
+		Recipients []RecipientConfiguration `xml:"RecipientConfigurations>RecipientConfiguration"`
+	}
+	if err := callSOAP(c, request, &response); err != nil {
+		return nil, fmt.Errorf("get recipients: %w", err)
+	}
+	return response.Recipients, nil
+}
+
+// AddRecipientConfiguration adds a recipient configuration to the device.
+// The ID of the configuration is ignored; the device assigns a new one.
+//
+// Parameters:
+//   - c:         The client of the device.
+//   - recipient: The recipient configuration to add.
+//
+// Returns:
+//   - string: The ID of the new configuration.
+//   - error:  An error if the request fails or the device reports an error.
+func AddRecipientConfiguration(c *Client, recipient RecipientConfiguration) (string, error) {
+	request := struct {
+		XMLName       xml.Name          `xml:"http://www.axis.com/vapix/ws/action1 AddRecipientConfiguration"`
+		Configuration wireConfiguration `xml:"NewRecipientConfiguration"`
+	}{Configuration: wireConfiguration{Name: recipient.Name, Template: recipient.Template, Parameters: wireParameters{recipient.Parameters}}}
+	var response struct {
+		ID string `xml:"ConfigurationID"`
+	}
+	if err := callSOAP(c, request, &response); err != nil {
+		return "", fmt.Errorf("add recipient %s: %w", recipient.Name, err)
+	}
+	return response.ID, nil
+}
+
+// RemoveRecipientConfiguration removes a recipient configuration from the
+// device.
+//
+// Parameters:
+//   - c:  The client of the device.
+//   - id: The ID of the configuration.
+//
+// Returns:
+//   - error: An error if the request fails or the device reports an error.
+func RemoveRecipientConfiguration(c *Client, id string) error {
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 RemoveRecipientConfiguration"`
+		ID      string   `xml:"ConfigurationID"`
+	}{ID: id}
+	if err := callSOAP(c, request, nil); err != nil {
+		return fmt.Errorf("remove recipient %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListActionTemplates reads the templates that action configurations can be
+// made from.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []ActionTemplate: The action templates.
+//   - error:            An error if the request fails or the device reports an error.
+func ListActionTemplates(c *Client) ([]ActionTemplate, error) {
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 GetActionTemplates"`
+	}{}
+	var response struct {
+		Templates []ActionTemplate `xml:"ActionTemplates>ActionTemplate"`
+	}
+	if err := callSOAP(c, request, &response); err != nil {
+		return nil, fmt.Errorf("get action templates: %w", err)
+	}
+	return response.Templates, nil
+}
+
+// ListRecipientTemplates reads the templates that recipient configurations
+// can be made from.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []ActionTemplate: The recipient templates.
+//   - error:            An error if the request fails or the device reports an error.
+func ListRecipientTemplates(c *Client) ([]ActionTemplate, error) {
+	request := struct {
+		XMLName xml.Name `xml:"http://www.axis.com/vapix/ws/action1 GetRecipientTemplates"`
+	}{}
+	var response struct {
+		Templates []ActionTemplate `xml:"RecipientTemplates>RecipientTemplate"`
+	}
+	if err := callSOAP(c, request, &response); err != nil {
+		return nil, fmt.Errorf("get recipient templates: %w", err)
+	}
+	return response.Templates, nil
+}
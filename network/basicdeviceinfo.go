@@ -0,0 +1,114 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// VAPIX endpoint for basic device information
+	basicDeviceInfoPath = "/axis-cgi/basicdeviceinfo.cgi"
+)
+
+// BasicDeviceInfo holds the properties returned by the Basic device
+// information API.
+type BasicDeviceInfo struct {
+	Architecture    string `json:"Architecture"`
+	Brand           string `json:"Brand"`
+	BuildDate       string `json:"BuildDate"`
+	HardwareID      string `json:"HardwareID"`
+	ProdFullName    string `json:"ProdFullName"`
+	ProdNbr         string `json:"ProdNbr"`
+	ProdShortName   string `json:"ProdShortName"`
+	ProdType        string `json:"ProdType"`
+	ProdVariant     string `json:"ProdVariant"`
+	SerialNumber    string `json:"SerialNumber"`
+	Soc             string `json:"Soc"`
+	SocSerialNumber string `json:"SocSerialNumber"`
+	Version         string `json:"Version"`
+	WebURL          string `json:"WebURL"`
+}
+
+// apiError is the error object of the VAPIX JSON APIs.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("error %d: %s", e.Code, e.Message)
+}
+
+// GetBasicDeviceInfo reads the serial number, model, firmware version, and
+// other basic properties of the device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - *BasicDeviceInfo: The properties of the device.
+//   - error:            An error if the request fails or the device reports an error.
+func GetBasicDeviceInfo(c *Client) (*BasicDeviceInfo, error) {
+	var response struct {
+		Data struct {
+			PropertyList BasicDeviceInfo `json:"propertyList"`
+		} `json:"data"`
+		Error *apiError `json:"error"`
+	}
+
+	request := map[string]any{
+		"apiVersion": "1.0",
+		"method":     "getAllProperties",
+	}
+	if err := postJSON(c, basicDeviceInfoPath, request, &response); err != nil {
+		return nil, fmt.Errorf("get basic device info: %w", err)
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("get basic device info: %w", response.Error)
+	}
+
+	return &response.Data.PropertyList, nil
+}
+
+// callJSONAPI calls a method of a VAPIX JSON API at the given path and decodes
+// the data of the response into data, unless it is nil.
+func callJSONAPI(c *Client, path, method string, params map[string]any, data any) error {
+	request := map[string]any{
+		"apiVersion": "1.0",
+		"method":     method,
+	}
+	if params != nil {
+		request["params"] = params
+	}
+
+	response := struct {
+		Data  any       `json:"data"`
+		Error *apiError `json:"error"`
+	}{Data: data}
+	if err := postJSON(c, path, request, &response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	return nil
+}
+
+// postJSON posts the request as JSON to the given path and decodes the JSON
+// response into response.
+func postJSON(c *Client, path string, request any, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	data, err := c.Post(path, body, "application/json")
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("unmarshal response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/furkansuleymana/neba/pki"
+)
+
+const (
+	// VAPIX endpoints for certificate management
+	certificatesPath   = "/config/rest/cert/v1/certificates"
+	caCertificatesPath = "/config/rest/cert/v1/ca_certificates"
+	csrPath            = "/config/rest/cert/v1/create_csr"
+	httpsPath          = "/config/rest/web-server/v1/https"
+)
+
+// DeviceCertificate is a certificate installed on a device. The fields other
+// than ID are read from the certificate itself, so they do not depend on how
+// the device describes it.
+type DeviceCertificate struct {
+	ID          string    `json:"id"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	AuthorityID string    `json:"authority_key_id,omitempty"` // Hex encoded authority key identifier
+	DNSNames    []string  `json:"dns_names,omitempty"`
+	IPAddresses []string  `json:"ip_addresses,omitempty"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	SelfSigned  bool      `json:"self_signed"`
+}
+
+// CSRRequest describes a certificate signing request for the device to
+// generate. The device creates a new private key with the given ID, which
+// the signed certificate is installed under later.
+type CSRRequest struct {
+	ID          string
+	CommonName  string
+	DNSNames    []string
+	IPAddresses []string
+}
+
+// ListCertificates reads the client and server certificates of the device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []DeviceCertificate: The installed certificates.
+//   - error:               An error if the request fails or a certificate cannot be parsed.
+func ListCertificates(c *Client) ([]DeviceCertificate, error) {
+	certificates, err := listCertificates(c, certificatesPath)
+	if err != nil {
+		return nil, fmt.Errorf("list certificates: %w", err)
+	}
+	return certificates, nil
+}
+
+// ListCACertificates reads the CA certificates the device trusts.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []DeviceCertificate: The installed CA certificates.
+//   - error:               An error if the request fails or a certificate cannot be parsed.
+func ListCACertificates(c *Client) ([]DeviceCertificate, error) {
+	certificates, err := listCertificates(c, caCertificatesPath)
+	if err != nil {
+		return nil, fmt.Errorf("list CA certificates: %w", err)
+	}
+	return certificates, nil
+}
+
+// CreateCSR has the device generate a private key and a certificate signing
+// request for it. The key never leaves the device.
+//
+// Parameters:
+//   - c:       The client of the device.
+//   - request: The key ID, subject, and alternative names of the request.
+//
+// Returns:
+//   - string: The PEM encoded certificate signing request.
+//   - error:  An error if the request fails or the device rejects it.
+func CreateCSR(c *Client, request CSRRequest) (string, error) {
+	var response struct {
+		CSR string `json:"csr"`
+	}
+	err := callREST(c, http.MethodPost, csrPath, map[string]any{
+		"key_id":            request.ID,
+		"subject":           "CN=" + request.CommonName,
+		"subject_alt_names": nonNil(slices.Concat(request.DNSNames, request.IPAddresses)),
+	}, &response)
+	if err != nil {
+		return "", fmt.Errorf("create CSR: %w", err)
+	}
+	return response.CSR, nil
+}
+
+// InstallCertificate installs a signed certificate for the private key with
+// the same ID, as created by CreateCSR. An existing certificate with the ID
+// is replaced.
+//
+// Parameters:
+//   - c:           The client of the device.
+//   - id:          The ID of the key and certificate.
+//   - certificate: The PEM encoded certificate, optionally followed by its chain.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the certificate.
+func InstallCertificate(c *Client, id, certificate string) error {
+	err := callREST(c, http.MethodPost, certificatesPath, map[string]any{
+		"id":          id,
+		"key_id":      id,
+		"certificate": certificate,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("install certificate %s: %w", id, err)
+	}
+	return nil
+}
+
+// InstallCACertificate adds a CA certificate to the certificates the device
+// trusts.
+//
+// Parameters:
+//   - c:           The client of the device.
+//   - id:          The ID of the CA certificate.
+//   - certificate: The PEM encoded CA certificate.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the certificate.
+func InstallCACertificate(c *Client, id, certificate string) error {
+	err := callREST(c, http.MethodPost, caCertificatesPath, map[string]any{
+		"id":          id,
+		"certificate": certificate,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("install CA certificate %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteCertificate removes a certificate and its private key from the
+// device.
+//
+// Parameters:
+//   - c:  The client of the device.
+//   - id: The ID of the certificate.
+//
+// Returns:
+//   - error: An error if the request fails or the device refuses, for
+//     example because the certificate is in use.
+func DeleteCertificate(c *Client, id string) error {
+	if err := callREST(c, http.MethodDelete, certificatesPath+"/"+url.PathEscape(id), nil, nil); err != nil {
+		return fmt.Errorf("delete certificate %s: %w", id, err)
+	}
+	return nil
+}
+
+// HTTPSCertificate returns the ID of the certificate the web server of the
+// device uses for HTTPS.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - string: The ID of the certificate.
+//   - error:  An error if the request fails.
+func HTTPSCertificate(c *Client) (string, error) {
+	var response struct {
+		Certificate string `json:"certificate"`
+	}
+	if err := callREST(c, http.MethodGet, httpsPath, nil, &response); err != nil {
+		return "", fmt.Errorf("get HTTPS certificate: %w", err)
+	}
+	return response.Certificate, nil
+}
+
+// BindHTTPSCertificate makes the web server of the device use the given
+// certificate for HTTPS. The device may drop HTTPS connections while it
+// switches certificates.
+//
+// Parameters:
+//   - c:  The client of the device.
+//   - id: The ID of the certificate.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the certificate.
+func BindHTTPSCertificate(c *Client, id string) error {
+	if err := callREST(c, http.MethodPatch, httpsPath, map[string]any{"certificate": id}, nil); err != nil {
+		return fmt.Errorf("use certificate %s for HTTPS: %w", id, err)
+	}
+	return nil
+}
+
+// listCertificates reads the certificates at the given path and parses them.
+func listCertificates(c *Client, path string) ([]DeviceCertificate, error) {
+	var response []struct {
+		ID          string `json:"id"`
+		Certificate string `json:"certificate"`
+	}
+	if err := callREST(c, http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+
+	certificates := make([]DeviceCertificate, 0, len(response))
+	for _, item := range response {
+		cert, err := pki.ParseCertificatePEM([]byte(item.Certificate))
+		if err != nil {
+			return nil, fmt.Errorf("certificate %s: %w", item.ID, err)
+		}
+		certificate := DeviceCertificate{
+			ID:          item.ID,
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			AuthorityID: hex.EncodeToString(cert.AuthorityKeyId),
+			DNSNames:    cert.DNSNames,
+			NotBefore:   cert.NotBefore,
+			NotAfter:    cert.NotAfter,
+			SelfSigned:  bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil,
+		}
+		for _, ip := range cert.IPAddresses {
+			certificate.IPAddresses = append(certificate.IPAddresses, ip.String())
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// callREST calls a VAPIX REST configuration API. The request data, unless
+// nil, is sent wrapped in a "data" object, and the data of the response is
+// decoded into data, unless it is nil.
+func callREST(c *Client, method, path string, request any, data any) error {
+	var body []byte
+	if request != nil {
+		var err error
+		if body, err = json.Marshal(map[string]any{"data": request}); err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+	}
+
+	resp, err := c.Do(method, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	response := struct {
+		Status string    `json:"status"`
+		Data   any       `json:"data"`
+		Error  *apiError `json:"error"`
+	}{Data: data}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s %s: status code %d", method, path, resp.StatusCode)
+		}
+		return fmt.Errorf("unmarshal response: %w", err)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if resp.StatusCode >= 300 || response.Status == "error" {
+		return fmt.Errorf("%s %s: status code %d", method, path, resp.StatusCode)
+	}
+	return nil
+}
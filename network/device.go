@@ -0,0 +1,66 @@
+package network
+
+import (
+	"fmt"
+)
+
+const (
+	// VAPIX endpoints for device maintenance
+	restartPath            = "/axis-cgi/restart.cgi"
+	factoryDefaultPath     = "/axis-cgi/factorydefault.cgi"
+	hardFactoryDefaultPath = "/axis-cgi/hardfactorydefault.cgi"
+	serverReportPath       = "/axis-cgi/serverreport.cgi?mode=zip_with_image"
+)
+
+// Restart asks the device to restart. The device answers before it goes down,
+// so a nil error only means that the restart has been accepted.
+//
+// Parameters:
+//   - c: The client of the device to restart.
+//
+// Returns:
+//   - error: An error if the request fails.
+func Restart(c *Client) error {
+	if _, err := c.Get(restartPath); err != nil {
+		return fmt.Errorf("restart device: %w", err)
+	}
+	return nil
+}
+
+// FactoryDefault resets the device to its factory default settings. A soft
+// reset keeps the network settings, so the device stays reachable; a hard
+// reset restores everything, including the IP address and the root password.
+//
+// Parameters:
+//   - c:    The client of the device to reset.
+//   - hard: Whether to perform a hard factory default.
+//
+// Returns:
+//   - error: An error if the request fails.
+func FactoryDefault(c *Client, hard bool) error {
+	path := factoryDefaultPath
+	if hard {
+		path = hardFactoryDefaultPath
+	}
+	if _, err := c.Get(path); err != nil {
+		return fmt.Errorf("factory default device: %w", err)
+	}
+	return nil
+}
+
+// ServerReport downloads the server report of the device as a zip archive,
+// including a snapshot image.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []byte: The zip archive.
+//   - error:  An error if the request fails.
+func ServerReport(c *Client) ([]byte, error) {
+	report, err := c.Get(serverReportPath)
+	if err != nil {
+		return nil, fmt.Errorf("download server report: %w", err)
+	}
+	return report, nil
+}
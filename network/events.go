@@ -0,0 +1,213 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// VAPIX endpoint for event streams
+	eventStreamPath = "/vapix/ws-data-stream?sources=events"
+
+	// eventPingInterval is how often an idle event stream is checked with a
+	// ping. A stream that has not heard from the device for three intervals
+	// is considered dead.
+	eventPingInterval = 30 * time.Second
+)
+
+// EventNotification is an event reported by a device: its topic, such as
+// "tns1:Device/tnsaxis:IO/Port", and the source, key, and data items of its
+// message.
+type EventNotification struct {
+	Topic  string
+	Time   time.Time // When the device emitted the event, by its own clock
+	Source map[string]string
+	Key    map[string]string
+	Data   map[string]string
+}
+
+// EventStream is an open subscription to the events of a device over the
+// VAPIX event WebSocket.
+type EventStream struct {
+	ws       *webSocket
+	lastRead atomic.Int64 // Unix time in nanoseconds
+	stop     func() bool
+	done     chan struct{}
+	once     sync.Once
+}
+
+// eventMessage is a message of the event WebSocket.
+type eventMessage struct {
+	Method string `json:"method"`
+	Params struct {
+		Notification struct {
+			Topic     string `json:"topic"`
+			Timestamp int64  `json:"timestamp"` // Milliseconds since the epoch
+			Message   struct {
+				Source map[string]any `json:"source"`
+				Key    map[string]any `json:"key"`
+				Data   map[string]any `json:"data"`
+			} `json:"message"`
+		} `json:"notification"`
+	} `json:"params"`
+	Error *apiError `json:"error"`
+}
+
+// OpenEventStream subscribes to the events of the device whose topics match
+// the given filters. A filter ending in "//." also matches every topic below
+// it. The stream runs until ctx is done, the device closes it, or the device
+// stops answering pings.
+//
+// Parameters:
+//   - ctx:          The context that ends the stream when done.
+//   - c:            The client of the device.
+//   - topicFilters: The topics to subscribe to.
+//
+// Returns:
+//   - *EventStream: The open stream; the caller must close it.
+//   - error:        An error if the device does not accept the subscription.
+func OpenEventStream(ctx context.Context, c *Client, topicFilters []string) (*EventStream, error) {
+	ws, err := dialWebSocket(ctx, c, eventStreamPath)
+	if err != nil {
+		return nil, fmt.Errorf("open event stream: %w", err)
+	}
+
+	filters := make([]map[string]string, len(topicFilters))
+	for i, filter := range topicFilters {
+		filters[i] = map[string]string{"topicFilter": filter}
+	}
+	request, err := json.Marshal(map[string]any{
+		"apiVersion": "1.0",
+		"method":     "events:configure",
+		"params":     map[string]any{"eventFilterList": filters},
+	})
+	if err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("marshal event subscription: %w", err)
+	}
+	if err := ws.WriteText(request); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("subscribe to events: %w", err)
+	}
+
+	// Wait for the device to confirm the subscription.
+	timeout := time.AfterFunc(VAPIXTimeout, func() { ws.Close() })
+	defer timeout.Stop()
+	for {
+		data, err := ws.ReadMessage()
+		if err != nil {
+			ws.Close()
+			return nil, fmt.Errorf("subscribe to events: %w", err)
+		}
+		var message eventMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			ws.Close()
+			return nil, fmt.Errorf("unmarshal event subscription response: %w", err)
+		}
+		if message.Error != nil {
+			ws.Close()
+			return nil, fmt.Errorf("subscribe to events: %w", message.Error)
+		}
+		if message.Method == "events:configure" {
+			break
+		}
+	}
+
+	stream := &EventStream{ws: ws, done: make(chan struct{})}
+	stream.lastRead.Store(time.Now().UnixNano())
+	stream.stop = context.AfterFunc(ctx, func() { ws.Close() })
+	go stream.keepAlive()
+	return stream, nil
+}
+
+// keepAlive pings the device while the stream is idle and closes the stream
+// once the device has been silent for too long.
+func (s *EventStream) keepAlive() {
+	ticker := time.NewTicker(eventPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, s.lastRead.Load())) > 3*eventPingInterval {
+				s.ws.Close()
+				return
+			}
+			s.ws.Ping()
+		}
+	}
+}
+
+// Next waits for the next event of the device.
+//
+// Returns:
+//   - EventNotification: The event.
+//   - error:             An error if the stream has ended.
+func (s *EventStream) Next() (EventNotification, error) {
+	for {
+		data, err := s.ws.ReadMessage()
+		if err != nil {
+			return EventNotification{}, fmt.Errorf("read event stream: %w", err)
+		}
+		s.lastRead.Store(time.Now().UnixNano())
+
+		var message eventMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			return EventNotification{}, fmt.Errorf("unmarshal event: %w", err)
+		}
+		if message.Error != nil {
+			return EventNotification{}, fmt.Errorf("read event stream: %w", message.Error)
+		}
+		if message.Method != "events:notify" {
+			continue
+		}
+
+		notification := message.Params.Notification
+		event := EventNotification{
+			Topic:  notification.Topic,
+			Time:   time.UnixMilli(notification.Timestamp),
+			Source: eventItems(notification.Message.Source),
+			Key:    eventItems(notification.Message.Key),
+			Data:   eventItems(notification.Message.Data),
+		}
+		if notification.Timestamp == 0 {
+			event.Time = time.Now()
+		}
+		return event, nil
+	}
+}
+
+// Close ends the subscription.
+func (s *EventStream) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		s.stop()
+	})
+	return s.ws.Close()
+}
+
+// eventItems converts the items of an event message to strings. Devices send
+// most values as strings, but some as numbers or booleans.
+func eventItems(items map[string]any) map[string]string {
+	if len(items) == 0 {
+		return nil
+	}
+	converted := make(map[string]string, len(items))
+	for name, value := range items {
+		switch value := value.(type) {
+		case string:
+			converted[name] = value
+		case nil:
+			converted[name] = ""
+		default:
+			encoded, _ := json.Marshal(value)
+			converted[name] = string(encoded)
+		}
+	}
+	return converted
+}
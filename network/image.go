@@ -0,0 +1,53 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	// VAPIX endpoint for JPEG snapshots
+	imagePath = "/axis-cgi/jpg/image.cgi"
+)
+
+// SnapshotOptions select the resolution and compression of a snapshot.
+// Empty values leave the choice to the device.
+type SnapshotOptions struct {
+	Resolution  string // Such as "320x180"
+	Compression int    // JPEG compression from 0 to 100; negative for the default
+}
+
+// Snapshot fetches a JPEG snapshot of the current view of the device.
+//
+// Parameters:
+//   - c:       The client of the device.
+//   - options: The resolution and compression of the snapshot.
+//
+// Returns:
+//   - []byte: The JPEG image.
+//   - error:  An error if the request fails or the device does not return a
+//     JPEG image, for example because it has no camera.
+func Snapshot(c *Client, options SnapshotOptions) ([]byte, error) {
+	query := url.Values{}
+	if options.Resolution != "" {
+		query.Set("resolution", options.Resolution)
+	}
+	if options.Compression >= 0 {
+		query.Set("compression", strconv.Itoa(options.Compression))
+	}
+
+	path := imagePath
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	image, err := c.Get(path)
+	if err != nil {
+		return nil, fmt.Errorf("fetch snapshot: %w", err)
+	}
+	if !bytes.HasPrefix(image, []byte{0xFF, 0xD8}) {
+		return nil, fmt.Errorf("fetch snapshot: the device did not return a JPEG image")
+	}
+	return image, nil
+}
@@ -0,0 +1,191 @@
+package network
+
+import (
+	"fmt"
+)
+
+const (
+	// VAPIX endpoint for network settings
+	networkSettingsPath = "/axis-cgi/network_settings.cgi"
+
+	// IPv4 configuration modes
+	ConfigDHCP   = "dhcp"
+	ConfigStatic = "static"
+
+	// Link-local address modes
+	LinkLocalOn  = "on"
+	LinkLocalOff = "off"
+)
+
+// NetworkInfo holds the network configuration of a device as reported by the
+// Network settings API. Fields prefixed with Static hold the configured
+// values, the others the values in use, which may come from DHCP.
+type NetworkInfo struct {
+	System  NetworkSystem   `json:"system"`
+	Devices []NetworkDevice `json:"devices"`
+}
+
+// NetworkSystem holds the settings that apply to every network interface.
+type NetworkSystem struct {
+	Hostname HostnameConfig `json:"hostname"`
+	Resolver ResolverConfig `json:"resolver"`
+}
+
+// HostnameConfig is the hostname configuration of a device.
+type HostnameConfig struct {
+	UseDHCPHostname bool   `json:"useDhcpHostname"`
+	Hostname        string `json:"hostname"`
+	StaticHostname  string `json:"staticHostname"`
+}
+
+// ResolverConfig is the DNS configuration of a device.
+type ResolverConfig struct {
+	UseDHCPResolverInfo bool     `json:"useDhcpResolverInfo"`
+	NameServers         []string `json:"nameServers"`
+	StaticNameServers   []string `json:"staticNameServers"`
+	SearchDomains       []string `json:"searchDomains"`
+	StaticSearchDomains []string `json:"staticSearchDomains"`
+	DomainName          string   `json:"domainName"`
+	StaticDomainName    string   `json:"staticDomainName"`
+}
+
+// NetworkDevice is a network interface of a device, such as eth0.
+type NetworkDevice struct {
+	Name       string     `json:"name"`
+	MACAddress string     `json:"macAddress"`
+	IPv4       IPv4Config `json:"IPv4"`
+}
+
+// IPv4Config is the IPv4 configuration of a network interface.
+type IPv4Config struct {
+	Enabled                     bool          `json:"enabled"`
+	ConfigurationMode           string        `json:"configurationMode"`
+	LinkLocalMode               string        `json:"linkLocalMode"`
+	DefaultRouter               string        `json:"defaultRouter"`
+	StaticDefaultRouter         string        `json:"staticDefaultRouter"`
+	Addresses                   []IPv4Address `json:"addresses"`
+	StaticAddressConfigurations []IPv4Address `json:"staticAddressConfigurations"`
+}
+
+// IPv4Address is an IPv4 address with its prefix length.
+type IPv4Address struct {
+	Address      string `json:"address"`
+	PrefixLength int    `json:"prefixLength"`
+	Origin       string `json:"origin,omitempty"` // How the address was assigned, e.g. "dhcp"
+}
+
+// String returns the address in CIDR notation.
+func (a IPv4Address) String() string {
+	return fmt.Sprintf("%s/%d", a.Address, a.PrefixLength)
+}
+
+// IPv4Settings are the IPv4 settings to apply to a network interface.
+type IPv4Settings struct {
+	Mode          string      // ConfigDHCP or ConfigStatic
+	Address       IPv4Address // The static address, ignored with DHCP
+	DefaultRouter string      // The static default router, ignored with DHCP
+	LinkLocal     string      // LinkLocalOn or LinkLocalOff
+}
+
+// GetNetworkInfo reads the network configuration of the device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - *NetworkInfo: The network configuration.
+//   - error:        An error if the request fails or the device reports an error.
+func GetNetworkInfo(c *Client) (*NetworkInfo, error) {
+	var info NetworkInfo
+	if err := callNetworkSettings(c, "getNetworkInfo", nil, &info); err != nil {
+		return nil, fmt.Errorf("get network info: %w", err)
+	}
+	return &info, nil
+}
+
+// SetHostname configures the hostname of the device.
+//
+// Parameters:
+//   - c:        The client of the device.
+//   - useDHCP:  Whether to use the hostname assigned by DHCP.
+//   - hostname: The static hostname, used if DHCP assigns none.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the setting.
+func SetHostname(c *Client, useDHCP bool, hostname string) error {
+	params := map[string]any{
+		"useDhcpHostname": useDHCP,
+		"staticHostname":  hostname,
+	}
+	if err := callNetworkSettings(c, "setHostnameConfiguration", params, nil); err != nil {
+		return fmt.Errorf("set hostname: %w", err)
+	}
+	return nil
+}
+
+// SetResolver configures the DNS servers and domains of the device.
+//
+// Parameters:
+//   - c:             The client of the device.
+//   - useDHCP:       Whether to use the DNS settings assigned by DHCP.
+//   - nameServers:   The static DNS servers.
+//   - searchDomains: The static search domains.
+//   - domainName:    The static domain name.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the setting.
+func SetResolver(c *Client, useDHCP bool, nameServers, searchDomains []string, domainName string) error {
+	params := map[string]any{
+		"useDhcpResolverInfo": useDHCP,
+		"staticNameServers":   nonNil(nameServers),
+		"staticSearchDomains": nonNil(searchDomains),
+		"staticDomainName":    domainName,
+	}
+	if err := callNetworkSettings(c, "setResolverConfiguration", params, nil); err != nil {
+		return fmt.Errorf("set DNS configuration: %w", err)
+	}
+	return nil
+}
+
+// SetIPv4 configures the IPv4 address of the given network interface. If the
+// address changes, the device may drop the connection before it answers, so
+// an error does not necessarily mean that the settings were not applied.
+//
+// Parameters:
+//   - c:        The client of the device.
+//   - iface:    The name of the network interface, e.g. "eth0".
+//   - settings: The IPv4 settings to apply.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the settings.
+func SetIPv4(c *Client, iface string, settings IPv4Settings) error {
+	params := map[string]any{
+		"deviceName":        iface,
+		"configurationMode": settings.Mode,
+		"linkLocalMode":     settings.LinkLocal,
+	}
+	if settings.Mode == ConfigStatic {
+		params["staticDefaultRouter"] = settings.DefaultRouter
+		params["staticAddressConfigurations"] = []map[string]any{{
+			"address":      settings.Address.Address,
+			"prefixLength": settings.Address.PrefixLength,
+		}}
+	}
+	if err := callNetworkSettings(c, "setIPv4AddressConfiguration", params, nil); err != nil {
+		return fmt.Errorf("set IPv4 configuration: %w", err)
+	}
+	return nil
+}
+
+// callNetworkSettings calls a method of the Network settings API and decodes
+// the data of the response into data, unless it is nil.
+func callNetworkSettings(c *Client, method string, params map[string]any, data any) error {
+	return callJSONAPI(c, networkSettingsPath, method, params, data)
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
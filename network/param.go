@@ -0,0 +1,93 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// VAPIX endpoint for device parameters
+	paramPath = "/axis-cgi/param.cgi"
+)
+
+// GetParams lists the parameters of the given group, e.g. "Network" or
+// "Properties.Firmware". An empty group lists every parameter. The keys of
+// the returned map are full parameter names such as "root.Brand.ProdNbr".
+//
+// Parameters:
+//   - c:     The client of the device.
+//   - group: The parameter group to list.
+//
+// Returns:
+//   - map[string]string: The parameters and their values.
+//   - error:             An error if the request fails or the device reports an error.
+func GetParams(c *Client, group string) (map[string]string, error) {
+	query := url.Values{"action": {"list"}}
+	if group != "" {
+		query.Set("group", group)
+	}
+
+	data, err := c.Get(paramPath + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("list parameters: %w", err)
+	}
+	if msg, ok := paramError(data); ok {
+		return nil, fmt.Errorf("list parameters: %s", msg)
+	}
+
+	params := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			params[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read parameters: %w", err)
+	}
+
+	return params, nil
+}
+
+// SetParams updates the given parameters on the device in a single request.
+// Parameter names may be given with or without the "root." prefix.
+//
+// Parameters:
+//   - c:      The client of the device.
+//   - params: The parameters and their new values.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the update.
+func SetParams(c *Client, params map[string]string) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	query := url.Values{"action": {"update"}}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+
+	data, err := c.Get(paramPath + "?" + query.Encode())
+	if err != nil {
+		return fmt.Errorf("update parameters: %w", err)
+	}
+	if msg, ok := paramError(data); ok {
+		return fmt.Errorf("update parameters: %s", msg)
+	}
+	return nil
+}
+
+// paramError extracts the error message from a param.cgi response, which
+// reports errors with status 200 and a body starting with "# Error".
+func paramError(data []byte) (string, bool) {
+	text := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(text, "# Error") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(text, "#")), true
+}
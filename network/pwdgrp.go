@@ -0,0 +1,286 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// VAPIX endpoint for device accounts
+	pwdgrpPath = "/axis-cgi/pwdgrp.cgi"
+
+	// InitialAdminGroups are the groups of the first administrator account.
+	InitialAdminGroups = "admin:operator:viewer:ptz"
+
+	// Device account roles, from the most to the least privileged
+	AccountAdmin    = "admin"
+	AccountOperator = "operator"
+	AccountViewer   = "viewer"
+
+	// provisioningCheckTimeout limits how long discovery waits for each device
+	// to answer whether it is factory new.
+	provisioningCheckTimeout = 3 * time.Second
+)
+
+// AccountRoles lists the roles of device accounts, from the most to the least
+// privileged.
+var AccountRoles = []string{AccountAdmin, AccountOperator, AccountViewer}
+
+// DeviceAccount is a user account on a device.
+type DeviceAccount struct {
+	Name string `json:"name"`
+	Role string `json:"role"` // One of AccountRoles
+	PTZ  bool   `json:"ptz"`  // Whether the account may control pan, tilt, and zoom
+}
+
+// ListAccounts lists the user accounts of the device, sorted by name.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - []DeviceAccount: The accounts.
+//   - error:           An error if the request fails or the device reports an error.
+func ListAccounts(c *Client) ([]DeviceAccount, error) {
+	data, err := c.Get(pwdgrpPath + "?action=get")
+	if err != nil {
+		return nil, fmt.Errorf("list accounts: %w", err)
+	}
+	if msg, ok := pwdgrpError(data); ok {
+		return nil, fmt.Errorf("list accounts: %s", msg)
+	}
+
+	// The device lists the members of every group, e.g. admin="root,jane".
+	groups := make(map[string][]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		group, members, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		for _, member := range strings.Split(strings.Trim(members, `"`), ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				groups[group] = append(groups[group], member)
+			}
+		}
+	}
+
+	var accounts []DeviceAccount
+	for _, role := range AccountRoles {
+		for _, name := range groups[role] {
+			if slices.ContainsFunc(accounts, func(a DeviceAccount) bool { return a.Name == name }) {
+				continue
+			}
+			accounts = append(accounts, DeviceAccount{
+				Name: name,
+				Role: role,
+				PTZ:  slices.Contains(groups["ptz"], name),
+			})
+		}
+	}
+	slices.SortFunc(accounts, func(a, b DeviceAccount) int { return strings.Compare(a.Name, b.Name) })
+	return accounts, nil
+}
+
+// AddAccount creates a user account on the device.
+//
+// Parameters:
+//   - c:        The client of the device.
+//   - account:  The name, role, and PTZ access of the account.
+//   - password: The password of the account.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the account.
+func AddAccount(c *Client, account DeviceAccount, password string) error {
+	groups, err := accountGroups(account)
+	if err != nil {
+		return fmt.Errorf("add account %s: %w", account.Name, err)
+	}
+	return pwdgrp(c, fmt.Sprintf("add account %s", account.Name), url.Values{
+		"action": {"add"},
+		"user":   {account.Name},
+		"pwd":    {password},
+		"grp":    {"users"},
+		"sgrp":   {groups},
+	})
+}
+
+// UpdateAccount changes the role and PTZ access of a user account, and its
+// password unless password is empty.
+//
+// Parameters:
+//   - c:        The client of the device.
+//   - account:  The name, new role, and new PTZ access of the account.
+//   - password: The new password, or empty to keep the current one.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the change.
+func UpdateAccount(c *Client, account DeviceAccount, password string) error {
+	groups, err := accountGroups(account)
+	if err != nil {
+		return fmt.Errorf("update account %s: %w", account.Name, err)
+	}
+	form := url.Values{
+		"action": {"update"},
+		"user":   {account.Name},
+		"sgrp":   {groups},
+	}
+	if password != "" {
+		form.Set("pwd", password)
+	}
+	return pwdgrp(c, fmt.Sprintf("update account %s", account.Name), form)
+}
+
+// SetAccountPassword changes the password of a user account, keeping its
+// role. If the account is the one the client signs in with, the client must
+// use the new password afterwards.
+//
+// Parameters:
+//   - c:        The client of the device.
+//   - name:     The name of the account.
+//   - password: The new password.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the password.
+func SetAccountPassword(c *Client, name, password string) error {
+	return pwdgrp(c, fmt.Sprintf("set password of %s", name), url.Values{
+		"action": {"update"},
+		"user":   {name},
+		"pwd":    {password},
+	})
+}
+
+// RemoveAccount deletes a user account from the device.
+//
+// Parameters:
+//   - c:    The client of the device.
+//   - name: The name of the account.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the removal.
+func RemoveAccount(c *Client, name string) error {
+	return pwdgrp(c, fmt.Sprintf("remove account %s", name), url.Values{
+		"action": {"remove"},
+		"user":   {name},
+	})
+}
+
+// accountGroups returns the secondary groups that grant the account's role.
+func accountGroups(account DeviceAccount) (string, error) {
+	index := slices.Index(AccountRoles, account.Role)
+	if index < 0 {
+		return "", fmt.Errorf("unknown role %q", account.Role)
+	}
+	groups := AccountRoles[index:]
+	if account.PTZ {
+		groups = append(slices.Clone(groups), "ptz")
+	}
+	return strings.Join(groups, ":"), nil
+}
+
+// pwdgrp posts the form to the account management API. Passwords are sent
+// in the body, so that they do not end up in access logs.
+func pwdgrp(c *Client, action string, form url.Values) error {
+	data, err := c.Post(pwdgrpPath, []byte(form.Encode()), "application/x-www-form-urlencoded")
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if msg, ok := pwdgrpError(data); ok {
+		return fmt.Errorf("%s: %s", action, msg)
+	}
+	return nil
+}
+
+// IsUnprovisioned reports whether the device is factory new, i.e. has no
+// administrator account yet. Such devices answer the account management API
+// without credentials, while provisioned devices ask for them.
+//
+// Parameters:
+//   - c: The client of the device; its credentials are not used.
+//
+// Returns:
+//   - bool:  true if the device has no administrator account.
+//   - error: An error if the device cannot be reached or answers unexpectedly.
+func IsUnprovisioned(c *Client) (bool, error) {
+	resp, err := c.HTTP.Get(c.URL(pwdgrpPath + "?action=get"))
+	if err != nil {
+		return false, fmt.Errorf("check provisioning: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("check provisioning: status code %d", resp.StatusCode)
+	}
+}
+
+// MarkUnprovisioned checks the devices found by DiscoverSSDP concurrently and
+// adds two keys to each of them: "Address", the host of its presentation URL,
+// and "Unprovisioned", which is "true" if the device is factory new. Devices
+// that cannot be checked are left as they are.
+//
+// Parameters:
+//   - devices: The devices returned by DiscoverSSDP.
+func MarkUnprovisioned(devices []map[string]string) {
+	var wg sync.WaitGroup
+	for _, device := range devices {
+		u, err := url.Parse(device["PresentationURL"])
+		if err != nil || u.Host == "" {
+			continue
+		}
+		device["Address"] = u.Host
+
+		wg.Add(1)
+		go func(device map[string]string) {
+			defer wg.Done()
+			c := NewClient(device["Address"], "", "")
+			c.HTTP.Timeout = provisioningCheckTimeout
+			if unprovisioned, err := IsUnprovisioned(c); err == nil && unprovisioned {
+				device["Unprovisioned"] = "true"
+			}
+		}(device)
+	}
+	wg.Wait()
+}
+
+// SetInitialPassword creates the first administrator account of a factory-new
+// device. The request is sent without credentials, since there are none yet.
+//
+// Parameters:
+//   - c:        The client of the device; its credentials are not used.
+//   - username: The name of the account, usually "root".
+//   - password: The password of the account.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the account.
+func SetInitialPassword(c *Client, username, password string) error {
+	form := url.Values{
+		"action": {"add"},
+		"user":   {username},
+		"pwd":    {password},
+		"grp":    {"root"},
+		"sgrp":   {InitialAdminGroups},
+	}
+	unauthenticated := &Client{Address: c.Address, HTTP: c.HTTP}
+	return pwdgrp(unauthenticated, "set initial password", form)
+}
+
+// pwdgrpError returns the error message of an account management response,
+// and whether there was one.
+func pwdgrpError(data []byte) (string, bool) {
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, "Error") || strings.Contains(text, "<title>Error") {
+		return text, true
+	}
+	return "", false
+}
@@ -0,0 +1,99 @@
+package network
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// VAPIX endpoint for the SOAP web services
+	servicesPath    = "/vapix/services"
+	soapContentType = "application/soap+xml; charset=utf-8"
+
+	// Namespaces of the SOAP web services
+	topicsNamespace     = "http://www.onvif.org/ver10/topics"
+	axisTopicsNamespace = "http://www.axis.com/2009/event/topics"
+	concreteDialect     = "http://docs.oasis-open.org/wsn/t-1/TopicExpression/Concrete"
+	itemFilterDialect   = "http://www.onvif.org/ver10/tev/messageContentFilter/ItemFilter"
+)
+
+// soapEnvelope wraps a request. The topic namespaces are declared on the
+// envelope, so that event topics such as "tns1:Device/tnsaxis:IO/Port" can
+// be used anywhere in the request.
+type soapEnvelope struct {
+	XMLName    xml.Name `xml:"http://www.w3.org/2003/05/soap-envelope Envelope"`
+	Topics     string   `xml:"xmlns:tns1,attr"`
+	AxisTopics string   `xml:"xmlns:tnsaxis,attr"`
+	Body       struct {
+		Content any
+	} `xml:"Body"`
+}
+
+// soapResponse is the envelope of a response. Its body holds either the
+// response or a fault.
+type soapResponse struct {
+	Body struct {
+		Fault   *soapFault `xml:"Fault"`
+		Content []byte     `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+// soapFault is the error of a SOAP web service.
+type soapFault struct {
+	Code   string `xml:"Code>Subcode>Value"`
+	Reason string `xml:"Reason>Text"`
+}
+
+func (f *soapFault) Error() string {
+	if f.Code == "" {
+		return f.Reason
+	}
+	return fmt.Sprintf("%s: %s", strings.TrimSpace(f.Reason), f.Code)
+}
+
+// callSOAP sends the request to the SOAP web services of the device and
+// decodes the body of the response into response, if it is not nil. Faults
+// reported by the device are returned as errors.
+func callSOAP(c *Client, request any, response any) error {
+	envelope := soapEnvelope{Topics: topicsNamespace, AxisTopics: axisTopicsNamespace}
+	envelope.Body.Content = request
+	body, err := xml.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	resp, err := c.Do(http.MethodPost, servicesPath, append([]byte(xml.Header), body...), soapContentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	// Faults come with status 500.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusInternalServerError {
+		return fmt.Errorf("POST %s: status code %d", servicesPath, resp.StatusCode)
+	}
+
+	var envelopeResponse soapResponse
+	if err := xml.Unmarshal(data, &envelopeResponse); err != nil {
+		return fmt.Errorf("unmarshal response: %w", err)
+	}
+	if fault := envelopeResponse.Body.Fault; fault != nil {
+		return fault
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST %s: status code %d", servicesPath, resp.StatusCode)
+	}
+	if response == nil {
+		return nil
+	}
+	if err := xml.Unmarshal(envelopeResponse.Body.Content, response); err != nil {
+		return fmt.Errorf("unmarshal response: %w", err)
+	}
+	return nil
+}
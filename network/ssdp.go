@@ -62,6 +62,12 @@ type Service struct {
 // to discover devices on the network. It retrieves and parses the XML
 // data from the found devices to extract relevant information.
 //
+// Parameters:
+//   - waitSec:   How many seconds to wait for responses; SSDPMaxWaitTimeSec
+//     by default.
+//   - localAddr: The local IP address to search from, or empty to search on
+//     the default interface.
+//
 // Returns:
 //   - A slice of maps, where each map contains device information such as
 //     "FriendlyName", "ModelName", "SerialNumber", and "PresentationURL".
@@ -73,9 +79,9 @@ type Service struct {
 //   - The function requires the "koron/go-ssdp" package for performing the
 //     SSDP search and assumes the existence of a "fetchXMLData" function
 //     to retrieve XML data from a given URL.
-func DiscoverSSDP() ([]map[string]string, error) {
+func DiscoverSSDP(waitSec int, localAddr string) ([]map[string]string, error) {
 	// Perform SSDP search for devices matching the specified service type
-	ssdpResponses, err := ssdp.Search(SSDPServiceType, SSDPMaxWaitTimeSec, "")
+	ssdpResponses, err := ssdp.Search(SSDPServiceType, waitSec, localAddr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to SSDP search: %w", err)
 	}
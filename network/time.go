@@ -0,0 +1,157 @@
+package network
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// VAPIX endpoints for time settings
+	timePath = "/axis-cgi/time.cgi"
+	ntpPath  = "/axis-cgi/ntp.cgi"
+
+	// Sources of NTP servers
+	NTPServersDHCP   = "DHCP"
+	NTPServersStatic = "static"
+)
+
+// TimeInfo holds the date, time, and time zone of a device as reported by the
+// Time API.
+type TimeInfo struct {
+	DateTime      time.Time `json:"dateTime"`      // The current time, in UTC
+	LocalDateTime string    `json:"localDateTime"` // The current time in the device's time zone
+	TimeZone      string    `json:"timeZone"`      // An IANA time zone, e.g. "Europe/Stockholm"
+	PosixTimeZone string    `json:"posixTimeZone"`
+	DSTEnabled    bool      `json:"dstEnabled"`
+}
+
+// NTPInfo holds the NTP configuration of a device as reported by the NTP API.
+type NTPInfo struct {
+	Client NTPClient `json:"client"`
+}
+
+// NTPClient is the NTP client configuration of a device. Servers lists the
+// servers in use, which may come from DHCP; StaticServers the configured ones.
+type NTPClient struct {
+	Enabled       bool     `json:"enabled"`
+	ServersSource string   `json:"serversSource"` // NTPServersDHCP or NTPServersStatic
+	Servers       []string `json:"servers"`
+	StaticServers []string `json:"staticServers"`
+}
+
+// GetTimeInfo reads the date, time, and time zone of the device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - *TimeInfo: The date, time, and time zone.
+//   - error:     An error if the request fails or the device reports an error.
+func GetTimeInfo(c *Client) (*TimeInfo, error) {
+	var info TimeInfo
+	if err := callJSONAPI(c, timePath, "getDateTimeInfo", nil, &info); err != nil {
+		return nil, fmt.Errorf("get time info: %w", err)
+	}
+	return &info, nil
+}
+
+// SetDateTime sets the clock of the device. Devices that synchronize with
+// NTP set their clock again on the next synchronization.
+//
+// Parameters:
+//   - c: The client of the device.
+//   - t: The time to set.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the time.
+func SetDateTime(c *Client, t time.Time) error {
+	params := map[string]any{
+		"dateTime": t.UTC().Format(time.RFC3339),
+	}
+	if err := callJSONAPI(c, timePath, "setDateTime", params, nil); err != nil {
+		return fmt.Errorf("set date and time: %w", err)
+	}
+	return nil
+}
+
+// SetTimeZone sets the time zone of the device.
+//
+// Parameters:
+//   - c:        The client of the device.
+//   - timeZone: An IANA time zone, e.g. "Europe/Stockholm".
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the time zone.
+func SetTimeZone(c *Client, timeZone string) error {
+	params := map[string]any{
+		"timeZone": timeZone,
+	}
+	if err := callJSONAPI(c, timePath, "setTimeZone", params, nil); err != nil {
+		return fmt.Errorf("set time zone: %w", err)
+	}
+	return nil
+}
+
+// GetNTPInfo reads the NTP configuration of the device.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - *NTPInfo: The NTP configuration.
+//   - error:    An error if the request fails or the device reports an error.
+func GetNTPInfo(c *Client) (*NTPInfo, error) {
+	var info NTPInfo
+	if err := callJSONAPI(c, ntpPath, "getNTPInfo", nil, &info); err != nil {
+		return nil, fmt.Errorf("get NTP info: %w", err)
+	}
+	return &info, nil
+}
+
+// SetNTP configures the NTP client of the device.
+//
+// Parameters:
+//   - c:       The client of the device.
+//   - enabled: Whether the device synchronizes its clock with NTP.
+//   - source:  NTPServersDHCP to use the servers assigned by DHCP, or
+//     NTPServersStatic to use the given servers.
+//   - servers: The static NTP servers.
+//
+// Returns:
+//   - error: An error if the request fails or the device rejects the settings.
+func SetNTP(c *Client, enabled bool, source string, servers []string) error {
+	params := map[string]any{
+		"enabled":           enabled,
+		"serversSource":     source,
+		"staticServersList": nonNil(servers),
+	}
+	if err := callJSONAPI(c, ntpPath, "setNTPClientConfiguration", params, nil); err != nil {
+		return fmt.Errorf("set NTP configuration: %w", err)
+	}
+	return nil
+}
+
+// ClockDrift measures how far the clock of the device is ahead of the local
+// clock; a negative drift means that the device is behind. The device's time
+// is compared to the local time halfway through the request, so that the
+// round trip does not count as drift. Devices report whole seconds, so the
+// drift is rounded to the second.
+//
+// Parameters:
+//   - c: The client of the device.
+//
+// Returns:
+//   - time.Duration: The drift of the device clock.
+//   - *TimeInfo:     The date, time, and time zone reported by the device.
+//   - error:         An error if the time cannot be read.
+func ClockDrift(c *Client) (time.Duration, *TimeInfo, error) {
+	sent := time.Now()
+	info, err := GetTimeInfo(c)
+	if err != nil {
+		return 0, nil, err
+	}
+	received := time.Now()
+
+	local := sent.Add(received.Sub(sent) / 2)
+	return info.DateTime.Sub(local).Round(time.Second), info, nil
+}
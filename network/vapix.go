@@ -0,0 +1,221 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// VAPIX constants
+	VAPIXTimeout = 30 * time.Second
+)
+
+// Client sends authenticated VAPIX requests to a single Axis device. It
+// answers Digest challenges transparently and falls back to Basic
+// Authentication when the device does not ask for Digest.
+type Client struct {
+	Address  string
+	Username string
+	Password string
+	HTTP     *http.Client
+}
+
+// NewClient returns a Client for the device at the given address. The address
+// may be a bare host or IP address, in which case plain HTTP is used, or a URL
+// with an explicit scheme.
+//
+// Parameters:
+//   - address:  The host, IP address, or base URL of the device.
+//   - username: The username for authentication.
+//   - password: The password for authentication.
+//
+// Returns:
+//   - *Client: A client for the device.
+func NewClient(address, username, password string) *Client {
+	return &Client{
+		Address:  address,
+		Username: username,
+		Password: password,
+		HTTP:     &http.Client{Timeout: VAPIXTimeout},
+	}
+}
+
+// URL returns the absolute URL of the given path on the device.
+func (c *Client) URL(path string) string {
+	base := c.Address
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// Host returns the host of a device address, which may include a scheme or
+// port.
+//
+// Parameters:
+//   - address: The host, IP address, or base URL of the device.
+//
+// Returns:
+//   - string: The host or IP address alone.
+func Host(address string) string {
+	if strings.Contains(address, "://") {
+		if u, err := url.Parse(address); err == nil {
+			return u.Hostname()
+		}
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// Do sends a request with the given method, path, and body to the device. If
+// the device asks for credentials, the request is repeated with a Digest or
+// Basic Authorization header, depending on the challenge. The caller must close the response body.
+//
+// Parameters:
+//   - method:      The HTTP method (e.g., "GET", "POST").
+//   - path:        The path of the VAPIX endpoint, including the query string.
+//   - body:        The request body, or nil.
+//   - contentType: The content type of the body, ignored if body is nil.
+//
+// Returns:
+//   - *http.Response: The response of the device.
+//   - error:          An error if the request could not be sent.
+func (c *Client) Do(method, path string, body []byte, contentType string) (*http.Response, error) {
+	return c.DoContext(context.Background(), method, path, body, contentType)
+}
+
+// DoContext is like Do, but the request is cancelled when ctx is done, which
+// also ends reading the response body of a stream.
+//
+// Parameters:
+//   - ctx:         The context of the request.
+//   - method:      The HTTP method (e.g., "GET", "POST").
+//   - path:        The path of the VAPIX endpoint, including the query string.
+//   - body:        The request body, or nil.
+//   - contentType: The content type of the body, ignored if body is nil.
+//
+// Returns:
+//   - *http.Response: The response of the device.
+//   - error:          An error if the request could not be sent.
+func (c *Client) DoContext(ctx context.Context, method, path string, body []byte, contentType string) (*http.Response, error) {
+	return c.do(ctx, method, path, body, contentType, nil)
+}
+
+// do sends the request like DoContext, with the given additional headers.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, contentType string, header http.Header) (*http.Response, error) {
+	uri := c.URL(path)
+
+	req, err := c.newRequest(ctx, method, uri, body, contentType, header)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	authHeader := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	req, err = c.newRequest(ctx, method, uri, body, contentType, header)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.ToLower(authHeader), "digest ") {
+		authParams := parseDigestAuthParams(authHeader)
+		if authParams == nil {
+			return nil, fmt.Errorf("failed to parse WWW-Authenticate header")
+		}
+		d := &DigestAuth{}
+		d.setup(authParams, c.Username, c.Password, uri)
+		d.addDigestAuthHeader(req)
+	} else {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err = c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, fmt.Errorf("authentication failed: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// Get sends a GET request to the given path and returns the response body.
+// Responses with a status other than 200 OK are reported as errors.
+//
+// Parameters:
+//   - path: The path of the VAPIX endpoint, including the query string.
+//
+// Returns:
+//   - []byte: The response body.
+//   - error:  An error if the request fails or the device reports an error.
+func (c *Client) Get(path string) ([]byte, error) {
+	return c.read(http.MethodGet, path, nil, "")
+}
+
+// Post sends a POST request with the given body to the given path and returns
+// the response body. Responses with a status other than 200 OK are reported as
+// errors.
+//
+// Parameters:
+//   - path:        The path of the VAPIX endpoint.
+//   - body:        The request body.
+//   - contentType: The content type of the body.
+//
+// Returns:
+//   - []byte: The response body.
+//   - error:  An error if the request fails or the device reports an error.
+func (c *Client) Post(path string, body []byte, contentType string) ([]byte, error) {
+	return c.read(http.MethodPost, path, body, contentType)
+}
+
+func (c *Client) read(method, path string, body []byte, contentType string) ([]byte, error) {
+	resp, err := c.Do(method, path, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: status code %d", method, path, resp.StatusCode)
+	}
+	return data, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, uri string, body []byte, contentType string, header http.Header) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, uri, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil && contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
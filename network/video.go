@@ -0,0 +1,79 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// VAPIX endpoint for MJPEG video
+	videoPath = "/axis-cgi/mjpg/video.cgi"
+)
+
+// VideoOptions select the resolution, frame rate, and compression of a video
+// stream. Empty values leave the choice to the device.
+type VideoOptions struct {
+	Resolution  string // Such as "640x360"
+	FPS         int    // Frames per second; 0 for the default
+	Compression int    // JPEG compression from 0 to 100; negative for the default
+}
+
+// OpenMJPEG starts an MJPEG stream of the device. The stream is a
+// multipart/x-mixed-replace response that runs until ctx is done or the
+// device ends it. Unlike other requests, it is not subject to VAPIXTimeout
+// once the device has answered.
+//
+// Parameters:
+//   - ctx:     The context that ends the stream when done.
+//   - c:       The client of the device.
+//   - options: The resolution, frame rate, and compression of the stream.
+//
+// Returns:
+//   - *http.Response: The response whose body is the stream; the caller
+//     must close it.
+//   - error:          An error if the request fails or the device does not
+//     answer with an MJPEG stream.
+func OpenMJPEG(ctx context.Context, c *Client, options VideoOptions) (*http.Response, error) {
+	query := url.Values{}
+	if options.Resolution != "" {
+		query.Set("resolution", options.Resolution)
+	}
+	if options.FPS > 0 {
+		query.Set("fps", strconv.Itoa(options.FPS))
+	}
+	if options.Compression >= 0 {
+		query.Set("compression", strconv.Itoa(options.Compression))
+	}
+	path := videoPath
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	// The stream outlives the timeout of the client, so only waiting for the
+	// device to answer is limited.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = VAPIXTimeout
+	transport.DisableKeepAlives = true // Nothing else uses the transport
+	stream := *c
+	stream.HTTP = &http.Client{Transport: transport}
+
+	resp, err := stream.DoContext(ctx, http.MethodGet, path, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("open video stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("open video stream: status code %d", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "multipart/x-mixed-replace") {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("open video stream: the device did not return an MJPEG stream")
+	}
+	return resp, nil
+}
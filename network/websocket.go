@@ -0,0 +1,229 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	// WebSocket constants
+	webSocketGUID       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxWebSocketMessage = 1 << 20
+
+	// WebSocket opcodes
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// webSocket is the client side of a WebSocket connection (RFC 6455), with
+// just enough of the protocol to exchange messages with VAPIX services:
+// fragmented messages, ping, pong, and close. Messages are read by a single
+// goroutine, while writes may come from any goroutine.
+type webSocket struct {
+	conn   io.ReadWriteCloser
+	reader *bufio.Reader
+	mutex  sync.Mutex // Serializes writes
+}
+
+// dialWebSocket opens a WebSocket connection to the given path of the device,
+// authenticating the upgrade request like any other VAPIX request. Like
+// OpenMJPEG, the connection is not subject to VAPIXTimeout once the device
+// has answered.
+//
+// Parameters:
+//   - ctx:  The context of the upgrade request.
+//   - c:    The client of the device.
+//   - path: The path of the WebSocket endpoint, including the query string.
+//
+// Returns:
+//   - *webSocket: The open connection; the caller must close it.
+//   - error:      An error if the device does not accept the upgrade.
+func dialWebSocket(ctx context.Context, c *Client, path string) (*webSocket, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate WebSocket key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	header := http.Header{}
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", "websocket")
+	header.Set("Sec-WebSocket-Version", "13")
+	header.Set("Sec-WebSocket-Key", key)
+
+	// WebSocket connections are upgraded from HTTP/1.1 only.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = VAPIXTimeout
+	transport.DisableKeepAlives = true
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	upgrade := *c
+	upgrade.HTTP = &http.Client{Transport: transport}
+
+	resp, err := upgrade.do(ctx, http.MethodGet, path, nil, "", header)
+	if err != nil {
+		return nil, fmt.Errorf("open WebSocket: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return nil, fmt.Errorf("open WebSocket: status code %d", resp.StatusCode)
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("open WebSocket: the connection cannot be written to")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("open WebSocket: the device did not accept the key")
+	}
+
+	return &webSocket{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value expected for the key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ReadMessage returns the next text or binary message, answering pings on the
+// way. It returns io.EOF once the device has closed the connection.
+func (ws *webSocket) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opText, opBinary, opContinuation:
+			if len(message)+len(payload) > maxWebSocketMessage {
+				return nil, fmt.Errorf("WebSocket message exceeds %d bytes", maxWebSocketMessage)
+			}
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+		case opPing:
+			if err := ws.write(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+		case opClose:
+			ws.write(opClose, nil)
+			return nil, io.EOF
+		default:
+			return nil, fmt.Errorf("unknown WebSocket opcode %d", opcode)
+		}
+	}
+}
+
+// readFrame reads a single frame.
+func (ws *webSocket) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.reader, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxWebSocketMessage {
+		return false, 0, nil, fmt.Errorf("WebSocket frame exceeds %d bytes", maxWebSocketMessage)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteText sends a text message.
+func (ws *webSocket) WriteText(message []byte) error {
+	return ws.write(opText, message)
+}
+
+// Ping sends a ping, which the device answers with a pong.
+func (ws *webSocket) Ping() error {
+	return ws.write(opPing, nil)
+}
+
+// write sends a single frame. Frames from the client must be masked.
+func (ws *webSocket) write(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return fmt.Errorf("generate WebSocket mask: %w", err)
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	if _, err := ws.conn.Write(frame); err != nil {
+		return fmt.Errorf("write WebSocket frame: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection. A blocked ReadMessage returns with an error.
+func (ws *webSocket) Close() error {
+	ws.write(opClose, nil)
+	if err := ws.conn.Close(); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		return err
+	}
+	return nil
+}
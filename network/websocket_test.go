@@ -0,0 +1,230 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeConn reads what the device sent and records what the client writes.
+type fakeConn struct {
+	io.Reader
+	written bytes.Buffer
+}
+
+func (c *fakeConn) Write(p []byte) (int, error) { return c.written.Write(p) }
+func (c *fakeConn) Close() error                { return nil }
+
+func newFakeWebSocket(frames ...[]byte) (*webSocket, *fakeConn) {
+	conn := &fakeConn{Reader: bytes.NewReader(bytes.Join(frames, nil))}
+	return &webSocket{conn: conn, reader: bufio.NewReader(conn)}, conn
+}
+
+// frame encodes a frame as a device sends it, optionally masked.
+func frame(fin bool, opcode byte, payload []byte, mask []byte) []byte {
+	head := opcode
+	if fin {
+		head |= 0x80
+	}
+	encoded := []byte{head}
+	var maskBit byte
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length < 126:
+		encoded = append(encoded, maskBit|byte(length))
+	case length <= 0xFFFF:
+		encoded = append(encoded, maskBit|126)
+		encoded = binary.BigEndian.AppendUint16(encoded, uint16(length))
+	default:
+		encoded = append(encoded, maskBit|127)
+		encoded = binary.BigEndian.AppendUint64(encoded, uint64(length))
+	}
+	if mask == nil {
+		return append(encoded, payload...)
+	}
+	encoded = append(encoded, mask...)
+	for i, b := range payload {
+		encoded = append(encoded, b^mask[i%4])
+	}
+	return encoded
+}
+
+func TestReadMessage(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 300)
+	huge := bytes.Repeat([]byte("b"), 70000)
+
+	tests := []struct {
+		name        string
+		frames      [][]byte
+		want        []byte
+		wantErr     string
+		wantWritten []byte // Opcode of the frame the client answers with, if any
+	}{
+		{
+			name:   "text",
+			frames: [][]byte{frame(true, opText, []byte(`{"apiVersion":"1.0"}`), nil)},
+			want:   []byte(`{"apiVersion":"1.0"}`),
+		},
+		{
+			name:   "16-bit length",
+			frames: [][]byte{frame(true, opBinary, long, nil)},
+			want:   long,
+		},
+		{
+			name:   "64-bit length",
+			frames: [][]byte{frame(true, opText, huge, nil)},
+			want:   huge,
+		},
+		{
+			name:   "masked",
+			frames: [][]byte{frame(true, opText, []byte("hello"), []byte{1, 2, 3, 4})},
+			want:   []byte("hello"),
+		},
+		{
+			name: "fragmented",
+			frames: [][]byte{
+				frame(false, opText, []byte("hel"), nil),
+				frame(false, opContinuation, []byte("lo "), nil),
+				frame(true, opContinuation, []byte("world"), nil),
+			},
+			want: []byte("hello world"),
+		},
+		{
+			name: "ping between fragments",
+			frames: [][]byte{
+				frame(false, opText, []byte("hel"), nil),
+				frame(true, opPing, []byte("p"), nil),
+				frame(true, opContinuation, []byte("lo"), nil),
+			},
+			want:        []byte("hello"),
+			wantWritten: []byte{opPong},
+		},
+		{
+			name: "pong ignored",
+			frames: [][]byte{
+				frame(true, opPong, nil, nil),
+				frame(true, opText, []byte("x"), nil),
+			},
+			want: []byte("x"),
+		},
+		{
+			name:        "close",
+			frames:      [][]byte{frame(true, opClose, nil, nil)},
+			wantErr:     io.EOF.Error(),
+			wantWritten: []byte{opClose},
+		},
+		{
+			name:    "unknown opcode",
+			frames:  [][]byte{frame(true, 0x3, nil, nil)},
+			wantErr: "unknown WebSocket opcode 3",
+		},
+		{
+			name:    "frame too large",
+			frames:  [][]byte{{0x81, 127, 0, 0, 0, 0, 0x10, 0, 0, 0}},
+			wantErr: "frame exceeds",
+		},
+		{
+			name: "message too large",
+			frames: [][]byte{
+				frame(false, opText, make([]byte, maxWebSocketMessage), nil),
+				frame(true, opContinuation, []byte("x"), nil),
+			},
+			wantErr: "message exceeds",
+		},
+		{
+			name:    "truncated",
+			frames:  [][]byte{frame(true, opText, []byte("hello"), nil)[:4]},
+			wantErr: io.ErrUnexpectedEOF.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, conn := newFakeWebSocket(tt.frames...)
+			message, err := ws.ReadMessage()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadMessage() error = %v, want one containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ReadMessage() error = %v", err)
+			}
+			if !bytes.Equal(message, tt.want) {
+				t.Errorf("ReadMessage() = %d bytes, want %d", len(message), len(tt.want))
+			}
+
+			var written []byte
+			client, _ := newFakeWebSocket(conn.written.Bytes())
+			for {
+				_, opcode, _, err := client.readFrame()
+				if err != nil {
+					break
+				}
+				written = append(written, opcode)
+			}
+			if !bytes.Equal(written, tt.wantWritten) {
+				t.Errorf("client wrote opcodes %v, want %v", written, tt.wantWritten)
+			}
+		})
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    []byte
+		wantLength byte // The 7-bit length of the second byte, without the mask bit
+	}{
+		{name: "empty", payload: nil, wantLength: 0},
+		{name: "short", payload: []byte(`{"method":"events:configure"}`), wantLength: 29},
+		{name: "longest 7-bit length", payload: make([]byte, 125), wantLength: 125},
+		{name: "16-bit length", payload: make([]byte, 126), wantLength: 126},
+		{name: "64-bit length", payload: make([]byte, 0x10000), wantLength: 127},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, conn := newFakeWebSocket()
+			if err := ws.WriteText(tt.payload); err != nil {
+				t.Fatal(err)
+			}
+			written := conn.written.Bytes()
+			if written[0] != 0x80|opText {
+				t.Errorf("first byte = %#x, want a final text frame", written[0])
+			}
+			if written[1]&0x80 == 0 {
+				t.Error("the frame is not masked")
+			}
+			if length := written[1] & 0x7F; length != tt.wantLength {
+				t.Errorf("length = %d, want %d", length, tt.wantLength)
+			}
+
+			reader, _ := newFakeWebSocket(written)
+			fin, opcode, payload, err := reader.readFrame()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fin || opcode != opText || !bytes.Equal(payload, tt.payload) {
+				t.Errorf("readFrame() = %v, %d, %d bytes, want the written frame", fin, opcode, len(payload))
+			}
+		})
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455, section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey() = %q", got)
+	}
+}
+
+func TestReadMessageAfterEOF(t *testing.T) {
+	ws, _ := newFakeWebSocket()
+	if _, err := ws.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadMessage() error = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,366 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CA is a certificate authority that signs device certificates. Its private
+// key is kept in a file next to its certificate, readable only by Neba.
+type CA struct {
+	Certificate *x509.Certificate
+	CertPEM     []byte
+	key         crypto.Signer
+}
+
+// CreateCA generates a new ECDSA P-256 CA key and a self-signed CA
+// certificate, and stores them at the given paths, replacing an existing CA.
+//
+// Parameters:
+//   - certPath:   The path of the PEM encoded CA certificate.
+//   - keyPath:    The path of the PEM encoded CA private key.
+//   - commonName: The common name of the CA, shown as the issuer of device certificates.
+//   - validity:   How long the CA certificate is valid for.
+//
+// Returns:
+//   - *CA:  The new CA.
+//   - error: An error if the key or certificate could not be created or written.
+func CreateCA(certPath, keyPath, commonName string, validity time.Duration) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate CA key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"Neba"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true, // The CA only signs device certificates
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return ImportCA(certPath, keyPath, encodeCertificate(der), keyPEM)
+}
+
+// ImportCA checks that the PEM encoded certificate is a CA certificate that
+// matches the private key, and stores both at the given paths, replacing an
+// existing CA. Encrypted private keys are not supported.
+//
+// Parameters:
+//   - certPath: The path to store the CA certificate at.
+//   - keyPath:  The path to store the CA private key at.
+//   - certPEM:  The PEM encoded CA certificate.
+//   - keyPEM:   The PEM encoded private key, in PKCS #8, PKCS #1, or SEC 1 format.
+//
+// Returns:
+//   - *CA:  The imported CA.
+//   - error: An error if the certificate or key is invalid, or writing fails.
+func ImportCA(certPath, keyPath string, certPEM, keyPEM []byte) (*CA, error) {
+	ca, err := parseCA(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
+		return nil, fmt.Errorf("create CA directory: %w", err)
+	}
+
+	// Both files are written in full before either replaces the current CA,
+	// so that a failed import leaves the current CA usable.
+	keyTmp, err := writeTemp(keyPath, keyPEM, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("write CA key: %w", err)
+	}
+	defer os.Remove(keyTmp)
+	certTmp, err := writeTemp(certPath, ca.CertPEM, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("write CA certificate: %w", err)
+	}
+	defer os.Remove(certTmp)
+
+	previousKey, previousErr := os.ReadFile(keyPath)
+	if err := os.Rename(keyTmp, keyPath); err != nil {
+		return nil, fmt.Errorf("write CA key: %w", err)
+	}
+	if err := os.Rename(certTmp, certPath); err != nil {
+		// Put the previous key back, so that it matches the certificate again.
+		if previousErr == nil {
+			if restored, rerr := writeTemp(keyPath, previousKey, 0600); rerr == nil {
+				os.Rename(restored, keyPath)
+			}
+		} else {
+			os.Remove(keyPath)
+		}
+		return nil, fmt.Errorf("write CA certificate: %w", err)
+	}
+	return ca, nil
+}
+
+// writeTemp writes the data to a new temporary file next to the given path,
+// to be renamed into place, and returns the name of the file.
+func writeTemp(path string, data []byte, perm os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// LoadCA reads the CA stored at the given paths.
+//
+// Parameters:
+//   - certPath: The path of the PEM encoded CA certificate.
+//   - keyPath:  The path of the PEM encoded CA private key.
+//
+// Returns:
+//   - *CA:  The CA.
+//   - error: An error wrapping os.ErrNotExist if there is no CA yet, or an
+//     error if the files cannot be read or are invalid.
+func LoadCA(certPath, keyPath string) (*CA, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read CA key: %w", err)
+	}
+	return parseCA(certPEM, keyPEM)
+}
+
+// SignCSR issues a server certificate for the public key of the PEM encoded
+// certificate signing request. The certificate is valid for the names of the
+// request and the given hosts; hosts that parse as IP addresses become IP
+// SANs, all others DNS SANs. It never outlives the CA certificate.
+//
+// Parameters:
+//   - csrPEM:   The PEM encoded certificate signing request.
+//   - hosts:    Additional DNS names and IP addresses the certificate is valid for.
+//   - validity: How long the certificate is valid for.
+//
+// Returns:
+//   - []byte: The PEM encoded certificate, followed by the CA certificate.
+//   - error:  An error if the request is invalid or signing fails.
+func (ca *CA) SignCSR(csrPEM []byte, hosts []string, validity time.Duration) ([]byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || !strings.HasSuffix(block.Type, "CERTIFICATE REQUEST") {
+		return nil, fmt.Errorf("no PEM encoded certificate request found")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate request: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("certificate request: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: csr.Subject.CommonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     csr.DNSNames,
+		IPAddresses:  csr.IPAddresses,
+		// Identifies the CA even if it was imported without a subject key
+		// identifier, so that Neba can tell the certificates it issued.
+		AuthorityKeyId: ca.KeyID(),
+	}
+	if template.NotAfter.After(ca.Certificate.NotAfter) {
+		template.NotAfter = ca.Certificate.NotAfter
+	}
+	if _, ok := csr.PublicKey.(*rsa.PublicKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	for _, host := range hosts {
+		if !coversHost(template, host) {
+			addSANs(template, []string{host})
+		}
+	}
+	if len(template.DNSNames) == 0 && len(template.IPAddresses) == 0 {
+		return nil, fmt.Errorf("the certificate would not be valid for any host name or IP address")
+	}
+	if template.Subject.CommonName == "" {
+		if len(template.DNSNames) > 0 {
+			template.Subject.CommonName = template.DNSNames[0]
+		} else {
+			template.Subject.CommonName = template.IPAddresses[0].String()
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("sign certificate: %w", err)
+	}
+	return append(encodeCertificate(der), ca.CertPEM...), nil
+}
+
+// KeyID returns the identifier of the CA's key, which certificates it issued
+// carry as their authority key identifier: the subject key identifier of the
+// CA certificate or, if it has none, the SHA-1 hash of its public key, as
+// described in RFC 5280.
+func (ca *CA) KeyID() []byte {
+	if len(ca.Certificate.SubjectKeyId) > 0 {
+		return ca.Certificate.SubjectKeyId
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(ca.Certificate.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil
+	}
+	sum := sha1.Sum(spki.PublicKey.Bytes)
+	return sum[:]
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the CA certificate, as
+// colon-separated hex, to compare it with the certificate a browser shows.
+func (ca *CA) Fingerprint() string {
+	sum := sha256.Sum256(ca.Certificate.Raw)
+	return strings.ToUpper(strings.Join(splitPairs(hex.EncodeToString(sum[:])), ":"))
+}
+
+// parseCA parses and checks a CA certificate and its private key.
+func parseCA(certPEM, keyPEM []byte) (*CA, error) {
+	cert, err := ParseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("CA certificate: %w", err)
+	}
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return nil, fmt.Errorf("the certificate is not a CA certificate")
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, fmt.Errorf("the CA certificate expired on %s", cert.NotAfter.Format(time.DateOnly))
+	}
+
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("CA key: %w", err)
+	}
+	public, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(cert.PublicKey) {
+		return nil, fmt.Errorf("the private key does not belong to the CA certificate")
+	}
+
+	return &CA{Certificate: cert, CertPEM: encodeCertificate(cert.Raw), key: key}, nil
+}
+
+// parsePrivateKey parses a PEM encoded private key in PKCS #8, PKCS #1, or
+// SEC 1 format.
+func parsePrivateKey(keyPEM []byte) (crypto.Signer, error) {
+	var block *pem.Block
+	for rest := keyPEM; ; {
+		if block, rest = pem.Decode(rest); block == nil {
+			return nil, fmt.Errorf("no PEM encoded private key found")
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			break
+		}
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" || block.Headers["Proc-Type"] != "" {
+		return nil, fmt.Errorf("encrypted private keys are not supported; decrypt the key first")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, errors.New("unsupported private key type")
+	}
+}
+
+// coversHost reports whether the certificate template already lists the
+// host as a SAN.
+func coversHost(cert *x509.Certificate, host string) bool {
+	for _, ip := range cert.IPAddresses {
+		if ip.String() == host {
+			return true
+		}
+	}
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, host) {
+			return true
+		}
+	}
+	return host == ""
+}
+
+func splitPairs(s string) []string {
+	pairs := make([]string, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		pairs = append(pairs, s[i:i+2])
+	}
+	return pairs
+}
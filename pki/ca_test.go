@@ -0,0 +1,298 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate creates a self-signed certificate from the template, and
+// returns it PEM encoded along with its PEM encoded key.
+func testCertificate(t *testing.T, template *x509.Certificate) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeCertificate(der), keyPEM
+}
+
+func caTemplate(notAfter time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-2 * time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+// testCSR creates a PEM encoded certificate signing request.
+func testCSR(t *testing.T, commonName string, dnsNames []string, ips []net.IP) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: commonName},
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func TestParseCA(t *testing.T) {
+	caPEM, caKey := testCertificate(t, caTemplate(time.Now().Add(24*time.Hour)))
+	_, otherKey := testCertificate(t, caTemplate(time.Now().Add(24*time.Hour)))
+	expiredPEM, expiredKey := testCertificate(t, caTemplate(time.Now().Add(-time.Hour)))
+	leafPEM, leafKey := testCertificate(t, &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "camera"},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(24 * time.Hour),
+	})
+	encryptedKey := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: []byte{1}})
+
+	tests := []struct {
+		name    string
+		certPEM []byte
+		keyPEM  []byte
+		wantErr string
+	}{
+		{"valid", caPEM, caKey, ""},
+		{"key before certificate in one file", caPEM, append(append([]byte{}, caPEM...), caKey...), ""},
+		{"not a CA", leafPEM, leafKey, "not a CA certificate"},
+		{"expired", expiredPEM, expiredKey, "expired"},
+		{"key of another CA", caPEM, otherKey, "does not belong"},
+		{"encrypted key", caPEM, encryptedKey, "encrypted private keys are not supported"},
+		{"no key", caPEM, nil, "no PEM encoded private key"},
+		{"no certificate", nil, caKey, "no PEM encoded certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ca, err := parseCA(tt.certPEM, tt.keyPEM)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseCA() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCA() error = %v", err)
+			}
+			if ca.Certificate.Subject.CommonName != "Test CA" {
+				t.Errorf("parseCA() common name = %q, want %q", ca.Certificate.Subject.CommonName, "Test CA")
+			}
+		})
+	}
+}
+
+func TestSignCSR(t *testing.T) {
+	caNotAfter := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	certPEM, keyPEM := testCertificate(t, caTemplate(caNotAfter))
+	ca, err := parseCA(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		csr        []byte
+		hosts      []string
+		validity   time.Duration
+		wantCN     string
+		wantDNS    []string
+		wantIPs    []string
+		wantExpiry time.Time // Zero if the validity applies
+		wantErr    string
+	}{
+		{
+			name:     "names of the request",
+			csr:      testCSR(t, "axis-cam", []string{"axis-cam.local"}, nil),
+			validity: 24 * time.Hour,
+			wantCN:   "axis-cam",
+			wantDNS:  []string{"axis-cam.local"},
+		},
+		{
+			name:     "hosts added as SANs",
+			csr:      testCSR(t, "", nil, nil),
+			hosts:    []string{"192.168.0.90", "axis-cam"},
+			validity: 24 * time.Hour,
+			wantCN:   "axis-cam",
+			wantDNS:  []string{"axis-cam"},
+			wantIPs:  []string{"192.168.0.90"},
+		},
+		{
+			name:     "hosts already in the request",
+			csr:      testCSR(t, "cam", []string{"CAM"}, []net.IP{net.ParseIP("10.0.0.1")}),
+			hosts:    []string{"cam", "10.0.0.1"},
+			validity: 24 * time.Hour,
+			wantCN:   "cam",
+			wantDNS:  []string{"CAM"},
+			wantIPs:  []string{"10.0.0.1"},
+		},
+		{
+			name:       "capped at the expiry of the CA",
+			csr:        testCSR(t, "cam", []string{"cam"}, nil),
+			validity:   365 * 24 * time.Hour,
+			wantCN:     "cam",
+			wantDNS:    []string{"cam"},
+			wantExpiry: caNotAfter,
+		},
+		{
+			name:     "no names",
+			csr:      testCSR(t, "cam", nil, nil),
+			validity: 24 * time.Hour,
+			wantErr:  "not be valid for any host",
+		},
+		{
+			name:     "not a request",
+			csr:      certPEM,
+			validity: 24 * time.Hour,
+			wantErr:  "no PEM encoded certificate request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := ca.SignCSR(tt.csr, tt.hosts, tt.validity)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SignCSR() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SignCSR() error = %v", err)
+			}
+			if !bytes.HasSuffix(chain, ca.CertPEM) {
+				t.Error("SignCSR() chain does not end with the CA certificate")
+			}
+			cert, err := ParseCertificatePEM(chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := cert.CheckSignatureFrom(ca.Certificate); err != nil {
+				t.Errorf("certificate not signed by the CA: %v", err)
+			}
+			if !bytes.Equal(cert.AuthorityKeyId, ca.KeyID()) {
+				t.Errorf("authority key ID = %x, want %x", cert.AuthorityKeyId, ca.KeyID())
+			}
+			if cert.Subject.CommonName != tt.wantCN {
+				t.Errorf("common name = %q, want %q", cert.Subject.CommonName, tt.wantCN)
+			}
+			if strings.Join(cert.DNSNames, ",") != strings.Join(tt.wantDNS, ",") {
+				t.Errorf("DNS names = %v, want %v", cert.DNSNames, tt.wantDNS)
+			}
+			var ips []string
+			for _, ip := range cert.IPAddresses {
+				ips = append(ips, ip.String())
+			}
+			if strings.Join(ips, ",") != strings.Join(tt.wantIPs, ",") {
+				t.Errorf("IP addresses = %v, want %v", ips, tt.wantIPs)
+			}
+			if !tt.wantExpiry.IsZero() && !cert.NotAfter.Equal(tt.wantExpiry) {
+				t.Errorf("expiry = %s, want %s", cert.NotAfter, tt.wantExpiry)
+			}
+			if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageServerAuth {
+				t.Errorf("extended key usage = %v, want server authentication", cert.ExtKeyUsage)
+			}
+		})
+	}
+}
+
+func TestImportCA(t *testing.T) {
+	validPEM, validKey := testCertificate(t, caTemplate(time.Now().Add(24*time.Hour)))
+	_, otherKey := testCertificate(t, caTemplate(time.Now().Add(24*time.Hour)))
+	previousPEM, previousKey := testCertificate(t, caTemplate(time.Now().Add(48*time.Hour)))
+
+	tests := []struct {
+		name     string
+		previous bool // Whether a CA exists before the import
+		certPEM  []byte
+		keyPEM   []byte
+		wantErr  bool
+	}{
+		{name: "new CA", certPEM: validPEM, keyPEM: validKey},
+		{name: "replaces the previous CA", previous: true, certPEM: validPEM, keyPEM: validKey},
+		{name: "invalid CA keeps the previous one", previous: true, certPEM: validPEM, keyPEM: otherKey, wantErr: true},
+		{name: "invalid CA without a previous one", certPEM: validPEM, keyPEM: otherKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "config")
+			certPath, keyPath := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+			if tt.previous {
+				if _, err := ImportCA(certPath, keyPath, previousPEM, previousKey); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := ImportCA(certPath, keyPath, tt.certPEM, tt.keyPEM)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ImportCA() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			wantCert, wantKey := tt.certPEM, tt.keyPEM
+			if tt.wantErr {
+				wantCert, wantKey = previousPEM, previousKey
+			}
+			if tt.wantErr && !tt.previous {
+				if _, err := os.Stat(certPath); !os.IsNotExist(err) {
+					t.Errorf("certificate written despite the error: %v", err)
+				}
+			} else {
+				ca, err := LoadCA(certPath, keyPath)
+				if err != nil {
+					t.Fatalf("LoadCA() error = %v", err)
+				}
+				if !bytes.Equal(ca.CertPEM, wantCert) {
+					t.Error("LoadCA() returned another certificate")
+				}
+				if key, _ := os.ReadFile(keyPath); !bytes.Equal(key, wantKey) {
+					t.Error("the stored key is not the expected one")
+				}
+				if info, err := os.Stat(keyPath); err == nil && info.Mode().Perm() != 0600 {
+					t.Errorf("key permissions = %v, want 0600", info.Mode().Perm())
+				}
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".") {
+					t.Errorf("temporary file %s left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// ParseCertificatePEM parses the first certificate of a PEM encoded chain.
+// Other blocks, such as a private key pasted along with the certificate, are
+// skipped.
+//
+// Parameters:
+//   - data: The PEM encoded certificate or chain.
+//
+// Returns:
+//   - *x509.Certificate: The first certificate.
+//   - error:             An error if there is no certificate or it cannot be parsed.
+func ParseCertificatePEM(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM encoded certificate found")
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		return cert, nil
+	}
+}
@@ -0,0 +1,168 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Self-signed certificate constants
+	selfSignedValidity    = 365 * 24 * time.Hour
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// EnsureSelfSigned makes sure that a usable self-signed certificate and key
+// exist at the given paths. A new pair is generated if either file is
+// missing, cannot be parsed, expires within 30 days, or does not cover all of
+// the given hosts.
+//
+// Parameters:
+//   - certPath: The path of the PEM encoded certificate.
+//   - keyPath:  The path of the PEM encoded private key.
+//   - hosts:    The DNS names and IP addresses the certificate must be valid for.
+//
+// Returns:
+//   - error: An error if the certificate could not be generated or written.
+func EnsureSelfSigned(certPath, keyPath string, hosts []string) error {
+	if selfSignedUsable(certPath, keyPath, hosts) {
+		slog.Debug("using existing self-signed certificate",
+			slog.String("path", certPath))
+		return nil
+	}
+
+	certPEM, keyPEM, err := GenerateSelfSigned(hosts, selfSignedValidity)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
+		return fmt.Errorf("create certificate directory: %w", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return fmt.Errorf("write private key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return fmt.Errorf("write certificate: %w", err)
+	}
+
+	slog.Info("generated self-signed certificate",
+		slog.String("path", certPath),
+		slog.Any("hosts", hosts))
+	return nil
+}
+
+// GenerateSelfSigned creates a new ECDSA P-256 key and a self-signed server
+// certificate for the given hosts. Hosts that parse as IP addresses become IP
+// SANs, all others DNS SANs.
+//
+// Parameters:
+//   - hosts:    The DNS names and IP addresses the certificate is valid for.
+//   - validity: How long the certificate is valid for.
+//
+// Returns:
+//   - []byte: The PEM encoded certificate.
+//   - []byte: The PEM encoded private key.
+//   - error:  An error if the key or certificate could not be created.
+func GenerateSelfSigned(hosts []string, validity time.Duration) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate private key: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Neba", Organization: []string{"Neba"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	addSANs(template, hosts)
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create certificate: %w", err)
+	}
+
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCertificate(der), keyPEM, nil
+}
+
+// selfSignedUsable reports whether the existing pair can be used as is.
+func selfSignedUsable(certPath, keyPath string, hosts []string) bool {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+	if time.Until(cert.NotAfter) < selfSignedRenewBefore {
+		return false
+	}
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// addSANs adds the given hosts to the certificate's subject alternative names.
+func addSANs(cert *x509.Certificate, hosts []string) {
+	for _, host := range hosts {
+		if host == "" {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			cert.IPAddresses = append(cert.IPAddresses, ip)
+		} else {
+			cert.DNSNames = append(cert.DNSNames, host)
+		}
+	}
+}
+
+// randomSerial returns a random 128-bit certificate serial number.
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+func encodeCertificate(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encodePrivateKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
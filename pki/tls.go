@@ -0,0 +1,29 @@
+package pki
+
+import (
+	"crypto/tls"
+)
+
+// ServerTLSConfig returns the TLS configuration used by the Neba web server.
+// It only allows TLS 1.2 and later, and restricts TLS 1.2 to forward-secret
+// AEAD cipher suites. TLS 1.3 suites are not configurable and always secure.
+//
+// Returns:
+//   - *tls.Config: The server TLS configuration, without certificates.
+func ServerTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+		},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	}
+}
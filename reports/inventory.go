@@ -0,0 +1,353 @@
+package reports
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/ui"
+)
+
+// Report formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatHTML = "html"
+)
+
+// Formats lists every report format.
+var Formats = []string{FormatCSV, FormatJSON, FormatHTML}
+
+// Warranty statuses
+const (
+	WarrantyActive   = "Active"
+	WarrantyExpiring = "Expiring"
+	WarrantyExpired  = "Expired"
+	WarrantyUnknown  = "Unknown"
+)
+
+// warrantyNotice is how long before the end of its warranty a device is
+// reported as expiring.
+const warrantyNotice = 90 * 24 * time.Hour
+
+// Column is a column of the inventory report.
+type Column struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	value func(device models.AxisDevice, now time.Time) string
+}
+
+// Columns lists every column of the inventory report, in order.
+var Columns = []Column{
+	{"serial_number", "Serial Number", func(d models.AxisDevice, _ time.Time) string { return d.SerialNumber }},
+	{"mac_address", "MAC Address", func(d models.AxisDevice, _ time.Time) string { return MACAddress(d.SerialNumber) }},
+	{"model", "Model", func(d models.AxisDevice, _ time.Time) string { return d.Model }},
+	{"firmware", "Firmware", func(d models.AxisDevice, _ time.Time) string { return d.OSVersion }},
+	{"ip_address", "IP Address", func(d models.AxisDevice, _ time.Time) string { return d.IPAddress }},
+	{"site", "Site", func(d models.AxisDevice, _ time.Time) string { return d.Site }},
+	{"tags", "Tags", func(d models.AxisDevice, _ time.Time) string { return strings.Join(d.Tags, ", ") }},
+	{"credential_profile", "Credential Profile", func(d models.AxisDevice, _ time.Time) string { return d.CredentialProfile }},
+	{"warranty_expires", "Warranty Expires", func(d models.AxisDevice, _ time.Time) string { return d.WarrantyExpires }},
+	{"warranty_status", "Warranty Status", func(d models.AxisDevice, now time.Time) string { return WarrantyStatus(d, now) }},
+}
+
+// DefaultColumns are the columns of the report if none are selected.
+var DefaultColumns = []string{"serial_number", "mac_address", "model", "firmware", "ip_address", "site", "warranty_status"}
+
+// GroupBy lists the columns that the report can be grouped by.
+var GroupBy = []string{"site", "model", "firmware"}
+
+// Options select what an inventory report contains.
+type Options struct {
+	Columns []string // Column names, in order; DefaultColumns if empty
+	GroupBy string   // Column name to group by, or empty for a single group
+}
+
+// Report is an inventory report.
+type Report struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Columns     []Column          `json:"columns"`
+	GroupBy     string            `json:"group_by,omitempty"`
+	Groups      []Group           `json:"groups"`
+	Firmware    []FirmwareVersion `json:"firmware_distribution"`
+	Warranty    map[string]int    `json:"warranty_summary"`
+	Total       int               `json:"total"`
+}
+
+// Group is a set of devices that share the value of the grouping column.
+type Group struct {
+	Name string     `json:"name"`
+	Rows [][]string `json:"rows"`
+}
+
+// FirmwareVersion is the number of devices running a firmware version.
+type FirmwareVersion struct {
+	Version string   `json:"version"`
+	Count   int      `json:"count"`
+	Percent float64  `json:"percent"`
+	Models  []string `json:"models"`
+}
+
+// NewInventoryReport builds an inventory report of the given devices.
+//
+// Parameters:
+//   - devices: The devices to report on.
+//   - opts: The columns and grouping of the report.
+//   - now: The time of the report, which decides the warranty status.
+//
+// Returns:
+//   - *Report: A pointer to the report.
+//   - error: An error if a column or the grouping is unknown.
+func NewInventoryReport(devices []models.AxisDevice, opts Options, now time.Time) (*Report, error) {
+	if len(opts.Columns) == 0 {
+		opts.Columns = DefaultColumns
+	}
+	report := &Report{
+		GeneratedAt: now,
+		GroupBy:     opts.GroupBy,
+		Groups:      []Group{},
+		Firmware:    []FirmwareVersion{},
+		Warranty:    make(map[string]int),
+		Total:       len(devices),
+	}
+	for _, name := range opts.Columns {
+		column, ok := lookupColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		report.Columns = append(report.Columns, column)
+	}
+
+	var group *Column
+	if opts.GroupBy != "" {
+		column, ok := lookupColumn(opts.GroupBy)
+		if !ok || !slices.Contains(GroupBy, opts.GroupBy) {
+			return nil, fmt.Errorf("cannot group by %q", opts.GroupBy)
+		}
+		group = &column
+	}
+
+	sorted := slices.Clone(devices)
+	slices.SortStableFunc(sorted, func(a, b models.AxisDevice) int {
+		return cmp.Or(cmp.Compare(a.Site, b.Site), cmp.Compare(a.Model, b.Model), cmp.Compare(a.SerialNumber, b.SerialNumber))
+	})
+
+	groups := make(map[string]*Group)
+	firmware := make(map[string]*FirmwareVersion)
+	for _, device := range sorted {
+		name := ""
+		if group != nil {
+			name = group.value(device, now)
+		}
+		g, ok := groups[name]
+		if !ok {
+			g = &Group{Name: name}
+			groups[name] = g
+		}
+		row := make([]string, len(report.Columns))
+		for i, column := range report.Columns {
+			row[i] = column.value(device, now)
+		}
+		g.Rows = append(g.Rows, row)
+
+		version := device.OSVersion
+		f, ok := firmware[version]
+		if !ok {
+			f = &FirmwareVersion{Version: version}
+			firmware[version] = f
+		}
+		f.Count++
+		if device.Model != "" && !slices.Contains(f.Models, device.Model) {
+			f.Models = append(f.Models, device.Model)
+		}
+
+		report.Warranty[WarrantyStatus(device, now)]++
+	}
+
+	// Devices without a value for the grouping column come last.
+	for _, g := range groups {
+		report.Groups = append(report.Groups, *g)
+	}
+	slices.SortFunc(report.Groups, func(a, b Group) int {
+		if (a.Name == "") != (b.Name == "") {
+			if a.Name == "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	for _, f := range firmware {
+		f.Percent = 100 * float64(f.Count) / float64(len(devices))
+		slices.Sort(f.Models)
+		report.Firmware = append(report.Firmware, *f)
+	}
+	slices.SortFunc(report.Firmware, func(a, b FirmwareVersion) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Version, b.Version))
+	})
+
+	return report, nil
+}
+
+// Write writes the report to w in the given format.
+//
+// Parameters:
+//   - w: The writer to write the report to.
+//   - format: FormatCSV, FormatJSON, or FormatHTML.
+//
+// Returns:
+//   - error: An error if the format is unknown or the report cannot be written.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatCSV:
+		return r.writeCSV(w)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case FormatHTML:
+		return r.writeHTML(w)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// ContentType returns the MIME type of the given format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSON:
+		return "application/json"
+	default:
+		return "text/html; charset=utf-8"
+	}
+}
+
+// writeCSV writes the devices as a single table. If the report is grouped,
+// the grouping column comes first.
+func (r *Report) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, 0, len(r.Columns)+1)
+	if r.GroupBy != "" {
+		header = append(header, r.GroupBy)
+	}
+	for _, column := range r.Columns {
+		header = append(header, column.Name)
+	}
+	writer.Write(header)
+
+	for _, group := range r.Groups {
+		for _, row := range group.Rows {
+			if r.GroupBy != "" {
+				row = append([]string{group.Name}, row...)
+			}
+			writer.Write(row)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeHTML writes the report as a self-contained page that prints well, so
+// that it can be saved as PDF from the browser.
+func (r *Report) writeHTML(w io.Writer) error {
+	tmpl, err := template.New("report.html").Funcs(template.FuncMap{
+		"label": func(name string) string {
+			column, _ := lookupColumn(name)
+			return column.Label
+		},
+	}).ParseFS(ui.FS, "report.html")
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, r)
+}
+
+// WarrantyStatuses returns the warranty summary in a fixed order, for display.
+func (r *Report) WarrantyStatuses() []string {
+	var statuses []string
+	for _, status := range []string{WarrantyActive, WarrantyExpiring, WarrantyExpired, WarrantyUnknown} {
+		if r.Warranty[status] > 0 {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// WarrantyStatus returns whether the device's warranty is active, expires
+// within 90 days, has expired, or is unknown.
+//
+// Parameters:
+//   - device: The device.
+//   - now: The time to compare the warranty expiry date to.
+//
+// Returns:
+//   - string: One of the Warranty* statuses.
+func WarrantyStatus(device models.AxisDevice, now time.Time) string {
+	expires, err := time.ParseInLocation(time.DateOnly, device.WarrantyExpires, now.Location())
+	if err != nil {
+		return WarrantyUnknown
+	}
+	end := expires.AddDate(0, 0, 1) // The warranty covers the whole last day.
+	switch {
+	case !now.Before(end):
+		return WarrantyExpired
+	case end.Sub(now) <= warrantyNotice:
+		return WarrantyExpiring
+	default:
+		return WarrantyActive
+	}
+}
+
+// MACAddress returns the MAC address of an Axis device, which its serial
+// number is derived from, or an empty string if the serial number is not a
+// MAC address.
+//
+// Parameters:
+//   - serial: The serial number of the device.
+//
+// Returns:
+//   - string: The MAC address, in colon-separated form.
+func MACAddress(serial string) string {
+	if len(serial) != 12 {
+		return ""
+	}
+	parts := make([]string, 0, 6)
+	for i := 0; i < 12; i += 2 {
+		part := strings.ToUpper(serial[i : i+2])
+		if strings.Trim(part, "0123456789ABCDEF") != "" {
+			return ""
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ":")
+}
+
+// ParseColumns splits a comma-separated list of column names.
+func ParseColumns(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func lookupColumn(name string) (Column, bool) {
+	i := slices.IndexFunc(Columns, func(c Column) bool { return c.Name == name })
+	if i < 0 {
+		return Column{}, false
+	}
+	return Columns[i], true
+}
@@ -0,0 +1,272 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/furkansuleymana/neba/database/models"
+)
+
+var testDevices = []models.AxisDevice{
+	{SerialNumber: "ACCC8E000003", Model: "P3265-LVE", OSVersion: "11.11.73", Site: "Warehouse", WarrantyExpires: "2027-06-30"},
+	{SerialNumber: "ACCC8E000001", Model: "M3086-V", OSVersion: "11.11.73", Site: "Office", WarrantyExpires: "2026-11-01"},
+	{SerialNumber: "ACCC8E000002", Model: "M3086-V", OSVersion: "10.12.200", Site: "Office", Tags: []string{"lobby", "entrance"}},
+	{SerialNumber: "ACCC8E000004", Model: "Q6135-LE", OSVersion: "11.11.73", WarrantyExpires: "2026-01-31"},
+}
+
+var testNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func TestNewInventoryReport(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		wantErr    bool
+		wantHeader []string
+		wantGroups map[string][]string // Serial numbers by group, in order
+		wantOrder  []string            // Group names, in order
+	}{
+		{
+			name:       "default columns in a single group",
+			wantHeader: DefaultColumns,
+			wantOrder:  []string{""},
+			wantGroups: map[string][]string{"": {"ACCC8E000004", "ACCC8E000001", "ACCC8E000002", "ACCC8E000003"}},
+		},
+		{
+			name:       "grouped by site, devices without a site last",
+			opts:       Options{Columns: []string{"serial_number"}, GroupBy: "site"},
+			wantHeader: []string{"serial_number"},
+			wantOrder:  []string{"Office", "Warehouse", ""},
+			wantGroups: map[string][]string{
+				"Office":    {"ACCC8E000001", "ACCC8E000002"},
+				"Warehouse": {"ACCC8E000003"},
+				"":          {"ACCC8E000004"},
+			},
+		},
+		{
+			name:       "grouped by firmware",
+			opts:       Options{Columns: []string{"serial_number", "model"}, GroupBy: "firmware"},
+			wantHeader: []string{"serial_number", "model"},
+			wantOrder:  []string{"10.12.200", "11.11.73"},
+			wantGroups: map[string][]string{
+				"10.12.200": {"ACCC8E000002"},
+				"11.11.73":  {"ACCC8E000004", "ACCC8E000001", "ACCC8E000003"},
+			},
+		},
+		{
+			name:    "unknown column",
+			opts:    Options{Columns: []string{"serial_number", "password"}},
+			wantErr: true,
+		},
+		{
+			name:    "grouped by a column that is not groupable",
+			opts:    Options{GroupBy: "serial_number"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := NewInventoryReport(testDevices, tt.opts, testNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewInventoryReport() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var header []string
+			for _, column := range report.Columns {
+				header = append(header, column.Name)
+			}
+			if !slices.Equal(header, tt.wantHeader) {
+				t.Errorf("columns = %v, want %v", header, tt.wantHeader)
+			}
+			var order []string
+			for _, group := range report.Groups {
+				order = append(order, group.Name)
+				var serials []string
+				for _, row := range group.Rows {
+					serials = append(serials, row[0])
+				}
+				if !slices.Equal(serials, tt.wantGroups[group.Name]) {
+					t.Errorf("group %q = %v, want %v", group.Name, serials, tt.wantGroups[group.Name])
+				}
+			}
+			if !slices.Equal(order, tt.wantOrder) {
+				t.Errorf("groups = %v, want %v", order, tt.wantOrder)
+			}
+			if report.Total != len(testDevices) {
+				t.Errorf("total = %d, want %d", report.Total, len(testDevices))
+			}
+		})
+	}
+}
+
+func TestFirmwareDistribution(t *testing.T) {
+	report, err := NewInventoryReport(testDevices, Options{}, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FirmwareVersion{
+		{Version: "11.11.73", Count: 3, Percent: 75, Models: []string{"M3086-V", "P3265-LVE", "Q6135-LE"}},
+		{Version: "10.12.200", Count: 1, Percent: 25, Models: []string{"M3086-V"}},
+	}
+	if len(report.Firmware) != len(want) {
+		t.Fatalf("firmware = %+v, want %+v", report.Firmware, want)
+	}
+	for i, version := range report.Firmware {
+		if version.Version != want[i].Version || version.Count != want[i].Count || version.Percent != want[i].Percent || !slices.Equal(version.Models, want[i].Models) {
+			t.Errorf("firmware[%d] = %+v, want %+v", i, version, want[i])
+		}
+	}
+	if got := report.WarrantyStatuses(); !slices.Equal(got, []string{WarrantyActive, WarrantyExpiring, WarrantyExpired, WarrantyUnknown}) {
+		t.Errorf("WarrantyStatuses() = %v", got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	devices := []models.AxisDevice{
+		{SerialNumber: "ACCC8E000002", Model: "M3086-V", Site: "Office", Tags: []string{"lobby", "entrance"}},
+		{SerialNumber: "ACCC8E000001", Model: "P3265-LVE", Site: "Lab, 2nd floor"},
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		format  string
+		want    string // Expected output, or a part of it for HTML
+		wantErr bool
+	}{
+		{
+			name:   "CSV",
+			opts:   Options{Columns: []string{"serial_number", "tags"}},
+			format: FormatCSV,
+			want:   "serial_number,tags\nACCC8E000001,\nACCC8E000002,\"lobby, entrance\"\n",
+		},
+		{
+			name:   "CSV grouped, with quoted values",
+			opts:   Options{Columns: []string{"serial_number", "mac_address"}, GroupBy: "site"},
+			format: FormatCSV,
+			want:   "site,serial_number,mac_address\n\"Lab, 2nd floor\",ACCC8E000001,AC:CC:8E:00:00:01\nOffice,ACCC8E000002,AC:CC:8E:00:00:02\n",
+		},
+		{
+			name:   "HTML",
+			opts:   Options{Columns: []string{"serial_number", "site"}},
+			format: FormatHTML,
+			want:   "Lab, 2nd floor",
+		},
+		{
+			name:    "unknown format",
+			format:  "xlsx",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := NewInventoryReport(devices, tt.opts, testNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			err = report.Write(&buf, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Write() error = %v, want error %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.wantErr:
+			case tt.format == FormatHTML:
+				if !strings.Contains(buf.String(), tt.want) {
+					t.Errorf("Write() does not contain %q:\n%s", tt.want, buf.String())
+				}
+			case buf.String() != tt.want:
+				t.Errorf("Write() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	report, err := NewInventoryReport(testDevices, Options{Columns: []string{"serial_number"}, GroupBy: "site"}, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := report.Write(&buf, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		GroupBy  string         `json:"group_by"`
+		Groups   []Group        `json:"groups"`
+		Warranty map[string]int `json:"warranty_summary"`
+		Total    int            `json:"total"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Write() is not valid JSON: %v", err)
+	}
+	if decoded.GroupBy != "site" || len(decoded.Groups) != 3 || decoded.Total != 4 {
+		t.Errorf("Write() = %s", buf.String())
+	}
+	if decoded.Warranty[WarrantyUnknown] != 1 {
+		t.Errorf("warranty summary = %v, want one unknown warranty", decoded.Warranty)
+	}
+}
+
+func TestWarrantyStatus(t *testing.T) {
+	tests := []struct {
+		expires string
+		want    string
+	}{
+		{"", WarrantyUnknown},
+		{"31.12.2027", WarrantyUnknown},
+		{"2027-10-19", WarrantyActive},
+		{"2027-01-17", WarrantyActive}, // 90.5 days left
+		{"2027-01-16", WarrantyExpiring},
+		{"2026-10-19", WarrantyExpiring}, // The last day is covered
+		{"2026-10-18", WarrantyExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expires, func(t *testing.T) {
+			if got := WarrantyStatus(models.AxisDevice{WarrantyExpires: tt.expires}, testNow); got != tt.want {
+				t.Errorf("WarrantyStatus(%q) = %s, want %s", tt.expires, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMACAddress(t *testing.T) {
+	tests := []struct {
+		serial string
+		want   string
+	}{
+		{"ACCC8E123456", "AC:CC:8E:12:34:56"},
+		{"accc8e12abcd", "AC:CC:8E:12:AB:CD"},
+		{"ACCC8E12345", ""},
+		{"ACCC8E12345G", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MACAddress(tt.serial); got != tt.want {
+			t.Errorf("MACAddress(%q) = %q, want %q", tt.serial, got, tt.want)
+		}
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"serial_number", []string{"serial_number"}},
+		{" model, ,firmware ,", []string{"model", "firmware"}},
+	}
+	for _, tt := range tests {
+		if got := ParseColumns(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseColumns(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/handlers"
+	"github.com/furkansuleymana/neba/pki"
+)
+
+const (
+	// Server timeouts
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 60 * time.Second
+	writeTimeout      = 5 * time.Minute // Server reports can take a while
+	idleTimeout       = 2 * time.Minute
+)
+
+// Server serves the Neba web UI on every configured address. With HTTPS
+// enabled, the HTTP listeners either redirect to HTTPS or serve the UI as
+// well, depending on the configuration.
+type Server struct {
+	servers []*http.Server
+	url     string
+}
+
+// New creates a server for the given configuration and handler. Nothing is
+// listened on until ListenAndServe is called.
+//
+// Parameters:
+//   - config:    The application configuration.
+//   - configDir: The directory of the configuration file, where a self-signed
+//     certificate is stored if needed.
+//   - handler:   The handler serving the web UI.
+//
+// Returns:
+//   - *Server: A pointer to the new server.
+//   - error:   An error if the configuration is invalid or HTTPS cannot be set up.
+func New(config configs.AppConfig, configDir string, handler http.Handler) (*Server, error) {
+	s := &Server{}
+
+	httpAddrs, err := ListenAddrs(config.Server.HTTP.Address, config.Server.HTTP.Addresses, config.Server.HTTP.Port)
+	if err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+
+	if !config.Server.HTTPS.Enabled {
+		for _, addr := range httpAddrs {
+			s.servers = append(s.servers, newHTTPServer(addr, handler, nil))
+		}
+		s.url = browserURL("http", httpAddrs[0])
+		return s, nil
+	}
+
+	httpsAddrs, err := ListenAddrs(config.Server.HTTPS.Address, config.Server.HTTPS.Addresses, config.Server.HTTPS.Port)
+	if err != nil {
+		return nil, fmt.Errorf("https: %w", err)
+	}
+
+	certFile, keyFile, err := tlsFiles(configDir, config)
+	if err != nil {
+		return nil, fmt.Errorf("https: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("https: load certificate: %w", err)
+	}
+	tlsConfig := pki.ServerTLSConfig()
+	tlsConfig.Certificates = []tls.Certificate{cert}
+
+	for _, addr := range httpsAddrs {
+		s.servers = append(s.servers, newHTTPServer(addr, handler, tlsConfig))
+	}
+
+	httpHandler := handler
+	if config.Server.HTTPS.RedirectHTTP {
+		httpHandler = handlers.RedirectHTTPS(config.Server.HTTPS.Port)
+	}
+	for _, addr := range httpAddrs {
+		s.servers = append(s.servers, newHTTPServer(addr, httpHandler, nil))
+	}
+
+	s.url = browserURL("https", httpsAddrs[0])
+	return s, nil
+}
+
+// URL returns the URL of the web UI on the primary address. Wildcard
+// addresses are replaced by localhost, so that the URL can be opened in a
+// browser on the same machine.
+func (s *Server) URL() string {
+	return s.url
+}
+
+// ListenAndServe opens every listener and serves requests until Shutdown is
+// called. All addresses are bound before any request is served, so that a
+// misconfigured address is reported right away. If a listener fails later
+// on, its error is returned while the others keep running; call Shutdown to
+// stop them.
+//
+// Returns:
+//   - error: An error if an address cannot be bound or a listener fails.
+func (s *Server) ListenAndServe() error {
+	listeners := make([]net.Listener, 0, len(s.servers))
+	for _, srv := range s.servers {
+		listener, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("listen on %s: %w", srv.Addr, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	errs := make(chan error, len(s.servers))
+	for i, srv := range s.servers {
+		go func(srv *http.Server, listener net.Listener) {
+			var err error
+			if srv.TLSConfig != nil {
+				slog.Info("listening", slog.String("address", "https://"+srv.Addr))
+				err = srv.ServeTLS(listener, "", "")
+			} else {
+				slog.Info("listening", slog.String("address", "http://"+srv.Addr))
+				err = srv.Serve(listener)
+			}
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			} else {
+				err = fmt.Errorf("serve %s: %w", srv.Addr, err)
+			}
+			errs <- err
+		}(srv, listeners[i])
+	}
+
+	for range s.servers {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown gracefully stops every listener. In-flight requests are allowed
+// to complete until the given context expires.
+//
+// Parameters:
+//   - ctx: The context that limits how long to wait for in-flight requests.
+//
+// Returns:
+//   - error: The first error reported by a listener, if any.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(s.servers))
+	for _, srv := range s.servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				errs <- fmt.Errorf("shut down %s: %w", srv.Addr, err)
+			}
+		}(srv)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// ListenAddrs combines the primary address, any additional addresses, and the
+// port into listen addresses. The port may be given as "8181" or ":8181", and
+// IPv6 addresses may be given with or without brackets.
+//
+// Parameters:
+//   - address:   The primary address; empty to listen on all interfaces.
+//   - addresses: Additional addresses.
+//   - port:      The port to listen on.
+//
+// Returns:
+//   - []string: The listen addresses, primary first.
+//   - error:    An error if the port is missing.
+func ListenAddrs(address string, addresses []string, port string) ([]string, error) {
+	port = strings.TrimPrefix(port, ":")
+	if port == "" {
+		return nil, fmt.Errorf("no port configured")
+	}
+
+	addrs := make([]string, 0, 1+len(addresses))
+	for _, host := range append([]string{address}, addresses...) {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		addrs = append(addrs, net.JoinHostPort(host, port))
+	}
+	return addrs, nil
+}
+
+// browserURL returns the URL under which the listen address can be opened in
+// a browser on the same machine.
+func browserURL(scheme, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return scheme + "://" + addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+func newHTTPServer(addr string, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
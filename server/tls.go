@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/furkansuleymana/neba/configs"
+	"github.com/furkansuleymana/neba/pki"
+)
+
+const (
+	// Self-signed certificate file names, relative to the config directory
+	selfSignedCertFile = "neba.crt"
+	selfSignedKeyFile  = "neba.key"
+)
+
+// tlsFiles returns the certificate and key files to serve HTTPS with. If the
+// configuration does not name any, a self-signed certificate stored next to
+// the configuration file is used, and generated or renewed as needed.
+func tlsFiles(configDir string, config configs.AppConfig) (string, string, error) {
+	https := config.Server.HTTPS
+	if https.CertFile != "" || https.KeyFile != "" {
+		if https.CertFile == "" || https.KeyFile == "" {
+			return "", "", fmt.Errorf("both cert_file and key_file must be set")
+		}
+		return https.CertFile, https.KeyFile, nil
+	}
+
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	if https.Address != "" && !slices.Contains(hosts, https.Address) {
+		hosts = append(hosts, https.Address)
+	}
+
+	certFile := filepath.Join(configDir, selfSignedCertFile)
+	keyFile := filepath.Join(configDir, selfSignedKeyFile)
+	if err := pki.EnsureSelfSigned(certFile, keyFile, hosts); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
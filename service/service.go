@@ -0,0 +1,331 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+const (
+	// Service constants
+	Name         = "neba"
+	Description  = "Neba, Axis device management"
+	launchdLabel = "com.github.furkansuleymana.neba"
+	systemdDir   = "/etc/systemd/system"
+
+	// systemdStateDir is the directory that StateDirectory= creates for a
+	// systemd dynamic user, the only place such a user can write to.
+	systemdStateDir = "/var/lib/" + Name
+
+	// Definition formats
+	FormatSystemd = "systemd"
+	FormatLaunchd = "launchd"
+	FormatWindows = "windows"
+)
+
+// Formats lists the supported service definition formats.
+var Formats = []string{FormatSystemd, FormatLaunchd, FormatWindows}
+
+// Definition describes how Neba is run as a service.
+type Definition struct {
+	Executable string // Absolute path of the Neba binary
+	DataDir    string // Directory holding the configuration and the database
+	User       string // Account to run as; empty for a systemd dynamic user, which requires the default data directory
+}
+
+// NewDefinition returns a definition for the running executable and the given
+// data directory. If dataDir is empty, DefaultDataDir is used.
+//
+// Parameters:
+//   - dataDir: The directory holding the configuration and the database.
+//   - user:    The account to run the service as.
+//
+// Returns:
+//   - Definition: The service definition.
+//   - error:      An error if the executable path cannot be determined.
+func NewDefinition(dataDir, user string) (Definition, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return Definition{}, fmt.Errorf("determine executable path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	if dataDir == "" {
+		dataDir = DefaultDataDir()
+	}
+	if dataDir, err = filepath.Abs(dataDir); err != nil {
+		return Definition{}, fmt.Errorf("resolve data directory: %w", err)
+	}
+
+	return Definition{Executable: exePath, DataDir: dataDir, User: user}, nil
+}
+
+// DefaultFormat returns the definition format native to the current OS.
+func DefaultFormat() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return FormatLaunchd
+	case "windows":
+		return FormatWindows
+	default:
+		return FormatSystemd
+	}
+}
+
+// DefaultDataDir returns the conventional data directory of a system service
+// on the current OS.
+func DefaultDataDir() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "/Library/Application Support/Neba"
+	case "windows":
+		return filepath.Join(os.Getenv("ProgramData"), "Neba")
+	default:
+		return "/var/lib/neba"
+	}
+}
+
+// Render returns the service definition in the given format: a systemd unit,
+// a launchd property list, or a WinSW service configuration for Windows.
+//
+// Parameters:
+//   - format: One of Formats.
+//
+// Returns:
+//   - []byte: The rendered definition.
+//   - error:  An error if the format is unknown, or if a systemd unit without a
+//     user would not be able to write to the data directory.
+func (d Definition) Render(format string) ([]byte, error) {
+	tmpl, ok := templates[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown service format %q", format)
+	}
+	if format == FormatSystemd && d.User == "" && filepath.Clean(d.DataDir) != systemdStateDir {
+		return nil, fmt.Errorf("a systemd dynamic user can only write to %s; pass --user to use %s", systemdStateDir, d.DataDir)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return nil, fmt.Errorf("render %s definition: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Install writes the systemd unit and enables it. It is only supported on
+// Linux with systemd; on other systems, Render the definition instead.
+//
+// Returns:
+//   - error: An error if the unit cannot be written or enabled.
+func (d Definition) Install() error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+
+	unit, err := d.Render(FormatSystemd)
+	if err != nil {
+		return err
+	}
+	if d.User != "" {
+		if err := os.MkdirAll(d.DataDir, 0750); err != nil {
+			return fmt.Errorf("create data directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(unitPath(), unit, 0644); err != nil {
+		return fmt.Errorf("write unit file: %w", err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", Name)
+}
+
+// Uninstall stops and disables the service and removes the systemd unit. The
+// data directory is left untouched.
+//
+// Returns:
+//   - error: An error if the unit cannot be disabled or removed.
+func Uninstall() error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+
+	// Stopping a service that is not running is fine.
+	_ = systemctl("stop", Name)
+	if err := systemctl("disable", Name); err != nil {
+		return err
+	}
+	if err := os.Remove(unitPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove unit file: %w", err)
+	}
+	return systemctl("daemon-reload")
+}
+
+// Start starts the installed service.
+func Start() error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+	return systemctl("start", Name)
+}
+
+// Stop stops the installed service.
+func Stop() error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+	return systemctl("stop", Name)
+}
+
+func unitPath() string {
+	return filepath.Join(systemdDir, Name+".service")
+}
+
+func requireSystemd() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("managing the service is only supported with systemd; use \"neba service print --format %s\" and install the definition manually", DefaultFormat())
+	}
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return fmt.Errorf("systemctl not found: %w", err)
+	}
+	return nil
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("systemctl %v: %w", args, err)
+	}
+	return nil
+}
+
+// funcs escapes values for the XML based definitions and the systemd unit.
+var funcs = template.FuncMap{
+	"xml": func(s string) (string, error) {
+		var buf bytes.Buffer
+		err := xml.EscapeText(&buf, []byte(s))
+		return buf.String(), err
+	},
+	"systemdArg":   systemdArg,
+	"systemdQuote": systemdQuote,
+	"systemdPath":  systemdPath,
+}
+
+// systemdSpecifiers escapes the specifiers of systemd unit settings, such
+// as %h, which systemd would otherwise expand in paths.
+var systemdSpecifiers = strings.NewReplacer("%", "%%")
+
+// systemdQuote quotes a value of a systemd setting that is split into words,
+// such as a path of ReadWritePaths=, so that a value with spaces stays one
+// word.
+func systemdQuote(s string) string {
+	quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+	return `"` + systemdSpecifiers.Replace(quoted) + `"`
+}
+
+// systemdArg quotes an argument of ExecStart=, which also expands variables.
+func systemdArg(s string) string {
+	return systemdQuote(strings.ReplaceAll(s, "$", "$$"))
+}
+
+// systemdPath escapes a path for a systemd setting that takes the rest of the
+// line as one path, such as WorkingDirectory=. Such settings do not remove
+// quotes, so spaces are left as they are.
+func systemdPath(s string) string {
+	return systemdSpecifiers.Replace(s)
+}
+
+var templates = map[string]*template.Template{
+	FormatSystemd: template.Must(template.New(FormatSystemd).Funcs(funcs).Parse(`[Unit]
+Description=` + Description + `
+Documentation=https://github.com/furkansuleymana/neba
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart={{systemdArg .Executable}} serve --headless --data-dir {{systemdArg .DataDir}}
+WorkingDirectory={{systemdPath .DataDir}}
+{{- if .User}}
+User={{.User}}
+{{- else}}
+DynamicUser=yes
+StateDirectory=` + Name + `
+{{- end}}
+Restart=on-failure
+RestartSec=5
+KillSignal=SIGTERM
+TimeoutStopSec=45
+NoNewPrivileges=yes
+ProtectSystem=strict
+ProtectHome=yes
+ReadWritePaths={{systemdQuote .DataDir}}
+PrivateTmp=yes
+
+[Install]
+WantedBy=multi-user.target
+`)),
+	FormatLaunchd: template.Must(template.New(FormatLaunchd).Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<!-- Save as /Library/LaunchDaemons/` + launchdLabel + `.plist, then run:
+     sudo launchctl bootstrap system /Library/LaunchDaemons/` + launchdLabel + `.plist -->
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>` + launchdLabel + `</string>
+  <key>ProgramArguments</key>
+  <array>
+    <string>{{xml .Executable}}</string>
+    <string>serve</string>
+    <string>--headless</string>
+    <string>--data-dir</string>
+    <string>{{xml .DataDir}}</string>
+  </array>
+  <key>WorkingDirectory</key>
+  <string>{{xml .DataDir}}</string>
+{{- if .User}}
+  <key>UserName</key>
+  <string>{{xml .User}}</string>
+{{- end}}
+  <key>RunAtLoad</key>
+  <true/>
+  <key>KeepAlive</key>
+  <true/>
+  <key>StandardOutPath</key>
+  <string>{{xml .DataDir}}/neba.log</string>
+  <key>StandardErrorPath</key>
+  <string>{{xml .DataDir}}/neba.log</string>
+</dict>
+</plist>
+`)),
+	FormatWindows: template.Must(template.New(FormatWindows).Funcs(funcs).Parse(`<!--
+  WinSW service definition (https://github.com/winsw/winsw).
+  Save as neba-service.xml next to WinSW-x64.exe renamed to neba-service.exe,
+  then run "neba-service.exe install" and "neba-service.exe start" as Administrator.
+-->
+<service>
+  <id>` + Name + `</id>
+  <name>Neba</name>
+  <description>` + Description + `</description>
+  <executable>{{xml .Executable}}</executable>
+  <arguments>serve --headless --data-dir "{{xml .DataDir}}"</arguments>
+  <workingdirectory>{{xml .DataDir}}</workingdirectory>
+  <startmode>Automatic</startmode>
+  <onfailure action="restart" delay="5 sec"/>
+  <stoptimeout>45 sec</stoptimeout>
+  <log mode="roll-by-size">
+    <logpath>{{xml .DataDir}}\logs</logpath>
+  </log>
+</service>
+`)),
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRenderSystemd(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition Definition
+		want       []string // Lines the unit must contain
+		wantErr    string
+	}{
+		{
+			name:       "dynamic user",
+			definition: Definition{Executable: "/usr/local/bin/neba", DataDir: "/var/lib/neba"},
+			want: []string{
+				`ExecStart="/usr/local/bin/neba" serve --headless --data-dir "/var/lib/neba"`,
+				"WorkingDirectory=/var/lib/neba",
+				"DynamicUser=yes",
+				`ReadWritePaths="/var/lib/neba"`,
+			},
+		},
+		{
+			name:       "data directory with spaces",
+			definition: Definition{Executable: "/opt/neba app/neba", DataDir: "/srv/neba data", User: "neba"},
+			want: []string{
+				`ExecStart="/opt/neba app/neba" serve --headless --data-dir "/srv/neba data"`,
+				"WorkingDirectory=/srv/neba data",
+				"User=neba",
+				`ReadWritePaths="/srv/neba data"`,
+			},
+		},
+		{
+			name:       "specifiers, variables and quotes",
+			definition: Definition{Executable: "/usr/bin/neba", DataDir: `/srv/100% "$HOME"`, User: "neba"},
+			want: []string{
+				`ExecStart="/usr/bin/neba" serve --headless --data-dir "/srv/100%% \"$$HOME\""`,
+				`WorkingDirectory=/srv/100%% "$HOME"`,
+				`ReadWritePaths="/srv/100%% \"$HOME\""`,
+			},
+		},
+		{
+			name:       "dynamic user outside the state directory",
+			definition: Definition{Executable: "/usr/bin/neba", DataDir: "/srv/neba"},
+			wantErr:    "pass --user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit, err := tt.definition.Render(FormatSystemd)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(string(unit), "\n")
+			for _, want := range tt.want {
+				if !slices.Contains(lines, want) {
+					t.Errorf("unit has no line %s:\n%s", want, unit)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderEscapesXML(t *testing.T) {
+	definition := Definition{Executable: `C:\Neba\neba.exe`, DataDir: `C:\Data & <Neba>`}
+	for _, format := range []string{FormatLaunchd, FormatWindows} {
+		rendered, err := definition.Render(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(rendered), `C:\Data &amp; &lt;Neba&gt;`) {
+			t.Errorf("%s definition does not escape the data directory:\n%s", format, rendered)
+		}
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if _, err := (Definition{}).Render("upstart"); err == nil {
+		t.Error("Render() of an unknown format succeeded")
+	}
+}
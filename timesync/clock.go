@@ -0,0 +1,129 @@
+// Package timesync compares the clocks of Axis devices to the clock of the
+// Neba host, and keeps the outcome for the web UI.
+package timesync
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/furkansuleymana/neba/database"
+	"github.com/furkansuleymana/neba/database/models"
+	"github.com/furkansuleymana/neba/network"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// Clock check constants
+	checksSetting = "clock.checks"
+	checkWorkers  = 16
+)
+
+// ClockCheck is the outcome of comparing the clock of a device to the clock
+// of the Neba host.
+type ClockCheck struct {
+	SerialNumber string        `json:"serial_number"`
+	CheckedAt    time.Time     `json:"checked_at"`
+	Drift        time.Duration `json:"drift"`    // How far the device clock is ahead; negative if behind
+	Exceeded     bool          `json:"exceeded"` // Whether the drift was above the maximum at the time
+	Error        string        `json:"error,omitempty"`
+}
+
+// Describe returns the drift in words, such as "12s behind".
+func (c ClockCheck) Describe() string {
+	switch {
+	case c.Error != "":
+		return "Unknown"
+	case c.Drift > 0:
+		return fmt.Sprintf("%s ahead", c.Drift)
+	case c.Drift < 0:
+		return fmt.Sprintf("%s behind", -c.Drift)
+	default:
+		return "In sync"
+	}
+}
+
+// CheckClocks compares the clock of every device to the clock of the Neba
+// host, and stores the outcome, so that drifting clocks are flagged in the
+// web UI until the next check.
+//
+// Parameters:
+//   - db:       A pointer to the bbolt.DB instance.
+//   - maxDrift: How far a device clock may be off before it is flagged.
+//
+// Returns:
+//   - []ClockCheck: The outcome per device, ordered by serial number.
+//   - error:        An error if the devices cannot be read or the outcome
+//     cannot be stored.
+func CheckClocks(db *bbolt.DB, maxDrift time.Duration) ([]ClockCheck, error) {
+	devices, err := database.Devices(db).List()
+	if err != nil {
+		return nil, fmt.Errorf("check clocks: %w", err)
+	}
+
+	checks := make([]ClockCheck, len(devices))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range min(checkWorkers, len(devices)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				checks[i] = CheckClock(devices[i], maxDrift)
+			}
+		}()
+	}
+	for i := range devices {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	slices.SortFunc(checks, func(a, b ClockCheck) int { return strings.Compare(a.SerialNumber, b.SerialNumber) })
+	stored := make(map[string]ClockCheck, len(checks))
+	for _, check := range checks {
+		stored[check.SerialNumber] = check
+	}
+	if err := database.PutSetting(db, checksSetting, stored); err != nil {
+		return nil, fmt.Errorf("store clock checks: %w", err)
+	}
+	return checks, nil
+}
+
+// CheckClock compares the clock of the device to the clock of the Neba host.
+//
+// Parameters:
+//   - device:   The device to check.
+//   - maxDrift: How far the device clock may be off before it is flagged.
+//
+// Returns:
+//   - ClockCheck: The outcome, with the error if the clock cannot be read.
+func CheckClock(device models.AxisDevice, maxDrift time.Duration) ClockCheck {
+	check := ClockCheck{SerialNumber: device.SerialNumber, CheckedAt: time.Now()}
+	drift, _, err := network.ClockDrift(network.NewClient(device.IPAddress, device.Username, device.Password))
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Drift = drift
+	check.Exceeded = drift.Abs() > maxDrift
+	return check
+}
+
+// StoredChecks returns the outcome of the last clock check by serial number.
+//
+// Parameters:
+//   - db: A pointer to the bbolt.DB instance.
+//
+// Returns:
+//   - map[string]ClockCheck: The outcome per device.
+//   - error:                 An error if the outcome cannot be read.
+func StoredChecks(db *bbolt.DB) (map[string]ClockCheck, error) {
+	checks := map[string]ClockCheck{}
+	if err := database.GetSetting(db, checksSetting, &checks); err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
@@ -1 +1,9 @@
 console.log("Hello from Neba!");
+
+// Attach the session's CSRF token to every state-changing htmx request.
+document.addEventListener("htmx:configRequest", (event) => {
+  const meta = document.querySelector('meta[name="csrf-token"]');
+  if (meta && event.detail.verb !== "get") {
+    event.detail.headers["X-CSRF-Token"] = meta.content;
+  }
+});